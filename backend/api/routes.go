@@ -1,21 +1,40 @@
 package api
 
 import (
+	"log/slog"
+
 	"github.com/gin-gonic/gin"
 	"taulen/backend/internal/config"
+	"taulen/backend/internal/database"
 	"taulen/backend/internal/handlers"
+	"taulen/backend/internal/metrics"
 	"taulen/backend/internal/middleware"
+	"taulen/backend/internal/notify"
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/repositories"
 	"taulen/backend/internal/services"
 )
 
-// SetupRoutes configures all API routes
-func SetupRoutes(cfg *config.Config) *gin.Engine {
+// SetupRoutes configures all API routes, with services backed by the given store,
+// notifying through the given notifier and logging through the given logger
+func SetupRoutes(cfg *config.Config, store repositories.Store, notifier notify.Notifier, logger *slog.Logger) *gin.Engine {
 	// Set Gin mode based on environment
 	if cfg.Server.Environment == "prod" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	router := gin.Default()
+	router := gin.New()
+
+	// Only believe X-Forwarded-For from the configured proxies, so clients cannot
+	// choose the IP address that throttling, API keys and sessions see. The
+	// entries were validated with the configuration.
+	_ = router.SetTrustedProxies(cfg.Server.TrustedProxies)
+
+	// Tag every request with an ID and log it once it completes
+	router.Use(middleware.RequestID(), middleware.RequestLogger(logger), middleware.Metrics(), gin.Recovery())
+
+	// Record the device of sessions issued by the request
+	router.Use(middleware.ClientContext())
 
 	// Apply CORS middleware
 	router.Use(middleware.CORSMiddleware(&cfg.CORS))
@@ -28,59 +47,149 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 		})
 	})
 
+	// Liveness and readiness probes; the databases are only required by the postgres driver
+	healthHandler := handlers.NewHealthHandler(database.DB, database.MongoClient,
+		cfg.Database.Driver == config.DriverPostgres, cfg.Server.ReadinessTimeout, logger)
+	router.GET("/livez", healthHandler.Live)
+	router.GET("/readyz", healthHandler.Ready)
+
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	// Initialize services
-	authService := services.NewAuthService(cfg)
+	authService := services.NewAuthService(cfg, store, notifier, logger)
 	authHandler := handlers.NewAuthHandler(authService)
+	apiKeyService := services.NewAPIKeyService(cfg, store, logger)
+
+	// Public keys for services validating our tokens
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
 
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
+		// Each flow is limited per client IP on its own: failed password, code
+		// and token checks; requests for codes and emailed links; and SSO logins
+		loginThrottle := middleware.ThrottleFailures(cfg.Login.IPMaxAttempts, cfg.Login.IPWindow)
+		codeThrottle := middleware.Throttle(cfg.Login.IPMaxCodeSends, cfg.Login.IPCodeSendWindow)
+		ssoThrottle := middleware.Throttle(cfg.Login.IPMaxSSOLogins, cfg.Login.IPSSOWindow)
+
 		// Auth routes (public)
 		auth := v1.Group("/auth")
 		{
 			auth.POST("/register", authHandler.Register)
-			auth.POST("/register/send-verification", authHandler.SendVerificationCodeForRegister)
-			auth.POST("/register/verify", authHandler.VerifyAndRegister)
-			auth.POST("/login/send-verification", authHandler.SendLoginVerificationCode)
-			auth.POST("/login", authHandler.Login)
+			auth.POST("/register/send-verification", codeThrottle, authHandler.SendVerificationCodeForRegister)
+			auth.POST("/register/verify", loginThrottle, authHandler.VerifyAndRegister)
+			auth.POST("/login/send-verification", codeThrottle, authHandler.SendLoginVerificationCode)
+			auth.POST("/login", loginThrottle, authHandler.Login)
+			auth.POST("/login/mfa", loginThrottle, authHandler.LoginMFA)
+			auth.POST("/login/mfa/setup", loginThrottle, authHandler.LoginMFASetup)
+			auth.POST("/sso/authorize", ssoThrottle, authHandler.StartSSOLogin)
+			auth.POST("/sso/callback", ssoThrottle, authHandler.CompleteSSOLogin)
+			auth.POST("/password-reset/request", codeThrottle, authHandler.RequestPasswordReset)
+			auth.POST("/password-reset/confirm", loginThrottle, authHandler.ConfirmPasswordReset)
+			auth.POST("/email/verify", loginThrottle, authHandler.ConfirmEmail)
+			// Delegated tokens cannot manage the borrower's own account
+			auth.POST("/email/resend", middleware.AuthMiddleware(authService.GetJWTManager(), nil), middleware.RejectDelegation(), codeThrottle, authHandler.ResendEmailVerification)
 			auth.POST("/refresh", authHandler.Refresh)
-			auth.POST("/logout", middleware.AuthMiddleware(authService.GetJWTManager()), authHandler.Logout)
-			auth.GET("/me", middleware.AuthMiddleware(authService.GetJWTManager()), authHandler.GetMe)
+			auth.POST("/logout", middleware.AuthMiddleware(authService.GetJWTManager(), nil), middleware.RejectDelegation(), authHandler.Logout)
+			auth.GET("/me", middleware.AuthMiddleware(authService.GetJWTManager(), nil), authHandler.GetMe)
+
+			// TOTP enrollment and management for the caller's own account;
+			// failed code checks count towards the login throttle
+			mfa := auth.Group("/mfa", middleware.AuthMiddleware(authService.GetJWTManager(), nil), middleware.RejectDelegation())
+			mfa.GET("", authHandler.GetMFAStatus)
+			mfa.POST("/setup", authHandler.SetupMFA)
+			mfa.POST("/enable", loginThrottle, authHandler.EnableMFA)
+			mfa.POST("/disable", loginThrottle, authHandler.DisableMFA)
+			mfa.POST("/backup-codes", loginThrottle, authHandler.RegenerateBackupCodes)
+
+			// Opting the caller's own account in to or out of login codes while
+			// the 2FA mode is optional; failed code checks count towards the login throttle
+			twoFactor := auth.Group("/2fa", middleware.AuthMiddleware(authService.GetJWTManager(), nil), middleware.RejectDelegation())
+			twoFactor.GET("", authHandler.GetTwoFactorStatus)
+			twoFactor.POST("/send-code", codeThrottle, authHandler.SendTwoFactorCode)
+			twoFactor.POST("/enable", loginThrottle, authHandler.EnableTwoFactor)
+			twoFactor.POST("/disable", loginThrottle, authHandler.DisableTwoFactor)
+
+			// Signed-in devices of the caller's own account
+			sessions := auth.Group("/sessions", middleware.AuthMiddleware(authService.GetJWTManager(), nil), middleware.RejectDelegation())
+			sessions.GET("", authHandler.ListSessions)
+			sessions.POST("/revoke-others", authHandler.RevokeOtherSessions)
+			sessions.DELETE("/:id", authHandler.RevokeSession)
 		}
 
 		// Protected routes (require authentication)
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(authService.GetJWTManager()))
+		// API keys are accepted alongside access tokens; their scopes decide what they may do
+		protected.Use(middleware.AuthMiddleware(authService.GetJWTManager(), apiKeyService))
 		{
 			// Admin routes
 			adminHandler := handlers.NewAdminHandler(authService)
 			admin := protected.Group("/admin")
+			admin.Use(middleware.RequirePermission(rbac.PermEmployeesManage))
 			{
 				admin.POST("/employees", adminHandler.CreateEmployee)
+				admin.GET("/employees", adminHandler.ListEmployees)
+				admin.GET("/employees/:id", adminHandler.GetEmployee)
+				admin.PATCH("/employees/:id", adminHandler.UpdateEmployee)
+				admin.PUT("/employees/:id/nmls", adminHandler.SetEmployeeNMLS)
+				admin.POST("/employees/:id/deactivate", adminHandler.DeactivateEmployee)
+				admin.POST("/employees/:id/reactivate", adminHandler.ReactivateEmployee)
+				admin.POST("/employees/:id/password-reset", adminHandler.ForceEmployeePasswordReset)
+				admin.POST("/accounts/unlock", adminHandler.UnlockAccount)
+
+				apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+				admin.POST("/api-keys", apiKeyHandler.CreateAPIKey)
+				admin.GET("/api-keys", apiKeyHandler.ListAPIKeys)
+				admin.GET("/api-keys/:id", apiKeyHandler.GetAPIKey)
+				admin.POST("/api-keys/:id/revoke", apiKeyHandler.RevokeAPIKey)
+				admin.POST("/api-keys/:id/rotate", apiKeyHandler.RotateAPIKey)
 			}
 
-		// URLA routes
-		urlaService := services.NewURLAService(cfg)
-		urlaHandler := handlers.NewURLAHandler(urlaService)
-		
-		urla := protected.Group("/urla")
-		{
-			urla.POST("/applications", urlaHandler.CreateApplication)
-			urla.GET("/applications", urlaHandler.GetMyApplications)
-			urla.GET("/applications/:id", urlaHandler.GetApplication)
-			urla.PUT("/applications/:id/status", urlaHandler.UpdateApplicationStatus)
-			urla.POST("/applications/:id/save", urlaHandler.SaveApplication)
-			urla.GET("/applications/:id/progress", urlaHandler.GetApplicationProgress)
-			urla.PATCH("/applications/:id/progress/section", urlaHandler.UpdateApplicationProgressSection)
-			urla.PATCH("/applications/:id/progress/notes", urlaHandler.UpdateApplicationProgressNotes)
-		}
+			// URLA routes
+			urlaService := services.NewURLAService(cfg, store, notifier, logger)
+			urlaHandler := handlers.NewURLAHandler(urlaService, logger)
 
-		// Public URLA routes (no auth required)
-		urlaPublic := v1.Group("/urla")
-		{
-			urlaPublic.POST("/pre-application/send-verification", urlaHandler.SendVerificationCode)
-		urlaPublic.POST("/pre-application/verify-and-create", urlaHandler.VerifyAndCreateBorrower)
-		}
+			urla := protected.Group("/urla")
+			urla.Use(middleware.DealLogContext("id"))
+			{
+				canCreate := middleware.RequirePermission(rbac.PermApplicationsCreate)
+				canRead := middleware.RequirePermission(rbac.PermApplicationsRead)
+				canWrite := middleware.RequirePermission(rbac.PermApplicationsWrite)
+
+				// Only the deal's borrowers, assigned employees, admins and API keys may use :id routes
+				dealAccess := middleware.RequireDealAccess(services.NewDealAccessService(store), "id")
+
+				// Delegated tokens are limited to the :id routes of their application
+				notDelegated := middleware.RejectDelegation()
+
+				urla.POST("/applications", canCreate, notDelegated, urlaHandler.CreateApplication)
+				urla.GET("/applications", canRead, notDelegated, urlaHandler.GetMyApplications)
+				urla.GET("/applications/export", middleware.RequirePermission(rbac.PermApplicationsExport), urlaHandler.ExportApplications)
+				urla.GET("/applications/:id", canRead, dealAccess, urlaHandler.GetApplication)
+				// Status changes are checked per target status by the handler
+				urla.PUT("/applications/:id/status", dealAccess, urlaHandler.UpdateApplicationStatus)
+				urla.POST("/applications/:id/save", canWrite, dealAccess, urlaHandler.SaveApplication)
+				urla.GET("/applications/:id/progress", canRead, dealAccess, urlaHandler.GetApplicationProgress)
+				urla.PATCH("/applications/:id/progress/section", canWrite, dealAccess, urlaHandler.UpdateApplicationProgressSection)
+				urla.PATCH("/applications/:id/progress/notes", canWrite, dealAccess, urlaHandler.UpdateApplicationProgressNotes)
+				urla.GET("/applications/:id/edits", canRead, dealAccess, urlaHandler.GetApplicationEdits)
+				// Admins assign employees to any application, loan officers to the ones they are on
+				canAssign := middleware.RequirePermission(rbac.PermApplicationsAssign)
+				urla.GET("/applications/:id/assignees", canRead, dealAccess, urlaHandler.GetApplicationAssignees)
+				urla.POST("/applications/:id/assignees", canAssign, notDelegated, dealAccess, urlaHandler.AssignApplicationEmployee)
+				urla.DELETE("/applications/:id/assignees/:userId", canAssign, notDelegated, dealAccess, urlaHandler.UnassignApplicationEmployee)
+				// Assigned employees act on behalf of a borrower with a delegated token
+				urla.POST("/applications/:id/delegate", middleware.RequirePermission(rbac.PermApplicationsDelegate), notDelegated, dealAccess, authHandler.StartDelegation)
+			}
+
+			// Public URLA routes (no auth required); the pre-application shares the
+			// per-IP code send and login throttles
+			urlaPublic := v1.Group("/urla")
+			{
+				urlaPublic.POST("/pre-application/send-verification", codeThrottle, urlaHandler.SendVerificationCode)
+				urlaPublic.POST("/pre-application/verify-and-create", loginThrottle, urlaHandler.VerifyAndCreateBorrower)
+			}
 		}
 	}
 
@@ -0,0 +1,665 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/notify"
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/repositories"
+	"taulen/backend/internal/repositories/memory"
+	"taulen/backend/internal/utils"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestRouter creates the API router over an empty in-memory store, which
+// it returns, discarding notifications; env sets TAULEN_ variables before the configuration is loaded
+func newTestRouter(t *testing.T, env map[string]string) (*gin.Engine, *memory.Store) {
+	t.Helper()
+	t.Setenv("TAULEN_JWT_SECRET", "test-secret")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := memory.NewStore()
+	return SetupRoutes(cfg, store, notify.NewConsoleSink(io.Discard), logger), store
+}
+
+// do sends a JSON request, authenticated when token is set, and decodes the
+// JSON response into out when out is not nil
+func do(t *testing.T, router http.Handler, method, path, token string, body, out any) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: invalid response %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+type tokens struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// loginBorrower registers a borrower and returns an access token for them
+func loginBorrower(t *testing.T, router http.Handler, email string) string {
+	t.Helper()
+	code := do(t, router, http.MethodPost, "/api/v1/auth/register", "", map[string]string{
+		"email": email, "password": "correct horse", "firstName": "Jane", "lastName": "Doe",
+	}, nil)
+	if code != http.StatusCreated {
+		t.Fatalf("register %s: status %d", email, code)
+	}
+	var login tokens
+	if code := do(t, router, http.MethodPost, "/api/v1/auth/login", "", map[string]string{"email": email, "password": "correct horse"}, &login); code != http.StatusOK {
+		t.Fatalf("login %s: status %d", email, code)
+	}
+	return login.AccessToken
+}
+
+// loginEmployee creates an employee with the given role, e.g. "loan_officer",
+// and returns an access token for them
+func loginEmployee(t *testing.T, router http.Handler, store *memory.Store, email, role string) string {
+	t.Helper()
+	hash, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Users().Create(email, hash, "Lee", "Officer", repositories.EmployeeRole(role)); err != nil {
+		t.Fatal(err)
+	}
+	var login tokens
+	if code := do(t, router, http.MethodPost, "/api/v1/auth/login", "", map[string]string{"email": email, "password": "correct horse"}, &login); code != http.StatusOK {
+		t.Fatalf("login %s: status %d", email, code)
+	}
+	return login.AccessToken
+}
+
+// createAPIKey creates an API key with the given scopes as the admin and returns it
+func createAPIKey(t *testing.T, router http.Handler, adminToken string, scopes ...string) (id, key string) {
+	t.Helper()
+	var created struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	code := do(t, router, http.MethodPost, "/api/v1/admin/api-keys", adminToken, map[string]any{
+		"name": "los-sync", "scopes": scopes,
+	}, &created)
+	if code != http.StatusCreated || created.Key == "" {
+		t.Fatalf("create API key: status %d", code)
+	}
+	return created.ID, created.Key
+}
+
+func TestBorrowerApplicationFlow(t *testing.T) {
+	router, _ := newTestRouter(t, nil)
+
+	credentials := map[string]string{"email": "jane@example.com", "password": "correct horse"}
+	code := do(t, router, http.MethodPost, "/api/v1/auth/register", "", map[string]string{
+		"email": "jane@example.com", "password": "correct horse", "firstName": "Jane", "lastName": "Doe",
+	}, nil)
+	if code != http.StatusCreated {
+		t.Fatalf("register: status %d", code)
+	}
+
+	var login tokens
+	if code := do(t, router, http.MethodPost, "/api/v1/auth/login", "", credentials, &login); code != http.StatusOK {
+		t.Fatalf("login: status %d", code)
+	}
+
+	var refreshed tokens
+	code = do(t, router, http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refreshToken": login.RefreshToken}, &refreshed)
+	if code != http.StatusOK {
+		t.Fatalf("refresh: status %d", code)
+	}
+	// A refresh token is not accepted as an access token
+	if code := do(t, router, http.MethodGet, "/api/v1/urla/applications", refreshed.RefreshToken, nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("refresh token as access token: status %d, want 401", code)
+	}
+
+	var app struct {
+		ID string `json:"id"`
+	}
+	code = do(t, router, http.MethodPost, "/api/v1/urla/applications", refreshed.AccessToken, map[string]any{
+		"loanType": "Conventional", "loanPurpose": "Purchase", "loanAmount": 350000,
+	}, &app)
+	if code != http.StatusCreated || app.ID == "" {
+		t.Fatalf("create application: status %d, id %q", code, app.ID)
+	}
+
+	code = do(t, router, http.MethodPost, "/api/v1/urla/applications/"+app.ID+"/save", refreshed.AccessToken, map[string]any{
+		"borrower":     map[string]any{"firstName": "Janet"},
+		"nextFormStep": "borrower-info-2",
+	}, nil)
+	if code != http.StatusOK {
+		t.Fatalf("save application: status %d", code)
+	}
+
+	var saved struct {
+		CurrentFormStep string `json:"currentFormStep"`
+		Borrower        struct {
+			FirstName string `json:"firstName"`
+		} `json:"borrower"`
+	}
+	if code := do(t, router, http.MethodGet, "/api/v1/urla/applications/"+app.ID, refreshed.AccessToken, nil, &saved); code != http.StatusOK {
+		t.Fatalf("get application: status %d", code)
+	}
+	if saved.Borrower.FirstName != "Janet" || saved.CurrentFormStep != "borrower-info-2" {
+		t.Fatalf("saved application = %+v", saved)
+	}
+}
+
+func TestInvalidApplicationDataIsRejected(t *testing.T) {
+	router, _ := newTestRouter(t, nil)
+	token := loginBorrower(t, router, "jane@example.com")
+	var app struct {
+		ID string `json:"id"`
+	}
+	if code := do(t, router, http.MethodPost, "/api/v1/urla/applications", token, map[string]any{
+		"loanType": "Conventional", "loanPurpose": "Purchase", "loanAmount": 350000,
+	}, &app); code != http.StatusCreated {
+		t.Fatalf("create application: status %d", code)
+	}
+
+	var resp struct {
+		Error string `json:"error"`
+	}
+	code := do(t, router, http.MethodPost, "/api/v1/urla/applications/"+app.ID+"/save", token, map[string]any{
+		"coBorrower": map[string]any{"lastName": "Doe", "phone": "5555550100"},
+	}, &resp)
+	if code != http.StatusBadRequest || resp.Error != "co-borrower first name is required when creating a new co-borrower" {
+		t.Fatalf("save without a co-borrower name: status %d, %+v", code, resp)
+	}
+}
+
+func TestLogoutEndsTheLogin(t *testing.T) {
+	router, _ := newTestRouter(t, nil)
+	do(t, router, http.MethodPost, "/api/v1/auth/register", "", map[string]string{
+		"email": "jane@example.com", "password": "correct horse", "firstName": "Jane", "lastName": "Doe",
+	}, nil)
+	credentials := map[string]string{"email": "jane@example.com", "password": "correct horse"}
+
+	var first, second, refreshed tokens
+	do(t, router, http.MethodPost, "/api/v1/auth/login", "", credentials, &first)
+	do(t, router, http.MethodPost, "/api/v1/auth/login", "", credentials, &second)
+	if code := do(t, router, http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refreshToken": first.RefreshToken}, &refreshed); code != http.StatusOK {
+		t.Fatalf("refresh: status %d", code)
+	}
+
+	// Logging out with the rotated access token ends the whole login
+	if code := do(t, router, http.MethodPost, "/api/v1/auth/logout", refreshed.AccessToken, nil, nil); code != http.StatusOK {
+		t.Fatalf("logout: status %d", code)
+	}
+	if code := do(t, router, http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refreshToken": refreshed.RefreshToken}, nil); code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout: status %d, want 401", code)
+	}
+	// Other logins of the same user are unaffected
+	if code := do(t, router, http.MethodPost, "/api/v1/auth/refresh", "", map[string]string{"refreshToken": second.RefreshToken}, nil); code != http.StatusOK {
+		t.Errorf("refresh of another login: status %d, want 200", code)
+	}
+}
+
+// login attempts a login from a connection at remoteAddr claiming to forward for forwardedFor
+func login(router http.Handler, remoteAddr, forwardedFor string) int {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login",
+		bytes.NewReader([]byte(`{"email":"nobody@example.com","password":"wrong password"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", forwardedFor)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestLoginThrottleIgnoresSpoofedForwardedFor(t *testing.T) {
+	router, _ := newTestRouter(t, map[string]string{"TAULEN_LOGIN_IP_MAX_ATTEMPTS": "3"})
+
+	for i := 0; i < 3; i++ {
+		if code := login(router, "198.51.100.7:4000", fmt.Sprintf("203.0.113.%d", i)); code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status %d, want 401", i, code)
+		}
+	}
+	if code := login(router, "198.51.100.7:4000", "203.0.113.99"); code != http.StatusTooManyRequests {
+		t.Fatalf("attempt with a new X-Forwarded-For: status %d, want 429", code)
+	}
+}
+
+func TestLoginThrottleTrustsConfiguredProxies(t *testing.T) {
+	router, _ := newTestRouter(t, map[string]string{
+		"TAULEN_LOGIN_IP_MAX_ATTEMPTS":  "3",
+		"TAULEN_SERVER_TRUSTED_PROXIES": "10.0.0.0/8",
+	})
+
+	// Clients behind the proxy are throttled separately
+	for i := 0; i < 5; i++ {
+		if code := login(router, "10.1.2.3:4000", fmt.Sprintf("203.0.113.%d", i)); code != http.StatusUnauthorized {
+			t.Fatalf("client %d behind the proxy: status %d, want 401", i, code)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		login(router, "10.1.2.3:4000", "203.0.113.50")
+	}
+	if code := login(router, "10.1.2.3:4000", "203.0.113.50"); code != http.StatusTooManyRequests {
+		t.Fatalf("client over the limit behind the proxy: status %d, want 429", code)
+	}
+}
+
+func TestThrottlesArePerFlow(t *testing.T) {
+	router, _ := newTestRouter(t, map[string]string{
+		"TAULEN_LOGIN_IP_MAX_ATTEMPTS":   "2",
+		"TAULEN_LOGIN_IP_MAX_CODE_SENDS": "2",
+	})
+	loginBorrower(t, router, "jane@example.com")
+	credentials := map[string]string{"email": "jane@example.com", "password": "correct horse"}
+
+	// Successful logins do not count towards the login limit
+	for i := 0; i < 3; i++ {
+		if code := do(t, router, http.MethodPost, "/api/v1/auth/login", "", credentials, nil); code != http.StatusOK {
+			t.Fatalf("login %d: status %d", i, code)
+		}
+	}
+
+	// Requests for codes and links have a limit of their own
+	reset := map[string]string{"email": "jane@example.com"}
+	for i := 0; i < 2; i++ {
+		if code := do(t, router, http.MethodPost, "/api/v1/auth/password-reset/request", "", reset, nil); code != http.StatusAccepted {
+			t.Fatalf("reset request %d: status %d", i, code)
+		}
+	}
+	if code := do(t, router, http.MethodPost, "/api/v1/auth/password-reset/request", "", reset, nil); code != http.StatusTooManyRequests {
+		t.Fatalf("reset request over the limit: status %d, want 429", code)
+	}
+
+	// Failed logins do count, and then stop even the right password
+	wrong := map[string]string{"email": "jane@example.com", "password": "wrong password"}
+	for i := 0; i < 2; i++ {
+		if code := do(t, router, http.MethodPost, "/api/v1/auth/login", "", wrong, nil); code != http.StatusUnauthorized {
+			t.Fatalf("failed login %d: status %d", i, code)
+		}
+	}
+	if code := do(t, router, http.MethodPost, "/api/v1/auth/login", "", credentials, nil); code != http.StatusTooManyRequests {
+		t.Fatalf("login over the limit: status %d, want 429", code)
+	}
+}
+
+func TestPreApplicationThrottles(t *testing.T) {
+	router, _ := newTestRouter(t, map[string]string{
+		"TAULEN_TWO_FACTOR_MODE":         config.TwoFactorRequired,
+		"TAULEN_LOGIN_IP_MAX_ATTEMPTS":   "1",
+		"TAULEN_LOGIN_IP_MAX_CODE_SENDS": "1",
+	})
+
+	send := func(phone string) int {
+		return do(t, router, http.MethodPost, "/api/v1/urla/pre-application/send-verification", "", map[string]string{
+			"email": "jane@example.com", "phone": phone, "verificationMethod": "sms",
+		}, nil)
+	}
+	if code := send("+15555550100"); code != http.StatusOK {
+		t.Fatalf("send: status %d", code)
+	}
+	if code := send("+15555550101"); code != http.StatusTooManyRequests {
+		t.Fatalf("send over the limit: status %d, want 429", code)
+	}
+
+	create := func() int {
+		return do(t, router, http.MethodPost, "/api/v1/urla/pre-application/verify-and-create", "", map[string]any{
+			"email": "jane@example.com", "firstName": "Jane", "lastName": "Doe", "phone": "+15555550100",
+			"password": "correct horse", "loanPurpose": "Purchase",
+			"verificationMethod": "sms", "verificationCode": "000000",
+		}, nil)
+	}
+	if code := create(); code != http.StatusBadRequest {
+		t.Fatalf("wrong code: status %d, want 400", code)
+	}
+	if code := create(); code != http.StatusTooManyRequests {
+		t.Fatalf("after a wrong code: status %d, want 429", code)
+	}
+}
+
+// scrape reads /metrics and returns every sample by its name and labels
+func scrape(t *testing.T, router http.Handler) map[string]float64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics: status %d", w.Code)
+	}
+	samples := make(map[string]float64)
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("sample %q: %v", line, err)
+		}
+		samples[line[:i]] = value
+	}
+	return samples
+}
+
+func TestMetrics(t *testing.T) {
+	// Pre-application codes are only sent with 2FA on
+	router, _ := newTestRouter(t, map[string]string{"TAULEN_TWO_FACTOR_MODE": config.TwoFactorOptional})
+	before := scrape(t, router)
+
+	do(t, router, http.MethodPost, "/api/v1/auth/register", "", map[string]string{
+		"email": "jane@example.com", "password": "correct horse", "firstName": "Jane", "lastName": "Doe",
+	}, nil)
+	var login tokens
+	do(t, router, http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+		"email": "jane@example.com", "password": "correct horse",
+	}, &login)
+	var app struct {
+		ID string `json:"id"`
+	}
+	do(t, router, http.MethodPost, "/api/v1/urla/applications", login.AccessToken, map[string]any{
+		"loanType": "Conventional", "loanPurpose": "Purchase", "loanAmount": 350000,
+	}, &app)
+	do(t, router, http.MethodPost, "/api/v1/urla/applications/"+app.ID+"/save", login.AccessToken, map[string]any{
+		"borrower":          map[string]any{"firstName": "Janet"},
+		"completedSections": []string{"Section1a_PersonalInfo"},
+	}, nil)
+	do(t, router, http.MethodGet, "/api/v1/urla/applications/"+app.ID, login.AccessToken, nil, nil)
+	do(t, router, http.MethodGet, "/api/v1/urla/applications/"+app.ID, "", nil, nil)
+	do(t, router, http.MethodGet, "/no/such/path/"+app.ID, "", nil, nil)
+	// The code is recorded by the console sink the router sends notifications to
+	do(t, router, http.MethodPost, "/api/v1/urla/pre-application/send-verification", "", map[string]string{
+		"email": "lee@example.com", "phone": "+15555550100", "verificationMethod": "sms",
+	}, nil)
+
+	after := scrape(t, router)
+	for sample, want := range map[string]float64{
+		// Requests are labeled by route pattern, never by the raw path
+		`taulen_http_request_duration_seconds_count{method="GET",route="/api/v1/urla/applications/:id",status="200"}`: 1,
+		`taulen_http_request_duration_seconds_count{method="GET",route="/api/v1/urla/applications/:id",status="401"}`: 1,
+		`taulen_http_requests_total{method="POST",route="/api/v1/urla/applications/:id/save",status="200"}`:           1,
+		`taulen_http_requests_total{method="GET",route="unmatched",status="404"}`:                                     1,
+		`taulen_notifications_total{channel="sms",outcome="sent"}`:                                                    1,
+		`taulen_applications_created_total{source="borrower"}`:                                                        1,
+		`taulen_sections_completed_total{section="Section1a_PersonalInfo"}`:                                           1,
+	} {
+		if got := after[sample] - before[sample]; got != want {
+			t.Errorf("%s increased by %v, want %v", sample, got, want)
+		}
+	}
+	for sample := range after {
+		if strings.Contains(sample, app.ID) {
+			t.Errorf("sample %s is labeled with a deal ID", sample)
+		}
+	}
+}
+
+// forbidden is the body of a 403 answered by a permission check
+type forbidden struct {
+	Code               string `json:"code"`
+	RequiredPermission string `json:"requiredPermission"`
+}
+
+func TestRoutePermissions(t *testing.T) {
+	router, store := newTestRouter(t, nil)
+
+	applicant := loginBorrower(t, router, "jane@example.com")
+	loanOfficer := loginEmployee(t, router, store, "lee@example.com", "loan_officer")
+	underwriter := loginEmployee(t, router, store, "uma@example.com", "underwriter")
+	admin := loginEmployee(t, router, store, "ada@example.com", "admin")
+
+	application := map[string]any{"loanType": "Conventional", "loanPurpose": "Purchase", "loanAmount": 350000}
+	var app struct {
+		ID string `json:"id"`
+	}
+	if code := do(t, router, http.MethodPost, "/api/v1/urla/applications", applicant, application, &app); code != http.StatusCreated {
+		t.Fatalf("create application: status %d", code)
+	}
+	employee := func(email string) map[string]string {
+		return map[string]string{
+			"email": email, "password": "correct horse", "firstName": "Pat", "lastName": "Doe", "role": "processor",
+		}
+	}
+	tests := []struct {
+		name         string
+		token        string
+		method, path string
+		body         any
+		want         int
+		// permission is the one a 403 names
+		permission rbac.Permission
+	}{
+		{"applicant approves an application", applicant, http.MethodPut, "/api/v1/urla/applications/" + app.ID + "/status", map[string]string{"status": "approved"}, http.StatusForbidden, rbac.PermApplicationsUpdateStatus},
+		{"applicant creates an employee", applicant, http.MethodPost, "/api/v1/admin/employees", employee("pat@example.com"), http.StatusForbidden, rbac.PermEmployeesManage},
+
+		{"loan officer creates an application", loanOfficer, http.MethodPost, "/api/v1/urla/applications", application, http.StatusCreated, ""},
+		{"loan officer creates an employee", loanOfficer, http.MethodPost, "/api/v1/admin/employees", employee("pat@example.com"), http.StatusForbidden, rbac.PermEmployeesManage},
+		{"underwriter creates an application", underwriter, http.MethodPost, "/api/v1/urla/applications", application, http.StatusForbidden, rbac.PermApplicationsCreate},
+		{"underwriter saves an application", underwriter, http.MethodPost, "/api/v1/urla/applications/" + app.ID + "/save", map[string]any{}, http.StatusForbidden, rbac.PermApplicationsWrite},
+
+		{"admin creates an employee", admin, http.MethodPost, "/api/v1/admin/employees", employee("pat@example.com"), http.StatusCreated, ""},
+	}
+	for _, tt := range tests {
+		var body forbidden
+		var out any = &body
+		if tt.want != http.StatusForbidden {
+			out = nil
+		}
+		if code := do(t, router, tt.method, tt.path, tt.token, tt.body, out); code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.want)
+			continue
+		}
+		if tt.want == http.StatusForbidden && (body.Code != "forbidden" || body.RequiredPermission != string(tt.permission)) {
+			t.Errorf("%s: 403 body = %+v, want code forbidden and %s", tt.name, body, tt.permission)
+		}
+	}
+}
+
+func TestDealAccess(t *testing.T) {
+	router, store := newTestRouter(t, nil)
+
+	jane := loginBorrower(t, router, "jane@example.com")
+	joe := loginBorrower(t, router, "joe@example.com")
+	lee := loginEmployee(t, router, store, "lee@example.com", "loan_officer")
+	lou := loginEmployee(t, router, store, "lou@example.com", "loan_officer")
+	admin := loginEmployee(t, router, store, "ada@example.com", "admin")
+
+	create := func(token string) string {
+		t.Helper()
+		var app struct {
+			ID string `json:"id"`
+		}
+		code := do(t, router, http.MethodPost, "/api/v1/urla/applications", token, map[string]any{
+			"loanType": "Conventional", "loanPurpose": "Purchase", "loanAmount": 350000,
+		}, &app)
+		if code != http.StatusCreated {
+			t.Fatalf("create application: status %d", code)
+		}
+		return app.ID
+	}
+	borrowers := create(jane)
+	// The employee who creates an application is assigned to it
+	officers := create(lee)
+
+	tests := []struct {
+		name  string
+		token string
+		id    string
+		want  int
+	}{
+		{"borrower reads their application", jane, borrowers, http.StatusOK},
+		{"other borrower reads it", joe, borrowers, http.StatusForbidden},
+		{"unassigned loan officer reads it", lee, borrowers, http.StatusForbidden},
+		{"admin reads it", admin, borrowers, http.StatusOK},
+		{"assigned loan officer reads their application", lee, officers, http.StatusOK},
+		{"other loan officer reads it", lou, officers, http.StatusForbidden},
+		{"borrower reads it", jane, officers, http.StatusForbidden},
+		{"unknown application", admin, "00000000-0000-0000-0000-000000000000", http.StatusNotFound},
+		{"malformed application ID", admin, "not-an-id", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		var body forbidden
+		code := do(t, router, http.MethodGet, "/api/v1/urla/applications/"+tt.id, tt.token, nil, &body)
+		if code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.want)
+		}
+		if code == http.StatusForbidden && body.Code != "forbidden" {
+			t.Errorf("%s: 403 code = %q, want forbidden", tt.name, body.Code)
+		}
+	}
+
+	// Writes are checked the same way
+	code := do(t, router, http.MethodPost, "/api/v1/urla/applications/"+borrowers+"/save", joe, map[string]any{
+		"borrower": map[string]any{"firstName": "Joe"},
+	}, nil)
+	if code != http.StatusForbidden {
+		t.Errorf("other borrower saves the application: status %d, want 403", code)
+	}
+}
+
+// apiKey is an API key as shown to admins
+type apiKey struct {
+	ID         string `json:"id"`
+	Key        string `json:"key"`
+	Status     string `json:"status"`
+	ExpiresAt  string `json:"expiresAt"`
+	LastUsedAt string `json:"lastUsedAt"`
+	LastUsedIP string `json:"lastUsedIp"`
+}
+
+func TestAPIKeyAccess(t *testing.T) {
+	router, store := newTestRouter(t, nil)
+
+	applicant := loginBorrower(t, router, "jane@example.com")
+	admin := loginEmployee(t, router, store, "ada@example.com", "admin")
+	var app struct {
+		ID string `json:"id"`
+	}
+	do(t, router, http.MethodPost, "/api/v1/urla/applications", applicant, map[string]any{
+		"loanType": "Conventional", "loanPurpose": "Purchase", "loanAmount": 350000,
+	}, &app)
+	appPath := "/api/v1/urla/applications/" + app.ID
+	save := map[string]any{"borrower": map[string]any{"firstName": "Janet"}}
+
+	// Scopes decide what a key may do
+	readID, reader := createAPIKey(t, router, admin, "deals:read")
+	writeID, writer := createAPIKey(t, router, admin, "deals:read", "deals:write")
+	if code := do(t, router, http.MethodGet, appPath, reader, nil, nil); code != http.StatusOK {
+		t.Fatalf("deals:read key reading: status %d", code)
+	}
+	var denied forbidden
+	if code := do(t, router, http.MethodPost, appPath+"/save", reader, save, &denied); code != http.StatusForbidden ||
+		denied.Code != "forbidden" || denied.RequiredPermission != string(rbac.PermApplicationsWrite) {
+		t.Fatalf("deals:read key writing: status %d, body %+v", code, denied)
+	}
+	if code := do(t, router, http.MethodPost, appPath+"/save", writer, save, nil); code != http.StatusOK {
+		t.Fatalf("deals:write key writing: status %d", code)
+	}
+	// Keys may move an application along but never submit it for the borrower
+	if code := do(t, router, http.MethodPut, appPath+"/status", writer, map[string]string{"status": "in_review"}, nil); code != http.StatusOK {
+		t.Fatalf("deals:write key changing the status: status %d", code)
+	}
+	denied = forbidden{}
+	if code := do(t, router, http.MethodPut, appPath+"/status", writer, map[string]string{"status": "submitted"}, &denied); code != http.StatusForbidden ||
+		denied.RequiredPermission != string(rbac.PermApplicationsSubmit) {
+		t.Fatalf("deals:write key submitting: status %d, body %+v", code, denied)
+	}
+
+	// The key is recorded as the author of its writes and with its last use
+	var edits struct {
+		Edits []struct {
+			AccountID   string `json:"accountId"`
+			AccountType string `json:"accountType"`
+		} `json:"edits"`
+	}
+	if code := do(t, router, http.MethodGet, appPath+"/edits", admin, nil, &edits); code != http.StatusOK || len(edits.Edits) == 0 {
+		t.Fatalf("edits: status %d, %+v", code, edits)
+	}
+	if last := edits.Edits[len(edits.Edits)-1]; last.AccountID != writeID || last.AccountType != "integration" {
+		t.Fatalf("edit by the key recorded as %+v, want account %s of type integration", last, writeID)
+	}
+	var used apiKey
+	do(t, router, http.MethodGet, "/api/v1/admin/api-keys/"+writeID, admin, nil, &used)
+	if used.LastUsedAt == "" || used.LastUsedIP != "192.0.2.1" {
+		t.Fatalf("last use of the key = %+v", used)
+	}
+
+	// Revoked and expired keys are rejected
+	if code := do(t, router, http.MethodPost, "/api/v1/admin/api-keys/"+writeID+"/revoke", admin, nil, nil); code != http.StatusOK {
+		t.Fatalf("revoke: status %d", code)
+	}
+	if code := do(t, router, http.MethodGet, appPath, writer, nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("revoked key: status %d, want 401", code)
+	}
+	if err := store.APIKeys().ShortenExpiry(readID, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if code := do(t, router, http.MethodGet, appPath, reader, nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expired key: status %d, want 401", code)
+	}
+}
+
+func TestRotatedAPIKeyGracePeriod(t *testing.T) {
+	for _, grace := range []time.Duration{time.Hour, 0} {
+		router, store := newTestRouter(t, map[string]string{"TAULEN_API_KEYS_ROTATION_GRACE": grace.String()})
+		admin := loginEmployee(t, router, store, "ada@example.com", "admin")
+		id, old := createAPIKey(t, router, admin, "deals:read")
+
+		var rotated apiKey
+		if code := do(t, router, http.MethodPost, "/api/v1/admin/api-keys/"+id+"/rotate", admin, nil, &rotated); code != http.StatusCreated {
+			t.Fatalf("grace %v: rotate: status %d", grace, code)
+		}
+		if code := do(t, router, http.MethodGet, "/api/v1/urla/applications", rotated.Key, nil, nil); code != http.StatusOK {
+			t.Fatalf("grace %v: new key: status %d", grace, code)
+		}
+
+		// The old key works until the grace period ends, and no longer
+		var previous apiKey
+		do(t, router, http.MethodGet, "/api/v1/admin/api-keys/"+id, admin, nil, &previous)
+		expiresAt, err := time.Parse(time.RFC3339, previous.ExpiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if until := time.Until(expiresAt); until > grace || until < grace-time.Minute {
+			t.Fatalf("grace %v: old key expires in %v", grace, until)
+		}
+		want := http.StatusOK
+		if grace == 0 {
+			want = http.StatusUnauthorized
+		}
+		if code := do(t, router, http.MethodGet, "/api/v1/urla/applications", old, nil, nil); code != want {
+			t.Fatalf("grace %v: old key: status %d, want %d", grace, code, want)
+		}
+	}
+}
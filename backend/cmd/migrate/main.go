@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/database"
+	"taulen/backend/internal/logging"
+	"taulen/backend/internal/migrations"
+)
+
+const usage = `Usage: migrate <command>
+
+Commands:
+  up       Apply all pending migrations
+  down     Revert the most recently applied migration
+  status   List migrations and whether they are applied
+`
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[1]); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+}
+
+func run(command string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	if err := database.ConnectPostgreSQL(cfg); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	migrator, err := migrations.New(database.DB, logging.New(cfg.Logging, os.Stderr))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		reverted, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			fmt.Println("no applied migrations to revert")
+			return nil
+		}
+		fmt.Printf("reverted %04d_%s\n", reverted.Version, reverted.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	return nil
+}
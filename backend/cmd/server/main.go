@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"taulen/backend/api"
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/database"
+	"taulen/backend/internal/logging"
+	"taulen/backend/internal/metrics"
+	"taulen/backend/internal/migrations"
+	"taulen/backend/internal/notify"
+	"taulen/backend/internal/repositories"
+	"taulen/backend/internal/repositories/memory"
+	"taulen/backend/internal/services"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalf("server: %v", err)
+	}
+}
+
+// run wires configuration, databases and the HTTP router together and blocks
+// until the server is stopped by SIGINT/SIGTERM or fails to serve
+func run() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	// Route the standard library logger through the structured logger as well
+	logger := logging.New(cfg.Logging, os.Stderr)
+	slog.SetDefault(logger)
+
+	store, closeStore, err := openStore(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	notifier := notify.New(cfg, logger)
+
+	srv := &http.Server{
+		Addr:         cfg.Server.Addr(),
+		Handler:      api.SetupRoutes(cfg, store, notifier, logger),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go cleanupSessions(ctx, services.NewSessionService(cfg, store, notifier, logger), services.NewVerificationService(cfg, store, notifier, logger),
+		cfg.JWT.SessionCleanupInterval, logger)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("server: listening", "addr", srv.Addr, "environment", cfg.Server.Environment)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+		close(serveErr)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Stop listening for signals so a second SIGINT terminates immediately
+	stop()
+	logger.Info("server: shutting down, draining in-flight requests", "timeout", cfg.Server.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	logger.Info("server: stopped")
+	return nil
+}
+
+// openStore creates the repository store selected by the database driver.
+// The returned function closes any database connections opened for it.
+func openStore(cfg *config.Config, logger *slog.Logger) (repositories.Store, func(), error) {
+	if cfg.Database.Driver == config.DriverMemory {
+		logger.Warn("server: using in-memory store (demo mode, data is lost on restart)")
+		return memory.NewStore(), func() {}, nil
+	}
+
+	if err := database.Connect(cfg); err != nil {
+		return nil, nil, err
+	}
+	closeStore := func() {
+		if err := database.Close(); err != nil {
+			logger.Error("server: failed to close database connections", "error", err)
+		}
+	}
+	logger.Info("server: connected to databases",
+		"postgres", fmt.Sprintf("%s:%d", cfg.Database.Host, cfg.Database.Port),
+		"mongodb", fmt.Sprintf("%s:%d", cfg.MongoDB.Host, cfg.MongoDB.Port))
+
+	if cfg.Database.AutoMigrate {
+		if err := migrate(logger); err != nil {
+			closeStore()
+			return nil, nil, err
+		}
+	}
+	if err := metrics.RegisterDB(database.DB, cfg.Database.DBName); err != nil {
+		closeStore()
+		return nil, nil, fmt.Errorf("failed to register database metrics: %w", err)
+	}
+	return repositories.NewPostgresStore(database.DB), closeStore, nil
+}
+
+// migrate applies pending schema migrations before the server accepts traffic
+func migrate(logger *slog.Logger) error {
+	migrator, err := migrations.New(database.DB, logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	applied, err := migrator.Up(ctx)
+	for _, m := range applied {
+		logger.Info("server: applied migration", "version", m.Version, "name", m.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("auto-migrate failed: %w", err)
+	}
+	return nil
+}
+
+// cleanupSessions deletes expired refresh sessions and verification codes every
+// interval until ctx is done
+func cleanupSessions(ctx context.Context, sessions *services.SessionService, codes *services.VerificationService, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := sessions.DeleteExpired(ctx)
+			if err != nil {
+				logger.Error("server: session cleanup failed", "error", err)
+			} else if deleted > 0 {
+				logger.Info("server: deleted expired refresh sessions", "count", deleted)
+			}
+
+			deleted, err = codes.DeleteExpired(ctx)
+			if err != nil {
+				logger.Error("server: verification code cleanup failed", "error", err)
+			} else if deleted > 0 {
+				logger.Info("server: deleted expired verification codes", "count", deleted)
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
@@ -11,32 +12,64 @@ import (
 
 // Config holds application configuration
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	MongoDB  MongoDBConfig
-	JWT      JWTConfig
-	CORS     CORSConfig
-	FileUpload FileUploadConfig
-	Logging  LoggingConfig
-	Twilio   TwilioConfig
-	SendGrid SendGridConfig
+	Server            ServerConfig
+	Database          DatabaseConfig
+	MongoDB           MongoDBConfig
+	JWT               JWTConfig
+	Login             LoginConfig
+	PasswordReset     PasswordResetConfig
+	EmailVerification EmailVerificationConfig
+	MFA               MFAConfig
+	TwoFactor         TwoFactorConfig
+	OIDC              OIDCConfig
+	APIKeys           APIKeysConfig
+	CORS              CORSConfig
+	FileUpload        FileUploadConfig
+	Logging           LoggingConfig
+	Twilio            TwilioConfig
+	SendGrid          SendGridConfig
+	SMTP              SMTPConfig
+	Notifications     NotificationsConfig
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
-	Host        string
-	Port        int
-	Environment string // dev, staging, prod
+	Host             string
+	Port             int
+	Environment      string        // dev, staging, prod
+	ReadTimeout      time.Duration // Maximum duration for reading the entire request
+	WriteTimeout     time.Duration // Maximum duration before timing out writes of the response
+	IdleTimeout      time.Duration // Maximum time to wait for the next request on keep-alive connections
+	ShutdownTimeout  time.Duration // Maximum time to drain in-flight requests on shutdown
+	ReadinessTimeout time.Duration // Maximum time for the readiness probe to ping dependencies
+	// TrustedProxies are the IP addresses or CIDR ranges of reverse proxies whose
+	// X-Forwarded-For header is believed; with none, the client IP is always the
+	// address of the connection
+	TrustedProxies []string
 }
 
+// Addr returns the host:port address the HTTP server listens on
+func (s ServerConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+// Storage drivers for DatabaseConfig.Driver
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory" // in-memory store for local demo mode; data is lost on restart
+)
+
 // DatabaseConfig holds database connection configuration
 type DatabaseConfig struct {
+	Driver   string // postgres or memory
 	Host     string
 	Port     int
 	User     string
 	Password string
 	DBName   string
 	SSLMode  string
+	// AutoMigrate applies pending schema migrations when the server starts
+	AutoMigrate bool
 }
 
 // DSN returns the PostgreSQL connection string
@@ -67,9 +100,95 @@ func (m MongoDBConfig) URI() string {
 
 // JWTConfig holds JWT authentication configuration
 type JWTConfig struct {
-	Secret            string
-	AccessTokenExpiry time.Duration
+	Secret             string
+	AccessTokenExpiry  time.Duration
 	RefreshTokenExpiry time.Duration
+	// DelegationTokenExpiry is the lifetime of the tokens an employee uses to
+	// edit an application on a borrower's behalf; they cannot be refreshed
+	DelegationTokenExpiry time.Duration
+	// SigningKeys are asymmetric signing keys; when set they replace the
+	// HS256 Secret. The newest active key signs, all keys verify.
+	SigningKeys []SigningKey
+	// OtherTokenExpiries are the lifetimes of the other tokens signed with the
+	// keys: email verification links, MFA challenges and SSO logins
+	OtherTokenExpiries []time.Duration
+	// SessionCleanupInterval is how often expired refresh sessions are deleted
+	SessionCleanupInterval time.Duration
+}
+
+// LoginConfig holds brute-force protection settings for password login
+type LoginConfig struct {
+	// LockoutThreshold is the number of consecutive failed logins that locks an account
+	LockoutThreshold int
+	// LockoutDuration is the first lock's duration; every further failure doubles it
+	LockoutDuration    time.Duration
+	LockoutMaxDuration time.Duration
+	// IPMaxAttempts failed logins, code and token checks are allowed per client IP within IPWindow
+	IPMaxAttempts int
+	IPWindow      time.Duration
+	// IPMaxCodeSends verification codes and emailed links may be requested per client IP within IPCodeSendWindow
+	IPMaxCodeSends   int
+	IPCodeSendWindow time.Duration
+	// IPMaxSSOLogins single sign-on requests are allowed per client IP within IPSSOWindow
+	IPMaxSSOLogins int
+	IPSSOWindow    time.Duration
+}
+
+// PasswordResetConfig holds password reset settings
+type PasswordResetConfig struct {
+	// URL is the frontend page that completes a reset; the token is appended as ?token=
+	URL         string
+	TokenExpiry time.Duration
+}
+
+// EmailVerificationConfig holds borrower email verification settings
+type EmailVerificationConfig struct {
+	// URL is the frontend page that confirms an email; the token is appended as ?token=
+	URL         string
+	TokenExpiry time.Duration
+}
+
+// MFAConfig holds TOTP multi-factor authentication settings
+type MFAConfig struct {
+	// Issuer names the account in authenticator apps
+	Issuer string
+	// RequireEmployees makes employees enroll in MFA before they can log in
+	RequireEmployees bool
+	// ChallengeExpiry is how long the second login step may take after the password check
+	ChallengeExpiry time.Duration
+}
+
+// APIKeysConfig holds settings for the API keys of machine-to-machine integrations
+type APIKeysConfig struct {
+	// DefaultExpiry applies to keys created without an expiry
+	DefaultExpiry time.Duration
+	// MaxExpiry is the longest lifetime a key may be given
+	MaxExpiry time.Duration
+	// RotationGrace is how long a rotated key keeps working next to its replacement
+	RotationGrace time.Duration
+}
+
+// Two-factor modes for TwoFactorConfig.Mode
+const (
+	TwoFactorOff = "off"
+	// TwoFactorOptional lets each account opt in to codes; once it has, every
+	// login of the account must supply one
+	TwoFactorOptional = "optional"
+	TwoFactorRequired = "required" // logins and registrations must supply a code
+)
+
+// TwoFactorConfig holds code-based two-factor authentication settings. Codes are
+// sent by email; repeated wrong codes count towards the login lockout.
+type TwoFactorConfig struct {
+	Mode       string // off, optional or required
+	CodeExpiry time.Duration
+	// CodeSecret keys the HMAC under which codes are stored; the JWT secret is
+	// used when it is empty outside production
+	CodeSecret string
+	// MaxAttempts wrong entries invalidate a code
+	MaxAttempts int
+	// ResendCooldown is the minimum time between codes sent to one email or phone
+	ResendCooldown time.Duration
 }
 
 // CORSConfig holds CORS configuration
@@ -81,7 +200,7 @@ type CORSConfig struct {
 
 // FileUploadConfig holds file upload configuration
 type FileUploadConfig struct {
-	MaxSize      int64  // in bytes
+	MaxSize      int64 // in bytes
 	AllowedTypes []string
 	StoragePath  string
 }
@@ -103,7 +222,7 @@ type TwilioConfig struct {
 
 // SendGridConfig holds Twilio SendGrid email configuration
 type SendGridConfig struct {
-	APIKey  string // SendGrid API Key
+	APIKey    string // SendGrid API Key
 	FromEmail string // From email address
 	FromName  string // From name (optional)
 }
@@ -123,19 +242,36 @@ func Load() (*Config, error) {
 	// Set defaults
 	setDefaults()
 
+	signingKeys, err := parseSigningKeys(viper.GetString("jwt.signing_keys"))
+	if err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+	roleMappings, err := parseOIDCRoleMappings(viper.GetString("oidc.role_mappings"))
+	if err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+
 	config := &Config{
 		Server: ServerConfig{
-			Host:        viper.GetString("server.host"),
-			Port:        viper.GetInt("server.port"),
-			Environment: viper.GetString("server.environment"),
+			Host:             viper.GetString("server.host"),
+			Port:             viper.GetInt("server.port"),
+			Environment:      viper.GetString("server.environment"),
+			ReadTimeout:      viper.GetDuration("server.read_timeout"),
+			WriteTimeout:     viper.GetDuration("server.write_timeout"),
+			IdleTimeout:      viper.GetDuration("server.idle_timeout"),
+			ShutdownTimeout:  viper.GetDuration("server.shutdown_timeout"),
+			ReadinessTimeout: viper.GetDuration("server.readiness_timeout"),
+			TrustedProxies:   parseStringSlice(viper.GetString("server.trusted_proxies")),
 		},
 		Database: DatabaseConfig{
-			Host:     viper.GetString("database.host"),
-			Port:     viper.GetInt("database.port"),
-			User:     viper.GetString("database.user"),
-			Password: viper.GetString("database.password"),
-			DBName:   viper.GetString("database.dbname"),
-			SSLMode:  viper.GetString("database.sslmode"),
+			Driver:      viper.GetString("database.driver"),
+			Host:        viper.GetString("database.host"),
+			Port:        viper.GetInt("database.port"),
+			User:        viper.GetString("database.user"),
+			Password:    viper.GetString("database.password"),
+			DBName:      viper.GetString("database.dbname"),
+			SSLMode:     viper.GetString("database.sslmode"),
+			AutoMigrate: viper.GetBool("database.auto_migrate"),
 		},
 		MongoDB: MongoDBConfig{
 			Host:     viper.GetString("mongodb.host"),
@@ -145,9 +281,59 @@ func Load() (*Config, error) {
 			Password: viper.GetString("mongodb.password"),
 		},
 		JWT: JWTConfig{
-			Secret:            viper.GetString("jwt.secret"),
-			AccessTokenExpiry: viper.GetDuration("jwt.access_token_expiry"),
-			RefreshTokenExpiry: viper.GetDuration("jwt.refresh_token_expiry"),
+			Secret:                 viper.GetString("jwt.secret"),
+			AccessTokenExpiry:      viper.GetDuration("jwt.access_token_expiry"),
+			RefreshTokenExpiry:     viper.GetDuration("jwt.refresh_token_expiry"),
+			DelegationTokenExpiry:  viper.GetDuration("jwt.delegation_token_expiry"),
+			SigningKeys:            signingKeys,
+			SessionCleanupInterval: viper.GetDuration("jwt.session_cleanup_interval"),
+		},
+		Login: LoginConfig{
+			LockoutThreshold:   viper.GetInt("login.lockout_threshold"),
+			LockoutDuration:    viper.GetDuration("login.lockout_duration"),
+			LockoutMaxDuration: viper.GetDuration("login.lockout_max_duration"),
+			IPMaxAttempts:      viper.GetInt("login.ip_max_attempts"),
+			IPWindow:           viper.GetDuration("login.ip_window"),
+			IPMaxCodeSends:     viper.GetInt("login.ip_max_code_sends"),
+			IPCodeSendWindow:   viper.GetDuration("login.ip_code_send_window"),
+			IPMaxSSOLogins:     viper.GetInt("login.ip_max_sso_logins"),
+			IPSSOWindow:        viper.GetDuration("login.ip_sso_window"),
+		},
+		PasswordReset: PasswordResetConfig{
+			URL:         viper.GetString("password_reset.url"),
+			TokenExpiry: viper.GetDuration("password_reset.token_expiry"),
+		},
+		EmailVerification: EmailVerificationConfig{
+			URL:         viper.GetString("email_verification.url"),
+			TokenExpiry: viper.GetDuration("email_verification.token_expiry"),
+		},
+		MFA: MFAConfig{
+			Issuer:           viper.GetString("mfa.issuer"),
+			RequireEmployees: viper.GetBool("mfa.require_employees"),
+			ChallengeExpiry:  viper.GetDuration("mfa.challenge_expiry"),
+		},
+		TwoFactor: TwoFactorConfig{
+			Mode:           strings.ToLower(viper.GetString("two_factor.mode")),
+			CodeExpiry:     viper.GetDuration("two_factor.code_expiry"),
+			CodeSecret:     viper.GetString("two_factor.code_secret"),
+			MaxAttempts:    viper.GetInt("two_factor.max_attempts"),
+			ResendCooldown: viper.GetDuration("two_factor.resend_cooldown"),
+		},
+		OIDC: OIDCConfig{
+			IssuerURL:            viper.GetString("oidc.issuer_url"),
+			ClientID:             viper.GetString("oidc.client_id"),
+			ClientSecret:         viper.GetString("oidc.client_secret"),
+			RedirectURL:          viper.GetString("oidc.redirect_url"),
+			Scopes:               parseStringSlice(viper.GetString("oidc.scopes")),
+			GroupsClaim:          viper.GetString("oidc.groups_claim"),
+			RoleMappings:         roleMappings,
+			LoginExpiry:          viper.GetDuration("oidc.login_expiry"),
+			DisablePasswordLogin: viper.GetBool("oidc.disable_password_login"),
+		},
+		APIKeys: APIKeysConfig{
+			DefaultExpiry: viper.GetDuration("api_keys.default_expiry"),
+			MaxExpiry:     viper.GetDuration("api_keys.max_expiry"),
+			RotationGrace: viper.GetDuration("api_keys.rotation_grace"),
 		},
 		CORS: CORSConfig{
 			AllowedOrigins: parseStringSlice(viper.GetString("cors.allowed_origins")),
@@ -175,6 +361,22 @@ func Load() (*Config, error) {
 			FromEmail: viper.GetString("sendgrid.from_email"),
 			FromName:  viper.GetString("sendgrid.from_name"),
 		},
+		SMTP: SMTPConfig{
+			Host:      viper.GetString("smtp.host"),
+			Port:      viper.GetInt("smtp.port"),
+			Username:  viper.GetString("smtp.username"),
+			Password:  viper.GetString("smtp.password"),
+			FromEmail: viper.GetString("smtp.from_email"),
+			FromName:  viper.GetString("smtp.from_name"),
+		},
+		Notifications: NotificationsConfig{
+			EmailProvider: strings.ToLower(viper.GetString("notifications.email_provider")),
+			SMSProvider:   strings.ToLower(viper.GetString("notifications.sms_provider")),
+			FilePath:      viper.GetString("notifications.file_path"),
+		},
+	}
+	config.JWT.OtherTokenExpiries = []time.Duration{
+		config.EmailVerification.TokenExpiry, config.MFA.ChallengeExpiry, config.OIDC.LoginExpiry,
 	}
 
 	// Validate required configuration
@@ -191,14 +393,22 @@ func setDefaults() {
 	viper.SetDefault("server.host", "0.0.0.0")
 	viper.SetDefault("server.port", 8080)
 	viper.SetDefault("server.environment", "dev")
+	viper.SetDefault("server.read_timeout", "15s")
+	viper.SetDefault("server.write_timeout", "30s")
+	viper.SetDefault("server.idle_timeout", "60s")
+	viper.SetDefault("server.shutdown_timeout", "20s")
+	viper.SetDefault("server.readiness_timeout", "2s")
+	viper.SetDefault("server.trusted_proxies", "")
 
 	// Database defaults
+	viper.SetDefault("database.driver", DriverPostgres)
 	viper.SetDefault("database.host", "localhost")
 	viper.SetDefault("database.port", 5434)
 	viper.SetDefault("database.user", "taulen")
 	viper.SetDefault("database.password", "taulen_dev_password")
 	viper.SetDefault("database.dbname", "taulen_db")
 	viper.SetDefault("database.sslmode", "disable")
+	viper.SetDefault("database.auto_migrate", false)
 
 	// MongoDB defaults
 	viper.SetDefault("mongodb.host", "localhost")
@@ -210,12 +420,62 @@ func setDefaults() {
 	// JWT defaults
 	viper.SetDefault("jwt.secret", "change-me-in-production")
 	viper.SetDefault("jwt.access_token_expiry", "15m")
-	viper.SetDefault("jwt.refresh_token_expiry", "7d")
+	viper.SetDefault("jwt.refresh_token_expiry", "168h") // 7 days
+	viper.SetDefault("jwt.delegation_token_expiry", "30m")
+	viper.SetDefault("jwt.session_cleanup_interval", "1h")
+	viper.SetDefault("jwt.signing_keys", "")
+
+	// Login protection defaults
+	viper.SetDefault("login.lockout_threshold", 5)
+	viper.SetDefault("login.lockout_duration", "1m")
+	viper.SetDefault("login.lockout_max_duration", "24h")
+	viper.SetDefault("login.ip_max_attempts", 20)
+	viper.SetDefault("login.ip_window", "15m")
+	viper.SetDefault("login.ip_max_code_sends", 10)
+	viper.SetDefault("login.ip_code_send_window", "15m")
+	viper.SetDefault("login.ip_max_sso_logins", 30)
+	viper.SetDefault("login.ip_sso_window", "15m")
+
+	// Password reset defaults
+	viper.SetDefault("password_reset.url", "http://localhost:3000/reset-password")
+	viper.SetDefault("password_reset.token_expiry", "1h")
+
+	// Email verification defaults
+	viper.SetDefault("email_verification.url", "http://localhost:3000/verify-email")
+	viper.SetDefault("email_verification.token_expiry", "24h")
+
+	// MFA defaults
+	viper.SetDefault("mfa.issuer", "Taulen")
+	viper.SetDefault("mfa.require_employees", false)
+	viper.SetDefault("mfa.challenge_expiry", "5m")
+
+	// Two-factor defaults
+	viper.SetDefault("two_factor.mode", TwoFactorOff)
+	viper.SetDefault("two_factor.code_expiry", "10m")
+	viper.SetDefault("two_factor.code_secret", "")
+	viper.SetDefault("two_factor.max_attempts", 5)
+	viper.SetDefault("two_factor.resend_cooldown", "1m")
+
+	// OIDC single sign-on defaults; SSO stays off until an issuer is set
+	viper.SetDefault("oidc.issuer_url", "")
+	viper.SetDefault("oidc.client_id", "")
+	viper.SetDefault("oidc.client_secret", "")
+	viper.SetDefault("oidc.redirect_url", "http://localhost:3000/sso/callback")
+	viper.SetDefault("oidc.scopes", "openid,email,profile")
+	viper.SetDefault("oidc.groups_claim", "groups")
+	viper.SetDefault("oidc.role_mappings", "")
+	viper.SetDefault("oidc.login_expiry", "10m")
+	viper.SetDefault("oidc.disable_password_login", false)
+
+	// API key defaults
+	viper.SetDefault("api_keys.default_expiry", "2160h")
+	viper.SetDefault("api_keys.max_expiry", "8760h")
+	viper.SetDefault("api_keys.rotation_grace", "24h")
 
 	// CORS defaults
 	viper.SetDefault("cors.allowed_origins", "http://localhost:3000")
 	viper.SetDefault("cors.allowed_methods", "GET,POST,PUT,DELETE,OPTIONS")
-	viper.SetDefault("cors.allowed_headers", "Content-Type,Authorization")
+	viper.SetDefault("cors.allowed_headers", "Content-Type,Authorization,X-Request-ID")
 
 	// File upload defaults
 	viper.SetDefault("file_upload.max_size", 10*1024*1024) // 10MB
@@ -237,6 +497,19 @@ func setDefaults() {
 	viper.SetDefault("sendgrid.api_key", "")
 	viper.SetDefault("sendgrid.from_email", "noreply@taulen.com")
 	viper.SetDefault("sendgrid.from_name", "Taulen")
+
+	// SMTP defaults
+	viper.SetDefault("smtp.host", "")
+	viper.SetDefault("smtp.port", 587)
+	viper.SetDefault("smtp.username", "")
+	viper.SetDefault("smtp.password", "")
+	viper.SetDefault("smtp.from_email", "noreply@taulen.com")
+	viper.SetDefault("smtp.from_name", "Taulen")
+
+	// Notification defaults
+	viper.SetDefault("notifications.email_provider", ProviderSendGrid)
+	viper.SetDefault("notifications.sms_provider", ProviderTwilio)
+	viper.SetDefault("notifications.file_path", "notifications.log")
 }
 
 // parseStringSlice parses a comma-separated string into a slice
@@ -257,17 +530,40 @@ func parseStringSlice(s string) []string {
 
 // validate validates the configuration
 func validate(cfg *Config) error {
-	if cfg.Database.Host == "" {
-		return fmt.Errorf("database host is required")
+	if cfg.Server.Port <= 0 || cfg.Server.Port > 65535 {
+		return fmt.Errorf("server port must be between 1 and 65535")
 	}
-	if cfg.Database.User == "" {
-		return fmt.Errorf("database user is required")
+	for _, proxy := range cfg.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("server trusted proxy %q must be an IP address or CIDR range", proxy)
+		}
 	}
-	if cfg.Database.Password == "" {
-		return fmt.Errorf("database password is required")
+	if cfg.Server.ShutdownTimeout <= 0 {
+		return fmt.Errorf("server shutdown timeout must be positive")
 	}
-	if cfg.Database.DBName == "" {
-		return fmt.Errorf("database name is required")
+	if cfg.Server.ReadinessTimeout <= 0 {
+		return fmt.Errorf("server readiness timeout must be positive")
+	}
+	switch cfg.Database.Driver {
+	case DriverPostgres:
+		if cfg.Database.Host == "" {
+			return fmt.Errorf("database host is required")
+		}
+		if cfg.Database.User == "" {
+			return fmt.Errorf("database user is required")
+		}
+		if cfg.Database.Password == "" {
+			return fmt.Errorf("database password is required")
+		}
+		if cfg.Database.DBName == "" {
+			return fmt.Errorf("database name is required")
+		}
+	case DriverMemory:
+		if cfg.Database.AutoMigrate {
+			return fmt.Errorf("database auto-migrate is not supported by the memory driver")
+		}
+	default:
+		return fmt.Errorf("database driver must be %q or %q", DriverPostgres, DriverMemory)
 	}
 	if cfg.MongoDB.Host == "" {
 		return fmt.Errorf("mongodb host is required")
@@ -275,7 +571,70 @@ func validate(cfg *Config) error {
 	if cfg.MongoDB.Database == "" {
 		return fmt.Errorf("mongodb database name is required")
 	}
-	if cfg.JWT.Secret == "" || cfg.JWT.Secret == "change-me-in-production" {
+	switch strings.ToLower(cfg.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("logging level must be one of debug, info, warn, error")
+	}
+	switch strings.ToLower(cfg.Logging.Format) {
+	case "json", "text":
+	default:
+		return fmt.Errorf("logging format must be json or text")
+	}
+	if cfg.JWT.AccessTokenExpiry <= 0 {
+		return fmt.Errorf("JWT access token expiry must be a positive duration (e.g. 15m)")
+	}
+	if cfg.JWT.RefreshTokenExpiry <= 0 {
+		return fmt.Errorf("JWT refresh token expiry must be a positive duration (e.g. 168h)")
+	}
+	if cfg.JWT.DelegationTokenExpiry <= 0 {
+		return fmt.Errorf("JWT delegation token expiry must be a positive duration (e.g. 30m)")
+	}
+	if cfg.JWT.SessionCleanupInterval <= 0 {
+		return fmt.Errorf("JWT session cleanup interval must be positive")
+	}
+	if cfg.Login.LockoutThreshold <= 0 || cfg.Login.IPMaxAttempts <= 0 {
+		return fmt.Errorf("login lockout threshold and IP max attempts must be positive")
+	}
+	if cfg.Login.LockoutDuration <= 0 || cfg.Login.LockoutMaxDuration < cfg.Login.LockoutDuration || cfg.Login.IPWindow <= 0 {
+		return fmt.Errorf("login lockout durations and IP window must be positive, with the max duration at least the lockout duration")
+	}
+	if cfg.Login.IPMaxCodeSends <= 0 || cfg.Login.IPCodeSendWindow <= 0 || cfg.Login.IPMaxSSOLogins <= 0 || cfg.Login.IPSSOWindow <= 0 {
+		return fmt.Errorf("login IP limits for code sends and single sign-on and their windows must be positive")
+	}
+	if cfg.PasswordReset.URL == "" || cfg.PasswordReset.TokenExpiry <= 0 {
+		return fmt.Errorf("password reset URL is required and its token expiry must be positive")
+	}
+	if cfg.EmailVerification.URL == "" || cfg.EmailVerification.TokenExpiry <= 0 {
+		return fmt.Errorf("email verification URL is required and its token expiry must be positive")
+	}
+	if cfg.MFA.Issuer == "" || cfg.MFA.ChallengeExpiry <= 0 {
+		return fmt.Errorf("MFA issuer is required and its challenge expiry must be positive")
+	}
+	switch cfg.TwoFactor.Mode {
+	case TwoFactorOff, TwoFactorOptional, TwoFactorRequired:
+	default:
+		return fmt.Errorf("two-factor mode must be one of off, optional, required")
+	}
+	if cfg.TwoFactor.CodeExpiry <= 0 || cfg.TwoFactor.MaxAttempts <= 0 || cfg.TwoFactor.ResendCooldown < 0 {
+		return fmt.Errorf("two-factor code expiry and max attempts must be positive and the resend cooldown not negative")
+	}
+	if cfg.TwoFactor.CodeSecret == "" && cfg.Server.Environment == "prod" {
+		return fmt.Errorf("two-factor code secret must be set in production")
+	}
+	if err := validateOIDC(&cfg.OIDC, cfg.Server.Environment); err != nil {
+		return err
+	}
+	if cfg.APIKeys.DefaultExpiry <= 0 || cfg.APIKeys.MaxExpiry < cfg.APIKeys.DefaultExpiry || cfg.APIKeys.RotationGrace < 0 {
+		return fmt.Errorf("API key default expiry must be positive and at most the max expiry, and the rotation grace not negative")
+	}
+	if err := validateSigningKeys(cfg.JWT.SigningKeys); err != nil {
+		return err
+	}
+	if err := validateNotifications(&cfg.Notifications, &cfg.SMTP, cfg.Server.Environment); err != nil {
+		return err
+	}
+	if len(cfg.JWT.SigningKeys) == 0 && (cfg.JWT.Secret == "" || cfg.JWT.Secret == "change-me-in-production") {
 		if cfg.Server.Environment == "prod" {
 			return fmt.Errorf("JWT secret must be set in production")
 		}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// load loads the configuration after setting the given TAULEN_ variables
+func load(t *testing.T, env map[string]string) (*Config, error) {
+	t.Helper()
+	t.Setenv("TAULEN_JWT_SECRET", "test-secret")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	return Load()
+}
+
+func TestServerDefaults(t *testing.T) {
+	cfg, err := load(t, nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	server := cfg.Server
+	if server.Addr() != "0.0.0.0:8080" {
+		t.Errorf("Addr() = %q, want 0.0.0.0:8080", server.Addr())
+	}
+	if server.ReadTimeout != 15*time.Second || server.WriteTimeout != 30*time.Second ||
+		server.IdleTimeout != time.Minute || server.ShutdownTimeout != 20*time.Second {
+		t.Errorf("timeouts = %+v", server)
+	}
+}
+
+func TestServerSettingsFromEnvironment(t *testing.T) {
+	cfg, err := load(t, map[string]string{
+		"TAULEN_SERVER_HOST":             "127.0.0.1",
+		"TAULEN_SERVER_PORT":             "9090",
+		"TAULEN_SERVER_WRITE_TIMEOUT":    "1m",
+		"TAULEN_SERVER_SHUTDOWN_TIMEOUT": "5s",
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Addr() != "127.0.0.1:9090" {
+		t.Errorf("Addr() = %q, want 127.0.0.1:9090", cfg.Server.Addr())
+	}
+	if cfg.Server.WriteTimeout != time.Minute || cfg.Server.ShutdownTimeout != 5*time.Second {
+		t.Errorf("timeouts = %+v", cfg.Server)
+	}
+}
+
+func TestInvalidServerSettings(t *testing.T) {
+	for key, value := range map[string]string{
+		"TAULEN_SERVER_PORT":             "70000",
+		"TAULEN_SERVER_SHUTDOWN_TIMEOUT": "0s",
+	} {
+		t.Run(key, func(t *testing.T) {
+			if _, err := load(t, map[string]string{key: value}); err == nil {
+				t.Errorf("%s=%s: Load succeeded", key, value)
+			}
+		})
+	}
+}
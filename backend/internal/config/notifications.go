@@ -0,0 +1,60 @@
+package config
+
+import "fmt"
+
+// SMTPConfig holds SMTP email configuration
+type SMTPConfig struct {
+	Host      string
+	Port      int    // 465 uses implicit TLS; other ports upgrade with STARTTLS when offered
+	Username  string // Optional: authenticates with PLAIN when set
+	Password  string
+	FromEmail string
+	FromName  string
+}
+
+// Notification providers for NotificationsConfig
+const (
+	ProviderSendGrid = "sendgrid"
+	ProviderSMTP     = "smtp"
+	ProviderTwilio   = "twilio"
+	ProviderFile     = "file"    // appends notifications to NotificationsConfig.FilePath
+	ProviderConsole  = "console" // writes notifications to standard output
+)
+
+// NotificationsConfig selects the providers that deliver emails and text
+// messages. The file and console sinks deliver nothing; they are meant for
+// local development and automated tests.
+type NotificationsConfig struct {
+	EmailProvider string // sendgrid, smtp, file or console
+	SMSProvider   string // twilio, file or console
+	FilePath      string // written by the file sink
+}
+
+// validateNotifications checks the notification providers and their settings;
+// the file and console sinks are only accepted outside production
+func validateNotifications(cfg *NotificationsConfig, smtp *SMTPConfig, environment string) error {
+	switch cfg.EmailProvider {
+	case ProviderSendGrid, ProviderFile, ProviderConsole:
+	case ProviderSMTP:
+		if smtp.Host == "" || smtp.Port <= 0 || smtp.Port > 65535 || smtp.FromEmail == "" {
+			return fmt.Errorf("SMTP host, port and from email are required by the smtp email provider")
+		}
+	default:
+		return fmt.Errorf("notifications email provider must be one of sendgrid, smtp, file, console")
+	}
+	switch cfg.SMSProvider {
+	case ProviderTwilio, ProviderFile, ProviderConsole:
+	default:
+		return fmt.Errorf("notifications SMS provider must be one of twilio, file, console")
+	}
+
+	usesFile := cfg.EmailProvider == ProviderFile || cfg.SMSProvider == ProviderFile
+	usesConsole := cfg.EmailProvider == ProviderConsole || cfg.SMSProvider == ProviderConsole
+	if (usesFile || usesConsole) && environment == "prod" {
+		return fmt.Errorf("notification providers file and console cannot be used in production")
+	}
+	if usesFile && cfg.FilePath == "" {
+		return fmt.Errorf("notifications file path is required by the file provider")
+	}
+	return nil
+}
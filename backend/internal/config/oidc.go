@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OIDCConfig holds OpenID Connect single sign-on settings for employees. SSO
+// is disabled when IssuerURL is empty.
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string // empty for public clients, which rely on PKCE alone
+	// RedirectURL is the frontend page the provider sends the browser back to
+	RedirectURL string
+	Scopes      []string
+	// GroupsClaim names the ID token claim listing the employee's groups
+	GroupsClaim string
+	// RoleMappings grant roles to provider groups; the first mapping whose
+	// group the employee belongs to decides their role
+	RoleMappings []OIDCRoleMapping
+	// LoginExpiry is how long an employee has to complete a login at the provider
+	LoginExpiry time.Duration
+	// DisablePasswordLogin makes employees sign in through SSO only
+	DisablePasswordLogin bool
+}
+
+// OIDCRoleMapping grants an employee role to the members of a provider group
+type OIDCRoleMapping struct {
+	Group string
+	Role  string // loan_officer, underwriter, processor or admin
+}
+
+// Enabled reports whether SSO is configured
+func (c *OIDCConfig) Enabled() bool {
+	return c.IssuerURL != ""
+}
+
+// parseOIDCRoleMappings parses oidc.role_mappings, a comma-separated list of
+// "group=role" entries
+func parseOIDCRoleMappings(s string) ([]OIDCRoleMapping, error) {
+	var mappings []OIDCRoleMapping
+	for _, entry := range parseStringSlice(s) {
+		group, role, ok := strings.Cut(entry, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || role == "" {
+			return nil, fmt.Errorf("invalid OIDC role mapping %q: expected group=role", entry)
+		}
+		mappings = append(mappings, OIDCRoleMapping{Group: group, Role: role})
+	}
+	return mappings, nil
+}
+
+// validateOIDC checks the SSO settings; providers other than https ones are
+// only accepted outside production, e.g. a local stand-in provider
+func validateOIDC(cfg *OIDCConfig, environment string) error {
+	if !cfg.Enabled() {
+		if cfg.DisablePasswordLogin {
+			return fmt.Errorf("OIDC issuer URL is required to disable employee password login")
+		}
+		return nil
+	}
+
+	issuer, err := url.Parse(cfg.IssuerURL)
+	if err != nil || issuer.Host == "" || (issuer.Scheme != "https" && issuer.Scheme != "http") {
+		return fmt.Errorf("OIDC issuer URL must be an absolute http(s) URL")
+	}
+	if issuer.Scheme != "https" && environment == "prod" {
+		return fmt.Errorf("OIDC issuer URL must use https in production")
+	}
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return fmt.Errorf("OIDC client ID and redirect URL are required")
+	}
+	if cfg.GroupsClaim == "" || len(cfg.RoleMappings) == 0 {
+		return fmt.Errorf("OIDC groups claim and at least one role mapping are required")
+	}
+	for _, mapping := range cfg.RoleMappings {
+		switch mapping.Role {
+		case "loan_officer", "underwriter", "processor", "admin":
+		default:
+			return fmt.Errorf("OIDC role mapping for group %q must map to loan_officer, underwriter, processor or admin", mapping.Group)
+		}
+	}
+	if cfg.LoginExpiry <= 0 {
+		return fmt.Errorf("OIDC login expiry must be positive")
+	}
+	return nil
+}
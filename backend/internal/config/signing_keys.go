@@ -0,0 +1,115 @@
+package config
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// minRSAKeyBits is the smallest RSA modulus accepted for signing keys
+const minRSAKeyBits = 2048
+
+// SigningKey is an asymmetric JWT signing key loaded from a PEM file
+type SigningKey struct {
+	ID          string    // published as the kid header and in the JWKS
+	Path        string    // PEM file the key was loaded from
+	ActivatesAt time.Time // when the key starts signing; zero means immediately
+	// Key is an *rsa.PrivateKey (RS256) or an ed25519.PrivateKey (EdDSA)
+	Key crypto.Signer
+}
+
+// parseSigningKeys parses jwt.signing_keys, a comma-separated list of
+// "kid=path" or "kid=path@RFC3339-activation-time" entries, and loads each key
+func parseSigningKeys(s string) ([]SigningKey, error) {
+	var keys []SigningKey
+	for _, entry := range parseStringSlice(s) {
+		id, path, ok := strings.Cut(entry, "=")
+		id, path = strings.TrimSpace(id), strings.TrimSpace(path)
+		if !ok || id == "" || path == "" {
+			return nil, fmt.Errorf("invalid JWT signing key %q: expected kid=path[@activation-time]", entry)
+		}
+
+		key := SigningKey{ID: id, Path: path}
+		if at := strings.LastIndex(path, "@"); at >= 0 {
+			activatesAt, err := time.Parse(time.RFC3339, path[at+1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid activation time for JWT signing key %q: %w", id, err)
+			}
+			key.Path, key.ActivatesAt = path[:at], activatesAt
+		}
+
+		signer, err := loadSigningKey(key.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWT signing key %q: %w", id, err)
+		}
+		key.Key = signer
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// loadSigningKey reads an RSA (PKCS #1 or PKCS #8) or Ed25519 (PKCS #8) private key from a PEM file
+func loadSigningKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s contains no PEM block", path)
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in %s, expected a private key", block.Type, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key in %s is %d bits, at least %d are required", path, k.N.BitLen(), minRSAKeyBits)
+		}
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T in %s, expected RSA or Ed25519", key, path)
+	}
+}
+
+// validateSigningKeys checks that key IDs are unique and that a key is active now
+func validateSigningKeys(keys []SigningKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(keys))
+	active := false
+	now := time.Now()
+	for _, key := range keys {
+		if seen[key.ID] {
+			return fmt.Errorf("duplicate JWT signing key id %q", key.ID)
+		}
+		seen[key.ID] = true
+		if !key.ActivatesAt.After(now) {
+			active = true
+		}
+	}
+	if !active {
+		return fmt.Errorf("no JWT signing key is active yet")
+	}
+	return nil
+}
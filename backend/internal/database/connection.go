@@ -24,7 +24,7 @@ var MongoDB *mongo.Database
 // Connect establishes connections to PostgreSQL and MongoDB
 func Connect(cfg *config.Config) error {
 	// Connect to PostgreSQL
-	if err := connectPostgreSQL(cfg); err != nil {
+	if err := ConnectPostgreSQL(cfg); err != nil {
 		return fmt.Errorf("postgresql connection failed: %w", err)
 	}
 
@@ -36,8 +36,9 @@ func Connect(cfg *config.Config) error {
 	return nil
 }
 
-// connectPostgreSQL establishes a connection to PostgreSQL
-func connectPostgreSQL(cfg *config.Config) error {
+// ConnectPostgreSQL establishes a connection to PostgreSQL only
+// (used by tools such as the migrate command that do not need MongoDB)
+func ConnectPostgreSQL(cfg *config.Config) error {
 	dsn := cfg.Database.DSN()
 
 	db, err := sql.Open("pgx", dsn)
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"taulen/backend/internal/middleware"
+	"taulen/backend/internal/redact"
 	"taulen/backend/internal/services"
+	"taulen/backend/internal/utils"
 )
 
 // AdminHandler handles admin-related HTTP requests
@@ -38,13 +41,11 @@ func (h *AdminHandler) CreateEmployee(c *gin.Context) {
 		return
 	}
 
-	// TODO: Check if user is admin (need to get user and check role)
-	// For now, allow any authenticated user to create employees
-	// In production, add role check here
+	// The employees:manage permission is enforced by the route group
 
 	var req CreateEmployeeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
 		return
 	}
 
@@ -57,15 +58,221 @@ func (h *AdminHandler) CreateEmployee(c *gin.Context) {
 		Role:      req.Role,
 	}
 
-	employee, err := h.authService.CreateEmployee(employeeReq)
+	employee, err := h.authService.CreateEmployee(c.Request.Context(), employeeReq)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
-		if err.Error() == "employee with this email already exists" || err.Error() == "email already registered as applicant" {
+		if errors.Is(err, services.ErrEmployeeExists) || errors.Is(err, services.ErrEmailRegisteredAsBorrower) {
 			statusCode = http.StatusConflict
 		}
-		c.JSON(statusCode, gin.H{"error": err.Error()})
+		respondError(c, statusCode, err, "Failed to create employee")
 		return
 	}
 
 	c.JSON(http.StatusCreated, employee)
 }
+
+// UnlockAccountRequest represents a request to unlock a borrower or employee account
+type UnlockAccountRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// UnlockAccount clears the login lockout of a borrower or employee account (admin only)
+func (h *AdminHandler) UnlockAccount(c *gin.Context) {
+	var req UnlockAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	if err := h.authService.UnlockAccount(c.Request.Context(), req.Email); err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, services.ErrAccountNotFound) {
+			statusCode = http.StatusNotFound
+		}
+		respondError(c, statusCode, err, "Failed to unlock account")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked"})
+}
+
+// ListEmployeesQuery represents the query parameters of an employee search
+type ListEmployeesQuery struct {
+	Search   string `form:"search"`
+	Role     string `form:"role" binding:"omitempty,oneof=loan_officer underwriter processor admin"`
+	Status   string `form:"status" binding:"omitempty,oneof=active inactive"`
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"pageSize" binding:"omitempty,min=1,max=100"`
+}
+
+// ListEmployees handles searching employee accounts with pagination (admin only)
+func (h *AdminHandler) ListEmployees(c *gin.Context) {
+	var query ListEmployeesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	employees, err := h.authService.ListEmployees(c.Request.Context(), services.ListEmployeesRequest{
+		Search:   query.Search,
+		Role:     query.Role,
+		Status:   query.Status,
+		Page:     query.Page,
+		PageSize: query.PageSize,
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err, "Failed to list employees")
+		return
+	}
+
+	c.JSON(http.StatusOK, employees)
+}
+
+// GetEmployee handles fetching an employee account (admin only)
+func (h *AdminHandler) GetEmployee(c *gin.Context) {
+	id, ok := employeeID(c)
+	if !ok {
+		return
+	}
+
+	employee, err := h.authService.GetEmployee(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, employeeErrorStatus(err), err, "Failed to load employee")
+		return
+	}
+
+	c.JSON(http.StatusOK, employee)
+}
+
+// UpdateEmployeeRequest represents changes to an employee's profile and role;
+// omitted fields are left unchanged
+type UpdateEmployeeRequest struct {
+	FirstName *string `json:"firstName" binding:"omitempty,min=1,max=35"`
+	LastName  *string `json:"lastName" binding:"omitempty,min=1,max=35"`
+	Phone     *string `json:"phone" binding:"omitempty,max=20"`
+	Role      *string `json:"role" binding:"omitempty,oneof=loan_officer underwriter processor admin"`
+}
+
+// UpdateEmployee handles updating an employee's profile and role (admin only)
+func (h *AdminHandler) UpdateEmployee(c *gin.Context) {
+	id, ok := employeeID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateEmployeeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	employee, err := h.authService.UpdateEmployee(c.Request.Context(), adminID, id, services.UpdateEmployeeRequest{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Phone:     req.Phone,
+		Role:      req.Role,
+	})
+	if err != nil {
+		respondError(c, employeeErrorStatus(err), err, "Failed to update employee")
+		return
+	}
+
+	c.JSON(http.StatusOK, employee)
+}
+
+// SetEmployeeNMLSRequest represents a request to set an employee's NMLS ID;
+// an empty ID clears it
+type SetEmployeeNMLSRequest struct {
+	NMLSID string `json:"nmlsId" binding:"omitempty,numeric,max=12"`
+}
+
+// SetEmployeeNMLS handles setting an employee's NMLS ID (admin only)
+func (h *AdminHandler) SetEmployeeNMLS(c *gin.Context) {
+	id, ok := employeeID(c)
+	if !ok {
+		return
+	}
+
+	var req SetEmployeeNMLSRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	employee, err := h.authService.SetEmployeeNMLSID(c.Request.Context(), id, req.NMLSID)
+	if err != nil {
+		respondError(c, employeeErrorStatus(err), err, "Failed to set NMLS ID")
+		return
+	}
+
+	c.JSON(http.StatusOK, employee)
+}
+
+// DeactivateEmployee handles deactivating an employee account, which also
+// revokes its sessions (admin only)
+func (h *AdminHandler) DeactivateEmployee(c *gin.Context) {
+	h.setEmployeeActive(c, false)
+}
+
+// ReactivateEmployee handles reactivating a deactivated employee account (admin only)
+func (h *AdminHandler) ReactivateEmployee(c *gin.Context) {
+	h.setEmployeeActive(c, true)
+}
+
+// setEmployeeActive deactivates or reactivates the employee named in the path
+func (h *AdminHandler) setEmployeeActive(c *gin.Context, active bool) {
+	id, ok := employeeID(c)
+	if !ok {
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	employee, err := h.authService.SetEmployeeActive(c.Request.Context(), adminID, id, active)
+	if err != nil {
+		respondError(c, employeeErrorStatus(err), err, "Failed to update employee")
+		return
+	}
+
+	c.JSON(http.StatusOK, employee)
+}
+
+// ForceEmployeePasswordReset handles requiring an employee to reset their
+// password; a reset link is emailed to them (admin only)
+func (h *AdminHandler) ForceEmployeePasswordReset(c *gin.Context) {
+	id, ok := employeeID(c)
+	if !ok {
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	if err := h.authService.ForceEmployeePasswordReset(c.Request.Context(), adminID, id); err != nil {
+		respondError(c, employeeErrorStatus(err), err, "Failed to require a password reset")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Password reset required, a reset link has been sent to the employee"})
+}
+
+// employeeID returns the employee ID from the path, answering 404 Not Found
+// if it is not a valid ID
+func employeeID(c *gin.Context) (string, bool) {
+	id := c.Param("id")
+	if !utils.IsUUID(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrEmployeeNotFound.Error()})
+		return "", false
+	}
+	return id, true
+}
+
+// employeeErrorStatus maps employee management errors to HTTP status codes
+func employeeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrEmployeeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrCannotModifyOwnAccount), errors.Is(err, services.ErrPasswordLoginDisabled):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
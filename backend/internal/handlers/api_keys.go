@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/middleware"
+	"taulen/backend/internal/redact"
+	"taulen/backend/internal/services"
+	"taulen/backend/internal/utils"
+)
+
+// APIKeyHandler handles the admin API key endpoints
+type APIKeyHandler struct {
+	apiKeys *services.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeys *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeys: apiKeys,
+	}
+}
+
+// CreateAPIKeyRequest represents a request to create an API key
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// ExpiresInDays is the key's lifetime; omitted applies the default. The
+	// bound keeps the lifetime from overflowing a time.Duration; the service
+	// enforces the configured maximum.
+	ExpiresInDays int `json:"expiresInDays" binding:"omitempty,min=1,max=36500"`
+}
+
+// CreateAPIKey handles creating an API key; the key is only returned in this
+// response (admin only)
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	key, err := h.apiKeys.Create(c.Request.Context(), adminID, services.CreateAPIKeyRequest{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresIn: time.Duration(req.ExpiresInDays) * 24 * time.Hour,
+	})
+	if err != nil {
+		respondError(c, apiKeyErrorStatus(err), err, "Failed to create API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys handles listing every API key, newest first (admin only)
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeys.List(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err, "Failed to list API keys")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"apiKeys": keys})
+}
+
+// GetAPIKey handles fetching an API key (admin only)
+func (h *APIKeyHandler) GetAPIKey(c *gin.Context) {
+	id, ok := apiKeyID(c)
+	if !ok {
+		return
+	}
+
+	key, err := h.apiKeys.Get(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, apiKeyErrorStatus(err), err, "Failed to load API key")
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+// RevokeAPIKey handles revoking an API key, which stops working immediately (admin only)
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, ok := apiKeyID(c)
+	if !ok {
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	key, err := h.apiKeys.Revoke(c.Request.Context(), adminID, id)
+	if err != nil {
+		respondError(c, apiKeyErrorStatus(err), err, "Failed to revoke API key")
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+// RotateAPIKey handles replacing an API key with a new one; the old key keeps
+// working for the rotation grace period (admin only)
+func (h *APIKeyHandler) RotateAPIKey(c *gin.Context) {
+	id, ok := apiKeyID(c)
+	if !ok {
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+	key, err := h.apiKeys.Rotate(c.Request.Context(), adminID, id)
+	if err != nil {
+		respondError(c, apiKeyErrorStatus(err), err, "Failed to rotate API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// apiKeyID returns the API key ID from the path, answering 404 Not Found if it
+// is not a valid ID
+func apiKeyID(c *gin.Context) (string, bool) {
+	id := c.Param("id")
+	if !utils.IsUUID(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrAPIKeyNotFound.Error()})
+		return "", false
+	}
+	return id, true
+}
+
+// apiKeyErrorStatus maps API key management errors to HTTP status codes
+func apiKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrAPIKeyRevoked):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidAPIKeyScope), errors.Is(err, services.ErrInvalidAPIKeyExpiry):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/repositories/memory"
+	"taulen/backend/internal/services"
+)
+
+func TestCreateAPIKeyExpiry(t *testing.T) {
+	t.Setenv("TAULEN_JWT_SECRET", "test-secret")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := NewAPIKeyHandler(services.NewAPIKeyService(cfg, memory.NewStore(), logger))
+	router := gin.New()
+	router.POST("/api-keys", h.CreateAPIKey)
+
+	create := func(expiresInDays int) (int, services.CreatedAPIKeyResponse) {
+		body := fmt.Sprintf(`{"name":"export","scopes":["deals:read"],"expiresInDays":%d}`, expiresInDays)
+		req := httptest.NewRequest(http.MethodPost, "/api-keys", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var key services.CreatedAPIKeyResponse
+		if w.Code == http.StatusCreated {
+			if err := json.Unmarshal(w.Body.Bytes(), &key); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, key
+	}
+
+	code, key := create(30)
+	if code != http.StatusCreated {
+		t.Fatalf("30 days: status %d", code)
+	}
+	expiresAt, err := time.Parse(time.RFC3339, key.ExpiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if until := time.Until(expiresAt); until < 29*24*time.Hour || until > 30*24*time.Hour {
+		t.Errorf("30 days: expires in %v", until)
+	}
+
+	// Lifetimes beyond the configured maximum, and ones that would overflow
+	// into the past, are rejected
+	maxDays := int(cfg.APIKeys.MaxExpiry / (24 * time.Hour))
+	for _, days := range []int{-1, maxDays + 1, 36501, 1 << 40, 106751 * 2} {
+		if code, _ := create(days); code != http.StatusBadRequest {
+			t.Errorf("%d days: status %d, want 400", days, code)
+		}
+	}
+}
@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/middleware"
+	"taulen/backend/internal/redact"
 	"taulen/backend/internal/services"
 )
 
@@ -24,12 +31,16 @@ func NewAuthHandler(authService *services.AuthService) *AuthHandler {
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req services.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
 		return
 	}
 
-	response, err := h.authService.Register(req)
+	response, err := h.authService.Register(c.Request.Context(), req)
 	if err != nil {
+		if errors.Is(err, services.ErrVerificationCodeRequired) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Registration requires a verification code", "code": "verification_code_required"})
+			return
+		}
 		statusCode := http.StatusInternalServerError
 		errorMsg := err.Error()
 		
@@ -41,7 +52,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 			statusCode = http.StatusConflict
 		}
 		
-		c.JSON(statusCode, gin.H{"error": errorMsg})
+		c.JSON(statusCode, gin.H{"error": redact.String(errorMsg)})
 		return
 	}
 
@@ -49,22 +60,47 @@ func (h *AuthHandler) Register(c *gin.Context) {
 }
 
 // SendVerificationCodeForRegister sends a verification code for registration
-// NOTE: 2FA is currently disabled - this endpoint is kept for future use
 func (h *AuthHandler) SendVerificationCodeForRegister(c *gin.Context) {
-	// 2FA is disabled - return message indicating it's not needed
-	c.JSON(http.StatusOK, gin.H{"message": "2FA is currently disabled. Registration does not require verification code."})
+	var req services.SendRegisterVerificationCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	if err := h.authService.SendRegisterVerificationCode(c.Request.Context(), req); err != nil {
+		if errors.Is(err, services.ErrTwoFactorDisabled) {
+			c.JSON(http.StatusOK, gin.H{"message": "2FA is currently disabled. Registration does not require verification code."})
+			return
+		}
+		if respondCodeCooldown(c, err) {
+			return
+		}
+		statusCode := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "already registered") {
+			statusCode = http.StatusConflict
+		}
+		c.JSON(statusCode, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent"})
 }
 
 // VerifyAndRegister handles registration with verification code
 func (h *AuthHandler) VerifyAndRegister(c *gin.Context) {
 	var req services.VerifyAndRegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
 		return
 	}
 
-	response, err := h.authService.VerifyAndRegister(req)
+	response, err := h.authService.VerifyAndRegister(c.Request.Context(), req)
 	if err != nil {
+		recordFailedCheck(c, err)
+		if errors.Is(err, services.ErrVerificationCodeRequired) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Registration requires a verification code", "code": "verification_code_required"})
+			return
+		}
 		statusCode := http.StatusInternalServerError
 		errorMsg := err.Error()
 		
@@ -75,61 +111,624 @@ func (h *AuthHandler) VerifyAndRegister(c *gin.Context) {
 			statusCode = http.StatusBadRequest
 		}
 		
-		c.JSON(statusCode, gin.H{"error": errorMsg})
+		c.JSON(statusCode, gin.H{"error": redact.String(errorMsg)})
 		return
 	}
 
 	c.JSON(http.StatusCreated, response)
 }
 
-// SendLoginVerificationCode sends a verification code for login
-// NOTE: 2FA is currently disabled - this endpoint is kept for future use
+// SendLoginVerificationCode sends a verification code for login. It responds
+// the same way whether or not the email is registered.
 func (h *AuthHandler) SendLoginVerificationCode(c *gin.Context) {
-	// 2FA is disabled - return message indicating it's not needed
-	c.JSON(http.StatusOK, gin.H{"message": "2FA is currently disabled. Login does not require verification code."})
+	var req services.SendLoginVerificationCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	if err := h.authService.SendLoginVerificationCode(c.Request.Context(), req); err != nil {
+		if errors.Is(err, services.ErrTwoFactorDisabled) {
+			c.JSON(http.StatusOK, gin.H{"message": "2FA is currently disabled. Login does not require verification code."})
+			return
+		}
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a verification code has been sent"})
 }
 
-// Login handles user login (2FA is currently disabled)
+// Login handles user login. Accounts using MFA get 401 with an mfaToken for
+// the second step; other accounts send a verificationCode as the 2FA mode requires
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req services.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	response, err := h.authService.Login(c.Request.Context(), req)
+	if err != nil {
+		recordFailedCheck(c, err)
+		if respondAccountLocked(c, err) || respondAccountBlocked(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrVerificationCodeRequired) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": "verification_code_required"})
+			return
+		}
+		var mfa *services.MFARequiredError
+		if errors.As(err, &mfa) {
+			code := "mfa_required"
+			if mfa.SetupRequired {
+				code = "mfa_setup_required"
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":    err.Error(),
+				"code":     code,
+				"mfaToken": mfa.Token,
+			})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// recordFailedCheck counts err towards the client's failed login attempts if
+// it rejected a password, code or token, or a login to a locked account
+func recordFailedCheck(c *gin.Context, err error) {
+	var locked *services.AccountLockedError
+	switch {
+	case errors.Is(err, services.ErrInvalidCredentials), errors.Is(err, services.ErrInvalidVerificationCode),
+		errors.Is(err, services.ErrInvalidMFACode), errors.Is(err, services.ErrInvalidMFAToken),
+		errors.Is(err, services.ErrInvalidResetToken), errors.Is(err, services.ErrInvalidEmailVerificationToken),
+		errors.As(err, &locked):
+		middleware.RecordFailure(c)
+	}
+}
+
+// respondAccountLocked answers 423 Locked if err is an AccountLockedError and
+// reports whether it did
+func respondAccountLocked(c *gin.Context, err error) bool {
+	var locked *services.AccountLockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	retryAfter := int(math.Ceil(time.Until(locked.Until).Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusLocked, gin.H{
+		"error":       err.Error(),
+		"code":        "account_locked",
+		"lockedUntil": locked.Until.UTC(),
+	})
+	return true
+}
+
+// respondAccountBlocked answers 403 Forbidden if err reports that an employee
+// account was deactivated or must reset its password, and reports whether it did
+func respondAccountBlocked(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrAccountDeactivated):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "account_deactivated"})
+	case errors.Is(err, services.ErrPasswordResetRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "password_reset_required"})
+	case errors.Is(err, services.ErrPasswordLoginDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "sso_required"})
+	default:
+		return false
+	}
+	return true
+}
+
+// GetTwoFactorStatus returns the code-based 2FA state of the caller's account
+func (h *AuthHandler) GetTwoFactorStatus(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetRole(c)
+
+	response, err := h.authService.GetTwoFactorStatus(c.Request.Context(), userID, role)
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SendTwoFactorCode emails the caller a code for opting in to or out of login codes
+func (h *AuthHandler) SendTwoFactorCode(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetRole(c)
+
+	if err := h.authService.SendTwoFactorCode(c.Request.Context(), userID, role); err != nil {
+		if respondCodeCooldown(c, err) {
+			return
+		}
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent"})
+}
+
+// EnableTwoFactor opts the caller in to login codes after checking a code
+func (h *AuthHandler) EnableTwoFactor(c *gin.Context) {
+	h.setTwoFactorEnabled(c, true)
+}
+
+// DisableTwoFactor opts the caller out of login codes after checking a code
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	h.setTwoFactorEnabled(c, false)
+}
+
+func (h *AuthHandler) setTwoFactorEnabled(c *gin.Context, enabled bool) {
+	var req services.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+	userID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetRole(c)
+
+	response, err := h.authService.SetTwoFactorEnabled(c.Request.Context(), userID, role, enabled, req)
+	if err != nil {
+		recordFailedCheck(c, err)
+		if respondAccountLocked(c, err) {
+			return
+		}
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// twoFactorErrorStatus maps code-based 2FA service errors to HTTP status codes
+func twoFactorErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidVerificationCode):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrTwoFactorNotOptional):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrAccountNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondCodeCooldown answers 429 Too Many Requests if err reports that a
+// verification code was sent too recently, and reports whether it did
+func respondCodeCooldown(c *gin.Context, err error) bool {
+	if !errors.Is(err, services.ErrVerificationCodeCooldown) {
+		return false
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "code": "verification_code_cooldown"})
+	return true
+}
+
+// respondEmailNotVerified answers 403 Forbidden if err reports that the
+// borrower's email has to be verified first, and reports whether it did
+func respondEmailNotVerified(c *gin.Context, err error) bool {
+	if !errors.Is(err, services.ErrEmailNotVerified) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "email_not_verified"})
+	return true
+}
+
+// LoginMFA completes a login with a TOTP or backup code
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var req services.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	response, err := h.authService.CompleteMFALogin(c.Request.Context(), req)
+	if err != nil {
+		recordFailedCheck(c, err)
+		if respondAccountLocked(c, err) || respondAccountBlocked(c, err) {
+			return
+		}
+		c.JSON(mfaErrorStatus(err), gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// LoginMFASetup starts MFA enrollment for an account that must enroll to log in
+func (h *AuthHandler) LoginMFASetup(c *gin.Context) {
+	var req services.MFASetupLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	response, err := h.authService.StartMFALoginSetup(c.Request.Context(), req)
+	if err != nil {
+		recordFailedCheck(c, err)
+		c.JSON(mfaErrorStatus(err), gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetMFAStatus returns the MFA state of the caller's account
+func (h *AuthHandler) GetMFAStatus(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetRole(c)
+
+	response, err := h.authService.GetMFAStatus(c.Request.Context(), userID, role)
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SetupMFA generates a TOTP secret for the caller's account
+func (h *AuthHandler) SetupMFA(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetRole(c)
+
+	response, err := h.authService.SetupMFA(c.Request.Context(), userID, role)
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// EnableMFA confirms the TOTP secret with a code and turns on MFA
+func (h *AuthHandler) EnableMFA(c *gin.Context) {
+	var req services.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
 		return
 	}
+	userID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetRole(c)
 
-	response, err := h.authService.Login(req)
+	response, err := h.authService.EnableMFA(c.Request.Context(), userID, role, req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		recordFailedCheck(c, err)
+		if respondAccountLocked(c, err) {
+			return
+		}
+		c.JSON(mfaErrorStatus(err), gin.H{"error": redact.Error(err)})
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// DisableMFA turns off MFA after checking a TOTP or backup code
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	var req services.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+	userID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetRole(c)
+
+	if err := h.authService.DisableMFA(c.Request.Context(), userID, role, req); err != nil {
+		recordFailedCheck(c, err)
+		if respondAccountLocked(c, err) {
+			return
+		}
+		c.JSON(mfaErrorStatus(err), gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Multi-factor authentication disabled"})
+}
+
+// RegenerateBackupCodes replaces the caller's backup codes after checking a TOTP code
+func (h *AuthHandler) RegenerateBackupCodes(c *gin.Context) {
+	var req services.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+	userID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetRole(c)
+
+	response, err := h.authService.RegenerateBackupCodes(c.Request.Context(), userID, role, req)
+	if err != nil {
+		recordFailedCheck(c, err)
+		if respondAccountLocked(c, err) {
+			return
+		}
+		c.JSON(mfaErrorStatus(err), gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// mfaErrorStatus maps MFA service errors to HTTP status codes
+func mfaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidMFAToken), errors.Is(err, services.ErrInvalidMFACode):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrMFAAlreadyEnabled):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrMFANotEnabled), errors.Is(err, services.ErrMFASetupNotStarted):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrMFARequired), errors.Is(err, services.ErrAccountDeactivated),
+		errors.Is(err, services.ErrPasswordResetRequired):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrAccountNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // Refresh handles token refresh
 func (h *AuthHandler) Refresh(c *gin.Context) {
 	var req services.RefreshRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	response, err := h.authService.RefreshToken(c.Request.Context(), req)
+	if err != nil {
+		if respondAccountBlocked(c, err) {
+			return
+		}
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			statusCode = http.StatusUnauthorized
+		}
+		c.JSON(statusCode, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RequestPasswordReset starts a password reset. It always responds the same
+// way so that it does not reveal whether the email is registered.
+func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
+	var req services.RequestPasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
 		return
 	}
 
-	response, err := h.authService.RefreshToken(req)
+	if err := h.authService.RequestPasswordReset(c.Request.Context(), req); err != nil {
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password reset request"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If an account exists for this email, a password reset link has been sent"})
+}
+
+// ConfirmPasswordReset sets a new password using a reset token
+func (h *AuthHandler) ConfirmPasswordReset(c *gin.Context) {
+	var req services.ConfirmPasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	if err := h.authService.ConfirmPasswordReset(c.Request.Context(), req); err != nil {
+		recordFailedCheck(c, err)
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in with your new password"})
+}
+
+// ConfirmEmail verifies a borrower's email using the token from a verification link
+func (h *AuthHandler) ConfirmEmail(c *gin.Context) {
+	var req services.ConfirmEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	if err := h.authService.ConfirmEmail(c.Request.Context(), req); err != nil {
+		recordFailedCheck(c, err)
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidEmailVerificationToken) {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address verified"})
+}
+
+// StartSSOLogin starts an employee login through the identity provider
+func (h *AuthHandler) StartSSOLogin(c *gin.Context) {
+	response, err := h.authService.StartSSOLogin(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.JSON(ssoErrorStatus(err), gin.H{"error": redact.Error(err)})
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// Logout handles user logout
+// CompleteSSOLogin completes an employee login with the code the identity provider sent back
+func (h *AuthHandler) CompleteSSOLogin(c *gin.Context) {
+	var req services.SSOCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	response, err := h.authService.CompleteSSOLogin(c.Request.Context(), req)
+	if err != nil {
+		if respondAccountBlocked(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrSSONotAuthorized) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "sso_not_authorized"})
+			return
+		}
+		c.JSON(ssoErrorStatus(err), gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ssoErrorStatus maps SSO errors to HTTP status codes
+func ssoErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrSSODisabled):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidSSOLogin):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrSSOAccountConflict):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrSSOUnavailable):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// StartDelegation issues the calling employee a delegated access token for
+// editing the application on behalf of one of its borrowers. Only employees
+// assigned to the application and admins may act on a borrower's behalf.
+func (h *AuthHandler) StartDelegation(c *gin.Context) {
+	relation, _ := middleware.GetDealRelation(c)
+	if relation != services.DealRelationAssignedEmployee && relation != services.DealRelationAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Only employees assigned to the application can act on behalf of its borrowers",
+			"code":  middleware.ErrCodeForbidden,
+		})
+		return
+	}
+
+	var req services.DelegationRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	employeeID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetRole(c)
+	response, err := h.authService.StartDelegation(c.Request.Context(), employeeID, role, c.Param("id"), req)
+	if err != nil {
+		if respondAccountBlocked(c, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrDealNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrDelegationBorrower):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ResendEmailVerification emails a new verification link to the calling borrower
+func (h *AuthHandler) ResendEmailVerification(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetRole(c)
+
+	if err := h.authService.ResendEmailVerification(c.Request.Context(), userID, role); err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrNotBorrowerAccount):
+			statusCode = http.StatusForbidden
+		case errors.Is(err, services.ErrEmailAlreadyVerified):
+			statusCode = http.StatusConflict
+		case errors.Is(err, services.ErrAccountNotFound):
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+// Logout revokes the refresh session of the access token used to call it.
+// The access token itself stays valid until it expires.
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// Since we're using stateless JWT tokens, logout is handled client-side
-	// by discarding the tokens. This endpoint is here for consistency.
+	if sessionID, ok := middleware.GetSessionID(c); ok {
+		if err := h.authService.Logout(c.Request.Context(), sessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": redact.Error(err)})
+			return
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
+// ListSessions returns the caller's active sessions, one per signed-in device
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	sessionID, _ := middleware.GetSessionID(c)
+
+	sessions, err := h.authService.ListSessions(c.Request.Context(), userID, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession signs the caller out of one of their sessions. Access tokens
+// already issued to it stay valid until they expire.
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	if err := h.authService.RevokeSession(c.Request.Context(), userID, c.Param("id")); err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, services.ErrSessionNotFound) {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions signs the caller out of every session but the one of the
+// access token used to call it
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	sessionID, _ := middleware.GetSessionID(c)
+
+	if err := h.authService.RevokeOtherSessions(c.Request.Context(), userID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked"})
+}
+
 // GetMe returns current user information
 func (h *AuthHandler) GetMe(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -139,9 +738,28 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 	}
 
 	email, _ := c.Get("email")
+	role, _ := middleware.GetRole(c)
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":    userID,
-		"email": email,
-	})
+	me := gin.H{
+		"id":          userID,
+		"email":       email,
+		"role":        role,
+		"permissions": role.Permissions(),
+	}
+	// A delegated token names the employee acting on the borrower's behalf
+	if delegation, ok := middleware.GetDelegation(c); ok {
+		me["delegation"] = gin.H{
+			"actorId":   delegation.ActorID,
+			"actorRole": delegation.ActorRole,
+			"dealId":    delegation.DealID,
+		}
+	}
+	c.JSON(http.StatusOK, me)
+}
+
+// JWKS serves the public keys that verify our tokens as a JSON Web Key Set,
+// for other services validating tokens issued here
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.authService.GetJWTManager().JWKS())
 }
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/redact"
+	"taulen/backend/internal/services"
+)
+
+// respondError answers status with err's message. Server errors can carry
+// storage details, so for those the client gets message instead and err is
+// attached to the request for the request log.
+func respondError(c *gin.Context, status int, err error, message string) {
+	if status >= http.StatusInternalServerError {
+		_ = c.Error(err)
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	c.JSON(status, gin.H{"error": redact.Error(err)})
+}
+
+// respondInvalid answers 400 Bad Request if err is a ValidationError and
+// reports whether it did
+func respondInvalid(c *gin.Context, err error) bool {
+	var invalid *services.ValidationError
+	if !errors.As(err, &invalid) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Error()})
+	return true
+}
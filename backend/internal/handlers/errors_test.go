@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/services"
+)
+
+func TestRespondError(t *testing.T) {
+	storage := errors.New(`pq: duplicate key value violates unique constraint "borrower_email_key"`)
+	tests := []struct {
+		name     string
+		status   int
+		err      error
+		body     string
+		recorded bool
+	}{
+		{"server error", http.StatusInternalServerError, storage, `{"error":"Failed to save"}`, true},
+		{"client error", http.StatusNotFound, services.ErrEmployeeNotFound, `{"error":"employee not found"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			respondError(c, tt.status, tt.err, "Failed to save")
+
+			if w.Code != tt.status || w.Body.String() != tt.body {
+				t.Errorf("response = %d %s, want %d %s", w.Code, w.Body, tt.status, tt.body)
+			}
+			// Server errors reach the request log instead of the client
+			if recorded := len(c.Errors) == 1 && errors.Is(c.Errors[0].Err, tt.err); recorded != tt.recorded {
+				t.Errorf("recorded errors = %v", c.Errors)
+			}
+		})
+	}
+}
+
+func TestRespondInvalid(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	invalid := fmt.Errorf("failed to save co-borrower data: %w", &services.ValidationError{Message: "co-borrower phone is required"})
+	if !respondInvalid(c, invalid) || w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"co-borrower phone is required"`) ||
+		strings.Contains(w.Body.String(), "failed to save") {
+		t.Fatalf("validation error: %d %s", w.Code, w.Body)
+	}
+
+	other, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if respondInvalid(other, errors.New("storage failure")) {
+		t.Fatal("answered 400 for a storage failure")
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"taulen/backend/internal/migrations"
+)
+
+// Dependency status values reported by the readiness endpoint
+const (
+	dependencyUp   = "up"
+	dependencyDown = "down"
+)
+
+// errNotConnected is reported for a required dependency that was never connected
+var errNotConnected = errors.New("not connected")
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	db       *sql.DB
+	mongo    *mongo.Client
+	migrator *migrations.Migrator
+	required bool
+	timeout  time.Duration
+	logger   *slog.Logger
+}
+
+// NewHealthHandler creates a health handler probing the given connections.
+// When required is false (e.g. the in-memory store) the databases are not
+// needed to serve traffic and readiness does not depend on them.
+func NewHealthHandler(db *sql.DB, mongoClient *mongo.Client, required bool, timeout time.Duration, logger *slog.Logger) *HealthHandler {
+	h := &HealthHandler{
+		db:       db,
+		mongo:    mongoClient,
+		required: required,
+		timeout:  timeout,
+		logger:   logger,
+	}
+	if db != nil {
+		// Only fails for a nil database or broken embedded files, both caught at startup
+		h.migrator, _ = migrations.New(db, logger)
+	}
+	return h
+}
+
+// DependencyStatus is the readiness result for a single dependency
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	Required  bool    `json:"required"`
+	LatencyMS float64 `json:"latencyMs"`
+}
+
+// MigrationStatus reports the schema version of the PostgreSQL database
+type MigrationStatus struct {
+	Version int64 `json:"version"`
+	Latest  int64 `json:"latest"`
+}
+
+// ReadinessResponse is the body returned by the readiness endpoint
+type ReadinessResponse struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+	Migrations   *MigrationStatus            `json:"migrations,omitempty"`
+}
+
+// Live reports that the process is running; it never touches dependencies, so a
+// database outage does not get healthy pods restarted
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready pings PostgreSQL and MongoDB and reports per-dependency status, latency
+// and the schema migration version. It responds 503 when a required dependency
+// is unavailable so the pod stops receiving traffic.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	defer cancel()
+
+	response := ReadinessResponse{
+		Status:       "ready",
+		Dependencies: make(map[string]DependencyStatus),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	check := func(name string, ping func(ctx context.Context) error) {
+		defer wg.Done()
+		start := time.Now()
+		err := ping(ctx)
+		status := DependencyStatus{
+			Status:    dependencyUp,
+			Required:  h.required,
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err != nil {
+			status.Status = dependencyDown
+			h.logger.WarnContext(ctx, "readiness check failed", "dependency", name, "error", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		response.Dependencies[name] = status
+		if err != nil && h.required {
+			response.Status = "unavailable"
+		}
+	}
+
+	if h.db != nil || h.required {
+		wg.Add(1)
+		go check("postgres", h.pingPostgres)
+	}
+	if h.mongo != nil || h.required {
+		wg.Add(1)
+		go check("mongodb", h.pingMongo)
+	}
+	wg.Wait()
+
+	if h.migrator != nil && response.Dependencies["postgres"].Status == dependencyUp {
+		version, err := migrations.Version(ctx, h.db)
+		if err != nil {
+			h.logger.WarnContext(ctx, "readiness: failed to read migration version", "error", err)
+		} else {
+			response.Migrations = &MigrationStatus{Version: version, Latest: h.migrator.Latest()}
+		}
+	}
+
+	statusCode := http.StatusOK
+	if response.Status != "ready" {
+		statusCode = http.StatusServiceUnavailable
+	}
+	c.JSON(statusCode, response)
+}
+
+// pingPostgres checks the PostgreSQL connection
+func (h *HealthHandler) pingPostgres(ctx context.Context) error {
+	if h.db == nil {
+		return errNotConnected
+	}
+	return h.db.PingContext(ctx)
+}
+
+// pingMongo checks the MongoDB connection
+func (h *HealthHandler) pingMongo(ctx context.Context) error {
+	if h.mongo == nil {
+		return errNotConnected
+	}
+	return h.mongo.Ping(ctx, nil)
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/migrations"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// schemaDB is a database driver that answers only the migration version
+// queries of the readiness check, reporting the given schema version
+type schemaDB struct {
+	version int64
+}
+
+func (d schemaDB) Connect(context.Context) (driver.Conn, error) { return d, nil }
+func (d schemaDB) Driver() driver.Driver                        { return nil }
+
+func (d schemaDB) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("statements are not supported")
+}
+func (d schemaDB) Close() error { return nil }
+func (d schemaDB) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (d schemaDB) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if strings.Contains(query, "to_regclass") {
+		return &row{value: true}, nil
+	}
+	return &row{value: d.version}, nil
+}
+
+// row is a single-column, single-row result
+type row struct {
+	value driver.Value
+	read  bool
+}
+
+func (r *row) Columns() []string { return []string{"value"} }
+func (r *row) Close() error      { return nil }
+
+func (r *row) Next(dest []driver.Value) error {
+	if r.read {
+		return io.EOF
+	}
+	r.read = true
+	dest[0] = r.value
+	return nil
+}
+
+// ready calls the readiness endpoint and decodes its response
+func ready(t *testing.T, h *HealthHandler) (int, ReadinessResponse) {
+	t.Helper()
+	router := gin.New()
+	router.GET("/readyz", h.Ready)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var resp ReadinessResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	return w.Code, resp
+}
+
+func TestReady(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	closed := sql.OpenDB(schemaDB{})
+	closed.Close()
+	up := sql.OpenDB(schemaDB{version: 4})
+	defer up.Close()
+
+	migrator, err := migrations.New(up, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := migrator.Latest()
+
+	tests := []struct {
+		name       string
+		db         *sql.DB
+		required   bool
+		code       int
+		dependency map[string]DependencyStatus
+		migrations *MigrationStatus
+	}{
+		{
+			name:       "optional database down",
+			db:         closed,
+			code:       http.StatusOK,
+			dependency: map[string]DependencyStatus{"postgres": {Status: dependencyDown}},
+		},
+		{
+			name:       "optional database up",
+			db:         up,
+			code:       http.StatusOK,
+			dependency: map[string]DependencyStatus{"postgres": {Status: dependencyUp}},
+			migrations: &MigrationStatus{Version: 4, Latest: latest},
+		},
+		{
+			name:     "required database down",
+			db:       closed,
+			required: true,
+			code:     http.StatusServiceUnavailable,
+			dependency: map[string]DependencyStatus{
+				"postgres": {Status: dependencyDown, Required: true},
+				"mongodb":  {Status: dependencyDown, Required: true},
+			},
+		},
+		{
+			// MongoDB was never connected, so the pod is still not ready
+			name:     "required database up",
+			db:       up,
+			required: true,
+			code:     http.StatusServiceUnavailable,
+			dependency: map[string]DependencyStatus{
+				"postgres": {Status: dependencyUp, Required: true},
+				"mongodb":  {Status: dependencyDown, Required: true},
+			},
+			migrations: &MigrationStatus{Version: 4, Latest: latest},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := ready(t, NewHealthHandler(tt.db, nil, tt.required, time.Second, logger))
+			if code != tt.code {
+				t.Errorf("status code = %d, want %d", code, tt.code)
+			}
+			if len(resp.Dependencies) != len(tt.dependency) {
+				t.Errorf("dependencies = %+v, want %+v", resp.Dependencies, tt.dependency)
+			}
+			for name, want := range tt.dependency {
+				got := resp.Dependencies[name]
+				if got.Status != want.Status || got.Required != want.Required {
+					t.Errorf("%s = %+v, want %+v", name, got, want)
+				}
+			}
+			switch {
+			case tt.migrations == nil && resp.Migrations != nil:
+				t.Errorf("migrations = %+v, want none", resp.Migrations)
+			case tt.migrations != nil && (resp.Migrations == nil || *resp.Migrations != *tt.migrations):
+				t.Errorf("migrations = %+v, want %+v", resp.Migrations, tt.migrations)
+			}
+		})
+	}
+}
@@ -1,23 +1,28 @@
 package handlers
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"taulen/backend/internal/middleware"
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/redact"
 	"taulen/backend/internal/services"
 )
 
 // URLAHandler handles URLA application HTTP requests
 type URLAHandler struct {
 	urlaService *services.URLAService
+	logger      *slog.Logger
 }
 
 // NewURLAHandler creates a new URLA handler
-func NewURLAHandler(urlaService *services.URLAService) *URLAHandler {
+func NewURLAHandler(urlaService *services.URLAService, logger *slog.Logger) *URLAHandler {
 	return &URLAHandler{
 		urlaService: urlaService,
+		logger:      logger,
 	}
 }
 
@@ -32,19 +37,19 @@ func (h *URLAHandler) CreateApplication(c *gin.Context) {
 
 	var req services.CreateApplicationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
 		return
 	}
 
 	// Both borrowers and employees now use UUID strings
 	// Try to create application for borrower first (if userID is a borrower)
 	// If that fails, try as employee
-	response, err := h.urlaService.CreateApplicationForBorrower(userID, req)
+	response, err := h.urlaService.CreateApplicationForBorrower(c.Request.Context(), userID, req)
 	if err != nil {
 		// If borrower creation fails, try as employee
-		response, err = h.urlaService.CreateApplication(userID, req)
+		response, err = h.urlaService.CreateApplication(c.Request.Context(), userID, req)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondError(c, http.StatusInternalServerError, err, "Failed to create application")
 			return
 		}
 	}
@@ -60,9 +65,9 @@ func (h *URLAHandler) GetApplication(c *gin.Context) {
 		return
 	}
 
-	application, err := h.urlaService.GetApplication(idStr)
+	application, err := h.urlaService.GetApplication(c.Request.Context(), idStr)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(http.StatusNotFound, gin.H{"error": redact.Error(err)})
 		return
 	}
 
@@ -81,13 +86,38 @@ func (h *URLAHandler) UpdateApplicationStatus(c *gin.Context) {
 		Status string `json:"status" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
 		return
 	}
 
-	err := h.urlaService.UpdateApplicationStatus(idStr, req.Status)
+	// Applicants may only submit; any other transition is an employee decision
+	required := rbac.PermApplicationsUpdateStatus
+	if req.Status == "submitted" && !middleware.HasPermission(c, required) {
+		required = rbac.PermApplicationsSubmit
+	}
+	if !middleware.HasPermission(c, required) {
+		middleware.Forbidden(c, required)
+		return
+	}
+
+	err := h.urlaService.UpdateApplicationStatus(c.Request.Context(), idStr, requestEditor(c), req.Status)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if respondEmailNotVerified(c, err) || respondDelegatedConsent(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrDealNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+			return
+		}
+		// API key scopes never grant submitting
+		if errors.Is(err, services.ErrIntegrationSubmit) {
+			middleware.Forbidden(c, rbac.PermApplicationsSubmit)
+			return
+		}
+		if respondInvalid(c, err) {
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err, "Failed to update application status")
 		return
 	}
 
@@ -102,74 +132,33 @@ func (h *URLAHandler) SaveApplication(c *gin.Context) {
 		return
 	}
 
-	var req map[string]interface{}
+	var req services.SaveApplicationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
 		return
 	}
 
-	// Extract nextFormStep if provided
-	var nextFormStep string
-	if val, ok := req["nextFormStep"].(string); ok {
-		nextFormStep = val
-	}
-
-	// Save borrower information if provided
-	if borrowerData, ok := req["borrower"].(map[string]interface{}); ok {
-		log.Printf("SaveApplication: Saving borrower data for deal %s: %+v", idStr, borrowerData)
-		err := h.urlaService.SaveBorrowerData(idStr, borrowerData, nextFormStep)
-		if err != nil {
-			log.Printf("SaveApplication: Error saving borrower data: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save borrower data: " + err.Error()})
-			return
-		}
-		log.Printf("SaveApplication: Successfully saved borrower data for deal %s", idStr)
-	} else {
-		log.Printf("SaveApplication: No borrower data provided in request for deal %s", idStr)
-	}
-
-	// Save co-borrower information if provided
-	if coBorrowerData, ok := req["coBorrower"].(map[string]interface{}); ok {
-		log.Printf("SaveApplication: Saving co-borrower data: %+v", coBorrowerData)
-		err := h.urlaService.SaveCoBorrowerData(idStr, coBorrowerData, nextFormStep)
-		if err != nil {
-			log.Printf("SaveApplication: Error saving co-borrower data: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save co-borrower data: " + err.Error()})
-			return
-		}
-	}
-
-	// Save loan information if provided
-	if loanData, ok := req["loan"].(map[string]interface{}); ok {
-		err := h.urlaService.SaveLoanData(idStr, loanData, nextFormStep)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save loan data: " + err.Error()})
-			return
-		}
-	}
-
-	// If only nextFormStep is provided (no borrower, coBorrower, or loan data), update the form step directly
-	if nextFormStep != "" && req["borrower"] == nil && req["coBorrower"] == nil && req["loan"] == nil {
-		err := h.urlaService.UpdateCurrentFormStep(idStr, nextFormStep)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update form step: " + err.Error()})
+	// Save all provided sections and the form step as one unit of work
+	if err := h.urlaService.SaveApplication(c.Request.Context(), idStr, requestEditor(c), req); err != nil {
+		if respondEmailNotVerified(c, err) || respondDelegatedConsent(c, err) || respondInvalid(c, err) {
 			return
 		}
+		respondError(c, http.StatusInternalServerError, err, "Failed to save application")
+		return
 	}
 
 	// TODO: Save other sections (property, employment, income, assets, liabilities) as needed
 
 	// Return success response with application data
 	// Fetch updated application to return to frontend
-	application, err := h.urlaService.GetApplication(idStr)
+	application, err := h.urlaService.GetApplication(c.Request.Context(), idStr)
 	if err != nil {
-		log.Printf("SaveApplication: Warning - failed to fetch updated application: %v", err)
+		h.logger.WarnContext(c.Request.Context(), "SaveApplication: failed to fetch updated application", "error", err)
 		// Still return success, but without application data
 		c.JSON(http.StatusOK, gin.H{"message": "Application saved successfully"})
 		return
 	}
-	
-	log.Printf("SaveApplication: Successfully saved and returning application data for deal %s", idStr)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Application saved successfully", "data": application})
 }
 
@@ -181,7 +170,7 @@ func (h *URLAHandler) GetApplicationProgress(c *gin.Context) {
 		return
 	}
 
-	progress, err := h.urlaService.GetDealProgress(idStr)
+	progress, err := h.urlaService.GetDealProgress(c.Request.Context(), idStr)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Progress not found"})
 		return
@@ -203,13 +192,13 @@ func (h *URLAHandler) UpdateApplicationProgressSection(c *gin.Context) {
 		Complete bool   `json:"complete"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
 		return
 	}
 
-	err := h.urlaService.UpdateDealProgressSection(idStr, req.Section, req.Complete)
+	err := h.urlaService.UpdateDealProgressSection(c.Request.Context(), idStr, requestEditor(c), req.Section, req.Complete)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err, "Failed to update progress")
 		return
 	}
 
@@ -228,81 +217,191 @@ func (h *URLAHandler) UpdateApplicationProgressNotes(c *gin.Context) {
 		Notes string `json:"notes"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
 		return
 	}
 
-	err := h.urlaService.UpdateDealProgressNotes(idStr, req.Notes)
+	err := h.urlaService.UpdateDealProgressNotes(c.Request.Context(), idStr, requestEditor(c), req.Notes)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err, "Failed to update notes")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Notes updated successfully"})
 }
 
+// GetApplicationEdits handles listing who wrote each section of an application,
+// including sections an employee entered on a borrower's behalf
+func (h *URLAHandler) GetApplicationEdits(c *gin.Context) {
+	edits, err := h.urlaService.GetApplicationEdits(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err, "Failed to load application edits")
+		return
+	}
+
+	c.JSON(http.StatusOK, edits)
+}
+
+// GetApplicationAssignees handles listing the employees assigned to an application
+func (h *URLAHandler) GetApplicationAssignees(c *gin.Context) {
+	assignees, err := h.urlaService.ListAssignees(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err, "Failed to load assignees")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assignees": assignees})
+}
+
+// AssignApplicationEmployee handles assigning an employee to an application,
+// which gives the employee access to it
+func (h *URLAHandler) AssignApplicationEmployee(c *gin.Context) {
+	var req services.AssignEmployeeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+	userID, _ := middleware.GetUserID(c)
+
+	assignees, err := h.urlaService.AssignEmployee(c.Request.Context(), c.Param("id"), userID, req.UserID)
+	if err != nil {
+		if errors.Is(err, services.ErrAssigneeNotEmployee) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err, "Failed to assign employee")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assignees": assignees})
+}
+
+// UnassignApplicationEmployee handles removing an employee from an application
+func (h *URLAHandler) UnassignApplicationEmployee(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	if err := h.urlaService.UnassignEmployee(c.Request.Context(), c.Param("id"), userID, c.Param("userId")); err != nil {
+		respondError(c, http.StatusInternalServerError, err, "Failed to unassign employee")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// requestEditor returns who is making a change with the request: the
+// authenticated account and, for delegated tokens, the acting employee
+func requestEditor(c *gin.Context) services.Editor {
+	userID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetRole(c)
+	delegation, _ := middleware.GetDelegation(c)
+	return services.NewEditor(userID, role, delegation.ActorID)
+}
+
+// respondDelegatedConsent answers 403 if err is ErrDelegatedConsent or
+// ErrDelegatedEmailChange and reports whether it did
+func respondDelegatedConsent(c *gin.Context, err error) bool {
+	if !errors.Is(err, services.ErrDelegatedConsent) && !errors.Is(err, services.ErrDelegatedEmailChange) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": middleware.ErrCodeDelegationNotAllowed})
+	return true
+}
+
 // GetMyApplications handles getting applications for the current user (employee or applicant)
 func (h *URLAHandler) GetMyApplications(c *gin.Context) {
-	// Force log flush immediately
-	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
-	log.Printf("=== GetMyApplications: START ===")
-	
+	ctx := c.Request.Context()
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
-		log.Printf("GetMyApplications: No user ID found in context")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	log.Printf("GetMyApplications: Processing request for user ID: %s", userID)
-	log.Printf("GetMyApplications: About to call GetApplicationsByBorrower for user %s", userID)
+	// Employees see the applications they are assigned to
+	if role, _ := middleware.GetRole(c); role.IsEmployee() {
+		applications, err := h.urlaService.GetApplicationsByEmployee(ctx, userID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err, "Failed to load applications")
+			return
+		}
+		if applications == nil {
+			applications = []services.ApplicationResponse{}
+		}
+		c.JSON(http.StatusOK, gin.H{"applications": applications})
+		return
+	}
 
 	// Both borrowers and employees now use UUID strings
 	// Try to get borrower applications first
-	applications, err := h.urlaService.GetApplicationsByBorrower(userID)
-	log.Printf("GetMyApplications: GetApplicationsByBorrower returned %d applications, error: %v", len(applications), err)
+	applications, err := h.urlaService.GetApplicationsByBorrower(ctx, userID)
 	if err != nil {
-		log.Printf("GetMyApplications: Error fetching borrower applications for user %s: %v", userID, err)
 		// If borrower lookup fails with a real error (not just no rows), try as employee
 		// But first check if it's a "no rows" case - that's not an error, just empty result
-		employeeApplications, employeeErr := h.urlaService.GetApplicationsByEmployee(userID)
+		employeeApplications, employeeErr := h.urlaService.GetApplicationsByEmployee(ctx, userID)
 		if employeeErr != nil {
-			log.Printf("GetMyApplications: Error fetching employee applications for user %s: %v", userID, employeeErr)
 			// Return empty array - user might not have any applications yet
-			log.Printf("GetMyApplications: Returning empty applications array for user %s", userID)
+			h.logger.WarnContext(ctx, "GetMyApplications: returning no applications after borrower and employee lookups failed",
+				"borrower_error", err, "employee_error", employeeErr)
 			c.JSON(http.StatusOK, gin.H{"applications": []services.ApplicationResponse{}})
 			return
 		}
 		// If employee lookup succeeds, use those results
 		// But log a warning since this suggests the user might be misidentified
 		if len(employeeApplications) > 0 {
-			log.Printf("GetMyApplications: Found employee applications for user %s, but borrower lookup failed", userID)
+			h.logger.WarnContext(ctx, "GetMyApplications: found employee applications after borrower lookup failed", "error", err)
 		}
 		applications = employeeApplications
 	}
 
 	// Ensure applications is never nil - convert to empty slice if needed
 	if applications == nil {
-		log.Printf("GetMyApplications: applications is nil, converting to empty slice")
 		applications = []services.ApplicationResponse{}
 	}
 
-	log.Printf("GetMyApplications: Returning %d applications for user %s", len(applications), userID)
 	c.JSON(http.StatusOK, gin.H{"applications": applications})
-	log.Printf("=== GetMyApplications: END ===")
+}
+
+// ExportApplicationsQuery represents the query parameters of an application export
+type ExportApplicationsQuery struct {
+	Page     int `form:"page" binding:"omitempty,min=1"`
+	PageSize int `form:"pageSize" binding:"omitempty,min=1,max=500"`
+}
+
+// ExportApplications handles listing every application a page at a time, for
+// reporting and LOS sync integrations
+func (h *URLAHandler) ExportApplications(c *gin.Context) {
+	var query ExportApplicationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	export, err := h.urlaService.ExportApplications(c.Request.Context(), query.Page, query.PageSize)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err, "Failed to export applications")
+		return
+	}
+
+	c.JSON(http.StatusOK, export)
 }
 
 // SendVerificationCode handles sending verification code via email or SMS
 func (h *URLAHandler) SendVerificationCode(c *gin.Context) {
 	var req services.SendVerificationCodeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
 		return
 	}
 
-	err := h.urlaService.SendVerificationCode(req)
+	err := h.urlaService.SendVerificationCode(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, services.ErrTwoFactorDisabled) {
+			c.JSON(http.StatusOK, gin.H{"message": "2FA is currently disabled. The pre-application does not require a verification code."})
+			return
+		}
+		if respondCodeCooldown(c, err) || respondInvalid(c, err) {
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err, "Failed to send verification code")
 		return
 	}
 
@@ -314,13 +413,25 @@ func (h *URLAHandler) SendVerificationCode(c *gin.Context) {
 func (h *URLAHandler) VerifyAndCreateBorrower(c *gin.Context) {
 	var req services.VerifyAndCreateBorrowerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": redact.Error(err)})
 		return
 	}
 
-	response, err := h.urlaService.VerifyAndCreateBorrower(req)
+	response, err := h.urlaService.VerifyAndCreateBorrower(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		recordFailedCheck(c, err)
+		if errors.Is(err, services.ErrVerificationCodeRequired) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The pre-application requires a verification code", "code": "verification_code_required"})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidVerificationCode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if respondInvalid(c, err) {
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err, "Failed to create account")
 		return
 	}
 
@@ -0,0 +1,96 @@
+// Package logging builds the application's structured logger from LoggingConfig
+// and carries request-scoped attributes (request ID, user ID, acting employee ID,
+// API key ID, deal ID) in contexts, so every record logged with a request's
+// context is tagged with them.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/redact"
+)
+
+// Attribute keys attached to request-scoped log records
+const (
+	KeyRequestID = "request_id"
+	KeyUserID    = "user_id"
+	KeyActorID   = "actor_id" // employee acting on the user's behalf
+	KeyAPIKeyID  = "api_key_id"
+	KeyDealID    = "deal_id"
+)
+
+// New creates a logger writing to w with the configured level and format.
+// Unknown values fall back to info and text; config validation rejects them earlier.
+// Every record is passed through redact.ReplaceAttr, so PII never reaches w.
+func New(cfg config.LoggingConfig, w io.Writer) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact.ReplaceAttr}
+
+	var handler slog.Handler
+	if strings.EqualFold(cfg.Format, "json") {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+type attrsKey struct{}
+
+// With returns a copy of ctx whose log records carry args (key-value pairs or
+// slog.Attr values, as accepted by slog.Logger.Info) in addition to any
+// attributes already attached to ctx
+func With(ctx context.Context, args ...any) context.Context {
+	var r slog.Record
+	r.Add(args...)
+
+	existing := attrs(ctx)
+	merged := make([]slog.Attr, len(existing), len(existing)+r.NumAttrs())
+	copy(merged, existing)
+	r.Attrs(func(a slog.Attr) bool {
+		merged = append(merged, a)
+		return true
+	})
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// attrs returns the attributes attached to ctx by With
+func attrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	a, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return a
+}
+
+// contextHandler adds the attributes attached to a record's context before
+// passing it on to the wrapped handler
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the context attributes to r
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if a := attrs(ctx); len(a) > 0 {
+		r = r.Clone()
+		r.AddAttrs(a...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs keeps the context handler in front of the derived handler
+func (h contextHandler) WithAttrs(a []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(a)}
+}
+
+// WithGroup keeps the context handler in front of the derived handler
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
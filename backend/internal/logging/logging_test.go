@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"taulen/backend/internal/config"
+)
+
+// pii lists raw values none of which may reach a log sink
+var pii = []string{
+	"123-45-6789", "987654321", // SSNs
+	"1990-01-31", "02/28/1985", // birth dates
+	"555-123-4567", "(555) 765-4321", // phone numbers
+	"jane.doe@example.com", "john@example.org", // email addresses
+}
+
+type borrower struct {
+	FirstName       string
+	TaxpayerIDValue sql.NullString
+	BirthDate       sql.NullTime
+	MobilePhone     sql.NullString
+	EmailAddress    sql.NullString
+}
+
+// logPII logs each kind of value that carries PII: form maps, structs, errors,
+// free-text messages and request-scoped attributes
+func logPII(logger *slog.Logger) {
+	ctx := With(context.Background(), "email", "john@example.org")
+
+	logger.InfoContext(ctx, "SaveBorrowerData: received borrower", "borrower", map[string]interface{}{
+		"firstName":   "Jane",
+		"ssn":         "123-45-6789",
+		"dateOfBirth": "1990-01-31",
+		"phone":       "555-123-4567",
+		"email":       "jane.doe@example.com",
+		"coBorrower": map[string]interface{}{
+			"taxpayer_identifier_value": "987654321",
+			"birth_date":                "02/28/1985",
+		},
+	})
+	logger.InfoContext(ctx, "loaded borrower", "borrower", &borrower{
+		FirstName:       "Jane",
+		TaxpayerIDValue: sql.NullString{String: "123-45-6789", Valid: true},
+		BirthDate:       sql.NullTime{Time: time.Date(1990, 1, 31, 0, 0, 0, 0, time.UTC), Valid: true},
+		MobilePhone:     sql.NullString{String: "555-123-4567", Valid: true},
+		EmailAddress:    sql.NullString{String: "jane.doe@example.com", Valid: true},
+	})
+	err := fmt.Errorf("failed to create borrower: %w",
+		errors.New(`duplicate key value violates unique constraint "borrower_ssn_key": Key (ssn)=(123-45-6789) already exists`))
+	logger.ErrorContext(ctx, "create failed", "error", err)
+	logger.WarnContext(ctx, "verification SMS to (555) 765-4321 for jane.doe@example.com failed, born 02/28/1985, ssn 987654321")
+	logger.With("phone", "555-123-4567").Info("derived logger", "note", "dob 1990-01-31")
+	logger.WithGroup("applicant").Info("grouped", "email", "jane.doe@example.com", "id", "987654321")
+}
+
+func TestNewRedactsPII(t *testing.T) {
+	for _, format := range []string{"text", "json"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			logPII(New(config.LoggingConfig{Level: "debug", Format: format}, &buf))
+
+			out := buf.String()
+			if lines := strings.Count(out, "\n"); lines != 6 {
+				t.Fatalf("got %d records, want 6:\n%s", lines, out)
+			}
+			for _, raw := range pii {
+				if strings.Contains(out, raw) {
+					t.Errorf("log output contains %q:\n%s", raw, out)
+				}
+			}
+			if !strings.Contains(out, "Jane") || !strings.Contains(out, "[REDACTED]") {
+				t.Errorf("log output lost non-sensitive values or masks:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestWithTagsRecords(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(config.LoggingConfig{Level: "info", Format: "json"}, &buf)
+
+	ctx := With(context.Background(), KeyRequestID, "req-1")
+	ctx = With(ctx, KeyUserID, "user-1")
+	logger.InfoContext(ctx, "handled")
+
+	out := buf.String()
+	if !strings.Contains(out, `"request_id":"req-1"`) || !strings.Contains(out, `"user_id":"user-1"`) {
+		t.Errorf("record lacks context attributes: %s", out)
+	}
+}
+
+func TestNewLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(config.LoggingConfig{Level: "warn", Format: "text"}, &buf)
+	logger.Info("dropped")
+	logger.Warn("kept")
+	if out := buf.String(); strings.Contains(out, "dropped") || !strings.Contains(out, "kept") {
+		t.Errorf("unexpected output for level warn: %s", out)
+	}
+}
@@ -0,0 +1,119 @@
+// Package metrics defines the Prometheus collectors exported on /metrics: HTTP
+// traffic, PostgreSQL connection pool statistics, notification (SMS/email)
+// outcomes and business counters for URLA applications.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric name
+const namespace = "taulen"
+
+// Notification channels
+const (
+	ChannelSMS   = "sms"
+	ChannelEmail = "email"
+)
+
+// Notification outcomes
+const (
+	OutcomeSent    = "sent"
+	OutcomeFailed  = "failed"
+	OutcomeSkipped = "skipped" // provider not configured, nothing was sent
+)
+
+// Application sources
+const (
+	SourceEmployee       = "employee"
+	SourceBorrower       = "borrower"
+	SourcePreApplication = "pre_application"
+)
+
+// registry holds the application collectors; it is separate from the global
+// default registry so tests and tools importing this package do not clash
+var registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestDuration observes request latency per route
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestsTotal counts requests per route and status code
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// NotificationsTotal counts verification code deliveries by channel and outcome
+	NotificationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_total",
+		Help:      "Verification code notifications by channel (sms, email) and outcome (sent, failed, skipped).",
+	}, []string{"channel", "outcome"})
+
+	// ApplicationsCreatedTotal counts newly created URLA applications (deals)
+	ApplicationsCreatedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "applications_created_total",
+		Help:      "URLA applications created, by source (employee, borrower, pre_application).",
+	}, []string{"source"})
+
+	// SectionsCompletedTotal counts URLA sections marked complete
+	SectionsCompletedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sections_completed_total",
+		Help:      "URLA application sections marked complete, by section.",
+	}, []string{"section"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		HTTPRequestsTotal,
+		NotificationsTotal,
+		ApplicationsCreatedTotal,
+		SectionsCompletedTotal,
+	)
+}
+
+// RegisterDB exports the connection pool statistics of db (open, in-use and idle
+// connections, wait counts and durations) under the given database name.
+// It must be called at most once per name.
+func RegisterDB(db *sql.DB, name string) error {
+	return registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the collected metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// Notification records the outcome of a notification sent on channel
+func Notification(channel, outcome string) {
+	NotificationsTotal.WithLabelValues(channel, outcome).Inc()
+}
+
+// ApplicationCreated records a new application created from source
+func ApplicationCreated(source string) {
+	ApplicationsCreatedTotal.WithLabelValues(source).Inc()
+}
+
+// SectionCompleted records an application section marked complete
+func SectionCompleted(section string) {
+	SectionsCompletedTotal.WithLabelValues(section).Inc()
+}
@@ -1,14 +1,21 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/logging"
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/redact"
+	"taulen/backend/internal/services"
 	"taulen/backend/internal/utils"
 )
 
-// AuthMiddleware creates a middleware for JWT authentication
-func AuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
+// AuthMiddleware creates a middleware for JWT authentication. When apiKeys is
+// not nil, API keys are accepted in the same header as access tokens; requests
+// made with one act with rbac.RoleIntegration and the key's scopes.
+func AuthMiddleware(jwtManager *utils.JWTManager, apiKeys *services.APIKeyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -24,7 +31,12 @@ func AuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := jwtManager.ValidateToken(tokenString)
+		if apiKeys != nil && services.IsAPIKey(tokenString) {
+			authenticateAPIKey(c, apiKeys, tokenString)
+			return
+		}
+
+		claims, err := jwtManager.ValidateAccessToken(tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
@@ -34,10 +46,37 @@ func AuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
 		// Set user information in context
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("session_id", claims.SessionID)
+		setRole(c, claims.Role)
+		addLogAttrs(c, logging.KeyUserID, claims.UserID)
+		if claims.Actor != nil {
+			setDelegation(c, claims)
+		}
 		c.Next()
 	}
 }
 
+// authenticateAPIKey authenticates a request made with an API key, setting the
+// key's ID as the user ID and recording it for the request log
+func authenticateAPIKey(c *gin.Context, apiKeys *services.APIKeyService, key string) {
+	principal, err := apiKeys.Authenticate(c.Request.Context(), key, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid, expired or revoked API key"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": redact.Error(err)})
+		return
+	}
+
+	c.Set("user_id", principal.ID)
+	c.Set("api_key_id", principal.ID)
+	c.Set("role", rbac.RoleIntegration)
+	c.Set("scopes", principal.Scopes)
+	addLogAttrs(c, logging.KeyAPIKeyID, principal.ID)
+	c.Next()
+}
+
 // OptionalAuthMiddleware creates a middleware that doesn't require authentication
 // but sets user info if token is present
 func OptionalAuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
@@ -46,10 +85,12 @@ func OptionalAuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
 		if authHeader != "" {
 			tokenString := utils.ExtractTokenFromHeader(authHeader)
 			if tokenString != "" {
-				claims, err := jwtManager.ValidateToken(tokenString)
+				claims, err := jwtManager.ValidateAccessToken(tokenString)
 				if err == nil {
 					c.Set("user_id", claims.UserID)
 					c.Set("email", claims.Email)
+					setRole(c, claims.Role)
+					addLogAttrs(c, logging.KeyUserID, claims.UserID)
 				}
 			}
 		}
@@ -67,6 +108,17 @@ func GetUserID(c *gin.Context) (string, bool) {
 	return id, ok
 }
 
+// GetAPIKeyID retrieves the ID of the API key a request was made with from
+// context (set by auth middleware); requests made with access tokens have none
+func GetAPIKeyID(c *gin.Context) (string, bool) {
+	keyID, exists := c.Get("api_key_id")
+	if !exists {
+		return "", false
+	}
+	id, ok := keyID.(string)
+	return id, ok
+}
+
 // GetEmail retrieves email from context (set by auth middleware)
 func GetEmail(c *gin.Context) (string, bool) {
 	email, exists := c.Get("email")
@@ -76,3 +128,14 @@ func GetEmail(c *gin.Context) (string, bool) {
 	emailStr, ok := email.(string)
 	return emailStr, ok
 }
+
+// GetSessionID retrieves the refresh session ID of the access token from context
+// (set by auth middleware); tokens issued before sessions were tracked have none
+func GetSessionID(c *gin.Context) (string, bool) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		return "", false
+	}
+	id, ok := sessionID.(string)
+	return id, ok && id != ""
+}
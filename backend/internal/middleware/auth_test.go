@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/utils"
+)
+
+func newTestJWTManager() *utils.JWTManager {
+	return utils.NewJWTManager(&config.JWTConfig{
+		Secret:             "test-secret",
+		AccessTokenExpiry:  15 * time.Minute,
+		RefreshTokenExpiry: time.Hour,
+	})
+}
+
+// serve sends a request with the given Authorization header through AuthMiddleware
+func serve(jwtManager *utils.JWTManager, authorization string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/", AuthMiddleware(jwtManager, nil), func(c *gin.Context) {
+		userID, _ := GetUserID(c)
+		sessionID, _ := GetSessionID(c)
+		c.String(http.StatusOK, userID+" "+sessionID)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthMiddlewareAcceptsAccessTokens(t *testing.T) {
+	m := newTestJWTManager()
+	access, err := m.GenerateAccessToken("user-1", "jane@example.com", "applicant", "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(m, "Bearer "+access)
+	if w.Code != http.StatusOK || w.Body.String() != "user-1 session-1" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthMiddlewareRejectsOtherTokens(t *testing.T) {
+	m := newTestJWTManager()
+	refresh, _, err := m.GenerateRefreshToken("user-1", "jane@example.com", "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfa, err := m.GenerateMFAToken("user-1", "jane@example.com", "applicant", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emailVerification, err := m.GenerateEmailVerificationToken("user-1", "jane@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sso, err := m.GenerateSSOToken("state", "nonce", "verifier", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := utils.NewJWTManager(&config.JWTConfig{Secret: "other-secret", AccessTokenExpiry: time.Minute}).
+		GenerateAccessToken("user-1", "jane@example.com", "admin", "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"no header":                "",
+		"not a bearer token":       "Basic dXNlcjpwYXNz",
+		"malformed token":          "Bearer not-a-jwt",
+		"refresh token":            "Bearer " + refresh,
+		"MFA token":                "Bearer " + mfa,
+		"email verification token": "Bearer " + emailVerification,
+		"SSO token":                "Bearer " + sso,
+		"token of another secret":  "Bearer " + foreign,
+	}
+	for name, authorization := range tests {
+		if w := serve(m, authorization); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: got %d, want 401", name, w.Code)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/services"
+)
+
+// ClientContext creates a middleware that attaches the client's IP address and
+// user agent to the request context, so sessions issued while handling the
+// request record the device they were issued to
+func ClientContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := services.Client{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+		c.Request = c.Request.WithContext(services.WithClient(c.Request.Context(), client))
+		c.Next()
+	}
+}
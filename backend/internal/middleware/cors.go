@@ -12,7 +12,7 @@ func CORSMiddleware(cfg *config.CORSConfig) gin.HandlerFunc {
 		AllowOrigins:     cfg.AllowedOrigins,
 		AllowMethods:     cfg.AllowedMethods,
 		AllowHeaders:     cfg.AllowedHeaders,
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * 60 * 60, // 12 hours
 	})
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/services"
+)
+
+// RequireDealAccess creates a middleware that resolves the authenticated user's
+// relation to the deal named by the route parameter and rejects the request
+// before any handler runs unless the user is the primary borrower, a linked
+// co-borrower, an assigned employee, an admin or an API key. A delegated token
+// is only accepted for the deal it was delegated for, and only while the
+// employee acting through it may access the deal themselves. It must run after
+// AuthMiddleware.
+func RequireDealAccess(access *services.DealAccessService, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := GetUserID(c)
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		role, _ := GetRole(c)
+
+		dealID := c.Param(param)
+		relation, err := access.ResolveAccess(c.Request.Context(), dealID, userID, role)
+		if delegation, delegated := GetDelegation(c); delegated && err == nil {
+			if delegation.DealID != dealID {
+				err = services.ErrDealAccessDenied
+			} else {
+				_, err = access.ResolveAccess(c.Request.Context(), dealID, delegation.ActorID, delegation.ActorRole)
+			}
+		}
+		switch {
+		case errors.Is(err, services.ErrDealNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+			return
+		case errors.Is(err, services.ErrDealAccessDenied):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "You do not have access to this application",
+				"code":  ErrCodeForbidden,
+			})
+			return
+		case err != nil:
+			_ = c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check application access"})
+			return
+		}
+
+		c.Set("deal_relation", relation)
+		c.Next()
+	}
+}
+
+// GetDealRelation retrieves the user's relation to the requested deal (set by RequireDealAccess)
+func GetDealRelation(c *gin.Context) (services.DealRelation, bool) {
+	relation, exists := c.Get("deal_relation")
+	if !exists {
+		return "", false
+	}
+	r, ok := relation.(services.DealRelation)
+	return r, ok
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/logging"
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/utils"
+)
+
+// ErrCodeDelegationNotAllowed is the "code" of 403 responses to delegated
+// tokens on routes that do not accept them
+const ErrCodeDelegationNotAllowed = "delegation_not_allowed"
+
+// Delegation describes a delegated access token, issued to an employee acting
+// on a borrower's behalf: the employee and the only deal they may act on
+type Delegation struct {
+	ActorID   string
+	ActorRole rbac.Role
+	DealID    string
+}
+
+// setDelegation stores the delegation of a token in the context and tags the
+// request log with the acting employee
+func setDelegation(c *gin.Context, claims *utils.Claims) {
+	role, _ := rbac.ParseRole(claims.Actor.Role)
+	c.Set("delegation", Delegation{ActorID: claims.Actor.UserID, ActorRole: role, DealID: claims.DealID})
+	addLogAttrs(c, logging.KeyActorID, claims.Actor.UserID)
+}
+
+// GetDelegation retrieves the delegation of the request's token from context
+// (set by auth middleware); ok is false for tokens that are not delegated
+func GetDelegation(c *gin.Context) (Delegation, bool) {
+	delegation, exists := c.Get("delegation")
+	if !exists {
+		return Delegation{}, false
+	}
+	d, ok := delegation.(Delegation)
+	return d, ok
+}
+
+// RejectDelegation creates a middleware that refuses delegated tokens, for
+// routes that manage the caller's own account or reach beyond the deal a token
+// was delegated for. It must run after AuthMiddleware.
+func RejectDelegation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, delegated := GetDelegation(c); delegated {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Not available while acting on behalf of a borrower",
+				"code":  ErrCodeDelegationNotAllowed,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/metrics"
+)
+
+// unmatchedRoute labels requests that matched no route, so arbitrary paths
+// cannot create unbounded metric series
+const unmatchedRoute = "unmatched"
+
+// Metrics creates a middleware that records per-route request latency and
+// status counts. Routes are labeled by their pattern (e.g. /api/v1/urla/applications/:id),
+// never by the raw path.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+		metrics.HTTPRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/rbac"
+)
+
+// ErrCodeForbidden is the "code" of every 403 response
+const ErrCodeForbidden = "forbidden"
+
+// RequirePermission creates a middleware that only lets requests through when the
+// authenticated user's role, or the scopes of the API key the request was made
+// with, grant every one of perms. It must run after AuthMiddleware; requests
+// without a role (e.g. tokens issued before roles were added) are rejected.
+func RequirePermission(perms ...rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, perm := range perms {
+			if !HasPermission(c, perm) {
+				Forbidden(c, perm)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// Forbidden aborts the request with the 403 contract:
+//
+//	{"error": "Insufficient permissions", "code": "forbidden", "requiredPermission": "employees:manage"}
+func Forbidden(c *gin.Context, perm rbac.Permission) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"error":              "Insufficient permissions",
+		"code":               ErrCodeForbidden,
+		"requiredPermission": perm,
+	})
+}
+
+// HasPermission reports whether the authenticated user is granted perm; a
+// request made with an API key is granted the permissions of its scopes
+func HasPermission(c *gin.Context, perm rbac.Permission) bool {
+	if scopes, ok := c.Get("scopes"); ok {
+		scopes, _ := scopes.([]rbac.Scope)
+		for _, scope := range scopes {
+			if scope.Has(perm) {
+				return true
+			}
+		}
+		return false
+	}
+	role, _ := GetRole(c)
+	return role.Has(perm)
+}
+
+// GetRole retrieves the user's role from context (set by auth middleware)
+func GetRole(c *gin.Context) (rbac.Role, bool) {
+	role, exists := c.Get("role")
+	if !exists {
+		return "", false
+	}
+	r, ok := role.(rbac.Role)
+	return r, ok
+}
+
+// setRole stores the role claimed by a token in the context, ignoring unknown roles
+func setRole(c *gin.Context, claimed string) {
+	if role, ok := rbac.ParseRole(claimed); ok {
+		c.Set("role", role)
+	}
+}
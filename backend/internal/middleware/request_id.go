@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/logging"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
+// RequestID creates a middleware that assigns every request an ID, echoes it in the
+// X-Request-ID response header and attaches it to the request context for logging.
+// A well-formed X-Request-ID sent by the client (e.g. a proxy) is reused.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = rand.Text()
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		addLogAttrs(c, logging.KeyRequestID, requestID)
+		c.Next()
+	}
+}
+
+// RequestLogger creates a middleware that logs one line per completed request.
+// It must run after RequestID so the line carries the request attributes.
+func RequestLogger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		// Errors attached with c.Error by handlers and middleware, kept out of the response
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request completed", attrs...)
+	}
+}
+
+// DealLogContext creates a middleware that tags the request's log records with the
+// deal ID taken from the named route parameter, when present
+func DealLogContext(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if dealID := c.Param(param); dealID != "" {
+			addLogAttrs(c, logging.KeyDealID, dealID)
+		}
+		c.Next()
+	}
+}
+
+// GetRequestID retrieves the request ID from context (set by RequestID middleware)
+func GetRequestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// addLogAttrs attaches log attributes to the request context, so they are seen by
+// later handlers and by the request logger once the chain returns
+func addLogAttrs(c *gin.Context, args ...any) {
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), args...))
+}
+
+// validRequestID reports whether a client-supplied request ID is safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/logging"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newLoggedRouter serves /deals/:id with the request logging middleware,
+// writing JSON log records to logs
+func newLoggedRouter(logs *bytes.Buffer) *gin.Engine {
+	logger := logging.New(config.LoggingConfig{Level: "info", Format: "json"}, logs)
+	router := gin.New()
+	router.Use(RequestID(), RequestLogger(logger))
+	router.GET("/deals/:id", DealLogContext("id"), func(c *gin.Context) {
+		logger.InfoContext(c.Request.Context(), "handled")
+		c.Status(http.StatusNoContent)
+	})
+	return router
+}
+
+func TestRequestIDTagsRecords(t *testing.T) {
+	var logs bytes.Buffer
+	router := newLoggedRouter(&logs)
+
+	req := httptest.NewRequest(http.MethodGet, "/deals/deal-1", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got != "req-1" {
+		t.Fatalf("X-Request-ID = %q, want the client's req-1", got)
+	}
+	// Both the handler's record and the request line carry the request and deal IDs
+	records := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2: %s", len(records), logs.String())
+	}
+	for _, record := range records {
+		if !strings.Contains(record, `"request_id":"req-1"`) || !strings.Contains(record, `"deal_id":"deal-1"`) {
+			t.Errorf("record lacks request attributes: %s", record)
+		}
+	}
+	if !strings.Contains(records[1], `"msg":"request completed"`) || !strings.Contains(records[1], `"status":204`) {
+		t.Errorf("request line = %s", records[1])
+	}
+}
+
+func TestRequestIDReplacesMalformedIDs(t *testing.T) {
+	var logs bytes.Buffer
+	router := newLoggedRouter(&logs)
+
+	for _, id := range []string{"", "has spaces", "quote\"d", strings.Repeat("a", maxRequestIDLength+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/deals/deal-1", nil)
+		req.Header.Set(RequestIDHeader, id)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		got := w.Header().Get(RequestIDHeader)
+		if got == "" || got == id || !validRequestID(got) {
+			t.Errorf("X-Request-ID for %q = %q, want a new ID", id, got)
+		}
+	}
+}
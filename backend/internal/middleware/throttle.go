@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrCodeRateLimited is the error code of responses rejected by Throttle
+const ErrCodeRateLimited = "rate_limited"
+
+// failedCheckKey marks a request whose credentials were rejected, for ThrottleFailures
+const failedCheckKey = "failed_check"
+
+// throttleWindow counts the requests of one client within a fixed window
+type throttleWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// throttle holds the windows of every client of one limit
+type throttle struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]*throttleWindow
+	nextSweep time.Time
+}
+
+func newThrottle(limit int, window time.Duration) *throttle {
+	return &throttle{
+		limit:     limit,
+		window:    window,
+		clients:   make(map[string]*throttleWindow),
+		nextSweep: time.Now().Add(window),
+	}
+}
+
+// current returns the client's window at now, starting a new one if it
+// expired. The caller must hold t.mu.
+func (t *throttle) current(ip string, now time.Time) *throttleWindow {
+	// Drop expired windows so idle clients don't accumulate
+	if now.After(t.nextSweep) {
+		for key, w := range t.clients {
+			if now.After(w.resetAt) {
+				delete(t.clients, key)
+			}
+		}
+		t.nextSweep = now.Add(t.window)
+	}
+	w, ok := t.clients[ip]
+	if !ok || now.After(w.resetAt) {
+		w = &throttleWindow{resetAt: now.Add(t.window)}
+		t.clients[ip] = w
+	}
+	return w
+}
+
+// reject answers 429 Too Many Requests, telling the client when its window resets
+func reject(c *gin.Context, resetAt, now time.Time) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(resetAt.Sub(now).Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error": "Too many attempts, please try again later",
+		"code":  ErrCodeRateLimited,
+	})
+}
+
+// Throttle creates a middleware that allows each client IP at most limit
+// requests per window and answers further requests with 429 Too Many Requests.
+// Routes sharing the returned handler share the limit. Counts are kept in
+// memory, so every server instance limits independently.
+func Throttle(limit int, window time.Duration) gin.HandlerFunc {
+	t := newThrottle(limit, window)
+
+	return func(c *gin.Context) {
+		now := time.Now()
+
+		t.mu.Lock()
+		w := t.current(c.ClientIP(), now)
+		w.count++
+		allowed, resetAt := w.count <= t.limit, w.resetAt
+		t.mu.Unlock()
+
+		if !allowed {
+			reject(c, resetAt, now)
+			return
+		}
+		c.Next()
+	}
+}
+
+// ThrottleFailures is like Throttle but only counts the requests whose handler
+// called RecordFailure, such as a wrong password or code. Clients that reach
+// limit failures are refused until their window resets, while successful
+// requests never use up the limit.
+func ThrottleFailures(limit int, window time.Duration) gin.HandlerFunc {
+	t := newThrottle(limit, window)
+
+	return func(c *gin.Context) {
+		now := time.Now()
+		ip := c.ClientIP()
+
+		t.mu.Lock()
+		w := t.current(ip, now)
+		allowed, resetAt := w.count < t.limit, w.resetAt
+		t.mu.Unlock()
+
+		if !allowed {
+			reject(c, resetAt, now)
+			return
+		}
+		c.Next()
+
+		if c.GetBool(failedCheckKey) {
+			t.mu.Lock()
+			t.current(ip, time.Now()).count++
+			t.mu.Unlock()
+		}
+	}
+}
+
+// RecordFailure marks the request as a failed credential check, counting it
+// towards the client's limit under ThrottleFailures
+func RecordFailure(c *gin.Context) {
+	c.Set(failedCheckKey, true)
+}
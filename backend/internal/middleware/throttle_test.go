@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestThrottle(t *testing.T) {
+	router := gin.New()
+	throttle := Throttle(2, time.Minute)
+	router.POST("/login", throttle, func(c *gin.Context) { c.Status(http.StatusUnauthorized) })
+	router.POST("/login/send-verification", throttle, func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Routes sharing the middleware share the limit
+	if w := send("/login", "198.51.100.7:4000"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("first attempt: status %d", w.Code)
+	}
+	if w := send("/login/send-verification", "198.51.100.7:4001"); w.Code != http.StatusOK {
+		t.Fatalf("second attempt: status %d", w.Code)
+	}
+	w := send("/login", "198.51.100.7:4002")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("attempt over the limit: status %d, want 429", w.Code)
+	}
+	if retry := w.Header().Get("Retry-After"); retry != "60" {
+		t.Errorf("Retry-After = %q, want 60", retry)
+	}
+
+	// Other clients have limits of their own
+	if w := send("/login", "203.0.113.9:4000"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("another client: status %d", w.Code)
+	}
+}
+
+func TestThrottleFailures(t *testing.T) {
+	router := gin.New()
+	router.POST("/login", ThrottleFailures(2, time.Minute), func(c *gin.Context) {
+		if c.Query("password") != "correct" {
+			RecordFailure(c)
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	send := func(password string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login?password="+password, nil))
+		return w.Code
+	}
+
+	// Only failures count towards the limit
+	for _, password := range []string{"correct", "wrong", "correct", "correct", "wrong"} {
+		want := http.StatusOK
+		if password == "wrong" {
+			want = http.StatusUnauthorized
+		}
+		if code := send(password); code != want {
+			t.Fatalf("%s password: status %d, want %d", password, code, want)
+		}
+	}
+	if code := send("correct"); code != http.StatusTooManyRequests {
+		t.Fatalf("after two failures: status %d, want 429", code)
+	}
+}
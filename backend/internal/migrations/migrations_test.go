@@ -0,0 +1,91 @@
+package migrations
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %d_%s: want version %d, versions must be contiguous", m.Version, m.Name, i+1)
+		}
+	}
+}
+
+// TestEmbeddedAdoptable checks that every migration can be adopted, since the
+// schema dump mirrors all of them, and that its check names objects of the dump
+func TestEmbeddedAdoptable(t *testing.T) {
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	dump, err := os.ReadFile("../sql/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := string(dump)
+
+	regclass := regexp.MustCompile(`to_regclass\('public\.([a-z_]+)'\)`)
+	column := regexp.MustCompile(`table_name = '([a-z_]+)' AND column_name = '([a-z_]+)'`)
+	for _, m := range migrations {
+		if m.AdoptIf == "" {
+			t.Errorf("migration %d_%s has no %q comment", m.Version, m.Name, adoptIfPrefix)
+			continue
+		}
+		if match := regclass.FindStringSubmatch(m.AdoptIf); match != nil {
+			if !regexp.MustCompile(`\b` + match[1] + `\b`).MatchString(schema) {
+				t.Errorf("migration %d_%s checks for %s, which the schema dump lacks", m.Version, m.Name, match[1])
+			}
+		} else if match := column.FindStringSubmatch(m.AdoptIf); match != nil {
+			if !strings.Contains(schema, "    "+match[2]+" ") {
+				t.Errorf("migration %d_%s checks for column %s.%s, which the schema dump lacks", m.Version, m.Name, match[1], match[2])
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0001_init.up.sql":     {Data: []byte("-- 0001_init\n--\n-- adopt-if: to_regclass('public.t') IS NOT NULL\n\nCREATE TABLE t (id int);\n")},
+		"sql/0001_init.down.sql":   {Data: []byte("DROP TABLE t;\n")},
+		"sql/0002_column.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN c int;\n")},
+		"sql/0002_column.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN c;\n")},
+	}
+	migrations, err := load(fsys)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "init" || migrations[1].Version != 2 {
+		t.Fatalf("load = %+v", migrations)
+	}
+	if got := migrations[0].AdoptIf; got != "to_regclass('public.t') IS NOT NULL" {
+		t.Errorf("AdoptIf = %q", got)
+	}
+	if migrations[1].AdoptIf != "" {
+		t.Errorf("AdoptIf = %q, want none", migrations[1].AdoptIf)
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad name":     {"sql/1_Init.up.sql": {Data: []byte("SELECT 1;")}},
+		"missing down": {"sql/0001_init.up.sql": {Data: []byte("SELECT 1;")}},
+		"conflicting names": {
+			"sql/0001_init.up.sql":    {Data: []byte("SELECT 1;")},
+			"sql/0001_other.down.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+	for name, fsys := range tests {
+		if _, err := load(fsys); err == nil {
+			t.Errorf("%s: load succeeded", name)
+		}
+	}
+}
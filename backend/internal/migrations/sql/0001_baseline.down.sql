@@ -0,0 +1,34 @@
+-- 0001_baseline (down): drop every object created by the baseline schema.
+--
+-- The pg_uuidv7 and uuid-ossp extensions are left installed because other
+-- databases on the same server may depend on them.
+
+DROP TRIGGER IF EXISTS trg_update_progress ON public.deal_progress;
+DROP TRIGGER IF EXISTS trg_create_deal_progress ON public.deal;
+
+DROP TABLE IF EXISTS public.asset CASCADE;
+DROP TABLE IF EXISTS public.borrower_alternate_name CASCADE;
+DROP TABLE IF EXISTS public.borrower_progress CASCADE;
+DROP TABLE IF EXISTS public.declaration CASCADE;
+DROP TABLE IF EXISTS public.demographic CASCADE;
+DROP TABLE IF EXISTS public.employment_income CASCADE;
+DROP TABLE IF EXISTS public.employment CASCADE;
+DROP TABLE IF EXISTS public.liability CASCADE;
+DROP TABLE IF EXISTS public.monthly_expense CASCADE;
+DROP TABLE IF EXISTS public.other_income CASCADE;
+DROP TABLE IF EXISTS public.owned_property CASCADE;
+DROP TABLE IF EXISTS public.residence CASCADE;
+DROP TABLE IF EXISTS public.subject_property CASCADE;
+DROP TABLE IF EXISTS public.loan CASCADE;
+DROP TABLE IF EXISTS public.deal_progress CASCADE;
+DROP TABLE IF EXISTS public.deal CASCADE;
+DROP TABLE IF EXISTS public.borrower CASCADE;
+DROP TABLE IF EXISTS public.party CASCADE;
+DROP TABLE IF EXISTS public."user" CASCADE;
+
+DROP FUNCTION IF EXISTS public.update_progress_percentage();
+DROP FUNCTION IF EXISTS public.generate_uuid_v7();
+DROP FUNCTION IF EXISTS public.create_deal_progress();
+
+DROP TYPE IF EXISTS public.urla_section_enum;
+DROP TYPE IF EXISTS public.deal_status_enum;
@@ -0,0 +1,3 @@
+-- 0002_add_deal_assignment (down)
+
+DROP TABLE IF EXISTS public.deal_assignment;
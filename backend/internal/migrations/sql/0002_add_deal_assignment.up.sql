@@ -0,0 +1,17 @@
+-- 0002_add_deal_assignment (up): employees assigned to a deal.
+--
+-- Employees other than admins may only access deals they are assigned to.
+-- The employee who creates a deal is assigned to it.
+--
+-- adopt-if: to_regclass('public.deal_assignment') IS NOT NULL
+
+CREATE TABLE public.deal_assignment (
+    deal_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT deal_assignment_pkey PRIMARY KEY (deal_id, user_id),
+    CONSTRAINT deal_assignment_deal_id_fkey FOREIGN KEY (deal_id) REFERENCES public.deal(id) ON DELETE CASCADE,
+    CONSTRAINT deal_assignment_user_id_fkey FOREIGN KEY (user_id) REFERENCES public."user"(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_deal_assignment_user_id ON public.deal_assignment USING btree (user_id);
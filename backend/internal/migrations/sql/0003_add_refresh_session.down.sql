@@ -0,0 +1,3 @@
+-- 0003_add_refresh_session (down)
+
+DROP TABLE IF EXISTS public.refresh_session;
@@ -0,0 +1,27 @@
+-- 0003_add_refresh_session (up): server-side refresh token sessions.
+--
+-- Every refresh token issued is a row; the token itself is never stored, only
+-- its SHA-256 hash. Refreshing rotates the token: the presented row is marked
+-- rotated and a new row is created in the same family. Presenting a rotated
+-- token again revokes the whole family. subject_id refers to either a "user"
+-- (employee) or a borrower (applicant), so it has no foreign key.
+--
+-- adopt-if: to_regclass('public.refresh_session') IS NOT NULL
+
+CREATE TABLE public.refresh_session (
+    id uuid NOT NULL,
+    family_id uuid NOT NULL,
+    subject_id uuid NOT NULL,
+    subject_type character varying(20) NOT NULL,
+    token_hash character varying(64) NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    rotated_at timestamp with time zone,
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT refresh_session_pkey PRIMARY KEY (id),
+    CONSTRAINT chk_refresh_session_subject_type CHECK (((subject_type)::text = ANY ((ARRAY['employee'::character varying, 'applicant'::character varying])::text[])))
+);
+
+CREATE INDEX idx_refresh_session_expires_at ON public.refresh_session USING btree (expires_at);
+
+CREATE INDEX idx_refresh_session_family_id ON public.refresh_session USING btree (family_id);
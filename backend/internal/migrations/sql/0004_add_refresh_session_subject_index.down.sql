@@ -0,0 +1,3 @@
+-- 0004_add_refresh_session_subject_index (down)
+
+DROP INDEX IF EXISTS public.idx_refresh_session_subject_id;
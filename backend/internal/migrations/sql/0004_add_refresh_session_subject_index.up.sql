@@ -0,0 +1,7 @@
+-- 0004_add_refresh_session_subject_index (up): look up refresh sessions by subject.
+--
+-- Used to revoke every session of an account, e.g. after a password reset.
+--
+-- adopt-if: to_regclass('public.idx_refresh_session_subject_id') IS NOT NULL
+
+CREATE INDEX idx_refresh_session_subject_id ON public.refresh_session USING btree (subject_id);
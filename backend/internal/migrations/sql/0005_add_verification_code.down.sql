@@ -0,0 +1,3 @@
+-- 0005_add_verification_code (down)
+
+DROP TABLE IF EXISTS public.verification_code;
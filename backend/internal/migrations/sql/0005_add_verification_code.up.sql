@@ -0,0 +1,26 @@
+-- 0005_add_verification_code (up): one-time verification codes.
+--
+-- Codes sent by email or SMS for login, registration and the pre-application
+-- are kept here instead of on the borrower and "user" rows, so a code can be
+-- sent before an account exists. Each email address or phone number holds at
+-- most one code; sending a new one replaces it. The code itself is never
+-- stored, only a keyed HMAC-SHA256 of it, and a code stops working after too
+-- many wrong attempts.
+--
+-- adopt-if: to_regclass('public.verification_code') IS NOT NULL
+
+CREATE TABLE public.verification_code (
+    id uuid NOT NULL,
+    channel character varying(10) NOT NULL,
+    destination character varying(255) NOT NULL,
+    purpose character varying(20) NOT NULL,
+    code_hash character varying(64) NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT verification_code_pkey PRIMARY KEY (id),
+    CONSTRAINT verification_code_channel_destination_key UNIQUE (channel, destination),
+    CONSTRAINT chk_verification_code_channel CHECK (((channel)::text = ANY ((ARRAY['email'::character varying, 'sms'::character varying])::text[])))
+);
+
+CREATE INDEX idx_verification_code_expires_at ON public.verification_code USING btree (expires_at);
@@ -0,0 +1,3 @@
+-- 0006_add_user_password_reset_required (down)
+
+ALTER TABLE public."user" DROP COLUMN IF EXISTS password_reset_required;
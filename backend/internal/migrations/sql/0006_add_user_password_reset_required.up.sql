@@ -0,0 +1,8 @@
+-- 0006_add_user_password_reset_required (up): admin-forced password resets.
+--
+-- An admin can require an employee to choose a new password. Logins are refused
+-- while the flag is set; completing a password reset clears it.
+--
+-- adopt-if: EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = 'public' AND table_name = 'user' AND column_name = 'password_reset_required')
+
+ALTER TABLE public."user" ADD COLUMN password_reset_required boolean DEFAULT false NOT NULL;
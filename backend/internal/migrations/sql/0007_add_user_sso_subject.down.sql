@@ -0,0 +1,4 @@
+-- 0007_add_user_sso_subject (down)
+
+DROP INDEX IF EXISTS public.idx_user_sso_subject;
+ALTER TABLE public."user" DROP COLUMN IF EXISTS sso_subject;
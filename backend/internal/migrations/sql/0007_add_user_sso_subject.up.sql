@@ -0,0 +1,11 @@
+-- 0007_add_user_sso_subject (up): single sign-on for employees.
+--
+-- Employees signing in through the company identity provider are linked to
+-- their provider account by its subject identifier, which never changes even
+-- if their email does.
+--
+-- adopt-if: EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = 'public' AND table_name = 'user' AND column_name = 'sso_subject')
+
+ALTER TABLE public."user" ADD COLUMN sso_subject character varying(255);
+
+CREATE UNIQUE INDEX idx_user_sso_subject ON public."user" USING btree (sso_subject) WHERE (sso_subject IS NOT NULL);
@@ -0,0 +1,3 @@
+-- 0008_add_api_key (down)
+
+DROP TABLE IF EXISTS public.api_key;
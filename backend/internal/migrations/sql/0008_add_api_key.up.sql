@@ -0,0 +1,28 @@
+-- 0008_add_api_key (up): API keys for machine-to-machine integrations.
+--
+-- Admins issue API keys to integrations such as LOS sync jobs and reporting
+-- scripts, which authenticate with them instead of logging in as an employee.
+-- Only a SHA-256 hash of a key is stored, along with its first characters so
+-- admins can tell keys apart. Each key holds a comma-separated list of scopes,
+-- always expires and records when and from where it was last used. Rotating a
+-- key issues a new one that names the key it replaces.
+--
+-- adopt-if: to_regclass('public.api_key') IS NOT NULL
+
+CREATE TABLE public.api_key (
+    id uuid NOT NULL,
+    name character varying(100) NOT NULL,
+    key_prefix character varying(16) NOT NULL,
+    key_hash character varying(64) NOT NULL,
+    scopes text NOT NULL,
+    created_by uuid,
+    rotated_from uuid,
+    expires_at timestamp with time zone NOT NULL,
+    last_used_at timestamp with time zone,
+    last_used_ip character varying(45),
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT api_key_pkey PRIMARY KEY (id),
+    CONSTRAINT api_key_key_hash_key UNIQUE (key_hash),
+    CONSTRAINT api_key_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(id) ON DELETE SET NULL
+);
@@ -0,0 +1,3 @@
+-- 0009_add_deal_edit (down)
+
+DROP TABLE IF EXISTS public.deal_edit;
@@ -0,0 +1,25 @@
+-- 0009_add_deal_edit (up): who wrote each part of an application.
+--
+-- Every write to an application records the section written and the account
+-- the request was made as: a borrower, an employee or an API key. When an
+-- employee edits an application on a borrower's behalf the write is made as
+-- the borrower and actor_id names the employee, so the borrower can see which
+-- sections their loan officer entered.
+--
+-- adopt-if: to_regclass('public.deal_edit') IS NOT NULL
+
+CREATE TABLE public.deal_edit (
+    id uuid DEFAULT public.generate_uuid_v7() NOT NULL,
+    deal_id uuid NOT NULL,
+    section character varying(50) NOT NULL,
+    account_id uuid NOT NULL,
+    account_type character varying(20) NOT NULL,
+    actor_id uuid,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT chk_account_type CHECK (((account_type)::text = ANY ((ARRAY['borrower'::character varying, 'employee'::character varying, 'integration'::character varying])::text[]))),
+    CONSTRAINT deal_edit_pkey PRIMARY KEY (id),
+    CONSTRAINT deal_edit_deal_id_fkey FOREIGN KEY (deal_id) REFERENCES public.deal(id) ON DELETE CASCADE,
+    CONSTRAINT deal_edit_actor_id_fkey FOREIGN KEY (actor_id) REFERENCES public."user"(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_deal_edit_deal_id ON public.deal_edit USING btree (deal_id, created_at);
@@ -0,0 +1,8 @@
+-- 0010_add_session_device (down)
+
+DROP TABLE IF EXISTS public.known_device;
+
+ALTER TABLE public.refresh_session
+    DROP COLUMN IF EXISTS signed_in_at,
+    DROP COLUMN IF EXISTS ip_address,
+    DROP COLUMN IF EXISTS user_agent;
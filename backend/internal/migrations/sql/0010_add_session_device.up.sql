@@ -0,0 +1,26 @@
+-- 0010_add_session_device (up): devices of refresh sessions.
+--
+-- Each refresh session records the user agent and IP address of the request
+-- that created it, and when the login it belongs to started, so account holders
+-- can list where they are signed in. known_device remembers the devices each
+-- account has signed in from, identified by a SHA-256 hash of their browser,
+-- operating system and network; a login from a device not in it triggers an
+-- email notice. subject_id refers to either a "user" (employee) or a borrower
+-- (applicant), so it has no foreign key.
+--
+-- adopt-if: to_regclass('public.known_device') IS NOT NULL
+
+ALTER TABLE public.refresh_session
+    ADD COLUMN user_agent character varying(512),
+    ADD COLUMN ip_address character varying(45),
+    ADD COLUMN signed_in_at timestamp with time zone;
+
+UPDATE public.refresh_session s
+SET signed_in_at = (SELECT min(f.created_at) FROM public.refresh_session f WHERE f.family_id = s.family_id);
+
+CREATE TABLE public.known_device (
+    subject_id uuid NOT NULL,
+    device_hash character varying(64) NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT known_device_pkey PRIMARY KEY (subject_id, device_hash)
+);
@@ -0,0 +1,12 @@
+-- 0011_add_verification_code_purpose_key (down)
+--
+-- Keeps only the newest code of each email address or phone number.
+
+DELETE FROM public.verification_code c
+USING public.verification_code n
+WHERE n.channel = c.channel AND n.destination = c.destination
+  AND (n.created_at, n.id) > (c.created_at, c.id);
+
+ALTER TABLE public.verification_code
+    DROP CONSTRAINT verification_code_channel_destination_purpose_key,
+    ADD CONSTRAINT verification_code_channel_destination_key UNIQUE (channel, destination);
@@ -0,0 +1,11 @@
+-- 0011_add_verification_code_purpose_key (up): one code per purpose.
+--
+-- Each email address or phone number now holds one code per purpose, so a
+-- login code no longer replaces a pending registration or pre-application code
+-- sent to the same address, and the resend cooldown applies per purpose.
+--
+-- adopt-if: to_regclass('public.verification_code_channel_destination_purpose_key') IS NOT NULL
+
+ALTER TABLE public.verification_code
+    DROP CONSTRAINT verification_code_channel_destination_key,
+    ADD CONSTRAINT verification_code_channel_destination_purpose_key UNIQUE (channel, destination, purpose);
@@ -0,0 +1,4 @@
+-- 0012_add_two_factor_enabled (down)
+
+ALTER TABLE public."user" DROP COLUMN IF EXISTS two_factor_enabled;
+ALTER TABLE public.borrower DROP COLUMN IF EXISTS two_factor_enabled;
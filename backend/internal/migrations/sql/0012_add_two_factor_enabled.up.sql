@@ -0,0 +1,10 @@
+-- 0012_add_two_factor_enabled (up): per-account opt-in to code-based 2FA.
+--
+-- When the two-factor mode is optional, borrowers and employees may opt in to
+-- verification codes. Once an account has opted in, every password login must
+-- carry a valid code.
+--
+-- adopt-if: EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = 'public' AND table_name = 'borrower' AND column_name = 'two_factor_enabled') AND EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = 'public' AND table_name = 'user' AND column_name = 'two_factor_enabled')
+
+ALTER TABLE public.borrower ADD COLUMN two_factor_enabled boolean DEFAULT false NOT NULL;
+ALTER TABLE public."user" ADD COLUMN two_factor_enabled boolean DEFAULT false NOT NULL;
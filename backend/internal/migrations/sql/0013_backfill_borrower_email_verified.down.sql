@@ -0,0 +1,4 @@
+-- 0013_backfill_borrower_email_verified (down)
+--
+-- The backfilled emails cannot be told apart from ones verified through a
+-- link, so they stay verified.
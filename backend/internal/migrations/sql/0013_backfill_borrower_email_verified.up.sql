@@ -0,0 +1,15 @@
+-- 0013_backfill_borrower_email_verified (up): treat existing borrower emails as verified.
+--
+-- Submitting an application and consenting to a credit check require a
+-- verified email. Borrowers who signed up before verification links were sent
+-- never got one, so their emails are marked verified rather than blocking
+-- their applications. Borrowers who sign up from now on verify through the
+-- emailed link.
+--
+-- The backfill changes no schema, so there is nothing to detect: it always
+-- runs, and does nothing on a new database.
+--
+-- adopt-if: false
+
+UPDATE public.borrower SET email_verified = true
+WHERE email_verified IS NOT TRUE AND email_address IS NOT NULL;
@@ -0,0 +1,89 @@
+// Package notify delivers emails and text messages through the providers
+// selected by configuration: SendGrid or SMTP for email, Twilio for SMS, and a
+// file or console sink for either, which records messages instead of sending
+// them. Callers compose messages and hand them to a Notifier, so switching
+// vendors does not touch them.
+package notify
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+
+	"taulen/backend/internal/config"
+)
+
+// ErrNotConfigured is returned when the selected provider lacks the settings
+// it needs to send, e.g. an API key
+var ErrNotConfigured = errors.New("notification provider is not configured")
+
+// Email is a plain-text email to one recipient
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// SMS is a text message to one phone number in E.164 format
+type SMS struct {
+	To   string
+	Body string
+}
+
+// EmailSender sends emails
+type EmailSender interface {
+	SendEmail(ctx context.Context, msg Email) error
+}
+
+// SMSSender sends text messages
+type SMSSender interface {
+	SendSMS(ctx context.Context, msg SMS) error
+}
+
+// Notifier sends emails and text messages
+type Notifier interface {
+	EmailSender
+	SMSSender
+}
+
+// notifier combines the senders selected for each channel
+type notifier struct {
+	EmailSender
+	SMSSender
+}
+
+// New creates the notifier selected by the configured providers. Channels
+// using the same sink share it, so their records are not interleaved.
+func New(cfg *config.Config, logger *slog.Logger) Notifier {
+	var console, file *Sink
+	sink := func(provider string) *Sink {
+		if provider == config.ProviderConsole {
+			if console == nil {
+				console = NewConsoleSink(os.Stdout)
+			}
+			return console
+		}
+		if file == nil {
+			file = NewFileSink(cfg.Notifications.FilePath)
+		}
+		return file
+	}
+
+	var n notifier
+	switch provider := cfg.Notifications.EmailProvider; provider {
+	case config.ProviderSMTP:
+		n.EmailSender = NewSMTP(&cfg.SMTP, logger)
+	case config.ProviderFile, config.ProviderConsole:
+		n.EmailSender = sink(provider)
+	default:
+		n.EmailSender = NewSendGrid(&cfg.SendGrid, logger)
+	}
+	switch provider := cfg.Notifications.SMSProvider; provider {
+	case config.ProviderFile, config.ProviderConsole:
+		n.SMSSender = sink(provider)
+	default:
+		n.SMSSender = NewTwilio(&cfg.Twilio, logger)
+	}
+	return n
+}
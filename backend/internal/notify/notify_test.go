@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"taulen/backend/internal/config"
+)
+
+// testConfig loads the default configuration with the given TAULEN_ variables
+func testConfig(t *testing.T, env map[string]string) *config.Config {
+	t.Helper()
+	t.Setenv("TAULEN_JWT_SECRET", "test-secret")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	return cfg
+}
+
+func TestNewSelectsProviders(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "notifications.log")
+
+	tests := []struct {
+		email, sms string
+		wantEmail  string
+		wantSMS    string
+	}{
+		{"", "", "*notify.SendGrid", "*notify.Twilio"},
+		{config.ProviderSendGrid, config.ProviderTwilio, "*notify.SendGrid", "*notify.Twilio"},
+		{config.ProviderSMTP, config.ProviderTwilio, "*notify.SMTP", "*notify.Twilio"},
+		{config.ProviderFile, config.ProviderFile, "*notify.Sink", "*notify.Sink"},
+		{config.ProviderConsole, config.ProviderConsole, "*notify.Sink", "*notify.Sink"},
+		{config.ProviderFile, config.ProviderConsole, "*notify.Sink", "*notify.Sink"},
+		{config.ProviderSendGrid, config.ProviderFile, "*notify.SendGrid", "*notify.Sink"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email+"/"+tt.sms, func(t *testing.T) {
+			env := map[string]string{
+				"TAULEN_SMTP_HOST":               "smtp.example.com",
+				"TAULEN_SMTP_FROM_EMAIL":         "noreply@example.com",
+				"TAULEN_NOTIFICATIONS_FILE_PATH": path,
+			}
+			if tt.email != "" {
+				env["TAULEN_NOTIFICATIONS_EMAIL_PROVIDER"] = tt.email
+			}
+			if tt.sms != "" {
+				env["TAULEN_NOTIFICATIONS_SMS_PROVIDER"] = tt.sms
+			}
+			n := New(testConfig(t, env), logger).(notifier)
+
+			if got := typeName(n.EmailSender); got != tt.wantEmail {
+				t.Errorf("email sender = %s, want %s", got, tt.wantEmail)
+			}
+			if got := typeName(n.SMSSender); got != tt.wantSMS {
+				t.Errorf("SMS sender = %s, want %s", got, tt.wantSMS)
+			}
+
+			// Channels using the same sink share it; different sinks write to
+			// the console and the configured file
+			emailSink, emailIsSink := n.EmailSender.(*Sink)
+			smsSink, smsIsSink := n.SMSSender.(*Sink)
+			if emailIsSink && smsIsSink && (emailSink == smsSink) != (tt.email == tt.sms) {
+				t.Errorf("email and SMS share a sink: %v", emailSink == smsSink)
+			}
+			for provider, sink := range map[string]*Sink{tt.email: emailSink, tt.sms: smsSink} {
+				switch {
+				case provider == config.ProviderFile && sink.path != path:
+					t.Errorf("file sink writes to %q, want %q", sink.path, path)
+				case provider == config.ProviderConsole && sink.w != os.Stdout:
+					t.Error("console sink does not write to standard output")
+				}
+			}
+		})
+	}
+}
+
+// typeName names the dynamic type of a sender, e.g. *notify.Sink
+func typeName(v any) string {
+	return fmt.Sprintf("%T", v)
+}
+
+func TestSinkRecords(t *testing.T) {
+	ctx := context.Background()
+	var console bytes.Buffer
+	path := filepath.Join(t.TempDir(), "notifications.log")
+
+	for name, sink := range map[string]*Sink{"console": NewConsoleSink(&console), "file": NewFileSink(path)} {
+		if err := sink.SendEmail(ctx, Email{To: "jane@example.com", Subject: "Hello", Body: "Your code is 123456"}); err != nil {
+			t.Fatalf("%s SendEmail: %v", name, err)
+		}
+		if err := sink.SendSMS(ctx, SMS{To: "+15551234567", Body: "Your code is 654321"}); err != nil {
+			t.Fatalf("%s SendSMS: %v", name, err)
+		}
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, records := range map[string]string{"console": console.String(), "file": string(written)} {
+		blocks := strings.Split(records, "--- ")
+		if len(blocks) != 3 || blocks[0] != "" {
+			t.Fatalf("%s: records = %q, want an email and an SMS", name, records)
+		}
+		if !strings.HasPrefix(blocks[1], "email ") ||
+			!strings.Contains(blocks[1], "\nTo: jane@example.com\nSubject: Hello\n\nYour code is 123456\n") {
+			t.Errorf("%s: email record = %q", name, blocks[1])
+		}
+		if !strings.HasPrefix(blocks[2], "sms ") ||
+			!strings.Contains(blocks[2], "\nTo: +15551234567\n\nYour code is 654321\n") {
+			t.Errorf("%s: SMS record = %q", name, blocks[2])
+		}
+	}
+}
+
+func TestFileSinkAppends(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "notifications.log")
+	if err := os.WriteFile(path, []byte("earlier\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records sent at once are appended whole, one after another
+	sink := NewFileSink(path)
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sink.SendSMS(ctx, SMS{To: "+15551234567", Body: "hello"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := string(written)
+	if !strings.HasPrefix(records, "earlier\n") {
+		t.Errorf("the file was not appended to: %q", records)
+	}
+	if n := strings.Count(records, "\nTo: +15551234567\n\nhello\n"); n != 20 {
+		t.Errorf("found %d whole records, want 20", n)
+	}
+}
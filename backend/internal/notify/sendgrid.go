@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"taulen/backend/internal/config"
+)
+
+// sendGridURL is the SendGrid API v3 Mail Send endpoint
+const sendGridURL = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGrid sends emails through the Twilio SendGrid API
+type SendGrid struct {
+	cfg    *config.SendGridConfig
+	client *http.Client
+	logger *slog.Logger
+}
+
+// NewSendGrid creates a SendGrid email sender
+func NewSendGrid(cfg *config.SendGridConfig, logger *slog.Logger) *SendGrid {
+	return &SendGrid{
+		cfg:    cfg,
+		client: &http.Client{},
+		logger: logger,
+	}
+}
+
+// SendEmail sends a plain-text email
+func (s *SendGrid) SendEmail(ctx context.Context, msg Email) error {
+	if s.cfg.APIKey == "" {
+		return fmt.Errorf("%w: SendGrid API key is missing", ErrNotConfigured)
+	}
+
+	s.logger.DebugContext(ctx, "sending email via SendGrid", "email", msg.To)
+
+	// SendGrid API request payload
+	payload := map[string]interface{}{
+		"personalizations": []map[string]interface{}{
+			{
+				"to": []map[string]string{
+					{"email": msg.To},
+				},
+			},
+		},
+		"from": map[string]string{
+			"email": s.cfg.FromEmail,
+			"name":  s.cfg.FromName,
+		},
+		"subject": msg.Subject,
+		"content": []map[string]string{
+			{
+				"type":  "text/plain",
+				"value": msg.Body,
+			},
+		},
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to create email payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", sendGridURL, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return fmt.Errorf("failed to create email request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "SendGrid request failed", "error", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Read response body for error details
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to read SendGrid response body", "error", err)
+	}
+
+	// Log the response for debugging
+	s.logger.DebugContext(ctx, "SendGrid API response", "status", resp.StatusCode, "body", string(body))
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		// Try to parse SendGrid error response
+		var sendGridError struct {
+			Errors []struct {
+				Message string `json:"message"`
+				Field   string `json:"field,omitempty"`
+				Help    string `json:"help,omitempty"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(body, &sendGridError); err == nil && len(sendGridError.Errors) > 0 {
+			errorMsg := sendGridError.Errors[0].Message
+			s.logger.ErrorContext(ctx, "SendGrid rejected email", "status", resp.StatusCode, "message", errorMsg)
+			return errors.New(errorMsg)
+		}
+
+		s.logger.ErrorContext(ctx, "SendGrid returned an unparseable error response", "status", resp.StatusCode, "body", string(body))
+		return fmt.Errorf("SendGrid API returned status %d. Response: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
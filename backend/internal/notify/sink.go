@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink records notifications instead of delivering them, for local development
+// and automated tests. Each message is written as a block headed by its channel
+// and time, followed by its headers and body.
+type Sink struct {
+	mu   sync.Mutex
+	w    io.Writer // console sinks write here
+	path string    // file sinks append here
+}
+
+// NewConsoleSink creates a sink writing to w, typically standard output
+func NewConsoleSink(w io.Writer) *Sink {
+	return &Sink{w: w}
+}
+
+// NewFileSink creates a sink appending to the file at path, which is created
+// if needed
+func NewFileSink(path string) *Sink {
+	return &Sink{path: path}
+}
+
+// SendEmail records an email
+func (s *Sink) SendEmail(ctx context.Context, msg Email) error {
+	return s.write(fmt.Sprintf("--- email %s\nTo: %s\nSubject: %s\n\n%s\n",
+		time.Now().UTC().Format(time.RFC3339), msg.To, msg.Subject, msg.Body))
+}
+
+// SendSMS records a text message
+func (s *Sink) SendSMS(ctx context.Context, msg SMS) error {
+	return s.write(fmt.Sprintf("--- sms %s\nTo: %s\n\n%s\n",
+		time.Now().UTC().Format(time.RFC3339), msg.To, msg.Body))
+}
+
+// write writes a record in a single call so concurrent records never interleave
+func (s *Sink) write(record string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		_, err := io.WriteString(s.w, record)
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %w", err)
+	}
+	if _, err := f.WriteString(record); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write notification file: %w", err)
+	}
+	return f.Close()
+}
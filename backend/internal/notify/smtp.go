@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"taulen/backend/internal/config"
+)
+
+// smtpTimeout bounds an SMTP conversation when the context has no deadline
+const smtpTimeout = 30 * time.Second
+
+// smtpImplicitTLSPort is the submission port that speaks TLS from the start
+const smtpImplicitTLSPort = 465
+
+// SMTP sends emails through an SMTP server
+type SMTP struct {
+	cfg    *config.SMTPConfig
+	logger *slog.Logger
+}
+
+// NewSMTP creates an SMTP email sender
+func NewSMTP(cfg *config.SMTPConfig, logger *slog.Logger) *SMTP {
+	return &SMTP{
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// SendEmail sends a plain-text email. The connection is upgraded with
+// STARTTLS when the server offers it; credentials are only sent over TLS.
+func (s *SMTP) SendEmail(ctx context.Context, msg Email) error {
+	if s.cfg.Host == "" {
+		return fmt.Errorf("%w: SMTP host is missing", ErrNotConfigured)
+	}
+
+	s.logger.DebugContext(ctx, "sending email via SMTP", "email", msg.To, "host", s.cfg.Host)
+
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+	data, err := s.message(to, msg)
+	if err != nil {
+		return fmt.Errorf("failed to create email message: %w", err)
+	}
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "SMTP connection failed", "error", err)
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer client.Close()
+
+	if err := s.send(client, to.Address, data); err != nil {
+		s.logger.ErrorContext(ctx, "SMTP server rejected email", "error", err)
+		return err
+	}
+	return nil
+}
+
+// dial connects to the server, using implicit TLS on port 465 and STARTTLS
+// elsewhere when offered, and bounds the conversation by the context deadline
+func (s *SMTP) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: s.cfg.Host}
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if s.cfg.Port == smtpImplicitTLSPort {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// send authenticates if configured and transfers one message
+func (s *SMTP) send(client *smtp.Client, to string, data []byte) error {
+	if s.cfg.Username != "" {
+		// PlainAuth refuses to send credentials over an unencrypted connection
+		// to anything but localhost
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(s.cfg.FromEmail); err != nil {
+		return fmt.Errorf("SMTP server rejected sender: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("SMTP server rejected recipient: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message renders msg as a MIME message with a quoted-printable UTF-8 body
+func (s *SMTP) message(to *mail.Address, msg Email) ([]byte, error) {
+	from := mail.Address{Name: s.cfg.FromName, Address: s.cfg.FromEmail}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write([]byte(msg.Body)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,180 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"taulen/backend/internal/config"
+)
+
+// twilioMessagesURL is the Twilio Messages endpoint of an account
+const twilioMessagesURL = "https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json"
+
+// Twilio sends text messages through the Twilio Programmable Messaging API
+type Twilio struct {
+	cfg    *config.TwilioConfig
+	client *http.Client
+	logger *slog.Logger
+}
+
+// NewTwilio creates a Twilio SMS sender
+func NewTwilio(cfg *config.TwilioConfig, logger *slog.Logger) *Twilio {
+	return &Twilio{
+		cfg:    cfg,
+		client: &http.Client{},
+		logger: logger,
+	}
+}
+
+// SendSMS sends a text message. Errors Twilio reports for the recipient or the
+// sender are returned with a message that explains how to fix them.
+func (s *Twilio) SendSMS(ctx context.Context, msg SMS) error {
+	if s.cfg.AccountSID == "" {
+		return fmt.Errorf("%w: Twilio AccountSID is missing", ErrNotConfigured)
+	}
+	// Need either AuthToken or APIKeySID+AuthToken (where AuthToken is API Key Secret)
+	if s.cfg.AuthToken == "" {
+		return fmt.Errorf("%w: Twilio AuthToken or API Key Secret must be set", ErrNotConfigured)
+	}
+	if s.cfg.MessagingServiceSID == "" && s.cfg.FromPhone == "" {
+		return fmt.Errorf("%w: either Twilio FromPhone or MessagingServiceSID must be set", ErrNotConfigured)
+	}
+
+	s.logger.DebugContext(ctx, "sending SMS via Twilio", "phone", msg.To,
+		"account_sid", s.cfg.AccountSID, "messaging_service_sid", s.cfg.MessagingServiceSID, "from", s.cfg.FromPhone)
+
+	apiURL := fmt.Sprintf(twilioMessagesURL, s.cfg.AccountSID)
+
+	data := url.Values{}
+	// Use Messaging Service SID if available (recommended for paid accounts), otherwise use FromPhone
+	if s.cfg.MessagingServiceSID != "" {
+		data.Set("MessagingServiceSid", s.cfg.MessagingServiceSID)
+	} else {
+		data.Set("From", s.cfg.FromPhone)
+	}
+	data.Set("To", msg.To)
+	data.Set("Body", msg.Body)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create SMS request: %w", err)
+	}
+
+	// Use API Key SID if provided, otherwise use Account SID
+	authSID := s.cfg.AccountSID
+	if s.cfg.APIKeySID != "" {
+		authSID = s.cfg.APIKeySID
+	}
+	req.SetBasicAuth(authSID, s.cfg.AuthToken)
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Twilio request failed", "error", err)
+		return fmt.Errorf("failed to send SMS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body for error details
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to read Twilio response body", "error", err)
+	}
+
+	// Log the full response for debugging (both success and error)
+	s.logger.DebugContext(ctx, "Twilio API response", "status", resp.StatusCode, "body", string(body))
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+
+		// Try to parse Twilio error response
+		var twilioError struct {
+			Code     int    `json:"code"`
+			Message  string `json:"message"`
+			Status   int    `json:"status"`
+			MoreInfo string `json:"more_info,omitempty"`
+		}
+		if err := json.Unmarshal(body, &twilioError); err == nil && twilioError.Message != "" {
+			s.logger.ErrorContext(ctx, "Twilio rejected SMS", "code", twilioError.Code,
+				"status", twilioError.Status, "message", twilioError.Message, "more_info", twilioError.MoreInfo)
+			// Return user-friendly error message
+			errorMsg := twilioError.Message
+			if twilioError.Code == 21211 {
+				errorMsg = "Invalid phone number format. Please enter a valid phone number."
+			} else if twilioError.Code == 21608 {
+				errorMsg = "Unable to send SMS to this phone number. The number may need to be verified in your Twilio account. Please contact support if this issue persists."
+			} else if twilioError.Code == 21610 {
+				errorMsg = "Unable to send SMS to this phone number. Please verify the phone number in your Twilio console or contact support."
+			} else if twilioError.Code == 21408 {
+				errorMsg = "Permission denied. This phone number cannot receive SMS messages."
+			} else if twilioError.Code == 20003 {
+				errorMsg = "Authentication failed. Please check Twilio Account SID and Auth Token."
+			} else if twilioError.Code == 21212 {
+				errorMsg = "Invalid 'To' phone number."
+			} else if twilioError.Code == 21214 {
+				errorMsg = "Invalid 'From' phone number. Please verify your Twilio phone number is active and properly configured."
+			} else if twilioError.Code == 30032 {
+				errorMsg = "Unregistered sender. Your Twilio phone number is not registered for sending SMS. Please register your phone number in Twilio Console or use a Messaging Service."
+			} else if twilioError.Code == 30034 {
+				errorMsg = "Phone number not registered for A2P messaging. Your Twilio phone number needs to be registered for US A2P 10DLC compliance. Please register your brand and campaign in Twilio Console (Messaging > Regulatory Compliance > A2P 10DLC) or use a Messaging Service."
+			} else if twilioError.Code == 21614 {
+				errorMsg = "Unsubscribed recipient. The recipient has opted out of receiving messages."
+			} else if twilioError.Code == 30008 {
+				errorMsg = "Unknown destination handset. The phone number may be invalid or unreachable."
+			}
+			return fmt.Errorf("failed to send SMS: %s (Code: %d)", errorMsg, twilioError.Code)
+		}
+
+		// If JSON parsing failed, log the raw response
+		s.logger.ErrorContext(ctx, "Twilio returned an unparseable error response", "status", resp.StatusCode, "body", string(body))
+		return fmt.Errorf("failed to send SMS: Twilio API returned status %d. Response: %s", resp.StatusCode, string(body))
+	}
+
+	// Parse successful response to get message SID and status
+	var twilioResponse struct {
+		SID          string  `json:"sid"`
+		Status       string  `json:"status"`
+		To           string  `json:"to"`
+		From         string  `json:"from"`
+		Body         string  `json:"body"`
+		ErrorCode    *int    `json:"error_code"`
+		ErrorMessage *string `json:"error_message"`
+	}
+	if err := json.Unmarshal(body, &twilioResponse); err == nil {
+		s.logger.DebugContext(ctx, "Twilio accepted message", "message_sid", twilioResponse.SID,
+			"message_status", twilioResponse.Status, "phone", twilioResponse.To, "from", twilioResponse.From)
+
+		// Check for delivery errors even if HTTP status was 201
+		if twilioResponse.ErrorCode != nil {
+			errorCode := *twilioResponse.ErrorCode
+			errorMsg := "Unknown error"
+			if twilioResponse.ErrorMessage != nil {
+				errorMsg = *twilioResponse.ErrorMessage
+			}
+			s.logger.WarnContext(ctx, "Twilio message has an error code", "code", errorCode, "message", errorMsg)
+
+			// Handle specific error codes
+			if errorCode == 30032 {
+				s.logger.ErrorContext(ctx, "Twilio sender is unregistered; register the phone number in Twilio Console or use a Messaging Service SID",
+					"code", errorCode)
+				return fmt.Errorf("failed to send SMS: Unregistered sender (Error 30032). Please register your phone number in Twilio Console or use a Messaging Service.")
+			} else if errorCode == 30034 {
+				s.logger.ErrorContext(ctx, "Twilio sender needs A2P 10DLC registration; register the brand and campaign in Twilio Console or use a Messaging Service SID",
+					"code", errorCode)
+				return fmt.Errorf("failed to send SMS: Phone number not registered for A2P messaging (Error 30034). Please register for A2P 10DLC compliance in Twilio Console or use a Messaging Service.")
+			}
+		}
+
+		// Warn if status indicates potential delivery issues
+		if twilioResponse.Status == "undelivered" || twilioResponse.Status == "failed" {
+			s.logger.WarnContext(ctx, "Twilio message may not be delivered", "message_status", twilioResponse.Status)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+// Package oidc is a minimal OpenID Connect relying party for the authorization
+// code flow with PKCE (RFC 7636). It discovers the provider's endpoints,
+// builds authorization URLs, redeems codes and verifies ID tokens against the
+// provider's published signing keys.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"taulen/backend/internal/config"
+)
+
+// requestTimeout bounds each request to the provider
+const requestTimeout = 10 * time.Second
+
+var (
+	// ErrProvider is returned when the provider cannot be reached or answers unexpectedly
+	ErrProvider = errors.New("identity provider unavailable")
+	// ErrCodeRejected is returned when the provider refuses to redeem an authorization code
+	ErrCodeRejected = errors.New("authorization code rejected by the identity provider")
+	// ErrInvalidIDToken is returned for ID tokens that fail verification
+	ErrInvalidIDToken = errors.New("invalid ID token")
+)
+
+// metadata is the part of the provider's discovery document used here
+type metadata struct {
+	Issuer                 string   `json:"issuer"`
+	AuthorizationEndpoint  string   `json:"authorization_endpoint"`
+	TokenEndpoint          string   `json:"token_endpoint"`
+	JWKSURI                string   `json:"jwks_uri"`
+	TokenEndpointAuthMeths []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// Provider is an OpenID Connect provider. Its discovery document is fetched on
+// first use and its signing keys are refetched when a token names an unknown key.
+type Provider struct {
+	cfg    *config.OIDCConfig
+	scopes []string
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     *keySet
+}
+
+// NewProvider creates a provider for the configured issuer
+func NewProvider(cfg *config.OIDCConfig) *Provider {
+	scopes := cfg.Scopes
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+	return &Provider{
+		cfg:    cfg,
+		scopes: scopes,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// IDToken holds the verified claims of an ID token
+type IDToken struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	// Claims are all claims of the token
+	Claims jwt.MapClaims
+}
+
+// Groups returns the string values of a claim listing groups; a single string
+// is treated as one group
+func (t *IDToken) Groups(claim string) []string {
+	switch value := t.Claims[claim].(type) {
+	case string:
+		return []string{value}
+	case []any:
+		groups := make([]string, 0, len(value))
+		for _, group := range value {
+			if s, ok := group.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	default:
+		return nil
+	}
+}
+
+// NewCodeVerifier returns a random PKCE code verifier
+func NewCodeVerifier() string {
+	return rand.Text() + rand.Text()
+}
+
+// codeChallenge returns the S256 PKCE challenge for a verifier
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the provider URL that starts a login. The state and
+// nonce are checked when the login completes; the code verifier must be kept
+// by the client and presented with the code.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return md.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified ID token,
+// which must carry the nonce of the authorization request
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*IDToken, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	basicAuth := p.cfg.ClientSecret != "" &&
+		(len(md.TokenEndpointAuthMeths) == 0 || slices.Contains(md.TokenEndpointAuthMeths, "client_secret_basic"))
+	if p.cfg.ClientSecret != "" && !basicAuth {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basicAuth {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &token)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusBadRequest || status == http.StatusUnauthorized {
+		return nil, fmt.Errorf("%w: %s", ErrCodeRejected, strings.TrimSpace(token.Error+" "+token.ErrorDescription))
+	}
+	if status != http.StatusOK || token.IDToken == "" {
+		return nil, fmt.Errorf("%w: token endpoint answered %d", ErrProvider, status)
+	}
+
+	return p.verify(ctx, md, token.IDToken, nonce)
+}
+
+// verify checks an ID token's signature, issuer, audience, lifetime and nonce
+func (p *Provider) verify(ctx context.Context, md *metadata, rawToken, nonce string) (*IDToken, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.key(ctx, md, kid)
+		},
+		jwt.WithValidMethods(signingAlgorithms),
+		jwt.WithIssuer(md.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		if errors.Is(err, ErrProvider) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	// A token issued to several clients must name us as the authorized party
+	audience, _ := claims.GetAudience()
+	if azp, ok := claims["azp"].(string); (ok || len(audience) > 1) && azp != p.cfg.ClientID {
+		return nil, fmt.Errorf("%w: issued to another client", ErrInvalidIDToken)
+	}
+	if claimNonce, _ := claims["nonce"].(string); claimNonce == "" || claimNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	idToken := &IDToken{Subject: subject, Claims: claims}
+	idToken.Email, _ = claims["email"].(string)
+	idToken.GivenName, _ = claims["given_name"].(string)
+	idToken.FamilyName, _ = claims["family_name"].(string)
+	// Some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		idToken.EmailVerified = verified
+	case string:
+		idToken.EmailVerified = verified == "true"
+	}
+	return idToken, nil
+}
+
+// discover fetches and caches the provider's discovery document
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.cfg.IssuerURL, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	md := &metadata{}
+	status, err := p.doJSON(req, md)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: discovery answered %d", ErrProvider, status)
+	}
+	if md.Issuer != p.cfg.IssuerURL {
+		return nil, fmt.Errorf("%w: discovery names issuer %q", ErrProvider, md.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document is incomplete", ErrProvider)
+	}
+
+	p.metadata = md
+	return md, nil
+}
+
+// doJSON sends a request and decodes a JSON response body into v, returning the status code
+func (p *Provider) doJSON(req *http.Request, v any) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("%w: invalid response: %v", ErrProvider, err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,179 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/oidc/oidctest"
+)
+
+const testClientID = "taulen"
+
+// newTestProvider starts a stand-in provider and returns a relying party for it
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Provider) {
+	t.Helper()
+	idp, err := oidctest.NewProvider(testClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(idp.Close)
+	return NewProvider(&config.OIDCConfig{
+		IssuerURL:   idp.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:3000/sso/callback",
+		Scopes:      []string{"email", "profile"},
+	}), idp
+}
+
+// login starts a login, has the provider authorize it with claims and redeems
+// the code with verifier
+func login(t *testing.T, p *Provider, idp *oidctest.Provider, claims jwt.MapClaims, verifier string) (*IDToken, error) {
+	t.Helper()
+	ctx := context.Background()
+	codeVerifier := NewCodeVerifier()
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", codeVerifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, _, err := idp.Authorize(authURL, claims)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if verifier == "" {
+		verifier = codeVerifier
+	}
+	return p.Exchange(ctx, code, verifier, "nonce")
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	p, idp := newTestProvider(t)
+
+	authURL, err := p.AuthCodeURL(context.Background(), "the-state", "the-nonce", "the-verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != idp.URL+"/authorize" {
+		t.Errorf("endpoint = %q, want the discovered authorization endpoint", got)
+	}
+	params := u.Query()
+	want := map[string]string{
+		"client_id":             testClientID,
+		"redirect_uri":          "http://localhost:3000/sso/callback",
+		"scope":                 "openid email profile",
+		"state":                 "the-state",
+		"nonce":                 "the-nonce",
+		"code_challenge":        codeChallenge("the-verifier"),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if params.Get(name) != value {
+			t.Errorf("%s = %q, want %q", name, params.Get(name), value)
+		}
+	}
+	// The verifier itself never leaves the relying party
+	if strings.Contains(authURL, "the-verifier") {
+		t.Error("the authorization URL contains the code verifier")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	p, idp := newTestProvider(t)
+
+	token, err := login(t, p, idp, jwt.MapClaims{
+		"sub":            "subject-1",
+		"email":          "jane@example.com",
+		"email_verified": "true",
+		"given_name":     "Jane",
+		"family_name":    "Doe",
+		"groups":         []string{"loan-officers", "everyone"},
+		"azp":            testClientID,
+	}, "")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if token.Subject != "subject-1" || token.Email != "jane@example.com" || !token.EmailVerified ||
+		token.GivenName != "Jane" || token.FamilyName != "Doe" {
+		t.Errorf("token = %+v", token)
+	}
+	if groups := token.Groups("groups"); len(groups) != 2 || groups[0] != "loan-officers" {
+		t.Errorf("Groups = %v", groups)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	p, idp := newTestProvider(t)
+	sub := jwt.MapClaims{"sub": "subject-1"}
+	with := func(claims jwt.MapClaims) jwt.MapClaims {
+		claims["sub"] = "subject-1"
+		return claims
+	}
+
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		verifier string
+		want     error
+	}{
+		{"wrong PKCE verifier", sub, "not-the-verifier", ErrCodeRejected},
+		{"nonce mismatch", with(jwt.MapClaims{"nonce": "another-nonce"}), "", ErrInvalidIDToken},
+		{"no nonce", with(jwt.MapClaims{"nonce": ""}), "", ErrInvalidIDToken},
+		{"azp of another client", with(jwt.MapClaims{"azp": "another-client"}), "", ErrInvalidIDToken},
+		{"several audiences without azp", with(jwt.MapClaims{"aud": []string{testClientID, "another-client"}}), "", ErrInvalidIDToken},
+		{"another audience", with(jwt.MapClaims{"aud": "another-client"}), "", ErrInvalidIDToken},
+		{"another issuer", with(jwt.MapClaims{"iss": "https://issuer.example.com"}), "", ErrInvalidIDToken},
+		{"expired", with(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), "", ErrInvalidIDToken},
+		{"no subject", jwt.MapClaims{}, "", ErrInvalidIDToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := login(t, p, idp, tt.claims, tt.verifier); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Several audiences are accepted when azp names this client
+	if _, err := login(t, p, idp, with(jwt.MapClaims{"aud": []string{testClientID, "another-client"}, "azp": testClientID}), ""); err != nil {
+		t.Fatalf("several audiences with azp: %v", err)
+	}
+}
+
+func TestExchangeRejectsReplayedCode(t *testing.T) {
+	p, idp := newTestProvider(t)
+	ctx := context.Background()
+
+	verifier := NewCodeVerifier()
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := idp.Authorize(authURL, jwt.MapClaims{"sub": "subject-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Exchange(ctx, code, verifier, "nonce"); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if _, err := p.Exchange(ctx, code, verifier, "nonce"); !errors.Is(err, ErrCodeRejected) {
+		t.Fatalf("replayed code: got %v, want ErrCodeRejected", err)
+	}
+}
+
+func TestDiscoveryRejectsAnotherIssuer(t *testing.T) {
+	_, idp := newTestProvider(t)
+	p := NewProvider(&config.OIDCConfig{IssuerURL: idp.URL + "/", ClientID: testClientID})
+
+	if _, err := p.AuthCodeURL(context.Background(), "state", "nonce", NewCodeVerifier()); !errors.Is(err, ErrProvider) {
+		t.Fatalf("got %v, want ErrProvider", err)
+	}
+}
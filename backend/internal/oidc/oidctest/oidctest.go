@@ -0,0 +1,162 @@
+// Package oidctest runs a stand-in OpenID Connect provider for tests of the
+// authorization code flow with PKCE. It serves discovery, a key set and a token
+// endpoint; logins are completed by calling Authorize with the authorization
+// URL and the claims the provider should vouch for.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keyID names the provider's only signing key
+const keyID = "oidctest"
+
+// Provider is a running stand-in provider; Close stops it
+type Provider struct {
+	*httptest.Server
+	ClientID string
+
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	logins map[string]login // by authorization code
+}
+
+// login is an authorization waiting for its code to be redeemed
+type login struct {
+	redirectURI string
+	challenge   string
+	claims      jwt.MapClaims
+}
+
+// NewProvider starts a provider for the given client. Its issuer is the
+// server's URL.
+func NewProvider(clientID string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	p := &Provider{ClientID: clientID, key: key, logins: make(map[string]login)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("POST /token", p.token)
+	p.Server = httptest.NewServer(mux)
+	return p, nil
+}
+
+// Authorize completes a login started at authURL, as the provider would after
+// the user signed in, and returns the code and state it sends back. The ID
+// token for the code carries the request's nonce and the given claims, which
+// may override the standard ones.
+func (p *Provider) Authorize(authURL string, claims jwt.MapClaims) (code, state string, err error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme+"://"+u.Host != p.URL || u.Path != "/authorize" {
+		return "", "", fmt.Errorf("authorization URL %q is not this provider's", authURL)
+	}
+	params := u.Query()
+	switch {
+	case params.Get("response_type") != "code":
+		return "", "", fmt.Errorf("response_type %q", params.Get("response_type"))
+	case params.Get("client_id") != p.ClientID:
+		return "", "", fmt.Errorf("client_id %q", params.Get("client_id"))
+	case params.Get("code_challenge_method") != "S256" || params.Get("code_challenge") == "":
+		return "", "", fmt.Errorf("missing S256 code challenge")
+	case params.Get("state") == "" || params.Get("nonce") == "":
+		return "", "", fmt.Errorf("missing state or nonce")
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   p.URL,
+		"aud":   p.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": params.Get("nonce"),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	code = rand.Text()
+	p.mu.Lock()
+	p.logins[code] = login{
+		redirectURI: params.Get("redirect_uri"),
+		challenge:   params.Get("code_challenge"),
+		claims:      idClaims,
+	}
+	p.mu.Unlock()
+	return code, params.Get("state"), nil
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/authorize",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/jwks",
+		"token_endpoint_auth_methods_supported": []string{"none"},
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": keyID,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+	}}})
+}
+
+// token redeems a code once, checking the redirect URI, client and PKCE verifier
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	p.mu.Lock()
+	l, ok := p.logins[r.PostForm.Get("code")]
+	delete(p.logins, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || r.PostForm.Get("client_id") != p.ClientID || r.PostForm.Get("redirect_uri") != l.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != l.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, l.claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id_token": idToken, "token_type": "Bearer"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
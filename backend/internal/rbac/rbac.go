@@ -0,0 +1,152 @@
+// Package rbac defines the roles carried in access tokens, the scopes carried
+// by API keys and the permissions each role or scope is granted. Route groups
+// are guarded by permission (see middleware.RequirePermission) rather than by
+// role, so that granting a role new capabilities only requires changing
+// rolePermissions.
+package rbac
+
+import "strings"
+
+// Role identifies what kind of user a token was issued to
+type Role string
+
+// Roles; employees have one of the first four, borrowers are always applicants
+const (
+	RoleLoanOfficer Role = "loan_officer"
+	RoleUnderwriter Role = "underwriter"
+	RoleProcessor   Role = "processor"
+	RoleAdmin       Role = "admin"
+	RoleApplicant   Role = "applicant"
+	// RoleIntegration is the role of requests made with an API key. It is
+	// granted no permissions itself; the key's scopes decide what it may do.
+	RoleIntegration Role = "integration"
+)
+
+// Permission is a capability checked by route guards
+type Permission string
+
+// Permissions
+const (
+	PermApplicationsCreate       Permission = "applications:create"
+	PermApplicationsRead         Permission = "applications:read"
+	PermApplicationsWrite        Permission = "applications:write"
+	PermApplicationsSubmit       Permission = "applications:submit"        // set status to submitted
+	PermApplicationsUpdateStatus Permission = "applications:update_status" // set any status
+	PermApplicationsExport       Permission = "applications:export"        // list every application
+	PermApplicationsDelegate     Permission = "applications:delegate"      // act on behalf of a borrower
+	PermApplicationsAssign       Permission = "applications:assign"        // assign employees to an application
+	PermUnderwritingReview       Permission = "underwriting:review"
+	PermEmployeesManage          Permission = "employees:manage"
+)
+
+// rolePermissions lists the permissions granted to each role
+var rolePermissions = map[Role][]Permission{
+	RoleApplicant: {
+		PermApplicationsCreate, PermApplicationsRead, PermApplicationsWrite,
+		PermApplicationsSubmit,
+	},
+	RoleLoanOfficer: {
+		PermApplicationsCreate, PermApplicationsRead, PermApplicationsWrite,
+		PermApplicationsSubmit, PermApplicationsUpdateStatus, PermApplicationsDelegate,
+		PermApplicationsAssign,
+	},
+	RoleProcessor: {
+		PermApplicationsRead, PermApplicationsWrite,
+		PermApplicationsSubmit, PermApplicationsUpdateStatus, PermApplicationsDelegate,
+	},
+	RoleUnderwriter: {
+		PermApplicationsRead,
+		PermApplicationsUpdateStatus, PermUnderwritingReview,
+	},
+	RoleAdmin: {
+		PermApplicationsCreate, PermApplicationsRead, PermApplicationsWrite,
+		PermApplicationsSubmit, PermApplicationsUpdateStatus, PermApplicationsExport,
+		PermApplicationsDelegate, PermApplicationsAssign, PermUnderwritingReview,
+		PermEmployeesManage,
+	},
+}
+
+// Scope is a capability granted to an API key
+type Scope string
+
+// Scopes
+const (
+	ScopeDealsRead  Scope = "deals:read"
+	ScopeDealsWrite Scope = "deals:write"
+	ScopeExport     Scope = "export"
+)
+
+// scopePermissions lists the permissions granted by each scope. API keys
+// cannot create applications, submit them for borrowers or manage employees.
+var scopePermissions = map[Scope][]Permission{
+	ScopeDealsRead:  {PermApplicationsRead},
+	ScopeDealsWrite: {PermApplicationsWrite, PermApplicationsUpdateStatus},
+	ScopeExport:     {PermApplicationsExport},
+}
+
+// employeeRoles maps the user_role values stored in the schema to roles
+var employeeRoles = map[string]Role{
+	"LoanOfficer": RoleLoanOfficer,
+	"Underwriter": RoleUnderwriter,
+	"Processor":   RoleProcessor,
+	"Admin":       RoleAdmin,
+}
+
+// ParseRole returns the role named s, accepting both token format ("loan_officer")
+// and the schema's user_role format ("LoanOfficer"). ok is false for unknown roles.
+func ParseRole(s string) (role Role, ok bool) {
+	if r, found := employeeRoles[s]; found {
+		return r, true
+	}
+	role = Role(strings.ToLower(strings.TrimSpace(s)))
+	_, ok = rolePermissions[role]
+	return role, ok
+}
+
+// EmployeeRole returns the role of an employee from the user_role stored in the
+// schema; unknown values yield an empty role, which is granted no permissions
+func EmployeeRole(userRole string) Role {
+	role, ok := ParseRole(userRole)
+	if !ok || role == RoleApplicant {
+		return ""
+	}
+	return role
+}
+
+// IsEmployee reports whether r is an employee role
+func (r Role) IsEmployee() bool {
+	_, ok := rolePermissions[r]
+	return ok && r != RoleApplicant
+}
+
+// Has reports whether r is granted permission p
+func (r Role) Has(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions returns the permissions granted to r
+func (r Role) Permissions() []Permission {
+	return append([]Permission{}, rolePermissions[r]...)
+}
+
+// ParseScope returns the scope named s; ok is false for unknown scopes
+func ParseScope(s string) (scope Scope, ok bool) {
+	scope = Scope(strings.ToLower(strings.TrimSpace(s)))
+	_, ok = scopePermissions[scope]
+	return scope, ok
+}
+
+// Has reports whether s grants permission p
+func (s Scope) Has(p Permission) bool {
+	for _, granted := range scopePermissions[s] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
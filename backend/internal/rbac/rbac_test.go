@@ -0,0 +1,71 @@
+package rbac
+
+import "testing"
+
+func TestRolePermissions(t *testing.T) {
+	all := []Permission{
+		PermApplicationsCreate, PermApplicationsRead, PermApplicationsWrite, PermApplicationsSubmit,
+		PermApplicationsUpdateStatus, PermApplicationsExport, PermApplicationsDelegate,
+		PermApplicationsAssign, PermUnderwritingReview, PermEmployeesManage,
+	}
+	granted := map[Role][]Permission{
+		RoleApplicant: {PermApplicationsCreate, PermApplicationsRead, PermApplicationsWrite, PermApplicationsSubmit},
+		RoleLoanOfficer: {
+			PermApplicationsCreate, PermApplicationsRead, PermApplicationsWrite, PermApplicationsSubmit,
+			PermApplicationsUpdateStatus, PermApplicationsDelegate, PermApplicationsAssign,
+		},
+		RoleProcessor: {
+			PermApplicationsRead, PermApplicationsWrite, PermApplicationsSubmit,
+			PermApplicationsUpdateStatus, PermApplicationsDelegate,
+		},
+		RoleUnderwriter: {PermApplicationsRead, PermApplicationsUpdateStatus, PermUnderwritingReview},
+		RoleAdmin:       all,
+		// Integrations act with the permissions of their key's scopes only
+		RoleIntegration: nil,
+		"":              nil,
+	}
+	for role, perms := range granted {
+		want := map[Permission]bool{}
+		for _, perm := range perms {
+			want[perm] = true
+		}
+		for _, perm := range all {
+			if got := role.Has(perm); got != want[perm] {
+				t.Errorf("%q.Has(%s) = %v, want %v", role, perm, got, want[perm])
+			}
+		}
+	}
+}
+
+func TestEmployeeRole(t *testing.T) {
+	tests := map[string]Role{
+		"LoanOfficer":  RoleLoanOfficer,
+		"loan_officer": RoleLoanOfficer,
+		"Admin":        RoleAdmin,
+		"applicant":    "",
+		"Borrower":     "",
+		"integration":  "",
+	}
+	for userRole, want := range tests {
+		if got := EmployeeRole(userRole); got != want {
+			t.Errorf("EmployeeRole(%q) = %q, want %q", userRole, got, want)
+		}
+	}
+	if RoleApplicant.IsEmployee() || RoleIntegration.IsEmployee() || !RoleUnderwriter.IsEmployee() {
+		t.Error("IsEmployee must hold for employee roles only")
+	}
+}
+
+func TestScopePermissions(t *testing.T) {
+	read, ok := ParseScope("deals:read")
+	if !ok || !read.Has(PermApplicationsRead) || read.Has(PermApplicationsWrite) {
+		t.Errorf("deals:read must grant reads only")
+	}
+	write, ok := ParseScope("deals:write")
+	if !ok || !write.Has(PermApplicationsWrite) || write.Has(PermApplicationsCreate) || write.Has(PermApplicationsSubmit) {
+		t.Errorf("deals:write must grant writes but not creating or submitting applications")
+	}
+	if _, ok := ParseScope("employees:manage"); ok {
+		t.Error("no scope may manage employees")
+	}
+}
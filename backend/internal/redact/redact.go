@@ -0,0 +1,198 @@
+// Package redact masks personally identifiable information from the URLA
+// (SSNs, taxpayer identifiers, account numbers, birth dates, phone numbers and
+// email addresses) before it reaches log output or client-facing error messages.
+//
+// Structured values are masked by key (see IsSensitiveKey); free text such as
+// error messages is scrubbed by pattern (see String).
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Mask replaces every redacted value
+const Mask = "[REDACTED]"
+
+// sensitiveKeys lists the normalized field names whose values are always masked.
+// Both the JSON (camelCase) and database (snake_case) spellings normalize to these.
+var sensitiveKeys = map[string]bool{
+	"ssn":                     true,
+	"taxpayeridentifier":      true,
+	"taxpayeridentifiervalue": true,
+	"taxpayerid":              true,
+	"taxpayeridvalue":         true,
+	"accountnumber":           true,
+	"birthdate":               true,
+	"dateofbirth":             true,
+	"dob":                     true,
+	"phone":                   true,
+	"phonenumber":             true,
+	"mobilephone":             true,
+	"homephone":               true,
+	"workphone":               true,
+	"email":                   true,
+	"emailaddress":            true,
+}
+
+// patterns match sensitive values embedded in free text, most specific first
+var patterns = []*regexp.Regexp{
+	// Email addresses
+	regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	// SSNs and taxpayer identifiers, formatted (123-45-6789) or not (123456789)
+	regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b|\b\d{9}\b`),
+	// US phone numbers: 1234567890, 123-456-7890, (123) 456-7890, +1 123.456.7890
+	regexp.MustCompile(`(?:\+?1[\-. ]?)?(?:\(\d{3}\)|\b\d{3})[\-. ]?\d{3}[\-. ]?\d{4}\b`),
+	// Dates, which in URLA data are mostly birth dates: 1990-01-31, 01/31/1990
+	regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}\b|\b\d{1,2}/\d{1,2}/\d{4}\b`),
+	// Bank account numbers: long digit runs
+	regexp.MustCompile(`\b\d{10,17}\b`),
+}
+
+// IsSensitiveKey reports whether values stored under key must always be masked.
+// Matching ignores case, underscores, hyphens and dots, so "taxpayer_identifier_value",
+// "taxpayerIdentifierValue" and "SSN" are all recognized.
+func IsSensitiveKey(key string) bool {
+	return sensitiveKeys[normalizeKey(key)]
+}
+
+// normalizeKey lowercases key and strips separators
+func normalizeKey(key string) string {
+	var b strings.Builder
+	b.Grow(len(key))
+	for _, r := range strings.ToLower(key) {
+		if r == '_' || r == '-' || r == '.' || r == ' ' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// String masks every sensitive value found in free text
+func String(s string) string {
+	for _, p := range patterns {
+		s = p.ReplaceAllString(s, Mask)
+	}
+	return s
+}
+
+// Error returns err's message with sensitive values masked, for logging or
+// returning to clients. A nil error yields an empty string.
+func Error(err error) string {
+	if err == nil {
+		return ""
+	}
+	return String(err.Error())
+}
+
+// Value returns v masked according to the key it is stored under: values of
+// sensitive keys are replaced entirely, strings are scrubbed, maps and slices
+// are redacted recursively and structs by their fields (see composite)
+func Value(key string, v interface{}) interface{} {
+	if IsSensitiveKey(key) {
+		if v == nil {
+			return nil
+		}
+		return Mask
+	}
+	switch val := v.(type) {
+	case string:
+		return String(val)
+	case error:
+		return Error(val)
+	case map[string]interface{}:
+		return Map(val)
+	case map[string]string:
+		out := make(map[string]interface{}, len(val))
+		for k, s := range val {
+			out[k] = Value(k, s)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = Value(key, item)
+		}
+		return out
+	case []string:
+		out := make([]string, len(val))
+		for i, s := range val {
+			out[i] = String(s)
+		}
+		return out
+	case json.Number:
+		return String(val.String())
+	}
+	return composite(key, v)
+}
+
+// composite redacts other structs, maps, slices and arrays, and pointers to
+// them, through their JSON representation, so struct fields are masked by name
+// like map keys (e.g. a Borrower's TaxpayerIdentifierValue or BirthDate).
+// Values that cannot be represented as JSON are masked entirely; other values
+// are returned unchanged.
+func composite(key string, v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return v
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+	default:
+		return v
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Mask
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return Mask
+	}
+	return Value(key, decoded)
+}
+
+// Map returns a redacted copy of m, such as a form payload; m is not modified
+func Map(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = Value(k, v)
+	}
+	return out
+}
+
+// ReplaceAttr redacts log attributes; use it as slog.HandlerOptions.ReplaceAttr so
+// that every record, including its message, is masked before it is written
+func ReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitiveKey(a.Key) {
+		return slog.String(a.Key, Mask)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, String(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, Error(v))
+		case []byte:
+			return slog.String(a.Key, String(string(v)))
+		default:
+			return slog.Any(a.Key, Value(a.Key, v))
+		}
+	}
+	return a
+}
@@ -0,0 +1,121 @@
+package redact
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"ssn 123-45-6789 rejected", "ssn [REDACTED] rejected"},
+		{"ssn 123456789 rejected", "ssn [REDACTED] rejected"},
+		{"mail jane.doe+urla@example.com", "mail [REDACTED]"},
+		{"call (555) 123-4567", "call [REDACTED]"},
+		{"call +1 555.123.4567", "call [REDACTED]"},
+		{"born 1990-01-31 or 01/31/1990", "born [REDACTED] or [REDACTED]"},
+		{"account 000123456789012", "account [REDACTED]"},
+		{"deal 42 saved", "deal 42 saved"},
+	}
+	for _, tt := range tests {
+		if got := String(tt.in); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsSensitiveKey(t *testing.T) {
+	for _, key := range []string{"ssn", "SSN", "taxpayer_identifier_value", "taxpayerIdentifierValue", "TaxpayerIDValue", "dateOfBirth", "birth_date", "mobilePhone", "EmailAddress"} {
+		if !IsSensitiveKey(key) {
+			t.Errorf("IsSensitiveKey(%q) = false, want true", key)
+		}
+	}
+	for _, key := range []string{"firstName", "deal_id", "status"} {
+		if IsSensitiveKey(key) {
+			t.Errorf("IsSensitiveKey(%q) = true, want false", key)
+		}
+	}
+}
+
+type borrower struct {
+	FirstName       string
+	TaxpayerIDValue sql.NullString
+	BirthDate       sql.NullTime
+	MobilePhone     string
+	Notes           string
+	Residences      []residence
+}
+
+type residence struct {
+	Street string `json:"street"`
+	Phone  string `json:"phone"`
+}
+
+func TestValueRedactsStructFields(t *testing.T) {
+	b := &borrower{
+		FirstName:       "Jane",
+		TaxpayerIDValue: sql.NullString{String: "123-45-6789", Valid: true},
+		BirthDate:       sql.NullTime{Time: time.Date(1990, 1, 31, 0, 0, 0, 0, time.UTC), Valid: true},
+		MobilePhone:     "555-123-4567",
+		Notes:           "reach me at jane@example.com",
+		Residences:      []residence{{Street: "1 Main St", Phone: "555-765-4321"}},
+	}
+
+	got := fmt.Sprint(Value("borrower", b))
+	for _, raw := range []string{"123-45-6789", "1990-01-31", "555-123-4567", "jane@example.com", "555-765-4321"} {
+		if strings.Contains(got, raw) {
+			t.Errorf("Value(borrower) = %s, contains %q", got, raw)
+		}
+	}
+	for _, kept := range []string{"Jane", "1 Main St"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("Value(borrower) = %s, lost %q", got, kept)
+		}
+	}
+	if b.MobilePhone != "555-123-4567" {
+		t.Error("Value modified its argument")
+	}
+}
+
+func TestValueKeepsScalars(t *testing.T) {
+	var nilBorrower *borrower
+	for _, v := range []interface{}{nil, 42, true, 1.5, nilBorrower} {
+		if got := Value("count", v); got != v {
+			t.Errorf("Value(%v) = %v, want it unchanged", v, got)
+		}
+	}
+	if got := Value("ssn", 123456789); got != Mask {
+		t.Errorf("Value(ssn) = %v, want %s", got, Mask)
+	}
+}
+
+func TestErrorAndMap(t *testing.T) {
+	if got := Error(errors.New("duplicate key (ssn)=(123-45-6789)")); strings.Contains(got, "123-45-6789") {
+		t.Errorf("Error = %q", got)
+	}
+	if Error(nil) != "" {
+		t.Error("Error(nil) is not empty")
+	}
+
+	in := map[string]interface{}{
+		"firstName": "Jane",
+		"ssn":       "123456789",
+		"address":   map[string]interface{}{"street": "1 Main St", "phone": "5551234567"},
+	}
+	out := Map(in)
+	if out["ssn"] != Mask || out["firstName"] != "Jane" {
+		t.Errorf("Map = %v", out)
+	}
+	if address := out["address"].(map[string]interface{}); address["phone"] != Mask || address["street"] != "1 Main St" {
+		t.Errorf("Map nested = %v", address)
+	}
+	if in["ssn"] != "123456789" {
+		t.Error("Map modified its argument")
+	}
+}
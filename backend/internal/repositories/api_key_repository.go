@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// APIKey is a key issued to a machine-to-machine integration. Only a hash of
+// the key is stored.
+type APIKey struct {
+	ID          string
+	Name        string
+	KeyPrefix   string   // first characters of the key, shown to tell keys apart
+	KeyHash     string   // hex SHA-256 of the key
+	Scopes      []string // rbac scopes, e.g. "deals:read"
+	CreatedBy   sql.NullString
+	RotatedFrom sql.NullString // the key this one replaced
+	ExpiresAt   time.Time
+	LastUsedAt  sql.NullTime
+	LastUsedIP  sql.NullString
+	RevokedAt   sql.NullTime
+	CreatedAt   sql.NullTime
+}
+
+// apiKeyRepository is the PostgreSQL implementation of APIKeyRepository
+type apiKeyRepository struct {
+	db DBTX
+}
+
+// newAPIKeyRepository creates an API key repository that runs its queries on db
+func newAPIKeyRepository(db DBTX) *apiKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+// apiKeyColumns lists the api_key columns in the order scanned by scanAPIKey
+const apiKeyColumns = `id, name, key_prefix, key_hash, scopes, created_by, rotated_from,
+	expires_at, last_used_at, last_used_ip, revoked_at, created_at`
+
+// scanAPIKey scans a row of apiKeyColumns
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (*APIKey, error) {
+	key := &APIKey{}
+	var scopes string
+	err := row.Scan(
+		&key.ID, &key.Name, &key.KeyPrefix, &key.KeyHash, &scopes, &key.CreatedBy, &key.RotatedFrom,
+		&key.ExpiresAt, &key.LastUsedAt, &key.LastUsedIP, &key.RevokedAt, &key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	return key, nil
+}
+
+// Create stores a new API key; its ID is chosen by the caller
+func (r *apiKeyRepository) Create(key *APIKey) error {
+	query := `INSERT INTO api_key (id, name, key_prefix, key_hash, scopes, created_by, rotated_from, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	          RETURNING created_at`
+	return r.db.QueryRow(query,
+		key.ID, key.Name, key.KeyPrefix, key.KeyHash, strings.Join(key.Scopes, ","),
+		key.CreatedBy, key.RotatedFrom, key.ExpiresAt,
+	).Scan(&key.CreatedAt)
+}
+
+// GetByID retrieves an API key by ID
+func (r *apiKeyRepository) GetByID(id string) (*APIKey, error) {
+	return scanAPIKey(r.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_key WHERE id = $1`, id))
+}
+
+// GetByHash retrieves the API key with the given hash
+func (r *apiKeyRepository) GetByHash(keyHash string) (*APIKey, error) {
+	return scanAPIKey(r.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_key WHERE key_hash = $1`, keyHash))
+}
+
+// List returns every API key, newest first
+func (r *apiKeyRepository) List() ([]*APIKey, error) {
+	rows, err := r.db.Query(`SELECT ` + apiKeyColumns + ` FROM api_key ORDER BY created_at DESC, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke revokes the key unless it already is, and reports whether it did
+func (r *apiKeyRepository) Revoke(id string) (bool, error) {
+	result, err := r.db.Exec(`UPDATE api_key SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// ShortenExpiry makes the key expire at the given time unless it expires earlier
+func (r *apiKeyRepository) ShortenExpiry(id string, expiresAt time.Time) error {
+	_, err := r.db.Exec(`UPDATE api_key SET expires_at = LEAST(expires_at, $2) WHERE id = $1`, id, expiresAt)
+	return err
+}
+
+// RecordUse records when and from which IP address the key was last used
+func (r *apiKeyRepository) RecordUse(id, ip string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE api_key SET last_used_at = $2, last_used_ip = $3 WHERE id = $1`, id, at, ip)
+	return err
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"strings"
 	"time"
-	"taulen/backend/internal/database"
 )
 
 // Borrower represents a borrower in the database
@@ -24,6 +23,7 @@ type Borrower struct {
 	MFABackupCodes              sql.NullString
 	MFASetupAt                  sql.NullTime
 	MFAVerifiedAt               sql.NullTime
+	TwoFactorEnabled            bool // opted in to verification codes at login
 	LastLoginAt                 sql.NullTime
 	FailedLoginAttempts         sql.NullInt64
 	AccountLockedUntil          sql.NullTime
@@ -49,24 +49,22 @@ type Borrower struct {
 	UpdatedAt                   sql.NullTime
 }
 
-// BorrowerRepository handles borrower data access
-type BorrowerRepository struct {
-	db *sql.DB
+// borrowerRepository is the PostgreSQL implementation of BorrowerRepository
+type borrowerRepository struct {
+	db DBTX
 }
 
-// NewBorrowerRepository creates a new borrower repository
-func NewBorrowerRepository() *BorrowerRepository {
-	return &BorrowerRepository{
-		db: database.DB,
-	}
+// newBorrowerRepository creates a borrower repository that runs its queries on db
+func newBorrowerRepository(db DBTX) *borrowerRepository {
+	return &borrowerRepository{db: db}
 }
 
 // GetByEmail retrieves a borrower by email (for authentication)
-func (r *BorrowerRepository) GetByEmail(email string) (*Borrower, error) {
+func (r *borrowerRepository) GetByEmail(email string) (*Borrower, error) {
 	query := `SELECT id, email_address, password_hash, email_verified, email_verification_token, 
 	          email_verification_expires_at, password_reset_token, password_reset_expires_at, 
 	          last_password_change_at, mfa_enabled, mfa_secret, mfa_backup_codes, mfa_setup_at, 
-	          mfa_verified_at, last_login_at, failed_login_attempts, account_locked_until,
+	          mfa_verified_at, two_factor_enabled, last_login_at, failed_login_attempts, account_locked_until,
 	          first_name, middle_name, last_name, suffix, taxpayer_identifier_type, 
 	          taxpayer_identifier_value, birth_date, citizenship_residency_type, marital_status, 
 	          dependent_count, dependent_ages, home_phone, mobile_phone, work_phone, 
@@ -80,7 +78,7 @@ func (r *BorrowerRepository) GetByEmail(email string) (*Borrower, error) {
 		&borrower.EmailVerified, &borrower.EmailVerificationToken, &borrower.EmailVerificationExpiresAt,
 		&borrower.PasswordResetToken, &borrower.PasswordResetExpiresAt, &borrower.LastPasswordChangeAt,
 		&borrower.MFAEnabled, &borrower.MFASecret, &borrower.MFABackupCodes, &borrower.MFASetupAt,
-		&borrower.MFAVerifiedAt, &borrower.LastLoginAt, &borrower.FailedLoginAttempts, &borrower.AccountLockedUntil,
+		&borrower.MFAVerifiedAt, &borrower.TwoFactorEnabled, &borrower.LastLoginAt, &borrower.FailedLoginAttempts, &borrower.AccountLockedUntil,
 		&borrower.FirstName, &borrower.MiddleName, &borrower.LastName, &borrower.Suffix,
 		&borrower.TaxpayerIDType, &borrower.TaxpayerIDValue, &borrower.BirthDate,
 		&borrower.CitizenshipType, &borrower.MaritalStatus, &borrower.DependentCount,
@@ -97,11 +95,11 @@ func (r *BorrowerRepository) GetByEmail(email string) (*Borrower, error) {
 }
 
 // GetByPhone retrieves a borrower by phone number (checks mobile_phone, home_phone, and work_phone)
-func (r *BorrowerRepository) GetByPhone(phone string) (*Borrower, error) {
+func (r *borrowerRepository) GetByPhone(phone string) (*Borrower, error) {
 	query := `SELECT id, email_address, password_hash, email_verified, email_verification_token, 
 	          email_verification_expires_at, password_reset_token, password_reset_expires_at, 
 	          last_password_change_at, mfa_enabled, mfa_secret, mfa_backup_codes, mfa_setup_at, 
-	          mfa_verified_at, last_login_at, failed_login_attempts, account_locked_until,
+	          mfa_verified_at, two_factor_enabled, last_login_at, failed_login_attempts, account_locked_until,
 	          first_name, middle_name, last_name, suffix, taxpayer_identifier_type, 
 	          taxpayer_identifier_value, birth_date, citizenship_residency_type, marital_status, 
 	          dependent_count, dependent_ages, home_phone, mobile_phone, work_phone, 
@@ -117,7 +115,7 @@ func (r *BorrowerRepository) GetByPhone(phone string) (*Borrower, error) {
 		&borrower.EmailVerified, &borrower.EmailVerificationToken, &borrower.EmailVerificationExpiresAt,
 		&borrower.PasswordResetToken, &borrower.PasswordResetExpiresAt, &borrower.LastPasswordChangeAt,
 		&borrower.MFAEnabled, &borrower.MFASecret, &borrower.MFABackupCodes, &borrower.MFASetupAt,
-		&borrower.MFAVerifiedAt, &borrower.LastLoginAt, &borrower.FailedLoginAttempts, &borrower.AccountLockedUntil,
+		&borrower.MFAVerifiedAt, &borrower.TwoFactorEnabled, &borrower.LastLoginAt, &borrower.FailedLoginAttempts, &borrower.AccountLockedUntil,
 		&borrower.FirstName, &borrower.MiddleName, &borrower.LastName, &borrower.Suffix,
 		&borrower.TaxpayerIDType, &borrower.TaxpayerIDValue, &borrower.BirthDate,
 		&borrower.CitizenshipType, &borrower.MaritalStatus, &borrower.DependentCount,
@@ -134,11 +132,11 @@ func (r *BorrowerRepository) GetByPhone(phone string) (*Borrower, error) {
 }
 
 // GetByID retrieves a borrower by ID
-func (r *BorrowerRepository) GetByID(id string) (*Borrower, error) {
+func (r *borrowerRepository) GetByID(id string) (*Borrower, error) {
 	query := `SELECT id, email_address, password_hash, email_verified, email_verification_token, 
 	          email_verification_expires_at, password_reset_token, password_reset_expires_at, 
 	          last_password_change_at, mfa_enabled, mfa_secret, mfa_backup_codes, mfa_setup_at, 
-	          mfa_verified_at, last_login_at, failed_login_attempts, account_locked_until,
+	          mfa_verified_at, two_factor_enabled, last_login_at, failed_login_attempts, account_locked_until,
 	          first_name, middle_name, last_name, suffix, taxpayer_identifier_type, 
 	          taxpayer_identifier_value, birth_date, citizenship_residency_type, marital_status, 
 	          dependent_count, dependent_ages, home_phone, mobile_phone, work_phone, 
@@ -153,7 +151,7 @@ func (r *BorrowerRepository) GetByID(id string) (*Borrower, error) {
 		&borrower.EmailVerified, &borrower.EmailVerificationToken, &borrower.EmailVerificationExpiresAt,
 		&borrower.PasswordResetToken, &borrower.PasswordResetExpiresAt, &borrower.LastPasswordChangeAt,
 		&borrower.MFAEnabled, &borrower.MFASecret, &borrower.MFABackupCodes, &borrower.MFASetupAt,
-		&borrower.MFAVerifiedAt, &borrower.LastLoginAt, &borrower.FailedLoginAttempts, &borrower.AccountLockedUntil,
+		&borrower.MFAVerifiedAt, &borrower.TwoFactorEnabled, &borrower.LastLoginAt, &borrower.FailedLoginAttempts, &borrower.AccountLockedUntil,
 		&borrower.FirstName, &borrower.MiddleName, &borrower.LastName, &borrower.Suffix,
 		&borrower.TaxpayerIDType, &borrower.TaxpayerIDValue, &borrower.BirthDate,
 		&borrower.CitizenshipType, &borrower.MaritalStatus, &borrower.DependentCount,
@@ -168,7 +166,7 @@ func (r *BorrowerRepository) GetByID(id string) (*Borrower, error) {
 }
 
 // Create creates a new borrower in the borrower table (during signup)
-func (r *BorrowerRepository) Create(email, passwordHash, firstName, lastName, phone string) (*Borrower, error) {
+func (r *borrowerRepository) Create(email, passwordHash, firstName, lastName, phone string) (*Borrower, error) {
 	// Validate that password hash is not empty
 	if passwordHash == "" {
 		return nil, errors.New("password hash cannot be empty")
@@ -179,7 +177,7 @@ func (r *BorrowerRepository) Create(email, passwordHash, firstName, lastName, ph
 	          RETURNING id, email_address, password_hash, email_verified, email_verification_token, 
 	          email_verification_expires_at, password_reset_token, password_reset_expires_at, 
 	          last_password_change_at, mfa_enabled, mfa_secret, mfa_backup_codes, mfa_setup_at, 
-	          mfa_verified_at, last_login_at, failed_login_attempts, account_locked_until,
+	          mfa_verified_at, two_factor_enabled, last_login_at, failed_login_attempts, account_locked_until,
 	          first_name, middle_name, last_name, suffix, taxpayer_identifier_type, 
 	          taxpayer_identifier_value, birth_date, citizenship_residency_type, marital_status, 
 	          dependent_count, dependent_ages, home_phone, mobile_phone, work_phone, 
@@ -192,7 +190,7 @@ func (r *BorrowerRepository) Create(email, passwordHash, firstName, lastName, ph
 		&borrower.EmailVerified, &borrower.EmailVerificationToken, &borrower.EmailVerificationExpiresAt,
 		&borrower.PasswordResetToken, &borrower.PasswordResetExpiresAt, &borrower.LastPasswordChangeAt,
 		&borrower.MFAEnabled, &borrower.MFASecret, &borrower.MFABackupCodes, &borrower.MFASetupAt,
-		&borrower.MFAVerifiedAt, &borrower.LastLoginAt, &borrower.FailedLoginAttempts, &borrower.AccountLockedUntil,
+		&borrower.MFAVerifiedAt, &borrower.TwoFactorEnabled, &borrower.LastLoginAt, &borrower.FailedLoginAttempts, &borrower.AccountLockedUntil,
 		&borrower.FirstName, &borrower.MiddleName, &borrower.LastName, &borrower.Suffix,
 		&borrower.TaxpayerIDType, &borrower.TaxpayerIDValue, &borrower.BirthDate,
 		&borrower.CitizenshipType, &borrower.MaritalStatus, &borrower.DependentCount,
@@ -215,13 +213,13 @@ func (r *BorrowerRepository) Create(email, passwordHash, firstName, lastName, ph
 // CreateFromPreApplication creates a borrower from pre-application data (without password)
 // This is used when a borrower completes the pre-application wizard
 // They will set a password later when they register/login
-func (r *BorrowerRepository) CreateFromPreApplication(email, firstName, lastName, phone, dateOfBirth, address, city, state, zipCode string) (*Borrower, error) {
+func (r *borrowerRepository) CreateFromPreApplication(email, firstName, lastName, phone, dateOfBirth, address, city, state, zipCode string) (*Borrower, error) {
 	query := `INSERT INTO borrower (email_address, first_name, last_name, mobile_phone, birth_date, created_at, updated_at) 
 	          VALUES ($1, $2, $3, $4, $5::date, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) 
 	          RETURNING id, email_address, password_hash, email_verified, email_verification_token, 
 	          email_verification_expires_at, password_reset_token, password_reset_expires_at, 
 	          last_password_change_at, mfa_enabled, mfa_secret, mfa_backup_codes, mfa_setup_at, 
-	          mfa_verified_at, last_login_at, failed_login_attempts, account_locked_until,
+	          mfa_verified_at, two_factor_enabled, last_login_at, failed_login_attempts, account_locked_until,
 	          first_name, middle_name, last_name, suffix, taxpayer_identifier_type, 
 	          taxpayer_identifier_value, birth_date, citizenship_residency_type, marital_status, 
 	          dependent_count, dependent_ages, home_phone, mobile_phone, work_phone, 
@@ -244,7 +242,7 @@ func (r *BorrowerRepository) CreateFromPreApplication(email, firstName, lastName
 		&borrower.EmailVerified, &borrower.EmailVerificationToken, &borrower.EmailVerificationExpiresAt,
 		&borrower.PasswordResetToken, &borrower.PasswordResetExpiresAt, &borrower.LastPasswordChangeAt,
 		&borrower.MFAEnabled, &borrower.MFASecret, &borrower.MFABackupCodes, &borrower.MFASetupAt,
-		&borrower.MFAVerifiedAt, &borrower.LastLoginAt, &borrower.FailedLoginAttempts, &borrower.AccountLockedUntil,
+		&borrower.MFAVerifiedAt, &borrower.TwoFactorEnabled, &borrower.LastLoginAt, &borrower.FailedLoginAttempts, &borrower.AccountLockedUntil,
 		&borrower.FirstName, &borrower.MiddleName, &borrower.LastName, &borrower.Suffix,
 		&borrower.TaxpayerIDType, &borrower.TaxpayerIDValue, &borrower.BirthDate,
 		&borrower.CitizenshipType, &borrower.MaritalStatus, &borrower.DependentCount,
@@ -264,58 +262,20 @@ func (r *BorrowerRepository) CreateFromPreApplication(email, firstName, lastName
 	return borrower, nil
 }
 
-// SetVerificationCode stores a verification code for a borrower
-func (r *BorrowerRepository) SetVerificationCode(email, code, method string, expiresAt time.Time) error {
-	query := `UPDATE borrower 
-	          SET verification_code = $1, 
-	              verification_code_expires_at = $2,
-	              verification_method = $3,
-	              updated_at = CURRENT_TIMESTAMP
-	          WHERE LOWER(email_address) = LOWER($4) AND email_address IS NOT NULL`
-	
-	_, err := r.db.Exec(query, code, expiresAt, method, email)
+// MarkEmailVerified records that the borrower proved ownership of their email address
+func (r *borrowerRepository) MarkEmailVerified(id string) error {
+	_, err := r.db.Exec(`UPDATE borrower SET email_verified = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
 	return err
 }
 
-// VerifyCode checks if a verification code is valid for a borrower
-func (r *BorrowerRepository) VerifyCode(email, code string) (bool, error) {
-	query := `SELECT verification_code, verification_code_expires_at 
-	          FROM borrower 
-	          WHERE LOWER(email_address) = LOWER($1) 
-	          AND email_address IS NOT NULL
-	          AND verification_code = $2
-	          AND verification_code_expires_at > CURRENT_TIMESTAMP`
-	
-	var storedCode sql.NullString
-	var expiresAt sql.NullTime
-	
-	err := r.db.QueryRow(query, email, code).Scan(&storedCode, &expiresAt)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return false, nil
-		}
-		return false, err
-	}
-	
-	return storedCode.Valid && storedCode.String == code, nil
-}
-
-// ClearVerificationCode clears the verification code after successful verification
-func (r *BorrowerRepository) ClearVerificationCode(email string) error {
-	query := `UPDATE borrower 
-	          SET verification_code = NULL, 
-	              verification_code_expires_at = NULL,
-	              verification_method = NULL,
-	              email_verified = TRUE,
-	              updated_at = CURRENT_TIMESTAMP
-	          WHERE LOWER(email_address) = LOWER($1) AND email_address IS NOT NULL`
-	
-	_, err := r.db.Exec(query, email)
+// SetTwoFactorEnabled opts the borrower in to or out of login verification codes
+func (r *borrowerRepository) SetTwoFactorEnabled(id string, enabled bool) error {
+	_, err := r.db.Exec(`UPDATE borrower SET two_factor_enabled = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, id, enabled)
 	return err
 }
 
 // UpdatePassword updates a borrower's password
-func (r *BorrowerRepository) UpdatePassword(borrowerID string, passwordHash string) error {
+func (r *borrowerRepository) UpdatePassword(borrowerID string, passwordHash string) error {
 	query := `UPDATE borrower 
 	          SET password_hash = $1,
 	              last_password_change_at = CURRENT_TIMESTAMP,
@@ -327,7 +287,7 @@ func (r *BorrowerRepository) UpdatePassword(borrowerID string, passwordHash stri
 }
 
 // UpdateName updates a borrower's name
-func (r *BorrowerRepository) UpdateName(borrowerID string, firstName, lastName string) error {
+func (r *borrowerRepository) UpdateName(borrowerID string, firstName, lastName string) error {
 	query := `UPDATE borrower 
 	          SET first_name = $1,
 	              last_name = $2,
@@ -339,7 +299,7 @@ func (r *BorrowerRepository) UpdateName(borrowerID string, firstName, lastName s
 }
 
 // UpdateBorrowerInfo updates borrower personal information (date of birth, etc.)
-func (r *BorrowerRepository) UpdateBorrowerInfo(id string, dateOfBirth *time.Time) error {
+func (r *borrowerRepository) UpdateBorrowerInfo(id string, dateOfBirth *time.Time) error {
 	query := `UPDATE borrower SET 
 	          birth_date = $1, 
 	          updated_at = CURRENT_TIMESTAMP
@@ -349,7 +309,7 @@ func (r *BorrowerRepository) UpdateBorrowerInfo(id string, dateOfBirth *time.Tim
 }
 
 // UpdateBorrowerDetails updates borrower details including middle name, suffix, marital status, and phone
-func (r *BorrowerRepository) UpdateBorrowerDetails(id string, middleName, suffix, maritalStatus *string, phone, phoneType *string) error {
+func (r *borrowerRepository) UpdateBorrowerDetails(id string, middleName, suffix, maritalStatus *string, phone, phoneType *string) error {
 	query := `UPDATE borrower SET 
 	          middle_name = COALESCE($1, middle_name),
 	          suffix = COALESCE($2, suffix),
@@ -363,9 +323,10 @@ func (r *BorrowerRepository) UpdateBorrowerDetails(id string, middleName, suffix
 	return err
 }
 
-// UpdateEmail updates a borrower's email address
-func (r *BorrowerRepository) UpdateEmail(id string, email string) error {
+// UpdateEmail updates a borrower's email address; a changed address is no longer verified
+func (r *borrowerRepository) UpdateEmail(id string, email string) error {
 	query := `UPDATE borrower SET 
+	          email_verified = CASE WHEN LOWER(email_address) = LOWER($1) THEN email_verified ELSE FALSE END,
 	          email_address = $1,
 	          updated_at = CURRENT_TIMESTAMP
 	          WHERE id = $2`
@@ -374,7 +335,7 @@ func (r *BorrowerRepository) UpdateEmail(id string, email string) error {
 }
 
 // UpdateBorrowerConsentsAndMilitary updates military service status and consents
-func (r *BorrowerRepository) UpdateBorrowerConsentsAndMilitary(id string, militaryServiceStatus, consentToCreditCheck, consentToContact *bool) error {
+func (r *borrowerRepository) UpdateBorrowerConsentsAndMilitary(id string, militaryServiceStatus, consentToCreditCheck, consentToContact *bool) error {
 	query := `UPDATE borrower SET 
 	          military_service_status = COALESCE($1, military_service_status),
 	          consent_to_credit_check = COALESCE($2, consent_to_credit_check),
@@ -386,7 +347,7 @@ func (r *BorrowerRepository) UpdateBorrowerConsentsAndMilitary(id string, milita
 }
 
 // UpdateBorrowerName updates borrower first and last name
-func (r *BorrowerRepository) UpdateBorrowerName(id string, firstName, lastName *string) error {
+func (r *borrowerRepository) UpdateBorrowerName(id string, firstName, lastName *string) error {
 	query := `UPDATE borrower SET 
 	          first_name = COALESCE($1, first_name),
 	          last_name = COALESCE($2, last_name),
@@ -397,7 +358,7 @@ func (r *BorrowerRepository) UpdateBorrowerName(id string, firstName, lastName *
 }
 
 // UpdateBorrowerSSN updates borrower SSN (taxpayer_identifier_value)
-func (r *BorrowerRepository) UpdateBorrowerSSN(id string, ssn string) error {
+func (r *borrowerRepository) UpdateBorrowerSSN(id string, ssn string) error {
 	query := `UPDATE borrower SET 
 	          taxpayer_identifier_value = $1,
 	          updated_at = CURRENT_TIMESTAMP
@@ -407,7 +368,7 @@ func (r *BorrowerRepository) UpdateBorrowerSSN(id string, ssn string) error {
 }
 
 // UpdateBorrowerCitizenship updates borrower citizenship/residency type
-func (r *BorrowerRepository) UpdateBorrowerCitizenship(id string, citizenshipType string) error {
+func (r *borrowerRepository) UpdateBorrowerCitizenship(id string, citizenshipType string) error {
 	query := `UPDATE borrower SET 
 	          citizenship_residency_type = $1,
 	          updated_at = CURRENT_TIMESTAMP
@@ -417,7 +378,7 @@ func (r *BorrowerRepository) UpdateBorrowerCitizenship(id string, citizenshipTyp
 }
 
 // UpdateBorrowerDependents updates borrower dependent count
-func (r *BorrowerRepository) UpdateBorrowerDependents(id string, count int) error {
+func (r *borrowerRepository) UpdateBorrowerDependents(id string, count int) error {
 	query := `UPDATE borrower SET 
 	          dependent_count = $1,
 	          updated_at = CURRENT_TIMESTAMP
@@ -427,7 +388,7 @@ func (r *BorrowerRepository) UpdateBorrowerDependents(id string, count int) erro
 }
 
 // UpdateBorrowerAdditionalPhones updates additional phone fields (homePhone, mobilePhone, workPhone, workPhoneExt)
-func (r *BorrowerRepository) UpdateBorrowerAdditionalPhones(id string, homePhone, mobilePhone, workPhone, workPhoneExt *string) error {
+func (r *borrowerRepository) UpdateBorrowerAdditionalPhones(id string, homePhone, mobilePhone, workPhone, workPhoneExt *string) error {
 	query := `UPDATE borrower SET 
 	          home_phone = COALESCE($1, home_phone),
 	          mobile_phone = COALESCE($2, mobile_phone),
@@ -440,7 +401,7 @@ func (r *BorrowerRepository) UpdateBorrowerAdditionalPhones(id string, homePhone
 }
 
 // CreateResidence creates a residence record for a borrower
-func (r *BorrowerRepository) CreateResidence(borrowerID string, residencyType, address, city, state, zipCode string) (string, error) {
+func (r *borrowerRepository) CreateResidence(borrowerID string, residencyType, address, city, state, zipCode string) (string, error) {
 	query := `INSERT INTO residence (borrower_id, residency_type, address_line_text, city_name, state_code, postal_code) 
 	          VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
 	var residenceID string
@@ -449,7 +410,7 @@ func (r *BorrowerRepository) CreateResidence(borrowerID string, residencyType, a
 }
 
 // GetCurrentResidence retrieves the current residence for a borrower
-func (r *BorrowerRepository) GetCurrentResidence(borrowerID string) (address, city, state, zipCode string, err error) {
+func (r *borrowerRepository) GetCurrentResidence(borrowerID string) (address, city, state, zipCode string, err error) {
 	query := `SELECT address_line_text, city_name, state_code, postal_code 
 	          FROM residence 
 	          WHERE borrower_id = $1 AND residency_type = 'BorrowerCurrentResidence' 
@@ -479,7 +440,7 @@ func (r *BorrowerRepository) GetCurrentResidence(borrowerID string) (address, ci
 }
 
 // GetCurrentResidenceID retrieves the ID of the current residence for a borrower
-func (r *BorrowerRepository) GetCurrentResidenceID(borrowerID string) (string, error) {
+func (r *borrowerRepository) GetCurrentResidenceID(borrowerID string) (string, error) {
 	query := `SELECT id 
 	          FROM residence 
 	          WHERE borrower_id = $1 AND residency_type = 'BorrowerCurrentResidence' 
@@ -496,7 +457,7 @@ func (r *BorrowerRepository) GetCurrentResidenceID(borrowerID string) (string, e
 }
 
 // UpdateOrCreateResidence updates existing residence or creates a new one
-func (r *BorrowerRepository) UpdateOrCreateResidence(borrowerID string, residencyType, address, city, state, zipCode string) error {
+func (r *borrowerRepository) UpdateOrCreateResidence(borrowerID string, residencyType, address, city, state, zipCode string) error {
 	// Check if residence exists
 	residenceID, err := r.GetCurrentResidenceID(borrowerID)
 	if err != nil {
@@ -521,7 +482,7 @@ func (r *BorrowerRepository) UpdateOrCreateResidence(borrowerID string, residenc
 }
 
 // DeleteFormerResidences deletes all former residence records for a borrower
-func (r *BorrowerRepository) DeleteFormerResidences(borrowerID string) error {
+func (r *borrowerRepository) DeleteFormerResidences(borrowerID string) error {
 	query := `DELETE FROM residence 
 	          WHERE borrower_id = $1 AND residency_type = 'BorrowerFormerResidence'`
 	_, err := r.db.Exec(query, borrowerID)
@@ -529,7 +490,7 @@ func (r *BorrowerRepository) DeleteFormerResidences(borrowerID string) error {
 }
 
 // CreateFormerResidence creates a former residence record for a borrower
-func (r *BorrowerRepository) CreateFormerResidence(borrowerID, address, city, state, zipCode string, durationYears, durationMonths *int, housingStatus *string) error {
+func (r *borrowerRepository) CreateFormerResidence(borrowerID, address, city, state, zipCode string, durationYears, durationMonths *int, housingStatus *string) error {
 	var residencyBasisType *string
 	if housingStatus != nil {
 		// Map housing status to residency_basis_type
@@ -557,7 +518,7 @@ func (r *BorrowerRepository) CreateFormerResidence(borrowerID, address, city, st
 // Borrowers are linked to deals through the deal.borrower_id relationship or a junction table if needed
 
 // CreateCoBorrower creates a co-borrower record (without email/password since they don't have an account)
-func (r *BorrowerRepository) CreateCoBorrower(firstName, lastName, middleName, suffix, email, phone, phoneType, maritalStatus string, isVeteran bool) (string, error) {
+func (r *borrowerRepository) CreateCoBorrower(firstName, lastName, middleName, suffix, email, phone, phoneType, maritalStatus string, isVeteran bool) (string, error) {
 	var borrowerID string
 	
 	// Set phone based on phoneType
@@ -634,7 +595,7 @@ func (r *BorrowerRepository) CreateCoBorrower(firstName, lastName, middleName, s
 }
 
 // LinkBorrowerToDeal links a borrower to a deal via borrower_progress table
-func (r *BorrowerRepository) LinkBorrowerToDeal(borrowerID, dealID string) error {
+func (r *borrowerRepository) LinkBorrowerToDeal(borrowerID, dealID string) error {
 	query := `INSERT INTO borrower_progress (borrower_id, deal_id, created_at, updated_at) 
 	          VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 	          ON CONFLICT (borrower_id, deal_id) DO NOTHING`
@@ -642,9 +603,17 @@ func (r *BorrowerRepository) LinkBorrowerToDeal(borrowerID, dealID string) error
 	return err
 }
 
+// IsLinkedToDeal reports whether a borrower is linked to a deal via the borrower_progress table
+func (r *borrowerRepository) IsLinkedToDeal(borrowerID, dealID string) (bool, error) {
+	var linked bool
+	query := `SELECT EXISTS (SELECT 1 FROM borrower_progress WHERE borrower_id = $1 AND deal_id = $2)`
+	err := r.db.QueryRow(query, borrowerID, dealID).Scan(&linked)
+	return linked, err
+}
+
 // GetByEmailOrPhone retrieves a borrower by email OR phone number (checks mobile_phone, home_phone, and work_phone)
 // Returns the borrower if found by either email or phone, nil if not found
-func (r *BorrowerRepository) GetByEmailOrPhone(email, phone string) (*Borrower, error) {
+func (r *borrowerRepository) GetByEmailOrPhone(email, phone string) (*Borrower, error) {
 	// First try by email if provided
 	if email != "" {
 		borrower, err := r.GetByEmail(email)
@@ -671,7 +640,7 @@ func (r *BorrowerRepository) GetByEmailOrPhone(email, phone string) (*Borrower,
 }
 
 // GetCoBorrowersByDealID retrieves all co-borrowers (non-primary) for a deal
-func (r *BorrowerRepository) GetCoBorrowersByDealID(dealID, primaryBorrowerID string) ([]*Borrower, error) {
+func (r *borrowerRepository) GetCoBorrowersByDealID(dealID, primaryBorrowerID string) ([]*Borrower, error) {
 	query := `SELECT b.id, b.first_name, b.middle_name, b.last_name, b.suffix, 
 	                 b.email_address, b.mobile_phone, b.home_phone, b.work_phone,
 	                 b.marital_status, b.military_service_status
@@ -710,3 +679,128 @@ func (r *BorrowerRepository) GetCoBorrowersByDealID(dealID, primaryBorrowerID st
 
 	return borrowers, rows.Err()
 }
+
+// RecordLoginFailure increments the failed login counter and returns its new value
+func (r *borrowerRepository) RecordLoginFailure(id string) (int, error) {
+	query := `UPDATE borrower SET failed_login_attempts = COALESCE(failed_login_attempts, 0) + 1
+	          WHERE id = $1 RETURNING failed_login_attempts`
+	var attempts int
+	err := r.db.QueryRow(query, id).Scan(&attempts)
+	return attempts, err
+}
+
+// LockAccount locks the account until the given time
+func (r *borrowerRepository) LockAccount(id string, until time.Time) error {
+	_, err := r.db.Exec(`UPDATE borrower SET account_locked_until = $2 WHERE id = $1`, id, until)
+	return err
+}
+
+// RecordLoginSuccess resets the lockout state and records the login time
+func (r *borrowerRepository) RecordLoginSuccess(id string) error {
+	query := `UPDATE borrower
+	          SET failed_login_attempts = 0,
+	              account_locked_until = NULL,
+	              last_login_at = CURRENT_TIMESTAMP
+	          WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// UnlockAccount clears the lock and the failed login counter
+func (r *borrowerRepository) UnlockAccount(id string) error {
+	_, err := r.db.Exec(`UPDATE borrower SET failed_login_attempts = 0, account_locked_until = NULL WHERE id = $1`, id)
+	return err
+}
+
+// SetPasswordResetToken stores the hash of a password reset token, replacing any previous one
+func (r *borrowerRepository) SetPasswordResetToken(id, tokenHash string, expiresAt time.Time) error {
+	query := `UPDATE borrower SET password_reset_token = $2, password_reset_expires_at = $3 WHERE id = $1`
+	_, err := r.db.Exec(query, id, tokenHash, expiresAt)
+	return err
+}
+
+// ResetPassword sets a new password for the account holding the unexpired reset
+// token, consuming the token and clearing any login lockout
+func (r *borrowerRepository) ResetPassword(tokenHash, passwordHash string) (string, error) {
+	query := `UPDATE borrower
+	          SET password_hash = $2,
+	              password_reset_token = NULL,
+	              password_reset_expires_at = NULL,
+	              last_password_change_at = CURRENT_TIMESTAMP,
+	              failed_login_attempts = 0,
+	              account_locked_until = NULL,
+	              updated_at = CURRENT_TIMESTAMP
+	          WHERE password_reset_token = $1 AND password_reset_expires_at > CURRENT_TIMESTAMP
+	          RETURNING id`
+	var id string
+	err := r.db.QueryRow(query, tokenHash, passwordHash).Scan(&id)
+	return id, err
+}
+
+// SetMFASecret stores a new TOTP secret pending confirmation, disabling MFA
+// and discarding any backup codes until EnableMFA is called
+func (r *borrowerRepository) SetMFASecret(id, secret string) error {
+	query := `UPDATE borrower
+	          SET mfa_enabled = false,
+	              mfa_secret = $2,
+	              mfa_backup_codes = NULL,
+	              mfa_setup_at = CURRENT_TIMESTAMP,
+	              mfa_verified_at = NULL
+	          WHERE id = $1`
+	_, err := r.db.Exec(query, id, secret)
+	return err
+}
+
+// EnableMFA turns on MFA for the pending secret and stores the backup code hashes
+func (r *borrowerRepository) EnableMFA(id, backupCodes string) error {
+	query := `UPDATE borrower SET mfa_enabled = true, mfa_backup_codes = $2
+	          WHERE id = $1 AND mfa_secret IS NOT NULL`
+	_, err := r.db.Exec(query, id, backupCodes)
+	return err
+}
+
+// DisableMFA turns off MFA and removes the secret and backup codes
+func (r *borrowerRepository) DisableMFA(id string) error {
+	query := `UPDATE borrower
+	          SET mfa_enabled = false,
+	              mfa_secret = NULL,
+	              mfa_backup_codes = NULL,
+	              mfa_setup_at = NULL,
+	              mfa_verified_at = NULL
+	          WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// RecordMFAVerification records the time step of an accepted TOTP code and
+// reports whether it did; false means a code of this or a later step was
+// already used
+func (r *borrowerRepository) RecordMFAVerification(id string, step time.Time) (bool, error) {
+	query := `UPDATE borrower SET mfa_verified_at = $2
+	          WHERE id = $1 AND (mfa_verified_at IS NULL OR mfa_verified_at < $2)`
+	result, err := r.db.Exec(query, id, step)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// SetMFABackupCodes replaces the backup code hashes
+func (r *borrowerRepository) SetMFABackupCodes(id, backupCodes string) error {
+	_, err := r.db.Exec(`UPDATE borrower SET mfa_backup_codes = $2 WHERE id = $1`, id, backupCodes)
+	return err
+}
+
+// ConsumeMFABackupCode removes a backup code hash and reports whether it was present
+func (r *borrowerRepository) ConsumeMFABackupCode(id, codeHash string) (bool, error) {
+	query := `UPDATE borrower
+	          SET mfa_backup_codes = NULLIF(array_to_string(array_remove(string_to_array(mfa_backup_codes, ','), $2::text), ','), '')
+	          WHERE id = $1 AND $2::text = ANY(string_to_array(mfa_backup_codes, ','))`
+	result, err := r.db.Exec(query, id, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
@@ -2,7 +2,6 @@ package repositories
 
 import (
 	"database/sql"
-	"taulen/backend/internal/database"
 )
 
 // DealProgress represents progress tracking for a deal
@@ -39,20 +38,90 @@ type DealProgress struct {
 	UpdatedAt                 sql.NullTime
 }
 
-// DealProgressRepository handles deal progress data access
-type DealProgressRepository struct {
-	db *sql.DB
+// progressColumns maps URLA section names to their deal_progress completion columns
+var progressColumns = map[string]string{
+	"Section1a_PersonalInfo":         "section_1a_complete",
+	"Section1b_CurrentEmployment":    "section_1b_complete",
+	"Section1c_AdditionalEmployment": "section_1c_complete",
+	"Section1d_PreviousEmployment":   "section_1d_complete",
+	"Section1e_OtherIncome":          "section_1e_complete",
+	"Section2a_Assets":               "section_2a_complete",
+	"Section2b_OtherAssetsCredits":   "section_2b_complete",
+	"Section2c_Liabilities":          "section_2c_complete",
+	"Section2d_Expenses":             "section_2d_complete",
+	"Section3_RealEstateOwned":       "section_3_complete",
+	"Section4_LoanPropertyInfo":      "section_4_complete",
+	"Section5_Declarations":          "section_5_complete",
+	"Section6_Acknowledgments":       "section_6_complete",
+	"Section7_MilitaryService":       "section_7_complete",
+	"Section8_Demographics":          "section_8_complete",
+	"Section9_OriginatorInfo":        "section_9_complete",
+	"Lender_L1_PropertyLoanInfo":     "lender_l1_complete",
+	"Lender_L2_TitleInfo":            "lender_l2_complete",
+	"Lender_L3_MortgageLoanInfo":     "lender_l3_complete",
+	"Lender_L4_Qualification":        "lender_l4_complete",
+	"ContinuationSheet":              "continuation_complete",
+	"UnmarriedAddendum":              "unmarried_addendum_complete",
 }
 
-// NewDealProgressRepository creates a new deal progress repository
-func NewDealProgressRepository() *DealProgressRepository {
-	return &DealProgressRepository{
-		db: database.DB,
+// SectionFlag pairs a URLA section name with its completion flag
+type SectionFlag struct {
+	Section  string
+	Complete *bool
+}
+
+// SectionFlags returns the URLA section names in form order, each paired with
+// a pointer to its completion flag
+func (p *DealProgress) SectionFlags() []SectionFlag {
+	return []SectionFlag{
+		{"Section1a_PersonalInfo", &p.Section1aComplete},
+		{"Section1b_CurrentEmployment", &p.Section1bComplete},
+		{"Section1c_AdditionalEmployment", &p.Section1cComplete},
+		{"Section1d_PreviousEmployment", &p.Section1dComplete},
+		{"Section1e_OtherIncome", &p.Section1eComplete},
+		{"Section2a_Assets", &p.Section2aComplete},
+		{"Section2b_OtherAssetsCredits", &p.Section2bComplete},
+		{"Section2c_Liabilities", &p.Section2cComplete},
+		{"Section2d_Expenses", &p.Section2dComplete},
+		{"Section3_RealEstateOwned", &p.Section3Complete},
+		{"Section4_LoanPropertyInfo", &p.Section4Complete},
+		{"Section5_Declarations", &p.Section5Complete},
+		{"Section6_Acknowledgments", &p.Section6Complete},
+		{"Section7_MilitaryService", &p.Section7Complete},
+		{"Section8_Demographics", &p.Section8Complete},
+		{"Section9_OriginatorInfo", &p.Section9Complete},
+		{"Lender_L1_PropertyLoanInfo", &p.LenderL1Complete},
+		{"Lender_L2_TitleInfo", &p.LenderL2Complete},
+		{"Lender_L3_MortgageLoanInfo", &p.LenderL3Complete},
+		{"Lender_L4_Qualification", &p.LenderL4Complete},
+		{"ContinuationSheet", &p.ContinuationComplete},
+		{"UnmarriedAddendum", &p.UnmarriedAddendumComplete},
 	}
 }
 
+// NextIncompleteSection returns the first incomplete section in form order,
+// or an empty string when every section is complete
+func (p *DealProgress) NextIncompleteSection() string {
+	for _, s := range p.SectionFlags() {
+		if !*s.Complete {
+			return s.Section
+		}
+	}
+	return ""
+}
+
+// dealProgressRepository is the PostgreSQL implementation of DealProgressRepository
+type dealProgressRepository struct {
+	db DBTX
+}
+
+// newDealProgressRepository creates a deal progress repository that runs its queries on db
+func newDealProgressRepository(db DBTX) *dealProgressRepository {
+	return &dealProgressRepository{db: db}
+}
+
 // GetByDealID retrieves progress for a deal
-func (r *DealProgressRepository) GetByDealID(dealID string) (*DealProgress, error) {
+func (r *dealProgressRepository) GetByDealID(dealID string) (*DealProgress, error) {
 	query := `SELECT id, deal_id, section_1a_complete, section_1b_complete, section_1c_complete,
 	          section_1d_complete, section_1e_complete, section_2a_complete, section_2b_complete,
 	          section_2c_complete, section_2d_complete, section_3_complete, section_4_complete,
@@ -90,34 +159,8 @@ func (r *DealProgressRepository) GetByDealID(dealID string) (*DealProgress, erro
 }
 
 // UpdateSection marks a section as complete or incomplete
-func (r *DealProgressRepository) UpdateSection(dealID string, section string, complete bool) error {
-	// Map section names to column names
-	sectionMap := map[string]string{
-		"Section1a_PersonalInfo":        "section_1a_complete",
-		"Section1b_CurrentEmployment":    "section_1b_complete",
-		"Section1c_AdditionalEmployment": "section_1c_complete",
-		"Section1d_PreviousEmployment":   "section_1d_complete",
-		"Section1e_OtherIncome":          "section_1e_complete",
-		"Section2a_Assets":               "section_2a_complete",
-		"Section2b_OtherAssetsCredits":   "section_2b_complete",
-		"Section2c_Liabilities":          "section_2c_complete",
-		"Section2d_Expenses":             "section_2d_complete",
-		"Section3_RealEstateOwned":       "section_3_complete",
-		"Section4_LoanPropertyInfo":      "section_4_complete",
-		"Section5_Declarations":          "section_5_complete",
-		"Section6_Acknowledgments":       "section_6_complete",
-		"Section7_MilitaryService":       "section_7_complete",
-		"Section8_Demographics":          "section_8_complete",
-		"Section9_OriginatorInfo":        "section_9_complete",
-		"Lender_L1_PropertyLoanInfo":     "lender_l1_complete",
-		"Lender_L2_TitleInfo":            "lender_l2_complete",
-		"Lender_L3_MortgageLoanInfo":     "lender_l3_complete",
-		"Lender_L4_Qualification":       "lender_l4_complete",
-		"ContinuationSheet":              "continuation_complete",
-		"UnmarriedAddendum":              "unmarried_addendum_complete",
-	}
-	
-	columnName, ok := sectionMap[section]
+func (r *dealProgressRepository) UpdateSection(dealID string, section string, complete bool) error {
+	columnName, ok := progressColumns[section]
 	if !ok {
 		return sql.ErrNoRows // Invalid section name
 	}
@@ -133,7 +176,7 @@ func (r *DealProgressRepository) UpdateSection(dealID string, section string, co
 }
 
 // UpdateNotes updates progress notes
-func (r *DealProgressRepository) UpdateNotes(dealID string, notes string) error {
+func (r *dealProgressRepository) UpdateNotes(dealID string, notes string) error {
 	query := `UPDATE deal_progress 
 	          SET progress_notes = $1, updated_at = CURRENT_TIMESTAMP
 	          WHERE deal_id = $2`
@@ -142,47 +185,10 @@ func (r *DealProgressRepository) UpdateNotes(dealID string, notes string) error
 }
 
 // GetNextIncompleteSection returns the first incomplete section for resumption
-func (r *DealProgressRepository) GetNextIncompleteSection(dealID string) (string, error) {
+func (r *dealProgressRepository) GetNextIncompleteSection(dealID string) (string, error) {
 	progress, err := r.GetByDealID(dealID)
 	if err != nil {
 		return "", err
 	}
-	
-	// Check sections in order
-	sections := []struct {
-		section string
-		complete bool
-	}{
-		{"Section1a_PersonalInfo", progress.Section1aComplete},
-		{"Section1b_CurrentEmployment", progress.Section1bComplete},
-		{"Section1c_AdditionalEmployment", progress.Section1cComplete},
-		{"Section1d_PreviousEmployment", progress.Section1dComplete},
-		{"Section1e_OtherIncome", progress.Section1eComplete},
-		{"Section2a_Assets", progress.Section2aComplete},
-		{"Section2b_OtherAssetsCredits", progress.Section2bComplete},
-		{"Section2c_Liabilities", progress.Section2cComplete},
-		{"Section2d_Expenses", progress.Section2dComplete},
-		{"Section3_RealEstateOwned", progress.Section3Complete},
-		{"Section4_LoanPropertyInfo", progress.Section4Complete},
-		{"Section5_Declarations", progress.Section5Complete},
-		{"Section6_Acknowledgments", progress.Section6Complete},
-		{"Section7_MilitaryService", progress.Section7Complete},
-		{"Section8_Demographics", progress.Section8Complete},
-		{"Section9_OriginatorInfo", progress.Section9Complete},
-		{"Lender_L1_PropertyLoanInfo", progress.LenderL1Complete},
-		{"Lender_L2_TitleInfo", progress.LenderL2Complete},
-		{"Lender_L3_MortgageLoanInfo", progress.LenderL3Complete},
-		{"Lender_L4_Qualification", progress.LenderL4Complete},
-		{"ContinuationSheet", progress.ContinuationComplete},
-		{"UnmarriedAddendum", progress.UnmarriedAddendumComplete},
-	}
-	
-	for _, s := range sections {
-		if !s.complete {
-			return s.section, nil
-		}
-	}
-	
-	// All sections complete
-	return "", nil
+	return progress.NextIncompleteSection(), nil
 }
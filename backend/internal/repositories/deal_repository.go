@@ -2,21 +2,69 @@ package repositories
 
 import (
 	"database/sql"
-	"log"
-	"taulen/backend/internal/database"
 )
 
-// DealRepository handles deal (mortgage application) data access
+// Deal represents a deal (mortgage application) together with its loan
 // A deal represents a mortgage application in the new schema
-type DealRepository struct {
-	db *sql.DB
+type Deal struct {
+	ID                      string
+	LoanNumber              sql.NullString
+	UniversalLoanIdentifier sql.NullString
+	AgencyCaseIdentifier    sql.NullString
+	ApplicationType         sql.NullString
+	TotalBorrowers          sql.NullInt64
+	ApplicationDate         sql.NullTime
+	CreatedAt               sql.NullTime
+	PrimaryBorrowerID       sql.NullString
+	CurrentFormStep         sql.NullString
+	LoanID                  sql.NullString
+	LoanPurposeType         sql.NullString
+	LoanAmountRequested     sql.NullFloat64
+	LoanTermMonths          sql.NullInt64
+	InterestRatePercentage  sql.NullFloat64
+	PropertyType            sql.NullString
+	ManufacturedHomeWidth   sql.NullString
+	TitleMannerType         sql.NullString
 }
 
-// NewDealRepository creates a new deal repository
-func NewDealRepository() *DealRepository {
-	return &DealRepository{
-		db: database.DB,
-	}
+// DealSummary is a deal as shown in application lists
+type DealSummary struct {
+	ID                  string
+	LoanNumber          sql.NullString
+	ApplicationType     sql.NullString
+	ApplicationDate     sql.NullTime
+	CreatedAt           sql.NullTime
+	LoanPurposeType     sql.NullString
+	LoanAmountRequested sql.NullFloat64
+	LastUpdatedAt       sql.NullTime
+	ProgressPercentage  sql.NullInt64
+	LastUpdatedSection  sql.NullString
+}
+
+// DealEdit records who wrote a section of a deal. AccountID is the account the
+// write was made as; ActorID names the employee who made it on that account's
+// behalf, if any.
+type DealEdit struct {
+	ID          string
+	DealID      string
+	Section     string
+	AccountID   string
+	AccountType string // "borrower", "employee" or "integration"
+	ActorID     sql.NullString
+	// ActorFirstName and ActorLastName are the actor's name; set by ListEdits
+	ActorFirstName sql.NullString
+	ActorLastName  sql.NullString
+	CreatedAt      sql.NullTime
+}
+
+// dealRepository is the PostgreSQL implementation of DealRepository
+type dealRepository struct {
+	db DBTX
+}
+
+// newDealRepository creates a deal repository that runs its queries on db
+func newDealRepository(db DBTX) *dealRepository {
+	return &dealRepository{db: db}
 }
 
 // CreateDeal creates a new deal (mortgage application) with an associated loan
@@ -24,13 +72,20 @@ func NewDealRepository() *DealRepository {
 // borrowerID can be NULL initially, set later when primary borrower is created
 // status defaults to 'Draft' if empty
 // Returns the deal ID (which is the primary identifier for an application)
-func (r *DealRepository) CreateDeal(userID string, borrowerID *string, loanPurpose string, loanAmount float64, status string) (string, error) {
-	// Start transaction
-	tx, err := r.db.Begin()
-	if err != nil {
-		return "", err
+func (r *dealRepository) CreateDeal(userID string, borrowerID *string, loanPurpose string, loanAmount float64, status string) (string, error) {
+	// Start transaction, or join the caller's transaction when the repository
+	// was obtained from Store.WithinTx
+	var err error
+	var tx DBTX = r.db
+	var ownTx *sql.Tx
+	if db, ok := r.db.(*sql.DB); ok {
+		ownTx, err = db.Begin()
+		if err != nil {
+			return "", err
+		}
+		defer ownTx.Rollback()
+		tx = ownTx
 	}
-	defer tx.Rollback()
 
 	// Create deal first
 	var dealID string
@@ -70,47 +125,61 @@ func (r *DealRepository) CreateDeal(userID string, borrowerID *string, loanPurpo
 		return "", err
 	}
 
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		return "", err
+	// Assign the creating employee to the deal
+	if userID != "" {
+		if err = assignUser(tx, dealID, userID); err != nil {
+			return "", err
+		}
+	}
+
+	// Commit transaction if we started it
+	if ownTx != nil {
+		if err = ownTx.Commit(); err != nil {
+			return "", err
+		}
 	}
 
 	// Return deal ID as the application identifier
 	return dealID, nil
 }
 
-// GetDealByID retrieves a deal by ID with associated loan information
-// Returns a row with deal and loan information
-func (r *DealRepository) GetDealByID(dealID string) (*sql.Row, error) {
-	query := `SELECT d.id, d.loan_number, d.universal_loan_identifier, d.agency_case_identifier,
+// dealColumns selects a deal joined with its loan, in the order scanned by scanDeal
+const dealColumns = `SELECT d.id, d.loan_number, d.universal_loan_identifier, d.agency_case_identifier,
 		d.application_type, d.total_borrowers, d.application_date, d.created_at, d.primary_borrower_id,
 		d.current_form_step,
 		l.id as loan_id, l.loan_purpose_type, l.loan_amount_requested, l.loan_term_months,
 		l.interest_rate_percentage, l.property_type, l.manufactured_home_width_type, l.title_manner_type
 		FROM deal d
-		LEFT JOIN loan l ON l.deal_id = d.id
-		WHERE d.id = $1`
-	
-	row := r.db.QueryRow(query, dealID)
-	return row, nil
+		LEFT JOIN loan l ON l.deal_id = d.id`
+
+// scanDeal scans a row selected with dealColumns
+func scanDeal(row *sql.Row) (*Deal, error) {
+	deal := &Deal{}
+	err := row.Scan(
+		&deal.ID, &deal.LoanNumber, &deal.UniversalLoanIdentifier, &deal.AgencyCaseIdentifier,
+		&deal.ApplicationType, &deal.TotalBorrowers, &deal.ApplicationDate, &deal.CreatedAt, &deal.PrimaryBorrowerID,
+		&deal.CurrentFormStep,
+		&deal.LoanID, &deal.LoanPurposeType, &deal.LoanAmountRequested, &deal.LoanTermMonths,
+		&deal.InterestRatePercentage, &deal.PropertyType, &deal.ManufacturedHomeWidth, &deal.TitleMannerType,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return deal, nil
+}
+
+// GetDealByID retrieves a deal by ID with associated loan information
+func (r *dealRepository) GetDealByID(dealID string) (*Deal, error) {
+	return scanDeal(r.db.QueryRow(dealColumns+` WHERE d.id = $1`, dealID))
 }
 
 // GetDealByLoanNumber retrieves a deal by loan number
-func (r *DealRepository) GetDealByLoanNumber(loanNumber string) (*sql.Row, error) {
-	query := `SELECT d.id, d.loan_number, d.universal_loan_identifier, d.agency_case_identifier,
-		d.application_type, d.total_borrowers, d.application_date, d.created_at,
-		l.id as loan_id, l.loan_purpose_type, l.loan_amount_requested, l.loan_term_months,
-		l.interest_rate_percentage, l.property_type, l.manufactured_home_width_type, l.title_manner_type
-		FROM deal d
-		LEFT JOIN loan l ON l.deal_id = d.id
-		WHERE d.loan_number = $1`
-	
-	row := r.db.QueryRow(query, loanNumber)
-	return row, nil
+func (r *dealRepository) GetDealByLoanNumber(loanNumber string) (*Deal, error) {
+	return scanDeal(r.db.QueryRow(dealColumns+` WHERE d.loan_number = $1`, loanNumber))
 }
 
 // UpdateDeal updates deal information
-func (r *DealRepository) UpdateDeal(dealID string, loanNumber, universalLoanIdentifier, agencyCaseIdentifier *string, applicationType *string, totalBorrowers *int) error {
+func (r *dealRepository) UpdateDeal(dealID string, loanNumber, universalLoanIdentifier, agencyCaseIdentifier *string, applicationType *string, totalBorrowers *int) error {
 	query := `UPDATE deal SET 
 		loan_number = COALESCE($2, loan_number),
 		universal_loan_identifier = COALESCE($3, universal_loan_identifier),
@@ -124,21 +193,21 @@ func (r *DealRepository) UpdateDeal(dealID string, loanNumber, universalLoanIden
 }
 
 // UpdateCurrentFormStep updates the current form step for a deal
-func (r *DealRepository) UpdateCurrentFormStep(dealID string, formStep string) error {
+func (r *dealRepository) UpdateCurrentFormStep(dealID string, formStep string) error {
 	query := `UPDATE deal SET current_form_step = $2 WHERE id = $1`
 	_, err := r.db.Exec(query, dealID, formStep)
 	return err
 }
 
 // UpdateToJointApplication updates a deal to joint application type
-func (r *DealRepository) UpdateToJointApplication(dealID string) error {
+func (r *dealRepository) UpdateToJointApplication(dealID string) error {
 	applicationType := "JointCredit"
 	totalBorrowers := 2
 	return r.UpdateDeal(dealID, nil, nil, nil, &applicationType, &totalBorrowers)
 }
 
 // UpdateLoan updates loan information for a deal
-func (r *DealRepository) UpdateLoan(dealID string, loanPurpose, propertyType, manufacturedHomeWidthType, titleMannerType *string, loanAmount *float64, loanTermMonths *int, interestRate *float64) error {
+func (r *dealRepository) UpdateLoan(dealID string, loanPurpose, propertyType, manufacturedHomeWidthType, titleMannerType *string, loanAmount *float64, loanTermMonths *int, interestRate *float64) error {
 	query := `UPDATE loan SET 
 		loan_purpose_type = COALESCE($2, loan_purpose_type),
 		loan_amount_requested = COALESCE($3, loan_amount_requested),
@@ -154,7 +223,7 @@ func (r *DealRepository) UpdateLoan(dealID string, loanPurpose, propertyType, ma
 }
 
 // CreateSubjectProperty creates a subject property record for a deal
-func (r *DealRepository) CreateSubjectProperty(dealID string, address, city, state, zipCode string, estimatedValue float64) (string, error) {
+func (r *dealRepository) CreateSubjectProperty(dealID string, address, city, state, zipCode string, estimatedValue float64) (string, error) {
 	query := `INSERT INTO subject_property (deal_id, address_line_text, city_name, state_code, postal_code, estimated_value, property_usage_type) 
 	          VALUES ($1, $2, $3, $4, $5, $6, 'PrimaryResidence') RETURNING id`
 	var propertyID string
@@ -162,53 +231,150 @@ func (r *DealRepository) CreateSubjectProperty(dealID string, address, city, sta
 	return propertyID, err
 }
 
-// GetDealsByUserID retrieves all deals managed by an employee
-// Note: The new schema doesn't have user_id in deal table
-// This relationship might need to be tracked in a separate table (deal_user_assignment) or added to deal
-// For now, returning all deals - this needs to be updated when user assignment is implemented
-func (r *DealRepository) GetDealsByUserID(userID string) (*sql.Rows, error) {
-	// TODO: Add user_id to deal table or create deal_user_assignment junction table
-	// For now, return all deals - this is a placeholder
-	query := `SELECT d.id, d.loan_number, d.application_type, d.application_date, d.created_at,
-		l.loan_purpose_type, l.loan_amount_requested
+// dealSummaryColumns selects deal list entries, in the order scanned by scanDealSummaries
+// Note: deal table doesn't have a status column, callers treat every deal as 'Draft'
+const dealSummaryColumns = `SELECT d.id, d.loan_number, d.application_type, d.application_date, d.created_at,
+		l.loan_purpose_type, l.loan_amount_requested,
+		COALESCE(dp.updated_at, d.created_at) as last_updated_at,
+		dp.progress_percentage, dp.last_updated_section
 		FROM deal d
 		LEFT JOIN loan l ON l.deal_id = d.id
+		LEFT JOIN deal_progress dp ON dp.deal_id = d.id`
+
+// scanDealSummaries scans rows selected with dealSummaryColumns and closes them
+func scanDealSummaries(rows *sql.Rows) ([]*DealSummary, error) {
+	defer rows.Close()
+
+	deals := make([]*DealSummary, 0)
+	for rows.Next() {
+		deal := &DealSummary{}
+		err := rows.Scan(
+			&deal.ID, &deal.LoanNumber, &deal.ApplicationType, &deal.ApplicationDate, &deal.CreatedAt,
+			&deal.LoanPurposeType, &deal.LoanAmountRequested,
+			&deal.LastUpdatedAt, &deal.ProgressPercentage, &deal.LastUpdatedSection,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deals = append(deals, deal)
+	}
+	return deals, rows.Err()
+}
+
+// GetDealsByUserID retrieves all deals an employee is assigned to
+func (r *dealRepository) GetDealsByUserID(userID string) ([]*DealSummary, error) {
+	query := dealSummaryColumns + `
+		JOIN deal_assignment da ON da.deal_id = d.id
+		WHERE da.user_id = $1
 		ORDER BY d.created_at DESC`
-	return r.db.Query(query)
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanDealSummaries(rows)
+}
+
+// AssignUser assigns an employee to a deal (no-op if already assigned)
+func (r *dealRepository) AssignUser(dealID, userID string) error {
+	return assignUser(r.db, dealID, userID)
+}
+
+// assignUser inserts a deal_assignment row on db
+func assignUser(db DBTX, dealID, userID string) error {
+	query := `INSERT INTO deal_assignment (deal_id, user_id) VALUES ($1, $2)
+	          ON CONFLICT (deal_id, user_id) DO NOTHING`
+	_, err := db.Exec(query, dealID, userID)
+	return err
+}
+
+// UnassignUser removes an employee from a deal (no-op if not assigned)
+func (r *dealRepository) UnassignUser(dealID, userID string) error {
+	_, err := r.db.Exec(`DELETE FROM deal_assignment WHERE deal_id = $1 AND user_id = $2`, dealID, userID)
+	return err
+}
+
+// IsUserAssigned reports whether an employee is assigned to a deal
+func (r *dealRepository) IsUserAssigned(dealID, userID string) (bool, error) {
+	var assigned bool
+	query := `SELECT EXISTS (SELECT 1 FROM deal_assignment WHERE deal_id = $1 AND user_id = $2)`
+	err := r.db.QueryRow(query, dealID, userID).Scan(&assigned)
+	return assigned, err
+}
+
+// ListAssignees returns the IDs of the employees assigned to a deal, in the
+// order they were assigned
+func (r *dealRepository) ListAssignees(dealID string) ([]string, error) {
+	rows, err := r.db.Query(`SELECT user_id FROM deal_assignment WHERE deal_id = $1 ORDER BY created_at, user_id`, dealID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// RecordEdit stores who wrote a section of a deal
+func (r *dealRepository) RecordEdit(edit *DealEdit) error {
+	query := `INSERT INTO deal_edit (deal_id, section, account_id, account_type, actor_id)
+	          VALUES ($1, $2, $3, $4, $5)
+	          RETURNING id, created_at`
+	return r.db.QueryRow(query, edit.DealID, edit.Section, edit.AccountID, edit.AccountType, edit.ActorID).
+		Scan(&edit.ID, &edit.CreatedAt)
+}
+
+// ListEdits returns the edits of a deal, oldest first, with their actors' names
+func (r *dealRepository) ListEdits(dealID string) ([]*DealEdit, error) {
+	query := `SELECT e.id, e.deal_id, e.section, e.account_id, e.account_type, e.actor_id,
+	                 u.first_name, u.last_name, e.created_at
+	          FROM deal_edit e
+	          LEFT JOIN "user" u ON u.id = e.actor_id
+	          WHERE e.deal_id = $1
+	          ORDER BY e.created_at, e.id`
+	rows, err := r.db.Query(query, dealID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edits []*DealEdit
+	for rows.Next() {
+		edit := &DealEdit{}
+		if err := rows.Scan(&edit.ID, &edit.DealID, &edit.Section, &edit.AccountID, &edit.AccountType, &edit.ActorID,
+			&edit.ActorFirstName, &edit.ActorLastName, &edit.CreatedAt); err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+	return edits, rows.Err()
 }
 
 // GetDealsByBorrowerID retrieves all deals for a borrower, ordered by latest modification
-func (r *DealRepository) GetDealsByBorrowerID(borrowerID string) (*sql.Rows, error) {
-	// Note: deal table doesn't have a status column, so we use 'Draft' as default
-	query := `SELECT d.id, d.loan_number, d.application_type, d.application_date, d.created_at, 'Draft' as status,
-		l.loan_purpose_type, l.loan_amount_requested,
-		COALESCE(dp.updated_at, d.created_at) as last_updated_at,
-		dp.progress_percentage, dp.last_updated_section
-		FROM deal d
-		LEFT JOIN loan l ON l.deal_id = d.id
-		LEFT JOIN deal_progress dp ON dp.deal_id = d.id
+func (r *dealRepository) GetDealsByBorrowerID(borrowerID string) ([]*DealSummary, error) {
+	query := dealSummaryColumns + `
 		WHERE d.primary_borrower_id = $1
 		ORDER BY COALESCE(dp.updated_at, d.created_at) DESC, d.created_at DESC`
-	
-	// Log the query for debugging
-	log.Printf("GetDealsByBorrowerID: Executing query for borrower ID: %s", borrowerID)
+
 	rows, err := r.db.Query(query, borrowerID)
 	if err != nil {
-		log.Printf("GetDealsByBorrowerID: Query error for borrower %s: %v", borrowerID, err)
 		return nil, err
 	}
-	log.Printf("GetDealsByBorrowerID: Query executed successfully for borrower %s", borrowerID)
-	return rows, nil
+	return scanDealSummaries(rows)
 }
 
 // ListDeals retrieves all deals with pagination
-func (r *DealRepository) ListDeals(limit, offset int) (*sql.Rows, error) {
-	query := `SELECT d.id, d.loan_number, d.universal_loan_identifier, d.application_type, 
-		d.total_borrowers, d.application_date, d.created_at,
-		l.loan_purpose_type, l.loan_amount_requested
-		FROM deal d
-		LEFT JOIN loan l ON l.deal_id = d.id
-		ORDER BY d.created_at DESC
-		LIMIT $1 OFFSET $2`
-	return r.db.Query(query, limit, offset)
+func (r *dealRepository) ListDeals(limit, offset int) ([]*DealSummary, error) {
+	rows, err := r.db.Query(dealSummaryColumns+` ORDER BY d.created_at DESC LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanDealSummaries(rows)
 }
@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"taulen/backend/internal/repositories"
+)
+
+// apiKeyRepository is the in-memory implementation of repositories.APIKeyRepository
+type apiKeyRepository struct {
+	db *db
+}
+
+// getAPIKey returns a copy of a stored key that does not share its scopes
+func getAPIKey(key repositories.APIKey) *repositories.APIKey {
+	key.Scopes = slices.Clone(key.Scopes)
+	return &key
+}
+
+// Create stores a new API key; its ID is chosen by the caller
+func (r *apiKeyRepository) Create(key *repositories.APIKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, exists := r.db.data.apiKeys[key.ID]; exists {
+		return errors.New("duplicate key value violates unique constraint \"api_key_pkey\"")
+	}
+	for _, existing := range r.db.data.apiKeys {
+		if existing.KeyHash == key.KeyHash {
+			return errors.New("duplicate key value violates unique constraint \"api_key_key_hash_key\"")
+		}
+	}
+	key.CreatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	r.db.data.apiKeys[key.ID] = *getAPIKey(*key)
+	return nil
+}
+
+// GetByID retrieves an API key by ID
+func (r *apiKeyRepository) GetByID(id string) (*repositories.APIKey, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	key, ok := r.db.data.apiKeys[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return getAPIKey(key), nil
+}
+
+// GetByHash retrieves the API key with the given hash
+func (r *apiKeyRepository) GetByHash(keyHash string) (*repositories.APIKey, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, key := range r.db.data.apiKeys {
+		if key.KeyHash == keyHash {
+			return getAPIKey(key), nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// List returns every API key, newest first
+func (r *apiKeyRepository) List() ([]*repositories.APIKey, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	keys := make([]*repositories.APIKey, 0, len(r.db.data.apiKeys))
+	for _, key := range r.db.data.apiKeys {
+		keys = append(keys, getAPIKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Time.Equal(keys[j].CreatedAt.Time) {
+			return keys[i].CreatedAt.Time.After(keys[j].CreatedAt.Time)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+// Revoke revokes the key unless it already is, and reports whether it did
+func (r *apiKeyRepository) Revoke(id string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	key, ok := r.db.data.apiKeys[id]
+	if !ok || key.RevokedAt.Valid {
+		return false, nil
+	}
+	key.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	r.db.data.apiKeys[id] = key
+	return true, nil
+}
+
+// ShortenExpiry makes the key expire at the given time unless it expires earlier
+func (r *apiKeyRepository) ShortenExpiry(id string, expiresAt time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	key, ok := r.db.data.apiKeys[id]
+	if ok && expiresAt.Before(key.ExpiresAt) {
+		key.ExpiresAt = expiresAt
+		r.db.data.apiKeys[id] = key
+	}
+	return nil
+}
+
+// RecordUse records when and from which IP address the key was last used
+func (r *apiKeyRepository) RecordUse(id, ip string, at time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	key, ok := r.db.data.apiKeys[id]
+	if ok {
+		key.LastUsedAt = sql.NullTime{Time: at, Valid: true}
+		key.LastUsedIP = sql.NullString{String: ip, Valid: true}
+		r.db.data.apiKeys[id] = key
+	}
+	return nil
+}
@@ -0,0 +1,687 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"taulen/backend/internal/repositories"
+)
+
+// errDuplicateBorrowerEmail mirrors the borrower_email_address_key unique constraint
+var errDuplicateBorrowerEmail = errors.New("borrower with this email already exists")
+
+// borrowerRepository is the in-memory implementation of repositories.BorrowerRepository
+type borrowerRepository struct {
+	db *db
+}
+
+// newBorrower returns a borrower with the column defaults of the borrower table
+func newBorrower(firstName, lastName string) repositories.Borrower {
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	return repositories.Borrower{
+		ID:                    newID(),
+		EmailVerified:         sql.NullBool{Bool: false, Valid: true},
+		MFAEnabled:            sql.NullBool{Bool: false, Valid: true},
+		FailedLoginAttempts:   sql.NullInt64{Int64: 0, Valid: true},
+		FirstName:             firstName,
+		LastName:              lastName,
+		MilitaryServiceStatus: sql.NullBool{Bool: false, Valid: true},
+		ConsentToCreditCheck:  sql.NullBool{Bool: false, Valid: true},
+		ConsentToContact:      sql.NullBool{Bool: false, Valid: true},
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	}
+}
+
+// emailTaken reports whether another borrower already uses email. Must be called with mu held.
+func (r *borrowerRepository) emailTaken(email, exceptID string) bool {
+	for _, b := range r.db.data.borrowers {
+		if b.ID != exceptID && b.EmailAddress.Valid && b.EmailAddress.String == email {
+			return true
+		}
+	}
+	return false
+}
+
+// findByEmail returns the borrower with the given email (case-insensitive). Must be called with mu held.
+func (r *borrowerRepository) findByEmail(email string) (repositories.Borrower, bool) {
+	for _, b := range r.db.data.borrowers {
+		if b.EmailAddress.Valid && strings.EqualFold(b.EmailAddress.String, email) {
+			return b, true
+		}
+	}
+	return repositories.Borrower{}, false
+}
+
+// update applies fn to the borrower with the given ID and bumps updated_at.
+// Like an UPDATE matching no rows, a missing borrower is not an error.
+func (r *borrowerRepository) update(id string, fn func(b *repositories.Borrower) error) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	b, ok := r.db.data.borrowers[id]
+	if !ok {
+		return nil
+	}
+	if err := fn(&b); err != nil {
+		return err
+	}
+	b.UpdatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	r.db.data.borrowers[id] = b
+	return nil
+}
+
+// GetByEmail retrieves a borrower by email (for authentication)
+func (r *borrowerRepository) GetByEmail(email string) (*repositories.Borrower, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	b, ok := r.findByEmail(email)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &b, nil
+}
+
+// GetByPhone retrieves a borrower by phone number (checks mobile, home and work phone)
+func (r *borrowerRepository) GetByPhone(phone string) (*repositories.Borrower, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, b := range r.db.data.borrowers {
+		if (b.MobilePhone.Valid && b.MobilePhone.String == phone) ||
+			(b.HomePhone.Valid && b.HomePhone.String == phone) ||
+			(b.WorkPhone.Valid && b.WorkPhone.String == phone) {
+			return &b, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetByID retrieves a borrower by ID
+func (r *borrowerRepository) GetByID(id string) (*repositories.Borrower, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	b, ok := r.db.data.borrowers[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &b, nil
+}
+
+// GetByEmailOrPhone retrieves a borrower by email or, failing that, by phone number
+func (r *borrowerRepository) GetByEmailOrPhone(email, phone string) (*repositories.Borrower, error) {
+	if email != "" {
+		b, err := r.GetByEmail(email)
+		if err == nil {
+			return b, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	if phone != "" {
+		b, err := r.GetByPhone(phone)
+		if err == nil {
+			return b, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// Create creates a new borrower (during signup)
+func (r *borrowerRepository) Create(email, passwordHash, firstName, lastName, phone string) (*repositories.Borrower, error) {
+	if passwordHash == "" {
+		return nil, errors.New("password hash cannot be empty")
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.emailTaken(email, "") {
+		return nil, errDuplicateBorrowerEmail
+	}
+
+	b := newBorrower(firstName, lastName)
+	b.EmailAddress = sql.NullString{String: email, Valid: true}
+	b.PasswordHash = sql.NullString{String: passwordHash, Valid: true}
+	b.MobilePhone = sql.NullString{String: phone, Valid: true}
+	r.db.data.borrowers[b.ID] = b
+	return &b, nil
+}
+
+// CreateFromPreApplication creates a borrower from pre-application data (without password)
+func (r *borrowerRepository) CreateFromPreApplication(email, firstName, lastName, phone, dateOfBirth, address, city, state, zipCode string) (*repositories.Borrower, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.emailTaken(email, "") {
+		return nil, errDuplicateBorrowerEmail
+	}
+
+	b := newBorrower(firstName, lastName)
+	b.EmailAddress = sql.NullString{String: email, Valid: true}
+	b.MobilePhone = sql.NullString{String: phone, Valid: true}
+	if t, err := time.Parse("2006-01-02", dateOfBirth); err == nil {
+		b.BirthDate = sql.NullTime{Time: t, Valid: true}
+	}
+	r.db.data.borrowers[b.ID] = b
+	return &b, nil
+}
+
+// CreateCoBorrower creates a co-borrower record (without password since they don't have an account)
+func (r *borrowerRepository) CreateCoBorrower(firstName, lastName, middleName, suffix, email, phone, phoneType, maritalStatus string, isVeteran bool) (string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if email != "" && r.emailTaken(email, "") {
+		return "", errDuplicateBorrowerEmail
+	}
+
+	b := newBorrower(firstName, lastName)
+	if middleName != "" {
+		b.MiddleName = sql.NullString{String: middleName, Valid: true}
+	}
+	if suffix != "" {
+		b.Suffix = sql.NullString{String: suffix, Valid: true}
+	}
+	if email != "" {
+		b.EmailAddress = sql.NullString{String: email, Valid: true}
+	}
+	if phone != "" {
+		// Same placement as the PostgreSQL repository: by phone type, mobile when unknown
+		switch phoneType {
+		case "HOME":
+			b.HomePhone = sql.NullString{String: phone, Valid: true}
+		case "WORK":
+			b.WorkPhone = sql.NullString{String: phone, Valid: true}
+		default:
+			b.MobilePhone = sql.NullString{String: phone, Valid: true}
+		}
+	}
+	if status := strings.ToLower(strings.TrimSpace(maritalStatus)); status != "" {
+		b.MaritalStatus = sql.NullString{String: strings.ToUpper(status[:1]) + status[1:], Valid: true}
+	}
+	b.MilitaryServiceStatus = sql.NullBool{Bool: isVeteran, Valid: true}
+
+	r.db.data.borrowers[b.ID] = b
+	return b.ID, nil
+}
+
+// MarkEmailVerified records that the borrower proved ownership of their email address
+func (r *borrowerRepository) MarkEmailVerified(id string) error {
+	return r.update(id, func(b *repositories.Borrower) error {
+		b.EmailVerified = sql.NullBool{Bool: true, Valid: true}
+		return nil
+	})
+}
+
+// SetTwoFactorEnabled opts the borrower in to or out of login verification codes
+func (r *borrowerRepository) SetTwoFactorEnabled(id string, enabled bool) error {
+	return r.update(id, func(b *repositories.Borrower) error {
+		b.TwoFactorEnabled = enabled
+		return nil
+	})
+}
+
+// UpdatePassword updates a borrower's password
+func (r *borrowerRepository) UpdatePassword(borrowerID string, passwordHash string) error {
+	return r.update(borrowerID, func(b *repositories.Borrower) error {
+		b.PasswordHash = sql.NullString{String: passwordHash, Valid: true}
+		b.LastPasswordChangeAt = sql.NullTime{Time: time.Now(), Valid: true}
+		return nil
+	})
+}
+
+// UpdateName updates a borrower's name
+func (r *borrowerRepository) UpdateName(borrowerID string, firstName, lastName string) error {
+	return r.update(borrowerID, func(b *repositories.Borrower) error {
+		b.FirstName = firstName
+		b.LastName = lastName
+		return nil
+	})
+}
+
+// UpdateBorrowerInfo updates borrower personal information (date of birth, etc.)
+func (r *borrowerRepository) UpdateBorrowerInfo(id string, dateOfBirth *time.Time) error {
+	return r.update(id, func(b *repositories.Borrower) error {
+		b.BirthDate = sql.NullTime{}
+		if dateOfBirth != nil {
+			b.BirthDate = sql.NullTime{Time: *dateOfBirth, Valid: true}
+		}
+		return nil
+	})
+}
+
+// UpdateBorrowerDetails updates borrower details including middle name, suffix, marital status, and phone
+func (r *borrowerRepository) UpdateBorrowerDetails(id string, middleName, suffix, maritalStatus *string, phone, phoneType *string) error {
+	return r.update(id, func(b *repositories.Borrower) error {
+		coalesce(&b.MiddleName, middleName)
+		coalesce(&b.Suffix, suffix)
+		coalesce(&b.MaritalStatus, maritalStatus)
+		if phoneType != nil {
+			switch *phoneType {
+			case "MOBILE":
+				coalesce(&b.MobilePhone, phone)
+			case "HOME":
+				coalesce(&b.HomePhone, phone)
+			case "WORK":
+				coalesce(&b.WorkPhone, phone)
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateEmail updates a borrower's email address; a changed address is no longer verified
+func (r *borrowerRepository) UpdateEmail(id string, email string) error {
+	r.db.mu.Lock()
+	taken := r.emailTaken(email, id)
+	r.db.mu.Unlock()
+	if taken {
+		return errDuplicateBorrowerEmail
+	}
+
+	return r.update(id, func(b *repositories.Borrower) error {
+		if !strings.EqualFold(b.EmailAddress.String, email) {
+			b.EmailVerified = sql.NullBool{Bool: false, Valid: true}
+		}
+		b.EmailAddress = sql.NullString{String: email, Valid: true}
+		return nil
+	})
+}
+
+// UpdateBorrowerConsentsAndMilitary updates military service status and consents
+func (r *borrowerRepository) UpdateBorrowerConsentsAndMilitary(id string, militaryServiceStatus, consentToCreditCheck, consentToContact *bool) error {
+	return r.update(id, func(b *repositories.Borrower) error {
+		if militaryServiceStatus != nil {
+			b.MilitaryServiceStatus = sql.NullBool{Bool: *militaryServiceStatus, Valid: true}
+		}
+		if consentToCreditCheck != nil {
+			b.ConsentToCreditCheck = sql.NullBool{Bool: *consentToCreditCheck, Valid: true}
+		}
+		if consentToContact != nil {
+			b.ConsentToContact = sql.NullBool{Bool: *consentToContact, Valid: true}
+		}
+		return nil
+	})
+}
+
+// UpdateBorrowerName updates borrower first and last name
+func (r *borrowerRepository) UpdateBorrowerName(id string, firstName, lastName *string) error {
+	return r.update(id, func(b *repositories.Borrower) error {
+		if firstName != nil {
+			b.FirstName = *firstName
+		}
+		if lastName != nil {
+			b.LastName = *lastName
+		}
+		return nil
+	})
+}
+
+// UpdateBorrowerSSN updates borrower SSN (taxpayer_identifier_value)
+func (r *borrowerRepository) UpdateBorrowerSSN(id string, ssn string) error {
+	return r.update(id, func(b *repositories.Borrower) error {
+		b.TaxpayerIDValue = sql.NullString{String: ssn, Valid: true}
+		return nil
+	})
+}
+
+// UpdateBorrowerCitizenship updates borrower citizenship/residency type
+func (r *borrowerRepository) UpdateBorrowerCitizenship(id string, citizenshipType string) error {
+	return r.update(id, func(b *repositories.Borrower) error {
+		b.CitizenshipType = sql.NullString{String: citizenshipType, Valid: true}
+		return nil
+	})
+}
+
+// UpdateBorrowerDependents updates borrower dependent count
+func (r *borrowerRepository) UpdateBorrowerDependents(id string, count int) error {
+	return r.update(id, func(b *repositories.Borrower) error {
+		b.DependentCount = sql.NullInt64{Int64: int64(count), Valid: true}
+		return nil
+	})
+}
+
+// UpdateBorrowerAdditionalPhones updates additional phone fields (homePhone, mobilePhone, workPhone, workPhoneExt)
+func (r *borrowerRepository) UpdateBorrowerAdditionalPhones(id string, homePhone, mobilePhone, workPhone, workPhoneExt *string) error {
+	return r.update(id, func(b *repositories.Borrower) error {
+		coalesce(&b.HomePhone, homePhone)
+		coalesce(&b.MobilePhone, mobilePhone)
+		coalesce(&b.WorkPhone, workPhone)
+		coalesce(&b.WorkPhoneExt, workPhoneExt)
+		return nil
+	})
+}
+
+// CreateResidence creates a residence record for a borrower
+func (r *borrowerRepository) CreateResidence(borrowerID string, residencyType, address, city, state, zipCode string) (string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	res := residence{
+		ID:            newID(),
+		BorrowerID:    borrowerID,
+		ResidencyType: residencyType,
+		Address:       address,
+		City:          city,
+		State:         state,
+		ZipCode:       zipCode,
+	}
+	r.db.data.residences = append(r.db.data.residences, res)
+	return res.ID, nil
+}
+
+// currentResidence returns the index of the latest current residence for a borrower,
+// or -1 if there is none. Must be called with mu held.
+func (r *borrowerRepository) currentResidence(borrowerID string) int {
+	for i := len(r.db.data.residences) - 1; i >= 0; i-- {
+		res := r.db.data.residences[i]
+		if res.BorrowerID == borrowerID && res.ResidencyType == "BorrowerCurrentResidence" {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetCurrentResidence retrieves the current residence for a borrower
+func (r *borrowerRepository) GetCurrentResidence(borrowerID string) (address, city, state, zipCode string, err error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	i := r.currentResidence(borrowerID)
+	if i < 0 {
+		// No residence found - return empty strings but no error
+		return "", "", "", "", nil
+	}
+	res := r.db.data.residences[i]
+	return res.Address, res.City, res.State, res.ZipCode, nil
+}
+
+// GetCurrentResidenceID retrieves the ID of the current residence for a borrower
+func (r *borrowerRepository) GetCurrentResidenceID(borrowerID string) (string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	i := r.currentResidence(borrowerID)
+	if i < 0 {
+		return "", nil
+	}
+	return r.db.data.residences[i].ID, nil
+}
+
+// UpdateOrCreateResidence updates existing residence or creates a new one
+func (r *borrowerRepository) UpdateOrCreateResidence(borrowerID string, residencyType, address, city, state, zipCode string) error {
+	r.db.mu.Lock()
+	if i := r.currentResidence(borrowerID); i >= 0 {
+		res := &r.db.data.residences[i]
+		res.Address, res.City, res.State, res.ZipCode = address, city, state, zipCode
+		r.db.mu.Unlock()
+		return nil
+	}
+	r.db.mu.Unlock()
+
+	_, err := r.CreateResidence(borrowerID, residencyType, address, city, state, zipCode)
+	return err
+}
+
+// DeleteFormerResidences deletes all former residence records for a borrower
+func (r *borrowerRepository) DeleteFormerResidences(borrowerID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	kept := r.db.data.residences[:0:0]
+	for _, res := range r.db.data.residences {
+		if res.BorrowerID == borrowerID && res.ResidencyType == "BorrowerFormerResidence" {
+			continue
+		}
+		kept = append(kept, res)
+	}
+	r.db.data.residences = kept
+	return nil
+}
+
+// CreateFormerResidence creates a former residence record for a borrower
+func (r *borrowerRepository) CreateFormerResidence(borrowerID, address, city, state, zipCode string, durationYears, durationMonths *int, housingStatus *string) error {
+	var residencyBasisType *string
+	if housingStatus != nil && (*housingStatus == "Own" || *housingStatus == "Rent") {
+		basis := *housingStatus
+		residencyBasisType = &basis
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.data.residences = append(r.db.data.residences, residence{
+		ID:                 newID(),
+		BorrowerID:         borrowerID,
+		ResidencyType:      "BorrowerFormerResidence",
+		ResidencyBasisType: residencyBasisType,
+		Address:            address,
+		City:               city,
+		State:              state,
+		ZipCode:            zipCode,
+		DurationYears:      durationYears,
+		DurationMonths:     durationMonths,
+	})
+	return nil
+}
+
+// LinkBorrowerToDeal links a borrower to a deal (no-op if already linked)
+func (r *borrowerRepository) LinkBorrowerToDeal(borrowerID, dealID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, link := range r.db.data.borrowerDeals {
+		if link.BorrowerID == borrowerID && link.DealID == dealID {
+			return nil
+		}
+	}
+	r.db.data.borrowerDeals = append(r.db.data.borrowerDeals, borrowerDeal{
+		BorrowerID: borrowerID,
+		DealID:     dealID,
+		CreatedAt:  time.Now(),
+	})
+	return nil
+}
+
+// IsLinkedToDeal reports whether a borrower is linked to a deal
+func (r *borrowerRepository) IsLinkedToDeal(borrowerID, dealID string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, link := range r.db.data.borrowerDeals {
+		if link.BorrowerID == borrowerID && link.DealID == dealID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetCoBorrowersByDealID retrieves all co-borrowers (non-primary) for a deal, oldest link first
+func (r *borrowerRepository) GetCoBorrowersByDealID(dealID, primaryBorrowerID string) ([]*repositories.Borrower, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var borrowers []*repositories.Borrower
+	for _, link := range r.db.data.borrowerDeals {
+		if link.DealID != dealID || link.BorrowerID == primaryBorrowerID {
+			continue
+		}
+		if b, ok := r.db.data.borrowers[link.BorrowerID]; ok {
+			borrowers = append(borrowers, &b)
+		}
+	}
+	return borrowers, nil
+}
+
+// coalesce sets dst to *val when val is non-nil, like COALESCE($n, column)
+func coalesce(dst *sql.NullString, val *string) {
+	if val != nil {
+		*dst = sql.NullString{String: *val, Valid: true}
+	}
+}
+
+// RecordLoginFailure increments the failed login counter and returns its new value
+func (r *borrowerRepository) RecordLoginFailure(id string) (int, error) {
+	var attempts int
+	err := r.update(id, func(a *repositories.Borrower) error {
+		a.FailedLoginAttempts = sql.NullInt64{Int64: a.FailedLoginAttempts.Int64 + 1, Valid: true}
+		attempts = int(a.FailedLoginAttempts.Int64)
+		return nil
+	})
+	if err == nil && attempts == 0 {
+		return 0, sql.ErrNoRows
+	}
+	return attempts, err
+}
+
+// LockAccount locks the account until the given time
+func (r *borrowerRepository) LockAccount(id string, until time.Time) error {
+	return r.update(id, func(a *repositories.Borrower) error {
+		a.AccountLockedUntil = sql.NullTime{Time: until, Valid: true}
+		return nil
+	})
+}
+
+// RecordLoginSuccess resets the lockout state and records the login time
+func (r *borrowerRepository) RecordLoginSuccess(id string) error {
+	return r.update(id, func(a *repositories.Borrower) error {
+		a.FailedLoginAttempts = sql.NullInt64{Int64: 0, Valid: true}
+		a.AccountLockedUntil = sql.NullTime{}
+		a.LastLoginAt = sql.NullTime{Time: time.Now(), Valid: true}
+		return nil
+	})
+}
+
+// UnlockAccount clears the lock and the failed login counter
+func (r *borrowerRepository) UnlockAccount(id string) error {
+	return r.update(id, func(a *repositories.Borrower) error {
+		a.FailedLoginAttempts = sql.NullInt64{Int64: 0, Valid: true}
+		a.AccountLockedUntil = sql.NullTime{}
+		return nil
+	})
+}
+
+// SetPasswordResetToken stores the hash of a password reset token, replacing any previous one
+func (r *borrowerRepository) SetPasswordResetToken(id, tokenHash string, expiresAt time.Time) error {
+	return r.update(id, func(a *repositories.Borrower) error {
+		a.PasswordResetToken = sql.NullString{String: tokenHash, Valid: true}
+		a.PasswordResetExpiresAt = sql.NullTime{Time: expiresAt, Valid: true}
+		return nil
+	})
+}
+
+// ResetPassword sets a new password for the account holding the unexpired reset
+// token, consuming the token and clearing any login lockout
+func (r *borrowerRepository) ResetPassword(tokenHash, passwordHash string) (string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	for id, a := range r.db.data.borrowers {
+		if !a.PasswordResetToken.Valid || a.PasswordResetToken.String != tokenHash ||
+			!a.PasswordResetExpiresAt.Valid || !a.PasswordResetExpiresAt.Time.After(now) {
+			continue
+		}
+		a.PasswordHash = sql.NullString{String: passwordHash, Valid: true}
+		a.PasswordResetToken = sql.NullString{}
+		a.PasswordResetExpiresAt = sql.NullTime{}
+		a.LastPasswordChangeAt = sql.NullTime{Time: now, Valid: true}
+		a.FailedLoginAttempts = sql.NullInt64{Int64: 0, Valid: true}
+		a.AccountLockedUntil = sql.NullTime{}
+		a.UpdatedAt = sql.NullTime{Time: now, Valid: true}
+		r.db.data.borrowers[id] = a
+		return id, nil
+	}
+	return "", sql.ErrNoRows
+}
+
+// SetMFASecret stores a new TOTP secret pending confirmation, disabling MFA
+// and discarding any backup codes until EnableMFA is called
+func (r *borrowerRepository) SetMFASecret(id, secret string) error {
+	return r.update(id, func(a *repositories.Borrower) error {
+		a.MFAEnabled = sql.NullBool{Bool: false, Valid: true}
+		a.MFASecret = sql.NullString{String: secret, Valid: true}
+		a.MFABackupCodes = sql.NullString{}
+		a.MFASetupAt = sql.NullTime{Time: time.Now(), Valid: true}
+		a.MFAVerifiedAt = sql.NullTime{}
+		return nil
+	})
+}
+
+// EnableMFA turns on MFA for the pending secret and stores the backup code hashes
+func (r *borrowerRepository) EnableMFA(id, backupCodes string) error {
+	return r.update(id, func(a *repositories.Borrower) error {
+		if a.MFASecret.Valid {
+			a.MFAEnabled = sql.NullBool{Bool: true, Valid: true}
+			a.MFABackupCodes = sql.NullString{String: backupCodes, Valid: true}
+		}
+		return nil
+	})
+}
+
+// DisableMFA turns off MFA and removes the secret and backup codes
+func (r *borrowerRepository) DisableMFA(id string) error {
+	return r.update(id, func(a *repositories.Borrower) error {
+		a.MFAEnabled = sql.NullBool{Bool: false, Valid: true}
+		a.MFASecret = sql.NullString{}
+		a.MFABackupCodes = sql.NullString{}
+		a.MFASetupAt = sql.NullTime{}
+		a.MFAVerifiedAt = sql.NullTime{}
+		return nil
+	})
+}
+
+// RecordMFAVerification records the time step of an accepted TOTP code and
+// reports whether it did; false means a code of this or a later step was
+// already used
+func (r *borrowerRepository) RecordMFAVerification(id string, step time.Time) (bool, error) {
+	recorded := false
+	err := r.update(id, func(a *repositories.Borrower) error {
+		if a.MFAVerifiedAt.Valid && !a.MFAVerifiedAt.Time.Before(step) {
+			return nil
+		}
+		a.MFAVerifiedAt = sql.NullTime{Time: step, Valid: true}
+		recorded = true
+		return nil
+	})
+	return recorded, err
+}
+
+// SetMFABackupCodes replaces the backup code hashes
+func (r *borrowerRepository) SetMFABackupCodes(id, backupCodes string) error {
+	return r.update(id, func(a *repositories.Borrower) error {
+		a.MFABackupCodes = sql.NullString{String: backupCodes, Valid: true}
+		return nil
+	})
+}
+
+// ConsumeMFABackupCode removes a backup code hash and reports whether it was present
+func (r *borrowerRepository) ConsumeMFABackupCode(id, codeHash string) (bool, error) {
+	consumed := false
+	err := r.update(id, func(a *repositories.Borrower) error {
+		if !a.MFABackupCodes.Valid {
+			return nil
+		}
+		codes := strings.Split(a.MFABackupCodes.String, ",")
+		for i, code := range codes {
+			if code != codeHash {
+				continue
+			}
+			codes = append(codes[:i], codes[i+1:]...)
+			a.MFABackupCodes = sql.NullString{String: strings.Join(codes, ","), Valid: len(codes) > 0}
+			consumed = true
+			return nil
+		}
+		return nil
+	})
+	return consumed, err
+}
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"database/sql"
+	"math"
+	"time"
+
+	"taulen/backend/internal/repositories"
+)
+
+// dealProgressRepository is the in-memory implementation of repositories.DealProgressRepository
+type dealProgressRepository struct {
+	db *db
+}
+
+// GetByDealID retrieves progress for a deal
+func (r *dealProgressRepository) GetByDealID(dealID string) (*repositories.DealProgress, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	progress, ok := r.db.data.progress[dealID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &progress, nil
+}
+
+// update applies fn to a deal's progress and then, like the trg_update_progress
+// trigger, recomputes the completion percentage
+func (r *dealProgressRepository) update(dealID string, fn func(p *repositories.DealProgress)) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	progress, ok := r.db.data.progress[dealID]
+	if !ok {
+		return
+	}
+	fn(&progress)
+
+	sections := progress.SectionFlags()
+	complete := 0
+	for _, s := range sections {
+		if *s.Complete {
+			complete++
+		}
+	}
+	progress.ProgressPercentage = int(math.Round(float64(complete) / float64(len(sections)) * 100))
+	progress.UpdatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	r.db.data.progress[dealID] = progress
+}
+
+// isSection reports whether section is a known URLA section name
+func isSection(section string) bool {
+	for _, s := range (&repositories.DealProgress{}).SectionFlags() {
+		if s.Section == section {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateSection marks a section as complete or incomplete
+func (r *dealProgressRepository) UpdateSection(dealID string, section string, complete bool) error {
+	if !isSection(section) {
+		return sql.ErrNoRows // Invalid section name
+	}
+
+	r.update(dealID, func(p *repositories.DealProgress) {
+		for _, s := range p.SectionFlags() {
+			if s.Section == section {
+				*s.Complete = complete
+			}
+		}
+		p.LastUpdatedSection = sql.NullString{String: section, Valid: true}
+		p.LastUpdatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	})
+	return nil
+}
+
+// UpdateNotes updates progress notes
+func (r *dealProgressRepository) UpdateNotes(dealID string, notes string) error {
+	r.update(dealID, func(p *repositories.DealProgress) {
+		p.ProgressNotes = sql.NullString{String: notes, Valid: true}
+	})
+	return nil
+}
+
+// GetNextIncompleteSection returns the first incomplete section for resumption
+func (r *dealProgressRepository) GetNextIncompleteSection(dealID string) (string, error) {
+	progress, err := r.GetByDealID(dealID)
+	if err != nil {
+		return "", err
+	}
+	return progress.NextIncompleteSection(), nil
+}
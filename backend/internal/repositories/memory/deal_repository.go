@@ -0,0 +1,360 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"taulen/backend/internal/repositories"
+)
+
+// dealRepository is the in-memory implementation of repositories.DealRepository
+type dealRepository struct {
+	db *db
+}
+
+// CreateDeal creates a new deal with an associated loan and, like the
+// trg_create_deal_progress trigger, its progress record
+func (r *dealRepository) CreateDeal(userID string, borrowerID *string, loanPurpose string, loanAmount float64, status string) (string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	deal := repositories.Deal{
+		ID:                  newID(),
+		ApplicationType:     sql.NullString{String: "IndividualCredit", Valid: true},
+		TotalBorrowers:      sql.NullInt64{Int64: 1, Valid: true},
+		ApplicationDate:     sql.NullTime{Time: now.Truncate(24 * time.Hour), Valid: true},
+		CreatedAt:           sql.NullTime{Time: now, Valid: true},
+		LoanID:              sql.NullString{String: newID(), Valid: true},
+		LoanPurposeType:     sql.NullString{String: loanPurpose, Valid: true},
+		LoanAmountRequested: sql.NullFloat64{Float64: loanAmount, Valid: true},
+	}
+	if borrowerID != nil {
+		if _, ok := r.db.data.borrowers[*borrowerID]; !ok {
+			return "", errors.New("primary borrower does not exist")
+		}
+		deal.PrimaryBorrowerID = sql.NullString{String: *borrowerID, Valid: true}
+	}
+	if userID != "" {
+		if _, ok := r.db.data.users[userID]; !ok {
+			return "", errors.New("assigned user does not exist")
+		}
+	}
+
+	r.db.data.deals[deal.ID] = deal
+	if userID != "" {
+		r.db.data.dealAssignments = append(r.db.data.dealAssignments, dealAssignment{
+			DealID:    deal.ID,
+			UserID:    userID,
+			CreatedAt: now,
+		})
+	}
+	r.db.data.progress[deal.ID] = repositories.DealProgress{
+		ID:            newID(),
+		DealID:        deal.ID,
+		LastUpdatedAt: sql.NullTime{Time: now, Valid: true},
+		CreatedAt:     sql.NullTime{Time: now, Valid: true},
+		UpdatedAt:     sql.NullTime{Time: now, Valid: true},
+	}
+	return deal.ID, nil
+}
+
+// GetDealByID retrieves a deal by ID with associated loan information
+func (r *dealRepository) GetDealByID(dealID string) (*repositories.Deal, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	deal, ok := r.db.data.deals[dealID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &deal, nil
+}
+
+// GetDealByLoanNumber retrieves a deal by loan number
+func (r *dealRepository) GetDealByLoanNumber(loanNumber string) (*repositories.Deal, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, deal := range r.db.data.deals {
+		if deal.LoanNumber.Valid && deal.LoanNumber.String == loanNumber {
+			return &deal, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// update applies fn to the deal with the given ID; a missing deal is not an error
+func (r *dealRepository) update(dealID string, fn func(d *repositories.Deal)) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if deal, ok := r.db.data.deals[dealID]; ok {
+		fn(&deal)
+		r.db.data.deals[dealID] = deal
+	}
+	return nil
+}
+
+// UpdateDeal updates deal information
+func (r *dealRepository) UpdateDeal(dealID string, loanNumber, universalLoanIdentifier, agencyCaseIdentifier *string, applicationType *string, totalBorrowers *int) error {
+	return r.update(dealID, func(d *repositories.Deal) {
+		coalesce(&d.LoanNumber, loanNumber)
+		coalesce(&d.UniversalLoanIdentifier, universalLoanIdentifier)
+		coalesce(&d.AgencyCaseIdentifier, agencyCaseIdentifier)
+		coalesce(&d.ApplicationType, applicationType)
+		if totalBorrowers != nil {
+			d.TotalBorrowers = sql.NullInt64{Int64: int64(*totalBorrowers), Valid: true}
+		}
+	})
+}
+
+// UpdateCurrentFormStep updates the current form step for a deal
+func (r *dealRepository) UpdateCurrentFormStep(dealID string, formStep string) error {
+	return r.update(dealID, func(d *repositories.Deal) {
+		d.CurrentFormStep = sql.NullString{String: formStep, Valid: true}
+	})
+}
+
+// UpdateToJointApplication updates a deal to joint application type
+func (r *dealRepository) UpdateToJointApplication(dealID string) error {
+	applicationType := "JointCredit"
+	totalBorrowers := 2
+	return r.UpdateDeal(dealID, nil, nil, nil, &applicationType, &totalBorrowers)
+}
+
+// UpdateLoan updates loan information for a deal
+func (r *dealRepository) UpdateLoan(dealID string, loanPurpose, propertyType, manufacturedHomeWidthType, titleMannerType *string, loanAmount *float64, loanTermMonths *int, interestRate *float64) error {
+	return r.update(dealID, func(d *repositories.Deal) {
+		coalesce(&d.LoanPurposeType, loanPurpose)
+		coalesce(&d.PropertyType, propertyType)
+		coalesce(&d.ManufacturedHomeWidth, manufacturedHomeWidthType)
+		coalesce(&d.TitleMannerType, titleMannerType)
+		if loanAmount != nil {
+			d.LoanAmountRequested = sql.NullFloat64{Float64: *loanAmount, Valid: true}
+		}
+		if loanTermMonths != nil {
+			d.LoanTermMonths = sql.NullInt64{Int64: int64(*loanTermMonths), Valid: true}
+		}
+		if interestRate != nil {
+			d.InterestRatePercentage = sql.NullFloat64{Float64: *interestRate, Valid: true}
+		}
+	})
+}
+
+// CreateSubjectProperty creates a subject property record for a deal
+func (r *dealRepository) CreateSubjectProperty(dealID string, address, city, state, zipCode string, estimatedValue float64) (string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.deals[dealID]; !ok {
+		return "", errors.New("deal does not exist")
+	}
+	for _, p := range r.db.data.subjectProperties {
+		if p.DealID == dealID {
+			return "", errors.New("subject property already exists for this deal")
+		}
+	}
+
+	property := subjectProperty{
+		ID:             newID(),
+		DealID:         dealID,
+		Address:        address,
+		City:           city,
+		State:          state,
+		ZipCode:        zipCode,
+		EstimatedValue: estimatedValue,
+	}
+	r.db.data.subjectProperties = append(r.db.data.subjectProperties, property)
+	return property.ID, nil
+}
+
+// summaries returns list entries for the deals matching keep, ordered by less.
+// Must be called with mu held.
+func (r *dealRepository) summaries(keep func(d repositories.Deal) bool, less func(a, b *repositories.DealSummary) bool) []*repositories.DealSummary {
+	deals := make([]*repositories.DealSummary, 0)
+	for _, d := range r.db.data.deals {
+		if !keep(d) {
+			continue
+		}
+		summary := &repositories.DealSummary{
+			ID:                  d.ID,
+			LoanNumber:          d.LoanNumber,
+			ApplicationType:     d.ApplicationType,
+			ApplicationDate:     d.ApplicationDate,
+			CreatedAt:           d.CreatedAt,
+			LoanPurposeType:     d.LoanPurposeType,
+			LoanAmountRequested: d.LoanAmountRequested,
+			LastUpdatedAt:       d.CreatedAt,
+		}
+		if p, ok := r.db.data.progress[d.ID]; ok {
+			if p.UpdatedAt.Valid {
+				summary.LastUpdatedAt = p.UpdatedAt
+			}
+			summary.ProgressPercentage = sql.NullInt64{Int64: int64(p.ProgressPercentage), Valid: true}
+			summary.LastUpdatedSection = p.LastUpdatedSection
+		}
+		deals = append(deals, summary)
+	}
+	sort.SliceStable(deals, func(i, j int) bool { return less(deals[i], deals[j]) })
+	return deals
+}
+
+// newestFirst orders deals by creation time, newest first
+func newestFirst(a, b *repositories.DealSummary) bool {
+	return a.CreatedAt.Time.After(b.CreatedAt.Time)
+}
+
+// GetDealsByUserID retrieves all deals an employee is assigned to
+func (r *dealRepository) GetDealsByUserID(userID string) ([]*repositories.DealSummary, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	assigned := func(d repositories.Deal) bool { return r.isAssigned(d.ID, userID) }
+	return r.summaries(assigned, newestFirst), nil
+}
+
+// AssignUser assigns an employee to a deal (no-op if already assigned)
+func (r *dealRepository) AssignUser(dealID, userID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.deals[dealID]; !ok {
+		return errors.New("deal does not exist")
+	}
+	if _, ok := r.db.data.users[userID]; !ok {
+		return errors.New("assigned user does not exist")
+	}
+	if r.isAssigned(dealID, userID) {
+		return nil
+	}
+	r.db.data.dealAssignments = append(r.db.data.dealAssignments, dealAssignment{
+		DealID:    dealID,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	})
+	return nil
+}
+
+// UnassignUser removes an employee from a deal (no-op if not assigned)
+func (r *dealRepository) UnassignUser(dealID, userID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var kept []dealAssignment
+	for _, a := range r.db.data.dealAssignments {
+		if a.DealID != dealID || a.UserID != userID {
+			kept = append(kept, a)
+		}
+	}
+	r.db.data.dealAssignments = kept
+	return nil
+}
+
+// IsUserAssigned reports whether an employee is assigned to a deal
+func (r *dealRepository) IsUserAssigned(dealID, userID string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return r.isAssigned(dealID, userID), nil
+}
+
+// ListAssignees returns the IDs of the employees assigned to a deal, in the
+// order they were assigned
+func (r *dealRepository) ListAssignees(dealID string) ([]string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var userIDs []string
+	for _, a := range r.db.data.dealAssignments {
+		if a.DealID == dealID {
+			userIDs = append(userIDs, a.UserID)
+		}
+	}
+	return userIDs, nil
+}
+
+// isAssigned reports whether a deal_assignment row exists; callers hold r.db.mu
+func (r *dealRepository) isAssigned(dealID, userID string) bool {
+	for _, a := range r.db.data.dealAssignments {
+		if a.DealID == dealID && a.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordEdit stores who wrote a section of a deal
+func (r *dealRepository) RecordEdit(edit *repositories.DealEdit) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.data.deals[edit.DealID]; !ok {
+		return errors.New("deal does not exist")
+	}
+	if edit.ActorID.Valid {
+		if _, ok := r.db.data.users[edit.ActorID.String]; !ok {
+			return errors.New("actor does not exist")
+		}
+	}
+	edit.ID = newID()
+	edit.CreatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	r.db.data.dealEdits = append(r.db.data.dealEdits, *edit)
+	return nil
+}
+
+// ListEdits returns the edits of a deal, oldest first, with their actors' names
+func (r *dealRepository) ListEdits(dealID string) ([]*repositories.DealEdit, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var edits []*repositories.DealEdit
+	for _, e := range r.db.data.dealEdits {
+		if e.DealID != dealID {
+			continue
+		}
+		edit := e
+		if actor, ok := r.db.data.users[edit.ActorID.String]; edit.ActorID.Valid && ok {
+			edit.ActorFirstName = actor.FirstName
+			edit.ActorLastName = actor.LastName
+		}
+		edits = append(edits, &edit)
+	}
+	return edits, nil
+}
+
+// GetDealsByBorrowerID retrieves all deals for a borrower, ordered by latest modification
+func (r *dealRepository) GetDealsByBorrowerID(borrowerID string) ([]*repositories.DealSummary, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	forBorrower := func(d repositories.Deal) bool {
+		return d.PrimaryBorrowerID.Valid && d.PrimaryBorrowerID.String == borrowerID
+	}
+	lastModifiedFirst := func(a, b *repositories.DealSummary) bool {
+		if !a.LastUpdatedAt.Time.Equal(b.LastUpdatedAt.Time) {
+			return a.LastUpdatedAt.Time.After(b.LastUpdatedAt.Time)
+		}
+		return newestFirst(a, b)
+	}
+	return r.summaries(forBorrower, lastModifiedFirst), nil
+}
+
+// ListDeals retrieves all deals with pagination
+func (r *dealRepository) ListDeals(limit, offset int) ([]*repositories.DealSummary, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	all := func(repositories.Deal) bool { return true }
+	deals := r.summaries(all, newestFirst)
+	if offset >= len(deals) {
+		return make([]*repositories.DealSummary, 0), nil
+	}
+	deals = deals[offset:]
+	if limit >= 0 && limit < len(deals) {
+		deals = deals[:limit]
+	}
+	return deals, nil
+}
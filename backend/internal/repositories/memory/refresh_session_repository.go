@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"taulen/backend/internal/repositories"
+)
+
+// refreshSessionRepository is the in-memory implementation of repositories.RefreshSessionRepository
+type refreshSessionRepository struct {
+	db *db
+}
+
+// Create stores a new refresh session; ID and FamilyID are chosen by the caller
+func (r *refreshSessionRepository) Create(session *repositories.RefreshSession) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, exists := r.db.data.refreshSessions[session.ID]; exists {
+		return errors.New("duplicate key value violates unique constraint \"refresh_session_pkey\"")
+	}
+	now := time.Now()
+	session.CreatedAt = sql.NullTime{Time: now, Valid: true}
+	session.SignedInAt = sql.NullTime{Time: now, Valid: true}
+	for _, other := range r.db.data.refreshSessions {
+		if other.FamilyID == session.FamilyID && other.SignedInAt.Valid && other.SignedInAt.Time.Before(session.SignedInAt.Time) {
+			session.SignedInAt = other.SignedInAt
+		}
+	}
+	r.db.data.refreshSessions[session.ID] = *session
+	return nil
+}
+
+// GetByID retrieves a refresh session by ID
+func (r *refreshSessionRepository) GetByID(id string) (*repositories.RefreshSession, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	session, ok := r.db.data.refreshSessions[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &session, nil
+}
+
+// ListActive returns the subject's sessions that can still be refreshed, most recently issued first
+func (r *refreshSessionRepository) ListActive(subjectID string) ([]*repositories.RefreshSession, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	var sessions []*repositories.RefreshSession
+	for _, session := range r.db.data.refreshSessions {
+		if session.SubjectID == subjectID && !session.RotatedAt.Valid && !session.RevokedAt.Valid && session.ExpiresAt.After(now) {
+			sessions = append(sessions, &session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Time.After(sessions[j].CreatedAt.Time)
+	})
+	return sessions, nil
+}
+
+// MarkRotated marks an active, unexpired session as rotated
+func (r *refreshSessionRepository) MarkRotated(id string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	session, ok := r.db.data.refreshSessions[id]
+	now := time.Now()
+	if !ok || session.RotatedAt.Valid || session.RevokedAt.Valid || !session.ExpiresAt.After(now) {
+		return false, nil
+	}
+	session.RotatedAt = sql.NullTime{Time: now, Valid: true}
+	r.db.data.refreshSessions[id] = session
+	return true, nil
+}
+
+// RevokeFamily revokes every session in the family that is not already revoked
+func (r *refreshSessionRepository) RevokeFamily(familyID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	for id, session := range r.db.data.refreshSessions {
+		if session.FamilyID == familyID && !session.RevokedAt.Valid {
+			session.RevokedAt = now
+			r.db.data.refreshSessions[id] = session
+		}
+	}
+	return nil
+}
+
+// RevokeSubject revokes every session of the subject that is not already revoked
+func (r *refreshSessionRepository) RevokeSubject(subjectID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	for id, session := range r.db.data.refreshSessions {
+		if session.SubjectID == subjectID && !session.RevokedAt.Valid {
+			session.RevokedAt = now
+			r.db.data.refreshSessions[id] = session
+		}
+	}
+	return nil
+}
+
+// RevokeSubjectExcept revokes every session of the subject outside the given
+// family that is not already revoked
+func (r *refreshSessionRepository) RevokeSubjectExcept(subjectID, familyID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	for id, session := range r.db.data.refreshSessions {
+		if session.SubjectID == subjectID && session.FamilyID != familyID && !session.RevokedAt.Valid {
+			session.RevokedAt = now
+			r.db.data.refreshSessions[id] = session
+		}
+	}
+	return nil
+}
+
+// HasDevices reports whether any device of the subject is known
+func (r *refreshSessionRepository) HasDevices(subjectID string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for device := range r.db.data.knownDevices {
+		if device.SubjectID == subjectID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AddDevice remembers a device of the subject and reports whether it was not known yet
+func (r *refreshSessionRepository) AddDevice(subjectID, deviceHash string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	device := knownDevice{SubjectID: subjectID, DeviceHash: deviceHash}
+	if _, known := r.db.data.knownDevices[device]; known {
+		return false, nil
+	}
+	r.db.data.knownDevices[device] = time.Now()
+	return true, nil
+}
+
+// DeleteExpired deletes sessions that expired before the given time
+func (r *refreshSessionRepository) DeleteExpired(before time.Time) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var deleted int64
+	for id, session := range r.db.data.refreshSessions {
+		if session.ExpiresAt.Before(before) {
+			delete(r.db.data.refreshSessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
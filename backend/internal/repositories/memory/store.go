@@ -0,0 +1,234 @@
+// Package memory provides an in-memory implementation of the repository
+// interfaces for local demo mode and for exercising services and handlers
+// without a database. It mirrors the behavior of the PostgreSQL schema that
+// callers rely on: defaults, unique constraints, the deal_progress triggers and
+// sql.ErrNoRows for missing records.
+package memory
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	"taulen/backend/internal/repositories"
+)
+
+var (
+	_ repositories.Store                      = (*Store)(nil)
+	_ repositories.UserRepository             = (*userRepository)(nil)
+	_ repositories.BorrowerRepository         = (*borrowerRepository)(nil)
+	_ repositories.DealRepository             = (*dealRepository)(nil)
+	_ repositories.DealProgressRepository     = (*dealProgressRepository)(nil)
+	_ repositories.RefreshSessionRepository   = (*refreshSessionRepository)(nil)
+	_ repositories.VerificationCodeRepository = (*verificationCodeRepository)(nil)
+	_ repositories.APIKeyRepository           = (*apiKeyRepository)(nil)
+)
+
+// residence is a row of the residence table
+type residence struct {
+	ID                 string
+	BorrowerID         string
+	ResidencyType      string
+	ResidencyBasisType *string
+	Address            string
+	City               string
+	State              string
+	ZipCode            string
+	DurationYears      *int
+	DurationMonths     *int
+}
+
+// subjectProperty is a row of the subject_property table
+type subjectProperty struct {
+	ID             string
+	DealID         string
+	Address        string
+	City           string
+	State          string
+	ZipCode        string
+	EstimatedValue float64
+}
+
+// borrowerDeal is a row of the borrower_progress table linking a borrower to a deal
+type borrowerDeal struct {
+	BorrowerID string
+	DealID     string
+	CreatedAt  time.Time
+}
+
+// dealAssignment is a row of the deal_assignment table assigning an employee to a deal
+type dealAssignment struct {
+	DealID    string
+	UserID    string
+	CreatedAt time.Time
+}
+
+// knownDevice is the key of a row of the known_device table
+type knownDevice struct {
+	SubjectID  string
+	DeviceHash string
+}
+
+// tables holds every record kept by the store. Records are stored by value so
+// that a shallow copy of the maps and slices is a complete snapshot.
+type tables struct {
+	users             map[string]repositories.User
+	borrowers         map[string]repositories.Borrower
+	residences        []residence
+	deals             map[string]repositories.Deal
+	subjectProperties []subjectProperty
+	borrowerDeals     []borrowerDeal
+	dealAssignments   []dealAssignment
+	dealEdits         []repositories.DealEdit
+	progress          map[string]repositories.DealProgress // keyed by deal ID
+	refreshSessions   map[string]repositories.RefreshSession
+	knownDevices      map[knownDevice]time.Time                // first seen
+	verificationCodes map[string]repositories.VerificationCode // keyed by channel, destination and purpose
+	apiKeys           map[string]repositories.APIKey
+}
+
+func newTables() *tables {
+	return &tables{
+		users:             make(map[string]repositories.User),
+		borrowers:         make(map[string]repositories.Borrower),
+		deals:             make(map[string]repositories.Deal),
+		progress:          make(map[string]repositories.DealProgress),
+		refreshSessions:   make(map[string]repositories.RefreshSession),
+		knownDevices:      make(map[knownDevice]time.Time),
+		verificationCodes: make(map[string]repositories.VerificationCode),
+		apiKeys:           make(map[string]repositories.APIKey),
+	}
+}
+
+// clone returns a snapshot of the tables used to roll back a transaction
+func (t *tables) clone() *tables {
+	c := &tables{
+		users:             make(map[string]repositories.User, len(t.users)),
+		borrowers:         make(map[string]repositories.Borrower, len(t.borrowers)),
+		residences:        append([]residence(nil), t.residences...),
+		deals:             make(map[string]repositories.Deal, len(t.deals)),
+		subjectProperties: append([]subjectProperty(nil), t.subjectProperties...),
+		borrowerDeals:     append([]borrowerDeal(nil), t.borrowerDeals...),
+		dealAssignments:   append([]dealAssignment(nil), t.dealAssignments...),
+		dealEdits:         append([]repositories.DealEdit(nil), t.dealEdits...),
+		progress:          make(map[string]repositories.DealProgress, len(t.progress)),
+		refreshSessions:   make(map[string]repositories.RefreshSession, len(t.refreshSessions)),
+		knownDevices:      make(map[knownDevice]time.Time, len(t.knownDevices)),
+		verificationCodes: make(map[string]repositories.VerificationCode, len(t.verificationCodes)),
+		apiKeys:           make(map[string]repositories.APIKey, len(t.apiKeys)),
+	}
+	for k, v := range t.users {
+		c.users[k] = v
+	}
+	for k, v := range t.borrowers {
+		c.borrowers[k] = v
+	}
+	for k, v := range t.deals {
+		c.deals[k] = v
+	}
+	for k, v := range t.progress {
+		c.progress[k] = v
+	}
+	for k, v := range t.refreshSessions {
+		c.refreshSessions[k] = v
+	}
+	for k, v := range t.knownDevices {
+		c.knownDevices[k] = v
+	}
+	for k, v := range t.verificationCodes {
+		c.verificationCodes[k] = v
+	}
+	for k, v := range t.apiKeys {
+		c.apiKeys[k] = v
+	}
+	return c
+}
+
+// db is the shared state behind a store and the transactional stores derived from it
+type db struct {
+	mu   sync.Mutex // guards data
+	txMu sync.Mutex // serializes transactions
+	data *tables
+}
+
+// Store is the in-memory implementation of repositories.Store.
+// Transactions are serialized and rolled back by restoring a snapshot taken
+// when they began; they are not isolated from concurrent non-transactional writes.
+type Store struct {
+	db   *db
+	inTx bool
+}
+
+// NewStore creates an empty in-memory store
+func NewStore() *Store {
+	return &Store{db: &db{data: newTables()}}
+}
+
+// Users returns the store's user repository
+func (s *Store) Users() repositories.UserRepository {
+	return &userRepository{db: s.db}
+}
+
+// Borrowers returns the store's borrower repository
+func (s *Store) Borrowers() repositories.BorrowerRepository {
+	return &borrowerRepository{db: s.db}
+}
+
+// Deals returns the store's deal repository
+func (s *Store) Deals() repositories.DealRepository {
+	return &dealRepository{db: s.db}
+}
+
+// DealProgress returns the store's deal progress repository
+func (s *Store) DealProgress() repositories.DealProgressRepository {
+	return &dealProgressRepository{db: s.db}
+}
+
+// RefreshSessions returns the store's refresh session repository
+func (s *Store) RefreshSessions() repositories.RefreshSessionRepository {
+	return &refreshSessionRepository{db: s.db}
+}
+
+// VerificationCodes returns the store's verification code repository
+func (s *Store) VerificationCodes() repositories.VerificationCodeRepository {
+	return &verificationCodeRepository{db: s.db}
+}
+
+// APIKeys returns the store's API key repository
+func (s *Store) APIKeys() repositories.APIKeyRepository {
+	return &apiKeyRepository{db: s.db}
+}
+
+// WithinTx runs fn with a store whose changes are discarded if fn returns an error
+func (s *Store) WithinTx(fn func(tx repositories.Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.db.txMu.Lock()
+	defer s.db.txMu.Unlock()
+
+	s.db.mu.Lock()
+	snapshot := s.db.data.clone()
+	s.db.mu.Unlock()
+
+	if err := fn(&Store{db: s.db, inTx: true}); err != nil {
+		s.db.mu.Lock()
+		s.db.data = snapshot
+		s.db.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// newID returns a random (version 4) UUID string
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("memory: failed to generate id: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
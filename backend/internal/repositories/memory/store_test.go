@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"taulen/backend/internal/repositories"
+)
+
+func TestWithinTxRollsBackOnError(t *testing.T) {
+	store := NewStore()
+	errAbort := errors.New("abort")
+
+	err := store.WithinTx(func(tx repositories.Store) error {
+		if _, err := tx.Borrowers().Create("jane@example.com", "hash", "Jane", "Doe", ""); err != nil {
+			return err
+		}
+		// Writes are visible inside the transaction
+		if _, err := tx.Borrowers().GetByEmail("jane@example.com"); err != nil {
+			t.Fatalf("GetByEmail inside transaction: %v", err)
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithinTx returned %v, want %v", err, errAbort)
+	}
+
+	if _, err := store.Borrowers().GetByEmail("jane@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetByEmail after rollback: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestWithinTxCommits(t *testing.T) {
+	store := NewStore()
+
+	err := store.WithinTx(func(tx repositories.Store) error {
+		_, err := tx.Borrowers().Create("jane@example.com", "hash", "Jane", "Doe", "")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+
+	if _, err := store.Borrowers().GetByEmail("jane@example.com"); err != nil {
+		t.Fatalf("GetByEmail after commit: %v", err)
+	}
+}
+
+func TestWithinTxNestedJoinsOuterTransaction(t *testing.T) {
+	store := NewStore()
+	errAbort := errors.New("abort")
+
+	err := store.WithinTx(func(tx repositories.Store) error {
+		if err := tx.WithinTx(func(inner repositories.Store) error {
+			_, err := inner.Borrowers().Create("jane@example.com", "hash", "Jane", "Doe", "")
+			return err
+		}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithinTx returned %v, want %v", err, errAbort)
+	}
+
+	if _, err := store.Borrowers().GetByEmail("jane@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetByEmail after outer rollback: got %v, want sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,372 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"taulen/backend/internal/repositories"
+)
+
+// userRepository is the in-memory implementation of repositories.UserRepository
+type userRepository struct {
+	db *db
+}
+
+// GetByID retrieves a user by ID
+func (r *userRepository) GetByID(id string) (*repositories.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	user, ok := r.db.data.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &user, nil
+}
+
+// GetByEmail retrieves a user by email (case-insensitive)
+func (r *userRepository) GetByEmail(email string) (*repositories.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, user := range r.db.data.users {
+		if strings.EqualFold(user.Email, email) {
+			return &user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetBySSOSubject retrieves the user linked to an identity provider account
+func (r *userRepository) GetBySSOSubject(subject string) (*repositories.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, user := range r.db.data.users {
+		if user.SSOSubject.Valid && user.SSOSubject.String == subject {
+			return &user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// Create creates a new user (employee only)
+func (r *userRepository) Create(email, passwordHash, firstName, lastName, role string) (*repositories.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, existing := range r.db.data.users {
+		if existing.Email == email {
+			return nil, errors.New("user with this email already exists")
+		}
+	}
+
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	user := repositories.User{
+		ID:                  newID(),
+		Email:               email,
+		PasswordHash:        passwordHash,
+		EmailVerified:       sql.NullBool{Bool: false, Valid: true},
+		FailedLoginAttempts: sql.NullInt64{Int64: 0, Valid: true},
+		FirstName:           sql.NullString{String: firstName, Valid: true},
+		LastName:            sql.NullString{String: lastName, Valid: true},
+		Role:                repositories.EmployeeRole(role),
+		UserType:            "employee",
+		Status:              "active",
+		IsActive:            sql.NullBool{Bool: true, Valid: true},
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+	r.db.data.users[user.ID] = user
+	return &user, nil
+}
+
+// List returns a page of employees matching the filter, ordered by name, and
+// the total number of matches
+func (r *userRepository) List(filter repositories.EmployeeFilter, limit, offset int) ([]*repositories.User, int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	search := strings.ToLower(filter.Search)
+	users := make([]*repositories.User, 0)
+	for _, user := range r.db.data.users {
+		name := strings.TrimSpace(user.FirstName.String + " " + user.LastName.String)
+		switch {
+		case user.UserType != "employee":
+		case search != "" && !strings.Contains(strings.ToLower(user.Email), search) &&
+			!strings.Contains(strings.ToLower(name), search):
+		case filter.Role != "" && user.Role != filter.Role:
+		case filter.Status != "" && user.Status != filter.Status:
+		default:
+			users = append(users, &user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		a, b := users[i], users[j]
+		if !strings.EqualFold(a.LastName.String, b.LastName.String) {
+			return strings.ToLower(a.LastName.String) < strings.ToLower(b.LastName.String)
+		}
+		if !strings.EqualFold(a.FirstName.String, b.FirstName.String) {
+			return strings.ToLower(a.FirstName.String) < strings.ToLower(b.FirstName.String)
+		}
+		return a.ID < b.ID
+	})
+
+	total := len(users)
+	if offset >= total {
+		return make([]*repositories.User, 0), total, nil
+	}
+	users = users[offset:]
+	if limit >= 0 && limit < len(users) {
+		users = users[:limit]
+	}
+	return users, total, nil
+}
+
+// UpdateProfile updates an employee's name, phone and role; nil values are left unchanged
+func (r *userRepository) UpdateProfile(id string, firstName, lastName, phone, role *string) error {
+	return r.update(id, func(u *repositories.User) error {
+		coalesce(&u.FirstName, firstName)
+		coalesce(&u.LastName, lastName)
+		coalesce(&u.Phone, phone)
+		if role != nil {
+			u.Role = repositories.EmployeeRole(*role)
+		}
+		return nil
+	})
+}
+
+// SetNMLSIdentifier sets an employee's NMLS ID; an empty ID clears it
+func (r *userRepository) SetNMLSIdentifier(id, nmlsID string) error {
+	return r.update(id, func(u *repositories.User) error {
+		u.NMLSRIdentifier = sql.NullString{String: nmlsID, Valid: nmlsID != ""}
+		return nil
+	})
+}
+
+// SetActive activates or deactivates an employee account
+func (r *userRepository) SetActive(id string, active bool) error {
+	return r.update(id, func(u *repositories.User) error {
+		u.IsActive = sql.NullBool{Bool: active, Valid: true}
+		u.Status = "inactive"
+		if active {
+			u.Status = "active"
+		}
+		return nil
+	})
+}
+
+// RequirePasswordReset refuses logins to the account until its password is reset
+func (r *userRepository) RequirePasswordReset(id string) error {
+	return r.update(id, func(u *repositories.User) error {
+		u.PasswordResetRequired = true
+		return nil
+	})
+}
+
+// LinkSSOSubject links the user to an identity provider account
+func (r *userRepository) LinkSSOSubject(id, subject string) error {
+	return r.update(id, func(u *repositories.User) error {
+		for _, other := range r.db.data.users {
+			if other.ID != id && other.SSOSubject.Valid && other.SSOSubject.String == subject {
+				return errors.New("identity provider account is already linked to another user")
+			}
+		}
+		u.SSOSubject = sql.NullString{String: subject, Valid: true}
+		return nil
+	})
+}
+
+// MarkEmailVerified records that the user proved ownership of their email address
+func (r *userRepository) MarkEmailVerified(id string) error {
+	return r.update(id, func(u *repositories.User) error {
+		u.EmailVerified = sql.NullBool{Bool: true, Valid: true}
+		return nil
+	})
+}
+
+// SetTwoFactorEnabled opts the user in to or out of login verification codes
+func (r *userRepository) SetTwoFactorEnabled(id string, enabled bool) error {
+	return r.update(id, func(u *repositories.User) error {
+		u.TwoFactorEnabled = enabled
+		return nil
+	})
+}
+
+// update applies fn to the user with the given ID; missing users are ignored
+func (r *userRepository) update(id string, fn func(u *repositories.User) error) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.data.users[id]
+	if !ok {
+		return nil
+	}
+	if err := fn(&u); err != nil {
+		return err
+	}
+	u.UpdatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	r.db.data.users[id] = u
+	return nil
+}
+
+// RecordLoginFailure increments the failed login counter and returns its new value
+func (r *userRepository) RecordLoginFailure(id string) (int, error) {
+	var attempts int
+	err := r.update(id, func(a *repositories.User) error {
+		a.FailedLoginAttempts = sql.NullInt64{Int64: a.FailedLoginAttempts.Int64 + 1, Valid: true}
+		attempts = int(a.FailedLoginAttempts.Int64)
+		return nil
+	})
+	if err == nil && attempts == 0 {
+		return 0, sql.ErrNoRows
+	}
+	return attempts, err
+}
+
+// LockAccount locks the account until the given time
+func (r *userRepository) LockAccount(id string, until time.Time) error {
+	return r.update(id, func(a *repositories.User) error {
+		a.AccountLockedUntil = sql.NullTime{Time: until, Valid: true}
+		return nil
+	})
+}
+
+// RecordLoginSuccess resets the lockout state and records the login time
+func (r *userRepository) RecordLoginSuccess(id string) error {
+	return r.update(id, func(a *repositories.User) error {
+		a.FailedLoginAttempts = sql.NullInt64{Int64: 0, Valid: true}
+		a.AccountLockedUntil = sql.NullTime{}
+		a.LastLoginAt = sql.NullTime{Time: time.Now(), Valid: true}
+		return nil
+	})
+}
+
+// UnlockAccount clears the lock and the failed login counter
+func (r *userRepository) UnlockAccount(id string) error {
+	return r.update(id, func(a *repositories.User) error {
+		a.FailedLoginAttempts = sql.NullInt64{Int64: 0, Valid: true}
+		a.AccountLockedUntil = sql.NullTime{}
+		return nil
+	})
+}
+
+// SetPasswordResetToken stores the hash of a password reset token, replacing any previous one
+func (r *userRepository) SetPasswordResetToken(id, tokenHash string, expiresAt time.Time) error {
+	return r.update(id, func(a *repositories.User) error {
+		a.PasswordResetToken = sql.NullString{String: tokenHash, Valid: true}
+		a.PasswordResetExpiresAt = sql.NullTime{Time: expiresAt, Valid: true}
+		return nil
+	})
+}
+
+// ResetPassword sets a new password for the account holding the unexpired reset
+// token, consuming the token and clearing any login lockout or required reset
+func (r *userRepository) ResetPassword(tokenHash, passwordHash string) (string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	for id, a := range r.db.data.users {
+		if !a.PasswordResetToken.Valid || a.PasswordResetToken.String != tokenHash ||
+			!a.PasswordResetExpiresAt.Valid || !a.PasswordResetExpiresAt.Time.After(now) {
+			continue
+		}
+		a.PasswordHash = passwordHash
+		a.PasswordResetToken = sql.NullString{}
+		a.PasswordResetExpiresAt = sql.NullTime{}
+		a.LastPasswordChangeAt = sql.NullTime{Time: now, Valid: true}
+		a.PasswordResetRequired = false
+		a.FailedLoginAttempts = sql.NullInt64{Int64: 0, Valid: true}
+		a.AccountLockedUntil = sql.NullTime{}
+		a.UpdatedAt = sql.NullTime{Time: now, Valid: true}
+		r.db.data.users[id] = a
+		return id, nil
+	}
+	return "", sql.ErrNoRows
+}
+
+// SetMFASecret stores a new TOTP secret pending confirmation, disabling MFA
+// and discarding any backup codes until EnableMFA is called
+func (r *userRepository) SetMFASecret(id, secret string) error {
+	return r.update(id, func(a *repositories.User) error {
+		a.MFAEnabled = false
+		a.MFASecret = sql.NullString{String: secret, Valid: true}
+		a.MFABackupCodes = sql.NullString{}
+		a.MFASetupAt = sql.NullTime{Time: time.Now(), Valid: true}
+		a.MFAVerifiedAt = sql.NullTime{}
+		return nil
+	})
+}
+
+// EnableMFA turns on MFA for the pending secret and stores the backup code hashes
+func (r *userRepository) EnableMFA(id, backupCodes string) error {
+	return r.update(id, func(a *repositories.User) error {
+		if a.MFASecret.Valid {
+			a.MFAEnabled = true
+			a.MFABackupCodes = sql.NullString{String: backupCodes, Valid: true}
+		}
+		return nil
+	})
+}
+
+// DisableMFA turns off MFA and removes the secret and backup codes
+func (r *userRepository) DisableMFA(id string) error {
+	return r.update(id, func(a *repositories.User) error {
+		a.MFAEnabled = false
+		a.MFASecret = sql.NullString{}
+		a.MFABackupCodes = sql.NullString{}
+		a.MFASetupAt = sql.NullTime{}
+		a.MFAVerifiedAt = sql.NullTime{}
+		return nil
+	})
+}
+
+// RecordMFAVerification records the time step of an accepted TOTP code and
+// reports whether it did; false means a code of this or a later step was
+// already used
+func (r *userRepository) RecordMFAVerification(id string, step time.Time) (bool, error) {
+	recorded := false
+	err := r.update(id, func(a *repositories.User) error {
+		if a.MFAVerifiedAt.Valid && !a.MFAVerifiedAt.Time.Before(step) {
+			return nil
+		}
+		a.MFAVerifiedAt = sql.NullTime{Time: step, Valid: true}
+		recorded = true
+		return nil
+	})
+	return recorded, err
+}
+
+// SetMFABackupCodes replaces the backup code hashes
+func (r *userRepository) SetMFABackupCodes(id, backupCodes string) error {
+	return r.update(id, func(a *repositories.User) error {
+		a.MFABackupCodes = sql.NullString{String: backupCodes, Valid: true}
+		return nil
+	})
+}
+
+// ConsumeMFABackupCode removes a backup code hash and reports whether it was present
+func (r *userRepository) ConsumeMFABackupCode(id, codeHash string) (bool, error) {
+	consumed := false
+	err := r.update(id, func(a *repositories.User) error {
+		if !a.MFABackupCodes.Valid {
+			return nil
+		}
+		codes := strings.Split(a.MFABackupCodes.String, ",")
+		for i, code := range codes {
+			if code != codeHash {
+				continue
+			}
+			codes = append(codes[:i], codes[i+1:]...)
+			a.MFABackupCodes = sql.NullString{String: strings.Join(codes, ","), Valid: len(codes) > 0}
+			consumed = true
+			return nil
+		}
+		return nil
+	})
+	return consumed, err
+}
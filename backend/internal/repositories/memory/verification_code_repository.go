@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+
+	"taulen/backend/internal/repositories"
+)
+
+// verificationCodeRepository is the in-memory implementation of repositories.VerificationCodeRepository
+type verificationCodeRepository struct {
+	db *db
+}
+
+// verificationCodeKey returns the key of a destination's code for purpose,
+// mirroring the unique (channel, destination, purpose) constraint
+func verificationCodeKey(channel, destination, purpose string) string {
+	return channel + ":" + destination + ":" + purpose
+}
+
+// Issue stores a code for its destination and purpose, replacing the previous
+// code unless that one was created after resendAfter
+func (r *verificationCodeRepository) Issue(code *repositories.VerificationCode, resendAfter time.Time) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	key := verificationCodeKey(code.Channel, code.Destination, code.Purpose)
+	if existing, ok := r.db.data.verificationCodes[key]; ok && existing.CreatedAt.Time.After(resendAfter) {
+		return false, nil
+	}
+	code.Attempts = 0
+	code.CreatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	r.db.data.verificationCodes[key] = *code
+	return true, nil
+}
+
+// Consume deletes the destination's code for purpose if it matches and is still
+// usable; a mismatch counts as a wrong attempt
+func (r *verificationCodeRepository) Consume(channel, destination, purpose, codeHash string, maxAttempts int) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	key := verificationCodeKey(channel, destination, purpose)
+	code, ok := r.db.data.verificationCodes[key]
+	if !ok || code.Attempts >= maxAttempts {
+		return false, nil
+	}
+	if code.CodeHash == codeHash && code.ExpiresAt.After(time.Now()) {
+		delete(r.db.data.verificationCodes, key)
+		return true, nil
+	}
+	code.Attempts++
+	r.db.data.verificationCodes[key] = code
+	return false, nil
+}
+
+// DeleteExpired deletes codes that expired before the given time
+func (r *verificationCodeRepository) DeleteExpired(before time.Time) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var deleted int64
+	for key, code := range r.db.data.verificationCodes {
+		if code.ExpiresAt.Before(before) {
+			delete(r.db.data.verificationCodes, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
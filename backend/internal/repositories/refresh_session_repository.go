@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RefreshSession is one issued refresh token. Tokens issued by rotating a
+// token share its FamilyID; the first token of a family has ID == FamilyID.
+type RefreshSession struct {
+	ID          string // the token's jti
+	FamilyID    string
+	SubjectID   string // user ID for employees, borrower ID for applicants
+	SubjectType string // "employee" or "applicant"
+	TokenHash   string // hex SHA-256 of the token
+	ExpiresAt   time.Time
+	RotatedAt   sql.NullTime
+	RevokedAt   sql.NullTime
+	CreatedAt   sql.NullTime
+	// UserAgent and IPAddress describe the client the token was issued to
+	UserAgent sql.NullString
+	IPAddress sql.NullString
+	// SignedInAt is when the family's login happened; rotated tokens keep it
+	SignedInAt sql.NullTime
+}
+
+// refreshSessionRepository is the PostgreSQL implementation of RefreshSessionRepository
+type refreshSessionRepository struct {
+	db DBTX
+}
+
+// newRefreshSessionRepository creates a refresh session repository that runs its queries on db
+func newRefreshSessionRepository(db DBTX) *refreshSessionRepository {
+	return &refreshSessionRepository{db: db}
+}
+
+// refreshSessionColumns lists the refresh_session columns in the order scanned by scanRefreshSession
+const refreshSessionColumns = `id, family_id, subject_id, subject_type, token_hash, expires_at,
+	rotated_at, revoked_at, created_at, user_agent, ip_address, signed_in_at`
+
+// scanRefreshSession scans a row of refreshSessionColumns
+func scanRefreshSession(row interface{ Scan(dest ...any) error }) (*RefreshSession, error) {
+	session := &RefreshSession{}
+	err := row.Scan(
+		&session.ID, &session.FamilyID, &session.SubjectID, &session.SubjectType,
+		&session.TokenHash, &session.ExpiresAt,
+		&session.RotatedAt, &session.RevokedAt, &session.CreatedAt,
+		&session.UserAgent, &session.IPAddress, &session.SignedInAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Create stores a new refresh session; ID and FamilyID are chosen by the caller.
+// A session joining an existing family keeps the family's sign-in time.
+func (r *refreshSessionRepository) Create(session *RefreshSession) error {
+	query := `INSERT INTO refresh_session (id, family_id, subject_id, subject_type, token_hash, expires_at,
+	              user_agent, ip_address, signed_in_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+	              COALESCE((SELECT min(signed_in_at) FROM refresh_session WHERE family_id = $2), CURRENT_TIMESTAMP))
+	          RETURNING created_at, signed_in_at`
+	return r.db.QueryRow(query,
+		session.ID, session.FamilyID, session.SubjectID, session.SubjectType,
+		session.TokenHash, session.ExpiresAt, session.UserAgent, session.IPAddress,
+	).Scan(&session.CreatedAt, &session.SignedInAt)
+}
+
+// GetByID retrieves a refresh session by ID
+func (r *refreshSessionRepository) GetByID(id string) (*RefreshSession, error) {
+	query := `SELECT ` + refreshSessionColumns + `
+	          FROM refresh_session WHERE id = $1`
+	return scanRefreshSession(r.db.QueryRow(query, id))
+}
+
+// ListActive returns the subject's sessions that can still be refreshed, which
+// is the latest token of each live login, most recently issued first
+func (r *refreshSessionRepository) ListActive(subjectID string) ([]*RefreshSession, error) {
+	query := `SELECT ` + refreshSessionColumns + `
+	          FROM refresh_session
+	          WHERE subject_id = $1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+	          ORDER BY created_at DESC`
+	rows, err := r.db.Query(query, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*RefreshSession
+	for rows.Next() {
+		session, err := scanRefreshSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// MarkRotated marks an active, unexpired session as rotated. The conditional
+// update makes concurrent refreshes with the same token race for a single winner.
+func (r *refreshSessionRepository) MarkRotated(id string) (bool, error) {
+	query := `UPDATE refresh_session SET rotated_at = CURRENT_TIMESTAMP
+	          WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP`
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// RevokeFamily revokes every session in the family that is not already revoked
+func (r *refreshSessionRepository) RevokeFamily(familyID string) error {
+	query := `UPDATE refresh_session SET revoked_at = CURRENT_TIMESTAMP
+	          WHERE family_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.Exec(query, familyID)
+	return err
+}
+
+// RevokeSubject revokes every session of the subject that is not already revoked
+func (r *refreshSessionRepository) RevokeSubject(subjectID string) error {
+	query := `UPDATE refresh_session SET revoked_at = CURRENT_TIMESTAMP
+	          WHERE subject_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.Exec(query, subjectID)
+	return err
+}
+
+// RevokeSubjectExcept revokes every session of the subject outside the given
+// family that is not already revoked
+func (r *refreshSessionRepository) RevokeSubjectExcept(subjectID, familyID string) error {
+	query := `UPDATE refresh_session SET revoked_at = CURRENT_TIMESTAMP
+	          WHERE subject_id = $1 AND family_id <> $2 AND revoked_at IS NULL`
+	_, err := r.db.Exec(query, subjectID, familyID)
+	return err
+}
+
+// HasDevices reports whether any device of the subject is known
+func (r *refreshSessionRepository) HasDevices(subjectID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM known_device WHERE subject_id = $1)`, subjectID).Scan(&exists)
+	return exists, err
+}
+
+// AddDevice remembers a device of the subject and reports whether it was not known yet
+func (r *refreshSessionRepository) AddDevice(subjectID, deviceHash string) (bool, error) {
+	query := `INSERT INTO known_device (subject_id, device_hash) VALUES ($1, $2)
+	          ON CONFLICT (subject_id, device_hash) DO NOTHING`
+	result, err := r.db.Exec(query, subjectID, deviceHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// DeleteExpired deletes sessions that expired before the given time and returns how many were deleted
+func (r *refreshSessionRepository) DeleteExpired(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM refresh_session WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,209 @@
+package repositories
+
+import "time"
+
+// Repository interfaces implemented by the PostgreSQL store in this package and
+// by the in-memory store in the memory subpackage. Lookups of a single record
+// that find nothing return sql.ErrNoRows from every implementation.
+
+// UserRepository provides access to employee users
+type UserRepository interface {
+	GetByID(id string) (*User, error)
+	GetByEmail(email string) (*User, error)
+	Create(email, passwordHash, firstName, lastName, role string) (*User, error)
+
+	// Employee administration. List returns a page of matching employees and
+	// the total number of matches; UpdateProfile leaves nil values unchanged.
+	List(filter EmployeeFilter, limit, offset int) ([]*User, int, error)
+	UpdateProfile(id string, firstName, lastName, phone, role *string) error
+	SetNMLSIdentifier(id, nmlsID string) error
+	SetActive(id string, active bool) error
+	RequirePasswordReset(id string) error
+
+	// Single sign-on; an identity provider account is linked to one user at most
+	GetBySSOSubject(subject string) (*User, error)
+	LinkSSOSubject(id, subject string) error
+
+	// MarkEmailVerified records that the user proved ownership of their email address
+	MarkEmailVerified(id string) error
+	// SetTwoFactorEnabled opts the user in to or out of login verification codes
+	SetTwoFactorEnabled(id string, enabled bool) error
+
+	// Login lockout bookkeeping; RecordLoginFailure returns the new failure count
+	RecordLoginFailure(id string) (int, error)
+	LockAccount(id string, until time.Time) error
+	RecordLoginSuccess(id string) error
+	UnlockAccount(id string) error
+
+	// Password reset; only a hash of the reset token is stored. ResetPassword
+	// consumes an unexpired token, returning the account ID or sql.ErrNoRows.
+	SetPasswordResetToken(id, tokenHash string, expiresAt time.Time) error
+	ResetPassword(tokenHash, passwordHash string) (string, error)
+
+	// TOTP multi-factor authentication; backupCodes is a comma-separated list
+	// of backup code hashes
+	SetMFASecret(id, secret string) error
+	EnableMFA(id, backupCodes string) error
+	DisableMFA(id string) error
+	RecordMFAVerification(id string, step time.Time) (bool, error)
+	SetMFABackupCodes(id, backupCodes string) error
+	ConsumeMFABackupCode(id, codeHash string) (bool, error)
+}
+
+// BorrowerRepository provides access to borrowers and their residences
+type BorrowerRepository interface {
+	GetByID(id string) (*Borrower, error)
+	GetByEmail(email string) (*Borrower, error)
+	GetByPhone(phone string) (*Borrower, error)
+	GetByEmailOrPhone(email, phone string) (*Borrower, error)
+	Create(email, passwordHash, firstName, lastName, phone string) (*Borrower, error)
+	CreateFromPreApplication(email, firstName, lastName, phone, dateOfBirth, address, city, state, zipCode string) (*Borrower, error)
+	CreateCoBorrower(firstName, lastName, middleName, suffix, email, phone, phoneType, maritalStatus string, isVeteran bool) (string, error)
+
+	// MarkEmailVerified records that the borrower proved ownership of their
+	// email address; UpdateEmail clears it when the address changes
+	MarkEmailVerified(id string) error
+	// SetTwoFactorEnabled opts the borrower in to or out of login verification codes
+	SetTwoFactorEnabled(id string, enabled bool) error
+
+	// Login lockout bookkeeping; RecordLoginFailure returns the new failure count
+	RecordLoginFailure(id string) (int, error)
+	LockAccount(id string, until time.Time) error
+	RecordLoginSuccess(id string) error
+	UnlockAccount(id string) error
+
+	// Password reset; only a hash of the reset token is stored. ResetPassword
+	// consumes an unexpired token, returning the account ID or sql.ErrNoRows.
+	SetPasswordResetToken(id, tokenHash string, expiresAt time.Time) error
+	ResetPassword(tokenHash, passwordHash string) (string, error)
+
+	// TOTP multi-factor authentication; backupCodes is a comma-separated list
+	// of backup code hashes
+	SetMFASecret(id, secret string) error
+	EnableMFA(id, backupCodes string) error
+	DisableMFA(id string) error
+	RecordMFAVerification(id string, step time.Time) (bool, error)
+	SetMFABackupCodes(id, backupCodes string) error
+	ConsumeMFABackupCode(id, codeHash string) (bool, error)
+
+	UpdatePassword(borrowerID string, passwordHash string) error
+	UpdateName(borrowerID string, firstName, lastName string) error
+	UpdateBorrowerInfo(id string, dateOfBirth *time.Time) error
+	UpdateBorrowerDetails(id string, middleName, suffix, maritalStatus *string, phone, phoneType *string) error
+	UpdateEmail(id string, email string) error
+	UpdateBorrowerConsentsAndMilitary(id string, militaryServiceStatus, consentToCreditCheck, consentToContact *bool) error
+	UpdateBorrowerName(id string, firstName, lastName *string) error
+	UpdateBorrowerSSN(id string, ssn string) error
+	UpdateBorrowerCitizenship(id string, citizenshipType string) error
+	UpdateBorrowerDependents(id string, count int) error
+	UpdateBorrowerAdditionalPhones(id string, homePhone, mobilePhone, workPhone, workPhoneExt *string) error
+
+	CreateResidence(borrowerID string, residencyType, address, city, state, zipCode string) (string, error)
+	GetCurrentResidence(borrowerID string) (address, city, state, zipCode string, err error)
+	GetCurrentResidenceID(borrowerID string) (string, error)
+	UpdateOrCreateResidence(borrowerID string, residencyType, address, city, state, zipCode string) error
+	DeleteFormerResidences(borrowerID string) error
+	CreateFormerResidence(borrowerID, address, city, state, zipCode string, durationYears, durationMonths *int, housingStatus *string) error
+
+	LinkBorrowerToDeal(borrowerID, dealID string) error
+	IsLinkedToDeal(borrowerID, dealID string) (bool, error)
+	GetCoBorrowersByDealID(dealID, primaryBorrowerID string) ([]*Borrower, error)
+}
+
+// DealRepository provides access to deals (mortgage applications) and their loans
+type DealRepository interface {
+	CreateDeal(userID string, borrowerID *string, loanPurpose string, loanAmount float64, status string) (string, error)
+	GetDealByID(dealID string) (*Deal, error)
+	GetDealByLoanNumber(loanNumber string) (*Deal, error)
+	UpdateDeal(dealID string, loanNumber, universalLoanIdentifier, agencyCaseIdentifier *string, applicationType *string, totalBorrowers *int) error
+	UpdateCurrentFormStep(dealID string, formStep string) error
+	UpdateToJointApplication(dealID string) error
+	UpdateLoan(dealID string, loanPurpose, propertyType, manufacturedHomeWidthType, titleMannerType *string, loanAmount *float64, loanTermMonths *int, interestRate *float64) error
+	CreateSubjectProperty(dealID string, address, city, state, zipCode string, estimatedValue float64) (string, error)
+	GetDealsByUserID(userID string) ([]*DealSummary, error)
+	GetDealsByBorrowerID(borrowerID string) ([]*DealSummary, error)
+	ListDeals(limit, offset int) ([]*DealSummary, error)
+
+	// Employees assigned to a deal; ListAssignees returns their IDs in the
+	// order they were assigned
+	AssignUser(dealID, userID string) error
+	UnassignUser(dealID, userID string) error
+	IsUserAssigned(dealID, userID string) (bool, error)
+	ListAssignees(dealID string) ([]string, error)
+
+	RecordEdit(edit *DealEdit) error
+	ListEdits(dealID string) ([]*DealEdit, error)
+}
+
+// DealProgressRepository provides access to URLA section progress for deals
+type DealProgressRepository interface {
+	GetByDealID(dealID string) (*DealProgress, error)
+	UpdateSection(dealID string, section string, complete bool) error
+	UpdateNotes(dealID string, notes string) error
+	GetNextIncompleteSection(dealID string) (string, error)
+}
+
+// RefreshSessionRepository provides access to server-side refresh token sessions
+type RefreshSessionRepository interface {
+	Create(session *RefreshSession) error
+	GetByID(id string) (*RefreshSession, error)
+	// MarkRotated marks an active, unexpired session as rotated and reports
+	// whether it did; false means the session was already used or revoked
+	MarkRotated(id string) (bool, error)
+	// ListActive returns the subject's unrotated, unrevoked and unexpired
+	// sessions, one per login, most recently issued first
+	ListActive(subjectID string) ([]*RefreshSession, error)
+	RevokeFamily(familyID string) error
+	RevokeSubject(subjectID string) error
+	// RevokeSubjectExcept revokes every session of the subject outside the given family
+	RevokeSubjectExcept(subjectID, familyID string) error
+	DeleteExpired(before time.Time) (int64, error)
+	// HasDevices reports whether the subject signed in from any known device
+	HasDevices(subjectID string) (bool, error)
+	// AddDevice remembers a device of the subject, identified by a hash, and
+	// reports whether it was not known yet
+	AddDevice(subjectID, deviceHash string) (bool, error)
+}
+
+// VerificationCodeRepository provides access to one-time verification codes
+type VerificationCodeRepository interface {
+	// Issue stores a code for its destination and purpose, replacing the
+	// previous code unless that one was created after resendAfter; it reports
+	// whether it did
+	Issue(code *VerificationCode, resendAfter time.Time) (bool, error)
+	// Consume deletes a matching, unexpired code with fewer than maxAttempts
+	// wrong attempts and reports whether it did; a mismatch counts as an attempt
+	Consume(channel, destination, purpose, codeHash string, maxAttempts int) (bool, error)
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+// APIKeyRepository provides access to the API keys of integrations
+type APIKeyRepository interface {
+	Create(key *APIKey) error
+	GetByID(id string) (*APIKey, error)
+	GetByHash(keyHash string) (*APIKey, error)
+	List() ([]*APIKey, error)
+	// Revoke revokes a key unless it already is and reports whether it did
+	Revoke(id string) (bool, error)
+	ShortenExpiry(id string, expiresAt time.Time) error
+	RecordUse(id, ip string, at time.Time) error
+}
+
+// Store is a unit of work over the repositories. Repositories obtained from the
+// same Store share its connection, and WithinTx hands out a Store whose
+// repositories all run in a single transaction.
+type Store interface {
+	Users() UserRepository
+	Borrowers() BorrowerRepository
+	Deals() DealRepository
+	DealProgress() DealProgressRepository
+	RefreshSessions() RefreshSessionRepository
+	VerificationCodes() VerificationCodeRepository
+	APIKeys() APIKeyRepository
+
+	// WithinTx runs fn with a store whose repositories share one transaction.
+	// The transaction is committed if fn returns nil and rolled back otherwise.
+	// Calling WithinTx on a store that is already transactional joins the
+	// existing transaction instead of starting a new one.
+	WithinTx(fn func(tx Store) error) error
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// DBTX is the subset of *sql.DB and *sql.Tx used by repositories, so the same
+// repository code runs either directly against the pool or inside a transaction
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+var (
+	_ Store                      = (*PostgresStore)(nil)
+	_ UserRepository             = (*userRepository)(nil)
+	_ BorrowerRepository         = (*borrowerRepository)(nil)
+	_ DealRepository             = (*dealRepository)(nil)
+	_ DealProgressRepository     = (*dealProgressRepository)(nil)
+	_ RefreshSessionRepository   = (*refreshSessionRepository)(nil)
+	_ VerificationCodeRepository = (*verificationCodeRepository)(nil)
+	_ APIKeyRepository           = (*apiKeyRepository)(nil)
+)
+
+// PostgresStore is the PostgreSQL implementation of Store
+type PostgresStore struct {
+	db   *sql.DB
+	conn DBTX
+}
+
+// NewPostgresStore creates a store backed by the given connection pool
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db, conn: db}
+}
+
+// Users returns a user repository bound to the store's connection
+func (s *PostgresStore) Users() UserRepository {
+	return newUserRepository(s.conn)
+}
+
+// Borrowers returns a borrower repository bound to the store's connection
+func (s *PostgresStore) Borrowers() BorrowerRepository {
+	return newBorrowerRepository(s.conn)
+}
+
+// Deals returns a deal repository bound to the store's connection
+func (s *PostgresStore) Deals() DealRepository {
+	return newDealRepository(s.conn)
+}
+
+// DealProgress returns a deal progress repository bound to the store's connection
+func (s *PostgresStore) DealProgress() DealProgressRepository {
+	return newDealProgressRepository(s.conn)
+}
+
+// RefreshSessions returns a refresh session repository bound to the store's connection
+func (s *PostgresStore) RefreshSessions() RefreshSessionRepository {
+	return newRefreshSessionRepository(s.conn)
+}
+
+// VerificationCodes returns a verification code repository bound to the store's connection
+func (s *PostgresStore) VerificationCodes() VerificationCodeRepository {
+	return newVerificationCodeRepository(s.conn)
+}
+
+// APIKeys returns an API key repository bound to the store's connection
+func (s *PostgresStore) APIKeys() APIKeyRepository {
+	return newAPIKeyRepository(s.conn)
+}
+
+// WithinTx runs fn with a store whose repositories share one transaction
+func (s *PostgresStore) WithinTx(fn func(tx Store) error) error {
+	if _, inTx := s.conn.(*sql.Tx); inTx {
+		return fn(s)
+	}
+	if s.db == nil {
+		return errors.New("database connection is not initialized")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&PostgresStore{db: s.db, conn: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
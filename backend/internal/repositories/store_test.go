@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// txRecorder is a database driver that supports nothing but transactions and
+// records when they begin, commit and roll back
+type txRecorder struct {
+	events []string
+}
+
+func (r *txRecorder) Connect(context.Context) (driver.Conn, error) { return r, nil }
+func (r *txRecorder) Driver() driver.Driver                        { return nil }
+
+func (r *txRecorder) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("statements are not supported")
+}
+func (r *txRecorder) Close() error { return nil }
+
+func (r *txRecorder) Begin() (driver.Tx, error) {
+	r.events = append(r.events, "begin")
+	return r, nil
+}
+func (r *txRecorder) Commit() error {
+	r.events = append(r.events, "commit")
+	return nil
+}
+func (r *txRecorder) Rollback() error {
+	r.events = append(r.events, "rollback")
+	return nil
+}
+
+func TestWithinTx(t *testing.T) {
+	recorder := &txRecorder{}
+	store := NewPostgresStore(sql.OpenDB(recorder))
+
+	err := store.WithinTx(func(tx Store) error {
+		if _, ok := tx.(*PostgresStore).conn.(*sql.Tx); !ok {
+			t.Error("the transactional store does not use its transaction")
+		}
+		// A nested unit of work joins the transaction
+		return tx.WithinTx(func(Store) error { return nil })
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+
+	failed := errors.New("save failed")
+	if err := store.WithinTx(func(Store) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("failing WithinTx: got %v, want %v", err, failed)
+	}
+
+	if got := strings.Join(recorder.events, " "); got != "begin commit begin rollback" {
+		t.Fatalf("transactions: %s, want one committed and one rolled back", got)
+	}
+}
@@ -2,7 +2,8 @@ package repositories
 
 import (
 	"database/sql"
-	"taulen/backend/internal/database"
+	"strings"
+	"time"
 )
 
 // User represents a user in the database (employees only)
@@ -21,6 +22,7 @@ type User struct {
 	MFABackupCodes              sql.NullString
 	MFASetupAt                  sql.NullTime
 	MFAVerifiedAt               sql.NullTime
+	TwoFactorEnabled            bool // opted in to verification codes at login
 	LastLoginAt                 sql.NullTime
 	FailedLoginAttempts         sql.NullInt64
 	AccountLockedUntil          sql.NullTime
@@ -30,40 +32,56 @@ type User struct {
 	Role                        string
 	UserType                    string // Always 'employee' for user table
 	Status                      string
+	NMLSRIdentifier             sql.NullString // NMLS ID of a licensed loan originator
+	IsActive                    sql.NullBool
+	PasswordResetRequired       bool // set by an admin; login is refused until the password is reset
+	SSOSubject                  sql.NullString // subject of the linked identity provider account
 	CreatedAt                   sql.NullTime
 	UpdatedAt                   sql.NullTime
 }
 
-// UserRepository handles user data access
-type UserRepository struct {
-	db *sql.DB
+// Active reports whether the employee account is enabled; deactivated
+// employees can neither log in nor refresh their tokens
+func (u *User) Active() bool {
+	return u.Status == "active" && (!u.IsActive.Valid || u.IsActive.Bool)
 }
 
-// NewUserRepository creates a new user repository
-func NewUserRepository() *UserRepository {
-	return &UserRepository{
-		db: database.DB,
-	}
+// EmployeeFilter narrows an employee listing; empty fields match everything
+type EmployeeFilter struct {
+	Search string // matched case-insensitively against email and name
+	Role   string // user_role value, e.g. "LoanOfficer"
+	Status string // "active" or "inactive"
 }
 
-// GetByID retrieves a user by ID
-func (r *UserRepository) GetByID(id string) (*User, error) {
-	query := `SELECT id, email_address, password_hash, email_verified, email_verification_token, 
-	          email_verification_expires_at, password_reset_token, password_reset_expires_at, 
-	          last_password_change_at, mfa_enabled, mfa_secret, mfa_backup_codes, mfa_setup_at, 
-	          mfa_verified_at, last_login_at, failed_login_attempts, account_locked_until,
-	          first_name, last_name, phone, user_role, user_type, status, created_at, updated_at 
-	          FROM "user" WHERE id = $1`
-	row := r.db.QueryRow(query, id)
+// userRepository is the PostgreSQL implementation of UserRepository
+type userRepository struct {
+	db DBTX
+}
+
+// newUserRepository creates a user repository that runs its queries on db
+func newUserRepository(db DBTX) *userRepository {
+	return &userRepository{db: db}
+}
+
+// userColumns lists the user columns in the order scanned by scanUser
+const userColumns = `id, email_address, password_hash, email_verified, email_verification_token,
+	email_verification_expires_at, password_reset_token, password_reset_expires_at,
+	last_password_change_at, mfa_enabled, mfa_secret, mfa_backup_codes, mfa_setup_at,
+	mfa_verified_at, two_factor_enabled, last_login_at, failed_login_attempts, account_locked_until,
+	first_name, last_name, phone, user_role, user_type, status, nmlsr_identifier, is_active,
+	password_reset_required, sso_subject, created_at, updated_at`
 
+// scanUser scans a row of userColumns
+func scanUser(row interface{ Scan(dest ...any) error }) (*User, error) {
 	user := &User{}
 	err := row.Scan(
 		&user.ID, &user.Email, &user.PasswordHash,
 		&user.EmailVerified, &user.EmailVerificationToken, &user.EmailVerificationExpiresAt,
 		&user.PasswordResetToken, &user.PasswordResetExpiresAt, &user.LastPasswordChangeAt,
 		&user.MFAEnabled, &user.MFASecret, &user.MFABackupCodes, &user.MFASetupAt,
-		&user.MFAVerifiedAt, &user.LastLoginAt, &user.FailedLoginAttempts, &user.AccountLockedUntil,
+		&user.MFAVerifiedAt, &user.TwoFactorEnabled, &user.LastLoginAt, &user.FailedLoginAttempts, &user.AccountLockedUntil,
 		&user.FirstName, &user.LastName, &user.Phone, &user.Role, &user.UserType, &user.Status,
+		&user.NMLSRIdentifier, &user.IsActive, &user.PasswordResetRequired, &user.SSOSubject,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
@@ -72,74 +90,270 @@ func (r *UserRepository) GetByID(id string) (*User, error) {
 	return user, nil
 }
 
+// GetByID retrieves a user by ID
+func (r *userRepository) GetByID(id string) (*User, error) {
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM "user" WHERE id = $1`, id))
+}
+
 // GetByEmail retrieves a user by email
-func (r *UserRepository) GetByEmail(email string) (*User, error) {
+func (r *userRepository) GetByEmail(email string) (*User, error) {
 	// Use LOWER() for case-insensitive comparison
-	query := `SELECT id, email_address, password_hash, email_verified, email_verification_token, 
-	          email_verification_expires_at, password_reset_token, password_reset_expires_at, 
-	          last_password_change_at, mfa_enabled, mfa_secret, mfa_backup_codes, mfa_setup_at, 
-	          mfa_verified_at, last_login_at, failed_login_attempts, account_locked_until,
-	          first_name, last_name, phone, user_role, user_type, status, created_at, updated_at 
-	          FROM "user" WHERE LOWER(email_address) = LOWER($1)`
-	row := r.db.QueryRow(query, email)
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM "user" WHERE LOWER(email_address) = LOWER($1)`, email))
+}
 
-	user := &User{}
-	err := row.Scan(
-		&user.ID, &user.Email, &user.PasswordHash,
-		&user.EmailVerified, &user.EmailVerificationToken, &user.EmailVerificationExpiresAt,
-		&user.PasswordResetToken, &user.PasswordResetExpiresAt, &user.LastPasswordChangeAt,
-		&user.MFAEnabled, &user.MFASecret, &user.MFABackupCodes, &user.MFASetupAt,
-		&user.MFAVerifiedAt, &user.LastLoginAt, &user.FailedLoginAttempts, &user.AccountLockedUntil,
-		&user.FirstName, &user.LastName, &user.Phone, &user.Role, &user.UserType, &user.Status,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, sql.ErrNoRows
-		}
-		return nil, err
-	}
-	return user, nil
+// GetBySSOSubject retrieves the user linked to an identity provider account
+func (r *userRepository) GetBySSOSubject(subject string) (*User, error) {
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM "user" WHERE sso_subject = $1`, subject))
 }
 
-// Create creates a new user (employee only)
-// role must be one of: LoanOfficer, Underwriter, Processor, Admin
-func (r *UserRepository) Create(email, passwordHash, firstName, lastName, role string) (*User, error) {
-	// Validate role is an employee role (not borrower)
-	// Map common role names to schema format
+// EmployeeRole maps common role names (e.g. "loan_officer") to the user_role
+// values stored in the schema; values already in schema format are returned as-is
+func EmployeeRole(role string) string {
 	roleMap := map[string]string{
 		"loan_officer": "LoanOfficer",
 		"underwriter":  "Underwriter",
-		"processor":     "Processor",
-		"admin":         "Admin",
+		"processor":    "Processor",
+		"admin":        "Admin",
 	}
-	mappedRole, ok := roleMap[role]
-	if !ok {
-		// If already in correct format, use as-is
-		mappedRole = role
+	if mapped, ok := roleMap[role]; ok {
+		return mapped
 	}
+	return role
+}
+
+// Create creates a new user (employee only)
+// role must be one of: LoanOfficer, Underwriter, Processor, Admin
+func (r *userRepository) Create(email, passwordHash, firstName, lastName, role string) (*User, error) {
+	mappedRole := EmployeeRole(role)
 
 	query := `INSERT INTO "user" (email_address, password_hash, first_name, last_name, user_role, user_type) 
 	          VALUES ($1, $2, $3, $4, $5, 'employee') 
-	          RETURNING id, email_address, password_hash, email_verified, email_verification_token, 
-	          email_verification_expires_at, password_reset_token, password_reset_expires_at, 
-	          last_password_change_at, mfa_enabled, mfa_secret, mfa_backup_codes, mfa_setup_at, 
-	          mfa_verified_at, last_login_at, failed_login_attempts, account_locked_until,
-	          first_name, last_name, phone, user_role, user_type, status, created_at, updated_at`
-	row := r.db.QueryRow(query, email, passwordHash, firstName, lastName, mappedRole)
+	          RETURNING ` + userColumns
+	return scanUser(r.db.QueryRow(query, email, passwordHash, firstName, lastName, mappedRole))
+}
 
-	user := &User{}
-	err := row.Scan(
-		&user.ID, &user.Email, &user.PasswordHash,
-		&user.EmailVerified, &user.EmailVerificationToken, &user.EmailVerificationExpiresAt,
-		&user.PasswordResetToken, &user.PasswordResetExpiresAt, &user.LastPasswordChangeAt,
-		&user.MFAEnabled, &user.MFASecret, &user.MFABackupCodes, &user.MFASetupAt,
-		&user.MFAVerifiedAt, &user.LastLoginAt, &user.FailedLoginAttempts, &user.AccountLockedUntil,
-		&user.FirstName, &user.LastName, &user.Phone, &user.Role, &user.UserType, &user.Status,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
+// List returns a page of employees matching the filter, ordered by name, and
+// the total number of matches
+func (r *userRepository) List(filter EmployeeFilter, limit, offset int) ([]*User, int, error) {
+	search := ""
+	if filter.Search != "" {
+		search = "%" + likeEscaper.Replace(filter.Search) + "%"
+	}
+	where := ` WHERE user_type = 'employee'
+	          AND ($1::text = '' OR email_address ILIKE $1 OR CONCAT_WS(' ', first_name, last_name) ILIKE $1)
+	          AND ($2::text = '' OR user_role = $2)
+	          AND ($3::text = '' OR status = $3)`
+
+	var total int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM "user"`+where, search, filter.Role, filter.Status).Scan(&total)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return user, nil
+
+	query := `SELECT ` + userColumns + ` FROM "user"` + where + `
+	          ORDER BY LOWER(last_name), LOWER(first_name), id
+	          LIMIT $4 OFFSET $5`
+	rows, err := r.db.Query(query, search, filter.Role, filter.Status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := make([]*User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+// likeEscaper escapes the LIKE wildcards in a search term
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// UpdateProfile updates an employee's name, phone and role; nil values are left unchanged
+func (r *userRepository) UpdateProfile(id string, firstName, lastName, phone, role *string) error {
+	if role != nil {
+		mapped := EmployeeRole(*role)
+		role = &mapped
+	}
+	query := `UPDATE "user" SET
+	          first_name = COALESCE($2, first_name),
+	          last_name = COALESCE($3, last_name),
+	          phone = COALESCE($4, phone),
+	          user_role = COALESCE($5, user_role),
+	          updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $1`
+	_, err := r.db.Exec(query, id, firstName, lastName, phone, role)
+	return err
+}
+
+// SetNMLSIdentifier sets an employee's NMLS ID; an empty ID clears it
+func (r *userRepository) SetNMLSIdentifier(id, nmlsID string) error {
+	query := `UPDATE "user" SET nmlsr_identifier = NULLIF($2::text, ''), updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	_, err := r.db.Exec(query, id, nmlsID)
+	return err
+}
+
+// SetActive activates or deactivates an employee account
+func (r *userRepository) SetActive(id string, active bool) error {
+	query := `UPDATE "user"
+	          SET is_active = $2,
+	              status = CASE WHEN $2 THEN 'active' ELSE 'inactive' END,
+	              updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $1`
+	_, err := r.db.Exec(query, id, active)
+	return err
+}
+
+// RequirePasswordReset refuses logins to the account until its password is reset
+func (r *userRepository) RequirePasswordReset(id string) error {
+	_, err := r.db.Exec(`UPDATE "user" SET password_reset_required = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	return err
+}
+
+// LinkSSOSubject links the user to an identity provider account
+func (r *userRepository) LinkSSOSubject(id, subject string) error {
+	_, err := r.db.Exec(`UPDATE "user" SET sso_subject = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, id, subject)
+	return err
+}
+
+// MarkEmailVerified records that the user proved ownership of their email address
+func (r *userRepository) MarkEmailVerified(id string) error {
+	_, err := r.db.Exec(`UPDATE "user" SET email_verified = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	return err
+}
+
+// SetTwoFactorEnabled opts the user in to or out of login verification codes
+func (r *userRepository) SetTwoFactorEnabled(id string, enabled bool) error {
+	_, err := r.db.Exec(`UPDATE "user" SET two_factor_enabled = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, id, enabled)
+	return err
+}
+
+// RecordLoginFailure increments the failed login counter and returns its new value
+func (r *userRepository) RecordLoginFailure(id string) (int, error) {
+	query := `UPDATE "user" SET failed_login_attempts = COALESCE(failed_login_attempts, 0) + 1
+	          WHERE id = $1 RETURNING failed_login_attempts`
+	var attempts int
+	err := r.db.QueryRow(query, id).Scan(&attempts)
+	return attempts, err
+}
+
+// LockAccount locks the account until the given time
+func (r *userRepository) LockAccount(id string, until time.Time) error {
+	_, err := r.db.Exec(`UPDATE "user" SET account_locked_until = $2 WHERE id = $1`, id, until)
+	return err
+}
+
+// RecordLoginSuccess resets the lockout state and records the login time
+func (r *userRepository) RecordLoginSuccess(id string) error {
+	query := `UPDATE "user"
+	          SET failed_login_attempts = 0,
+	              account_locked_until = NULL,
+	              last_login_at = CURRENT_TIMESTAMP
+	          WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// UnlockAccount clears the lock and the failed login counter
+func (r *userRepository) UnlockAccount(id string) error {
+	_, err := r.db.Exec(`UPDATE "user" SET failed_login_attempts = 0, account_locked_until = NULL WHERE id = $1`, id)
+	return err
+}
+
+// SetPasswordResetToken stores the hash of a password reset token, replacing any previous one
+func (r *userRepository) SetPasswordResetToken(id, tokenHash string, expiresAt time.Time) error {
+	query := `UPDATE "user" SET password_reset_token = $2, password_reset_expires_at = $3 WHERE id = $1`
+	_, err := r.db.Exec(query, id, tokenHash, expiresAt)
+	return err
+}
+
+// ResetPassword sets a new password for the account holding the unexpired reset
+// token, consuming the token and clearing any login lockout or required reset
+func (r *userRepository) ResetPassword(tokenHash, passwordHash string) (string, error) {
+	query := `UPDATE "user"
+	          SET password_hash = $2,
+	              password_reset_token = NULL,
+	              password_reset_expires_at = NULL,
+	              last_password_change_at = CURRENT_TIMESTAMP,
+	              password_reset_required = FALSE,
+	              failed_login_attempts = 0,
+	              account_locked_until = NULL,
+	              updated_at = CURRENT_TIMESTAMP
+	          WHERE password_reset_token = $1 AND password_reset_expires_at > CURRENT_TIMESTAMP
+	          RETURNING id`
+	var id string
+	err := r.db.QueryRow(query, tokenHash, passwordHash).Scan(&id)
+	return id, err
+}
+
+// SetMFASecret stores a new TOTP secret pending confirmation, disabling MFA
+// and discarding any backup codes until EnableMFA is called
+func (r *userRepository) SetMFASecret(id, secret string) error {
+	query := `UPDATE "user"
+	          SET mfa_enabled = false,
+	              mfa_secret = $2,
+	              mfa_backup_codes = NULL,
+	              mfa_setup_at = CURRENT_TIMESTAMP,
+	              mfa_verified_at = NULL
+	          WHERE id = $1`
+	_, err := r.db.Exec(query, id, secret)
+	return err
+}
+
+// EnableMFA turns on MFA for the pending secret and stores the backup code hashes
+func (r *userRepository) EnableMFA(id, backupCodes string) error {
+	query := `UPDATE "user" SET mfa_enabled = true, mfa_backup_codes = $2
+	          WHERE id = $1 AND mfa_secret IS NOT NULL`
+	_, err := r.db.Exec(query, id, backupCodes)
+	return err
+}
+
+// DisableMFA turns off MFA and removes the secret and backup codes
+func (r *userRepository) DisableMFA(id string) error {
+	query := `UPDATE "user"
+	          SET mfa_enabled = false,
+	              mfa_secret = NULL,
+	              mfa_backup_codes = NULL,
+	              mfa_setup_at = NULL,
+	              mfa_verified_at = NULL
+	          WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// RecordMFAVerification records the time step of an accepted TOTP code and
+// reports whether it did; false means a code of this or a later step was
+// already used
+func (r *userRepository) RecordMFAVerification(id string, step time.Time) (bool, error) {
+	query := `UPDATE "user" SET mfa_verified_at = $2
+	          WHERE id = $1 AND (mfa_verified_at IS NULL OR mfa_verified_at < $2)`
+	result, err := r.db.Exec(query, id, step)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// SetMFABackupCodes replaces the backup code hashes
+func (r *userRepository) SetMFABackupCodes(id, backupCodes string) error {
+	_, err := r.db.Exec(`UPDATE "user" SET mfa_backup_codes = $2 WHERE id = $1`, id, backupCodes)
+	return err
+}
+
+// ConsumeMFABackupCode removes a backup code hash and reports whether it was present
+func (r *userRepository) ConsumeMFABackupCode(id, codeHash string) (bool, error) {
+	query := `UPDATE "user"
+	          SET mfa_backup_codes = NULLIF(array_to_string(array_remove(string_to_array(mfa_backup_codes, ','), $2::text), ','), '')
+	          WHERE id = $1 AND $2::text = ANY(string_to_array(mfa_backup_codes, ','))`
+	result, err := r.db.Exec(query, id, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
 }
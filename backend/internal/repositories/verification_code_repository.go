@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Verification code channels
+const (
+	VerificationChannelEmail = "email"
+	VerificationChannelSMS   = "sms"
+)
+
+// VerificationCode is a one-time code sent to an email address or phone number.
+// Each destination holds at most one code per purpose; only a keyed hash of it
+// is stored.
+type VerificationCode struct {
+	ID          string
+	Channel     string // VerificationChannelEmail or VerificationChannelSMS
+	Destination string // normalized email address or phone number
+	Purpose     string // what the code may be used for, e.g. "login"
+	CodeHash    string // hex HMAC-SHA256 of the code
+	Attempts    int    // wrong codes entered so far
+	ExpiresAt   time.Time
+	CreatedAt   sql.NullTime
+}
+
+// verificationCodeRepository is the PostgreSQL implementation of VerificationCodeRepository
+type verificationCodeRepository struct {
+	db DBTX
+}
+
+// newVerificationCodeRepository creates a verification code repository that runs its queries on db
+func newVerificationCodeRepository(db DBTX) *verificationCodeRepository {
+	return &verificationCodeRepository{db: db}
+}
+
+// Issue stores a code for its destination and purpose, replacing the previous
+// code unless that one was created after resendAfter. The conditional upsert
+// keeps concurrent requests from sending more than one code within the cooldown.
+func (r *verificationCodeRepository) Issue(code *VerificationCode, resendAfter time.Time) (bool, error) {
+	query := `INSERT INTO verification_code (id, channel, destination, purpose, code_hash, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          ON CONFLICT (channel, destination, purpose) DO UPDATE
+	          SET id = EXCLUDED.id,
+	              code_hash = EXCLUDED.code_hash,
+	              attempts = 0,
+	              expires_at = EXCLUDED.expires_at,
+	              created_at = CURRENT_TIMESTAMP
+	          WHERE verification_code.created_at <= $7
+	          RETURNING created_at`
+	err := r.db.QueryRow(query,
+		code.ID, code.Channel, code.Destination, code.Purpose, code.CodeHash, code.ExpiresAt, resendAfter,
+	).Scan(&code.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Consume deletes the destination's code for purpose if it matches codeHash, is
+// unexpired and has had fewer than maxAttempts wrong attempts, and reports
+// whether it did. A mismatch counts as a wrong attempt against that code only.
+func (r *verificationCodeRepository) Consume(channel, destination, purpose, codeHash string, maxAttempts int) (bool, error) {
+	query := `DELETE FROM verification_code
+	          WHERE channel = $1 AND destination = $2 AND purpose = $3 AND code_hash = $4
+	          AND attempts < $5 AND expires_at > CURRENT_TIMESTAMP`
+	result, err := r.db.Exec(query, channel, destination, purpose, codeHash, maxAttempts)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 1 {
+		return true, nil
+	}
+
+	query = `UPDATE verification_code SET attempts = attempts + 1
+	         WHERE channel = $1 AND destination = $2 AND purpose = $3 AND attempts < $4`
+	_, err = r.db.Exec(query, channel, destination, purpose, maxAttempts)
+	return false, err
+}
+
+// DeleteExpired deletes codes that expired before the given time
+func (r *verificationCodeRepository) DeleteExpired(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM verification_code WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/repositories"
+	"taulen/backend/internal/utils"
+)
+
+// API keys for machine-to-machine integrations such as LOS sync jobs and
+// reporting scripts. Admins create keys with a set of scopes and an expiry;
+// the key itself is shown once and only its hash is stored. Requests made with
+// a key act with rbac.RoleIntegration and the permissions of its scopes, and
+// are logged with the key's ID. Rotating a key issues a replacement with the
+// same name and scopes and lets the old key expire after a grace period.
+
+// apiKeyPrefix starts every API key, telling them apart from JWTs
+const apiKeyPrefix = "tlk_"
+
+// apiKeyDisplayLength is how many leading characters of a key are kept to identify it
+const apiKeyDisplayLength = 12
+
+// apiKeyUseInterval limits how often the last use of a key is written
+const apiKeyUseInterval = time.Minute
+
+var (
+	// ErrAPIKeyNotFound is returned when no API key has the given ID
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrInvalidAPIKey is returned for unknown, expired and revoked keys
+	ErrInvalidAPIKey = errors.New("invalid, expired or revoked API key")
+	// ErrAPIKeyRevoked is returned when revoking or rotating a key that is already revoked
+	ErrAPIKeyRevoked = errors.New("API key is already revoked")
+	// ErrInvalidAPIKeyScope is returned when creating a key with an unknown scope
+	ErrInvalidAPIKeyScope = errors.New("unknown API key scope")
+	// ErrInvalidAPIKeyExpiry is returned when a key's expiry is negative or exceeds the configured maximum
+	ErrInvalidAPIKeyExpiry = errors.New("API key expiry must be positive and not exceed the maximum")
+	// ErrIntegrationSubmit is returned when an API key submits an application,
+	// which only the borrower or an employee may do
+	ErrIntegrationSubmit = errors.New("API keys cannot submit applications")
+)
+
+// CreateAPIKeyRequest represents a request to create an API key
+type CreateAPIKeyRequest struct {
+	Name   string
+	Scopes []string // e.g. "deals:read"
+	// ExpiresIn is the key's lifetime; zero applies the default
+	ExpiresIn time.Duration
+}
+
+// APIKeyResponse represents an API key as shown to admins
+type APIKeyResponse struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Prefix      string   `json:"prefix"`
+	Scopes      []string `json:"scopes"`
+	Status      string   `json:"status"` // "active", "expired" or "revoked"
+	CreatedBy   string   `json:"createdBy,omitempty"`
+	RotatedFrom string   `json:"rotatedFrom,omitempty"`
+	ExpiresAt   string   `json:"expiresAt"`
+	LastUsedAt  string   `json:"lastUsedAt,omitempty"`
+	LastUsedIP  string   `json:"lastUsedIp,omitempty"`
+	RevokedAt   string   `json:"revokedAt,omitempty"`
+	CreatedAt   string   `json:"createdAt,omitempty"`
+}
+
+// CreatedAPIKeyResponse represents a new API key; Key is shown only this once
+type CreatedAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// APIKeyPrincipal is the integration a request was authenticated as
+type APIKeyPrincipal struct {
+	ID     string
+	Name   string
+	Scopes []rbac.Scope
+}
+
+// APIKeyService manages API keys and authenticates requests made with them
+type APIKeyService struct {
+	store  repositories.Store
+	repo   repositories.APIKeyRepository
+	cfg    *config.Config
+	logger *slog.Logger
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(cfg *config.Config, store repositories.Store, logger *slog.Logger) *APIKeyService {
+	return &APIKeyService{
+		store:  store,
+		repo:   store.APIKeys(),
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// IsAPIKey reports whether a bearer credential is an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, apiKeyPrefix)
+}
+
+// toAPIKeyResponse converts an API key record into a response
+func toAPIKeyResponse(key *repositories.APIKey, now time.Time) APIKeyResponse {
+	response := APIKeyResponse{
+		ID:          key.ID,
+		Name:        key.Name,
+		Prefix:      key.KeyPrefix,
+		Scopes:      key.Scopes,
+		Status:      "active",
+		CreatedBy:   key.CreatedBy.String,
+		RotatedFrom: key.RotatedFrom.String,
+		ExpiresAt:   key.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+		LastUsedIP:  key.LastUsedIP.String,
+	}
+	if response.Scopes == nil {
+		response.Scopes = []string{}
+	}
+	switch {
+	case key.RevokedAt.Valid:
+		response.Status = "revoked"
+		response.RevokedAt = key.RevokedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+	case !key.ExpiresAt.After(now):
+		response.Status = "expired"
+	}
+	if key.LastUsedAt.Valid {
+		response.LastUsedAt = key.LastUsedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if key.CreatedAt.Valid {
+		response.CreatedAt = key.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return response
+}
+
+// parseScopes validates scope names, dropping duplicates
+func parseScopes(names []string) ([]string, error) {
+	scopes := make([]string, 0, len(names))
+	for _, name := range names {
+		scope, ok := rbac.ParseScope(name)
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrInvalidAPIKeyScope, name)
+		}
+		if !slices.Contains(scopes, string(scope)) {
+			scopes = append(scopes, string(scope))
+		}
+	}
+	return scopes, nil
+}
+
+// issue stores a new key and returns it with the key itself
+func (s *APIKeyService) issue(repo repositories.APIKeyRepository, key *repositories.APIKey) (*CreatedAPIKeyResponse, error) {
+	id, err := utils.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	secret := apiKeyPrefix + rand.Text() + rand.Text()
+	key.ID = id
+	key.KeyPrefix = secret[:apiKeyDisplayLength]
+	key.KeyHash = hashToken(secret)
+
+	if err := repo.Create(key); err != nil {
+		return nil, errors.New("failed to create API key: " + err.Error())
+	}
+	return &CreatedAPIKeyResponse{APIKeyResponse: toAPIKeyResponse(key, time.Now()), Key: secret}, nil
+}
+
+// Create creates an API key on behalf of the admin adminID
+func (s *APIKeyService) Create(ctx context.Context, adminID string, req CreateAPIKeyRequest) (*CreatedAPIKeyResponse, error) {
+	scopes, err := parseScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	expiresIn := req.ExpiresIn
+	if expiresIn == 0 {
+		expiresIn = s.cfg.APIKeys.DefaultExpiry
+	}
+	if expiresIn < 0 || expiresIn > s.cfg.APIKeys.MaxExpiry {
+		return nil, ErrInvalidAPIKeyExpiry
+	}
+
+	response, err := s.issue(s.repo, &repositories.APIKey{
+		Name:      strings.TrimSpace(req.Name),
+		Scopes:    scopes,
+		CreatedBy: sql.NullString{String: adminID, Valid: true},
+		ExpiresAt: time.Now().Add(expiresIn),
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(ctx, "admin: API key created", "api_key_id", response.ID, "scopes", scopes, "admin_id", adminID)
+	return response, nil
+}
+
+// List returns every API key, newest first
+func (s *APIKeyService) List(ctx context.Context) ([]APIKeyResponse, error) {
+	keys, err := s.repo.List()
+	if err != nil {
+		return nil, errors.New("failed to list API keys: " + err.Error())
+	}
+	now := time.Now()
+	responses := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		responses = append(responses, toAPIKeyResponse(key, now))
+	}
+	return responses, nil
+}
+
+// loadAPIKey loads the API key with the given ID
+func loadAPIKey(repo repositories.APIKeyRepository, id string) (*repositories.APIKey, error) {
+	key, err := repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, errors.New("failed to load API key: " + err.Error())
+	}
+	return key, nil
+}
+
+// Get returns the API key with the given ID
+func (s *APIKeyService) Get(ctx context.Context, id string) (*APIKeyResponse, error) {
+	key, err := loadAPIKey(s.repo, id)
+	if err != nil {
+		return nil, err
+	}
+	response := toAPIKeyResponse(key, time.Now())
+	return &response, nil
+}
+
+// Revoke revokes an API key on behalf of the admin adminID; it stops working immediately
+func (s *APIKeyService) Revoke(ctx context.Context, adminID, id string) (*APIKeyResponse, error) {
+	if _, err := loadAPIKey(s.repo, id); err != nil {
+		return nil, err
+	}
+	revoked, err := s.repo.Revoke(id)
+	if err != nil {
+		return nil, errors.New("failed to revoke API key: " + err.Error())
+	}
+	if !revoked {
+		return nil, ErrAPIKeyRevoked
+	}
+	s.logger.InfoContext(ctx, "admin: API key revoked", "api_key_id", id, "admin_id", adminID)
+	return s.Get(ctx, id)
+}
+
+// Rotate replaces an API key on behalf of the admin adminID with a new key
+// with the same name and scopes and the default lifetime. The old key keeps
+// working for the configured grace period so integrations can switch over.
+func (s *APIKeyService) Rotate(ctx context.Context, adminID, id string) (*CreatedAPIKeyResponse, error) {
+	var response *CreatedAPIKeyResponse
+	err := s.store.WithinTx(func(tx repositories.Store) error {
+		repo := tx.APIKeys()
+		old, err := loadAPIKey(repo, id)
+		if err != nil {
+			return err
+		}
+		if old.RevokedAt.Valid {
+			return ErrAPIKeyRevoked
+		}
+
+		now := time.Now()
+		if err := repo.ShortenExpiry(id, now.Add(s.cfg.APIKeys.RotationGrace)); err != nil {
+			return errors.New("failed to expire API key: " + err.Error())
+		}
+		response, err = s.issue(repo, &repositories.APIKey{
+			Name:        old.Name,
+			Scopes:      old.Scopes,
+			CreatedBy:   sql.NullString{String: adminID, Valid: true},
+			RotatedFrom: sql.NullString{String: old.ID, Valid: true},
+			ExpiresAt:   now.Add(s.cfg.APIKeys.DefaultExpiry),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(ctx, "admin: API key rotated", "api_key_id", id, "new_api_key_id", response.ID, "admin_id", adminID)
+	return response, nil
+}
+
+// Authenticate returns the integration holding an API key and records the use
+// of the key from clientIP. Unknown, expired and revoked keys are rejected
+// with ErrInvalidAPIKey.
+func (s *APIKeyService) Authenticate(ctx context.Context, secret, clientIP string) (*APIKeyPrincipal, error) {
+	key, err := s.repo.GetByHash(hashToken(secret))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, errors.New("failed to load API key: " + err.Error())
+	}
+	now := time.Now()
+	if key.RevokedAt.Valid || !key.ExpiresAt.After(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if !key.LastUsedAt.Valid || now.Sub(key.LastUsedAt.Time) >= apiKeyUseInterval || key.LastUsedIP.String != clientIP {
+		if err := s.repo.RecordUse(key.ID, clientIP, now); err != nil {
+			s.logger.WarnContext(ctx, "auth: failed to record API key use", "api_key_id", key.ID, "error", err)
+		}
+	}
+
+	principal := &APIKeyPrincipal{ID: key.ID, Name: key.Name}
+	for _, name := range key.Scopes {
+		if scope, ok := rbac.ParseScope(name); ok {
+			principal.Scopes = append(principal.Scopes, scope)
+		}
+	}
+	return principal, nil
+}
@@ -1,33 +1,48 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"log/slog"
 	"strings"
+	"taulen/backend/internal/metrics"
 	"taulen/backend/internal/repositories"
 )
 
+// Application export page sizes
+const (
+	defaultExportPageSize = 100
+	maxExportPageSize     = 500
+)
+
 // ApplicationService handles application/deal CRUD operations
 type ApplicationService struct {
-	dealRepo     *repositories.DealRepository
-	userRepo     *repositories.UserRepository
-	borrowerRepo *repositories.BorrowerRepository
+	dealRepo     repositories.DealRepository
+	userRepo     repositories.UserRepository
+	borrowerRepo repositories.BorrowerRepository
+	logger       *slog.Logger
 }
 
 // NewApplicationService creates a new application service
-func NewApplicationService() *ApplicationService {
+func NewApplicationService(store repositories.Store, logger *slog.Logger) *ApplicationService {
 	return &ApplicationService{
-		dealRepo:     repositories.NewDealRepository(),
-		userRepo:     repositories.NewUserRepository(),
-		borrowerRepo: repositories.NewBorrowerRepository(),
+		dealRepo:     store.Deals(),
+		userRepo:     store.Users(),
+		borrowerRepo: store.Borrowers(),
+		logger:       logger,
 	}
 }
 
+// withStore returns a copy of the service whose repositories use the given store
+func (s *ApplicationService) withStore(store repositories.Store) *ApplicationService {
+	return NewApplicationService(store, s.logger)
+}
+
 // CreateApplication creates a new URLA application
 // userID is the employee (User) managing this application
 // applicantID can be nil initially, set later when primary applicant is created
-func (s *ApplicationService) CreateApplication(userID string, req CreateApplicationRequest) (*ApplicationResponse, error) {
+func (s *ApplicationService) CreateApplication(ctx context.Context, userID string, req CreateApplicationRequest) (*ApplicationResponse, error) {
 	// Verify user exists (must be an employee)
 	_, err := s.userRepo.GetByID(userID)
 	if err != nil {
@@ -40,6 +55,7 @@ func (s *ApplicationService) CreateApplication(userID string, req CreateApplicat
 	if err != nil {
 		return nil, errors.New("failed to create application")
 	}
+	metrics.ApplicationCreated(metrics.SourceEmployee)
 
 	return &ApplicationResponse{
 		ID:          dealID,
@@ -51,54 +67,16 @@ func (s *ApplicationService) CreateApplication(userID string, req CreateApplicat
 }
 
 // GetApplication retrieves a deal (application) by ID
-func (s *ApplicationService) GetApplication(dealID string) (map[string]interface{}, error) {
-	log.Printf("GetApplication: Fetching application %s", dealID)
-	row, err := s.dealRepo.GetDealByID(dealID)
-	if err != nil {
-		log.Printf("GetApplication: Error fetching deal %s: %v", dealID, err)
-		return nil, errors.New("deal not found")
-	}
-
-	var deal struct {
-		ID                      string
-		LoanNumber              sql.NullString
-		UniversalLoanIdentifier sql.NullString
-		AgencyCaseIdentifier    sql.NullString
-		ApplicationType         sql.NullString
-		TotalBorrowers          sql.NullInt64
-		ApplicationDate         sql.NullTime
-		CreatedAt               sql.NullTime
-		PrimaryBorrowerID       sql.NullString
-		CurrentFormStep         sql.NullString
-		LoanID                  sql.NullString
-		LoanPurposeType         sql.NullString
-		LoanAmountRequested     sql.NullFloat64
-		LoanTermMonths          sql.NullInt64
-		InterestRatePercentage  sql.NullFloat64
-		PropertyType            sql.NullString
-		ManufacturedHomeWidth   sql.NullString
-		TitleMannerType         sql.NullString
-	}
-
-	err = row.Scan(
-		&deal.ID, &deal.LoanNumber, &deal.UniversalLoanIdentifier, &deal.AgencyCaseIdentifier,
-		&deal.ApplicationType, &deal.TotalBorrowers, &deal.ApplicationDate, &deal.CreatedAt, &deal.PrimaryBorrowerID,
-		&deal.CurrentFormStep,
-		&deal.LoanID, &deal.LoanPurposeType, &deal.LoanAmountRequested, &deal.LoanTermMonths,
-		&deal.InterestRatePercentage, &deal.PropertyType, &deal.ManufacturedHomeWidth, &deal.TitleMannerType,
-	)
+func (s *ApplicationService) GetApplication(ctx context.Context, dealID string) (map[string]interface{}, error) {
+	deal, err := s.dealRepo.GetDealByID(dealID)
 	if err != nil {
-		log.Printf("GetApplication: Error scanning deal %s: %v", dealID, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("deal not found")
+		}
+		s.logger.ErrorContext(ctx, "GetApplication: failed to fetch deal", "error", err)
 		return nil, errors.New("failed to retrieve deal")
 	}
 
-	primaryBorrowerIDValue := ""
-	if deal.PrimaryBorrowerID.Valid {
-		primaryBorrowerIDValue = deal.PrimaryBorrowerID.String
-	}
-	log.Printf("GetApplication: Deal %s - PrimaryBorrowerID.Valid=%v, PrimaryBorrowerID.String=%s",
-		dealID, deal.PrimaryBorrowerID.Valid, primaryBorrowerIDValue)
-
 	result := make(map[string]interface{})
 	result["id"] = deal.ID
 	if deal.LoanNumber.Valid {
@@ -119,17 +97,11 @@ func (s *ApplicationService) GetApplication(dealID string) (map[string]interface
 
 	// Fetch borrower data if primary_borrower_id exists
 	if deal.PrimaryBorrowerID.Valid {
-		log.Printf("GetApplication: Fetching borrower data for borrower ID %s", deal.PrimaryBorrowerID.String)
 		borrower, err := s.borrowerRepo.GetByID(deal.PrimaryBorrowerID.String)
 		if err != nil {
-			log.Printf("GetApplication: Error fetching borrower %s: %v", deal.PrimaryBorrowerID.String, err)
+			s.logger.WarnContext(ctx, "GetApplication: failed to fetch primary borrower",
+				"borrower_id", deal.PrimaryBorrowerID.String, "error", err)
 		} else if borrower != nil {
-			borrowerEmail := ""
-			if borrower.EmailAddress.Valid {
-				borrowerEmail = borrower.EmailAddress.String
-			}
-			log.Printf("GetApplication: Found borrower %s - firstName=%s, lastName=%s, email=%s",
-				borrower.ID, borrower.FirstName, borrower.LastName, borrowerEmail)
 			borrowerData := make(map[string]interface{})
 			borrowerData["id"] = borrower.ID // Include borrower ID for state management
 			borrowerData["firstName"] = borrower.FirstName
@@ -187,10 +159,9 @@ func (s *ApplicationService) GetApplication(dealID string) (map[string]interface
 			// Fetch current residence/address from residence table
 			addr, city, state, zipCode, err := s.borrowerRepo.GetCurrentResidence(deal.PrimaryBorrowerID.String)
 			if err != nil {
-				log.Printf("Error fetching residence for borrower %s: %v", deal.PrimaryBorrowerID.String, err)
+				s.logger.DebugContext(ctx, "GetApplication: no current residence for borrower",
+					"borrower_id", deal.PrimaryBorrowerID.String, "error", err)
 			} else {
-				log.Printf("Residence data for borrower %s: addr=%s, city=%s, state=%s, zip=%s",
-					deal.PrimaryBorrowerID.String, addr, city, state, zipCode)
 				// Combine address components into a single string
 				addressParts := []string{}
 				if strings.TrimSpace(addr) != "" {
@@ -213,31 +184,20 @@ func (s *ApplicationService) GetApplication(dealID string) (map[string]interface
 				}
 				// Set currentAddress if we have any address parts
 				if len(addressParts) > 0 {
-					fullAddress := strings.Join(addressParts, ", ")
-					borrowerData["currentAddress"] = fullAddress
-					log.Printf("Synthesized currentAddress for borrower %s: %s", deal.PrimaryBorrowerID.String, fullAddress)
-				} else {
-					log.Printf("No address parts found for borrower %s (addr=%s, city=%s, state=%s, zip=%s)",
-						deal.PrimaryBorrowerID.String, addr, city, state, zipCode)
+					borrowerData["currentAddress"] = strings.Join(addressParts, ", ")
 				}
 			}
 
 			result["borrower"] = borrowerData
 			result["borrowerId"] = borrower.ID // Also include at top level for easy access
-			log.Printf("GetApplication: Added borrower data to result for application %s", dealID)
-		} else {
-			log.Printf("GetApplication: Borrower not found or error for borrower ID %s", deal.PrimaryBorrowerID.String)
 		}
 
 		// Fetch co-borrower data - always try to fetch if primary borrower exists
 		// This is more robust than checking TotalBorrowers which might be NULL
-		log.Printf("GetApplication: Attempting to fetch co-borrower data for deal %s (total borrowers: %v)",
-			dealID, deal.TotalBorrowers)
 		coBorrowers, err := s.borrowerRepo.GetCoBorrowersByDealID(dealID, deal.PrimaryBorrowerID.String)
 		if err != nil {
-			log.Printf("GetApplication: Error fetching co-borrowers for deal %s: %v", dealID, err)
+			s.logger.WarnContext(ctx, "GetApplication: failed to fetch co-borrowers", "error", err)
 		} else {
-			log.Printf("GetApplication: Found %d co-borrower(s) for deal %s", len(coBorrowers), dealID)
 			if len(coBorrowers) > 0 {
 				// For now, we only support one co-borrower, so take the first one
 				coBorrower := coBorrowers[0]
@@ -311,28 +271,40 @@ func (s *ApplicationService) GetApplication(dealID string) (map[string]interface
 
 				result["coBorrower"] = coBorrowerData
 				result["coBorrowerId"] = coBorrower.ID // Also include at top level for easy access
-				log.Printf("GetApplication: Added co-borrower data to result for application %s", dealID)
-			} else {
-				log.Printf("GetApplication: No co-borrowers found for deal %s (checked borrower_progress table)", dealID)
 			}
 		}
-	} else {
-		log.Printf("GetApplication: No primary_borrower_id set for application %s", dealID)
 	}
 
-	log.Printf("GetApplication: Returning result for application %s, has borrower=%v, has coBorrower=%v",
-		dealID, result["borrower"] != nil, result["coBorrower"] != nil)
+	s.logger.DebugContext(ctx, "GetApplication: loaded application",
+		"has_borrower", result["borrower"] != nil, "has_co_borrower", result["coBorrower"] != nil)
 	return result, nil
 }
 
 // UpdateApplicationStatus updates the status of an application
-func (s *ApplicationService) UpdateApplicationStatus(applicationID string, status string) error {
+func (s *ApplicationService) UpdateApplicationStatus(ctx context.Context, applicationID string, status string) error {
 	validStatuses := map[string]bool{
 		"draft": true, "submitted": true, "in_review": true,
 		"approved": true, "denied": true, "withdrawn": true,
 	}
 	if !validStatuses[status] {
-		return errors.New("invalid status")
+		return &ValidationError{Message: "invalid status"}
+	}
+
+	// Only a borrower with a verified email may submit their application
+	if status == "submitted" {
+		deal, err := s.dealRepo.GetDealByID(applicationID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrDealNotFound
+			}
+			s.logger.ErrorContext(ctx, "UpdateApplicationStatus: failed to fetch deal", "deal_id", applicationID, "error", err)
+			return errors.New("failed to load application")
+		}
+		if deal.PrimaryBorrowerID.Valid {
+			if err := requireVerifiedEmail(s.borrowerRepo, deal.PrimaryBorrowerID.String); err != nil {
+				return err
+			}
+		}
 	}
 
 	// TODO: The new schema doesn't have application_status field
@@ -342,193 +314,97 @@ func (s *ApplicationService) UpdateApplicationStatus(applicationID string, statu
 }
 
 // GetApplicationsByEmployee retrieves all applications managed by an employee
-func (s *ApplicationService) GetApplicationsByEmployee(userID string) ([]ApplicationResponse, error) {
-	rows, err := s.dealRepo.GetDealsByUserID(userID)
+func (s *ApplicationService) GetApplicationsByEmployee(ctx context.Context, userID string) ([]ApplicationResponse, error) {
+	deals, err := s.dealRepo.GetDealsByUserID(userID)
 	if err != nil {
+		s.logger.ErrorContext(ctx, "GetApplicationsByEmployee: failed to query deals", "employee_id", userID, "error", err)
 		return make([]ApplicationResponse, 0), errors.New("failed to retrieve applications")
 	}
-	defer rows.Close()
 
 	// Initialize as empty slice, not nil, so it serializes to [] instead of null in JSON
-	applications := make([]ApplicationResponse, 0)
-	for rows.Next() {
-		var app struct {
-			ID              string
-			ApplicantID     sql.NullString
-			UserID          sql.NullString
-			ApplicationDate sql.NullTime
-			LoanType        sql.NullString
-			LoanPurpose     sql.NullString
-			LoanAmount      sql.NullFloat64
-			Status          sql.NullString
-			CreatedDate     sql.NullTime
-			LastUpdatedDate sql.NullTime
-		}
+	applications := make([]ApplicationResponse, 0, len(deals))
+	for _, deal := range deals {
+		applications = append(applications, toApplicationResponse(deal))
+	}
 
-		err := rows.Scan(
-			&app.ID, &app.ApplicantID, &app.UserID, &app.ApplicationDate,
-			&app.LoanType, &app.LoanPurpose, &app.LoanAmount, &app.Status,
-			&app.CreatedDate, &app.LastUpdatedDate,
-		)
-		if err != nil {
-			continue
-		}
+	return applications, nil
+}
 
-		loanType := ""
-		if app.LoanType.Valid {
-			loanType = app.LoanType.String
-		}
-		loanPurpose := ""
-		if app.LoanPurpose.Valid {
-			loanPurpose = app.LoanPurpose.String
-		}
-		loanAmount := 0.0
-		if app.LoanAmount.Valid {
-			loanAmount = app.LoanAmount.Float64
-		}
-		status := ""
-		if app.Status.Valid {
-			status = app.Status.String
-		}
-		createdDate := ""
-		if app.CreatedDate.Valid {
-			createdDate = app.CreatedDate.Time.Format("2006-01-02T15:04:05Z07:00")
-		}
-		lastUpdatedDate := ""
-		if app.LastUpdatedDate.Valid {
-			lastUpdatedDate = app.LastUpdatedDate.Time.Format("2006-01-02T15:04:05Z07:00")
-		}
+// ExportApplications returns a page of every application, newest first, for
+// integrations exporting deals; page starts at 1
+func (s *ApplicationService) ExportApplications(ctx context.Context, page, pageSize int) (*ApplicationExportResponse, error) {
+	page = max(page, 1)
+	if pageSize <= 0 {
+		pageSize = defaultExportPageSize
+	}
+	pageSize = min(pageSize, maxExportPageSize)
 
-		applications = append(applications, ApplicationResponse{
-			ID:              app.ID,
-			LoanType:        loanType,
-			LoanPurpose:     loanPurpose,
-			LoanAmount:      loanAmount,
-			Status:          status,
-			CreatedDate:     createdDate,
-			LastUpdatedDate: lastUpdatedDate,
-		})
+	deals, err := s.dealRepo.ListDeals(pageSize, (page-1)*pageSize)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "ExportApplications: failed to query deals", "error", err)
+		return nil, errors.New("failed to retrieve applications")
 	}
 
-	return applications, rows.Err()
+	applications := make([]ApplicationResponse, 0, len(deals))
+	for _, deal := range deals {
+		applications = append(applications, toApplicationResponse(deal))
+	}
+	return &ApplicationExportResponse{Applications: applications, Page: page, PageSize: pageSize}, nil
 }
 
 // GetApplicationsByBorrower retrieves all applications for a borrower
-func (s *ApplicationService) GetApplicationsByBorrower(borrowerID string) ([]ApplicationResponse, error) {
-	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
-	log.Printf("=== GetApplicationsByBorrower: START for borrower ID: %s ===", borrowerID)
-	rows, err := s.dealRepo.GetDealsByBorrowerID(borrowerID)
+func (s *ApplicationService) GetApplicationsByBorrower(ctx context.Context, borrowerID string) ([]ApplicationResponse, error) {
+	deals, err := s.dealRepo.GetDealsByBorrowerID(borrowerID)
 	if err != nil {
-		log.Printf("GetApplicationsByBorrower: Error querying deals for borrower %s: %v", borrowerID, err)
+		s.logger.ErrorContext(ctx, "GetApplicationsByBorrower: failed to query deals", "borrower_id", borrowerID, "error", err)
 		// Return empty slice instead of nil to ensure JSON serializes correctly
 		return make([]ApplicationResponse, 0), errors.New("failed to retrieve applications")
 	}
-	defer rows.Close()
 
 	// Initialize as empty slice, not nil, so it serializes to [] instead of null in JSON
-	applications := make([]ApplicationResponse, 0)
-	log.Printf("GetApplicationsByBorrower: Query executed successfully, about to iterate rows for borrower %s", borrowerID)
-	rowCount := 0
-	hasAnyRows := false
-	for rows.Next() {
-		hasAnyRows = true
-		rowCount++
-		log.Printf("GetApplicationsByBorrower: Processing row %d for borrower %s", rowCount, borrowerID)
-		var app struct {
-			ID                string
-			LoanNumber        sql.NullString
-			ApplicationType   sql.NullString
-			ApplicationDate   sql.NullTime
-			CreatedAt         sql.NullTime
-			Status            sql.NullString
-			LoanPurpose       sql.NullString
-			LoanAmount        sql.NullFloat64
-			LastUpdatedAt     sql.NullTime
-			ProgressPercentage sql.NullInt64
-			LastUpdatedSection sql.NullString
-		}
-
-		err := rows.Scan(
-			&app.ID, &app.LoanNumber, &app.ApplicationType, &app.ApplicationDate,
-			&app.CreatedAt, &app.Status, &app.LoanPurpose, &app.LoanAmount,
-			&app.LastUpdatedAt, &app.ProgressPercentage, &app.LastUpdatedSection,
-		)
-		if err != nil {
-			log.Printf("GetApplicationsByBorrower: Error scanning row %d for borrower %s: %v", rowCount, borrowerID, err)
-			continue
-		}
-		log.Printf("GetApplicationsByBorrower: Successfully scanned row %d, app.ID=%s", rowCount, app.ID)
-
-		loanPurpose := ""
-		if app.LoanPurpose.Valid {
-			loanPurpose = app.LoanPurpose.String
-		}
-		loanAmount := 0.0
-		if app.LoanAmount.Valid {
-			loanAmount = app.LoanAmount.Float64
-		}
-		status := "Draft"
-		if app.Status.Valid {
-			status = app.Status.String
-		}
-		createdDate := ""
-		if app.CreatedAt.Valid {
-			createdDate = app.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00")
-		}
-		lastUpdatedDate := ""
-		if app.LastUpdatedAt.Valid {
-			lastUpdatedDate = app.LastUpdatedAt.Time.Format("2006-01-02T15:04:05Z07:00")
-		}
-
-		var progressPercentage *int
-		if app.ProgressPercentage.Valid {
-			pct := int(app.ProgressPercentage.Int64)
-			progressPercentage = &pct
-		}
+	applications := make([]ApplicationResponse, 0, len(deals))
+	for _, deal := range deals {
+		applications = append(applications, toApplicationResponse(deal))
+	}
 
-		var lastUpdatedSection *string
-		if app.LastUpdatedSection.Valid {
-			section := app.LastUpdatedSection.String
-			lastUpdatedSection = &section
-		}
+	s.logger.DebugContext(ctx, "GetApplicationsByBorrower: loaded applications", "count", len(applications))
+	return applications, nil
+}
 
-		applications = append(applications, ApplicationResponse{
-			ID:                 app.ID,
-			LoanType:           "Conventional", // Default, can be updated later
-			LoanPurpose:        loanPurpose,
-			LoanAmount:         loanAmount,
-			Status:             status,
-			CreatedDate:        createdDate,
-			LastUpdatedDate:    lastUpdatedDate,
-			ProgressPercentage: progressPercentage,
-			LastUpdatedSection: lastUpdatedSection,
-		})
-		log.Printf("GetApplicationsByBorrower: Found application %s for borrower %s", app.ID, borrowerID)
+// toApplicationResponse converts a deal list entry into an application response
+// Note: deal table doesn't have a status column, so every deal is reported as 'Draft'
+func toApplicationResponse(deal *repositories.DealSummary) ApplicationResponse {
+	app := ApplicationResponse{
+		ID:       deal.ID,
+		LoanType: "Conventional", // Default, can be updated later
+		Status:   "Draft",
 	}
-
-	if err := rows.Err(); err != nil {
-		log.Printf("GetApplicationsByBorrower: Error iterating rows for borrower %s: %v", borrowerID, err)
-		// Return empty slice instead of nil on error to ensure JSON serializes correctly
-		return make([]ApplicationResponse, 0), err
+	if deal.LoanPurposeType.Valid {
+		app.LoanPurpose = deal.LoanPurposeType.String
 	}
-
-	if !hasAnyRows {
-		log.Printf("GetApplicationsByBorrower: WARNING - rows.Next() never returned true for borrower %s. No rows found.", borrowerID)
-	} else {
-		log.Printf("GetApplicationsByBorrower: Processed %d rows, found %d applications for borrower %s", rowCount, len(applications), borrowerID)
+	if deal.LoanAmountRequested.Valid {
+		app.LoanAmount = deal.LoanAmountRequested.Float64
 	}
-	// Ensure we always return a non-nil slice (applications is already initialized as empty slice above)
-	if applications == nil {
-		log.Printf("GetApplicationsByBorrower: WARNING - applications is nil, converting to empty slice")
-		applications = make([]ApplicationResponse, 0)
+	if deal.CreatedAt.Valid {
+		app.CreatedDate = deal.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00")
 	}
-	log.Printf("=== GetApplicationsByBorrower: END - returning %d applications ===", len(applications))
-	return applications, nil
+	if deal.LastUpdatedAt.Valid {
+		app.LastUpdatedDate = deal.LastUpdatedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if deal.ProgressPercentage.Valid {
+		pct := int(deal.ProgressPercentage.Int64)
+		app.ProgressPercentage = &pct
+	}
+	if deal.LastUpdatedSection.Valid {
+		section := deal.LastUpdatedSection.String
+		app.LastUpdatedSection = &section
+	}
+	return app
 }
 
 // CreateApplicationForBorrower creates a new application for a borrower
 // This is called when a borrower starts a new application from the home page
-func (s *ApplicationService) CreateApplicationForBorrower(borrowerID string, req CreateApplicationRequest) (*ApplicationResponse, error) {
+func (s *ApplicationService) CreateApplicationForBorrower(ctx context.Context, borrowerID string, req CreateApplicationRequest) (*ApplicationResponse, error) {
 	// Verify borrower exists
 	_, err := s.borrowerRepo.GetByID(borrowerID)
 	if err != nil {
@@ -541,6 +417,7 @@ func (s *ApplicationService) CreateApplicationForBorrower(borrowerID string, req
 	if err != nil {
 		return nil, errors.New("failed to create application")
 	}
+	metrics.ApplicationCreated(metrics.SourceBorrower)
 
 	// Update borrower's deal_id if it was NULL
 	// This will be handled when the deal is created
@@ -555,6 +432,6 @@ func (s *ApplicationService) CreateApplicationForBorrower(borrowerID string, req
 }
 
 // UpdateCurrentFormStep updates the current form step for a deal
-func (s *ApplicationService) UpdateCurrentFormStep(dealID string, formStep string) error {
+func (s *ApplicationService) UpdateCurrentFormStep(ctx context.Context, dealID string, formStep string) error {
 	return s.dealRepo.UpdateCurrentFormStep(dealID, formStep)
 }
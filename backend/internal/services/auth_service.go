@@ -1,32 +1,54 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
-	"time"
 	"taulen/backend/internal/config"
+	"taulen/backend/internal/notify"
+	"taulen/backend/internal/oidc"
+	"taulen/backend/internal/rbac"
 	"taulen/backend/internal/repositories"
 	"taulen/backend/internal/utils"
 )
 
 // AuthService handles authentication business logic
 type AuthService struct {
-	userRepo     *repositories.UserRepository
-	borrowerRepo *repositories.BorrowerRepository
-	jwtManager   *utils.JWTManager
-	cfg          *config.Config
+	userRepo          repositories.UserRepository
+	borrowerRepo      repositories.BorrowerRepository
+	dealRepo          repositories.DealRepository
+	jwtManager        *utils.JWTManager
+	sessions          *SessionService
+	verification      *VerificationService
+	emailVerification *EmailVerificationService
+	email             *EmailService
+	sso               *oidc.Provider // nil when SSO is disabled
+	cfg               *config.Config
+	logger            *slog.Logger
 }
 
-// NewAuthService creates a new auth service
-func NewAuthService(cfg *config.Config) *AuthService {
-	return &AuthService{
-		userRepo:     repositories.NewUserRepository(),
-		borrowerRepo: repositories.NewBorrowerRepository(),
-		jwtManager:   utils.NewJWTManager(&cfg.JWT),
-		cfg:          cfg,
-	}
+// NewAuthService creates a new auth service backed by the given store and
+// sending notifications through notifier
+func NewAuthService(cfg *config.Config, store repositories.Store, notifier notify.Notifier, logger *slog.Logger) *AuthService {
+	s := &AuthService{
+		userRepo:          store.Users(),
+		borrowerRepo:      store.Borrowers(),
+		dealRepo:          store.Deals(),
+		jwtManager:        utils.NewJWTManager(&cfg.JWT),
+		sessions:          NewSessionService(cfg, store, notifier, logger),
+		verification:      NewVerificationService(cfg, store, notifier, logger),
+		emailVerification: NewEmailVerificationService(cfg, store, notifier, logger),
+		email:             NewEmailService(notifier, logger),
+		cfg:               cfg,
+		logger:            logger,
+	}
+	if cfg.OIDC.Enabled() {
+		s.sso = oidc.NewProvider(&cfg.OIDC)
+	}
+	return s
 }
 
 // GetConfig returns the config (for use in handlers)
@@ -45,21 +67,21 @@ type RegisterRequest struct {
 }
 
 // VerifyAndRegisterRequest represents a registration request with verification code
-// NOTE: VerificationCode is optional when 2FA is disabled
+// NOTE: VerificationCode is optional unless the 2FA mode is required
 type VerifyAndRegisterRequest struct {
 	Email           string `json:"email" binding:"required,email"`
 	Password        string `json:"password" binding:"required,min=8"`
 	FirstName       string `json:"firstName" binding:"required"`
 	LastName        string `json:"lastName" binding:"required"`
-	Phone           string `json:"phone"` // Optional when 2FA is disabled
-	VerificationCode string `json:"verificationCode"` // Optional when 2FA is disabled
+	Phone           string `json:"phone"` // Optional
+	VerificationCode string `json:"verificationCode"` // Optional unless 2FA is required
 }
 
 // LoginRequest represents a login request
 type LoginRequest struct {
 	Email            string `json:"email" binding:"required,email"`
 	Password         string `json:"password" binding:"required"`
-	VerificationCode string `json:"verificationCode,omitempty"` // Optional: required for 2FA login
+	VerificationCode string `json:"verificationCode,omitempty"` // Checked as the 2FA mode requires
 }
 
 // SendLoginVerificationCodeRequest represents a request to send verification code for login
@@ -77,6 +99,8 @@ type AuthResponse struct {
 	AccessToken  string       `json:"accessToken"`
 	RefreshToken string       `json:"refreshToken"`
 	User         UserResponse `json:"user"`
+	// BackupCodes are returned once, when MFA enrollment completes a login
+	BackupCodes []string `json:"backupCodes,omitempty"`
 }
 
 // UserResponse represents user information in response
@@ -85,14 +109,22 @@ type UserResponse struct {
 	Email     string `json:"email"`
 	FirstName string `json:"firstName"`
 	LastName  string `json:"lastName"`
-	Role      string `json:"role,omitempty"`      // Only for employees
-	UserType  string `json:"userType"`            // 'employee' or 'applicant'
+	Role      string `json:"role,omitempty"` // Only for employees
+	UserType  string `json:"userType"`       // 'employee' or 'applicant'
+	// EmailVerified reports whether the email address was confirmed; borrowers
+	// must confirm it before submitting an application
+	EmailVerified bool `json:"emailVerified"`
 }
 
 // Register registers a new borrower (signup is only for borrowers)
 // IMPORTANT: This creates entries in the "borrower" table, NOT the "user" table.
 // The "user" table is reserved for employees created by system admins via CreateEmployee.
-func (s *AuthService) Register(req RegisterRequest) (*AuthResponse, error) {
+// When the 2FA mode is required, borrowers register through VerifyAndRegister instead.
+func (s *AuthService) Register(ctx context.Context, req RegisterRequest) (*AuthResponse, error) {
+	if s.cfg.TwoFactor.Mode == config.TwoFactorRequired {
+		return nil, ErrVerificationCodeRequired
+	}
+
 	// Check if borrower already exists
 	existingBorrower, err := s.borrowerRepo.GetByEmail(req.Email)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
@@ -136,15 +168,11 @@ func (s *AuthService) Register(req RegisterRequest) (*AuthResponse, error) {
 	if borrower.EmailAddress.Valid {
 		email = borrower.EmailAddress.String
 	}
+	s.emailVerification.SendLink(ctx, borrower.ID, email)
 
-	accessToken, err := s.jwtManager.GenerateAccessToken(borrower.ID, email)
-	if err != nil {
-		return nil, errors.New("failed to generate access token")
-	}
-
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(borrower.ID, email)
+	accessToken, refreshToken, err := s.sessions.Issue(ctx, "", borrower.ID, SubjectTypeApplicant, email, rbac.RoleApplicant)
 	if err != nil {
-		return nil, errors.New("failed to generate refresh token")
+		return nil, err
 	}
 
 	return &AuthResponse{
@@ -161,11 +189,9 @@ func (s *AuthService) Register(req RegisterRequest) (*AuthResponse, error) {
 }
 
 // VerifyAndRegister verifies the code and creates a borrower account
-// This is used for direct sign-up with 2FA
-// NOTE: 2FA is currently disabled - verification code check is bypassed
-func (s *AuthService) VerifyAndRegister(req VerifyAndRegisterRequest) (*AuthResponse, error) {
-	// 2FA is disabled - skip verification code check
-
+// This is used for direct sign-up with 2FA. The code was sent by
+// SendRegisterVerificationCode; whether one is needed depends on the 2FA mode.
+func (s *AuthService) VerifyAndRegister(ctx context.Context, req VerifyAndRegisterRequest) (*AuthResponse, error) {
 	// Check if borrower already exists
 	existingBorrower, err := s.borrowerRepo.GetByEmail(req.Email)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
@@ -184,6 +210,16 @@ func (s *AuthService) VerifyAndRegister(req VerifyAndRegisterRequest) (*AuthResp
 		return nil, errors.New("this email is already registered as an employee account")
 	}
 
+	verify, err := shouldVerifyCode(s.cfg, false, req.VerificationCode)
+	if err != nil {
+		return nil, err
+	}
+	if verify {
+		if err := s.verifyEmailCode(verificationPurposeRegister, req.Email, req.VerificationCode); err != nil {
+			return nil, err
+		}
+	}
+
 	// Validate password is not empty
 	if req.Password == "" {
 		return nil, errors.New("password cannot be empty")
@@ -208,137 +244,128 @@ func (s *AuthService) VerifyAndRegister(req VerifyAndRegisterRequest) (*AuthResp
 		}
 		return nil, fmt.Errorf("failed to create borrower: %w", err)
 	}
-
-	// Clear verification code
-	err = s.borrowerRepo.ClearVerificationCode(req.Email)
-	if err != nil {
-		// Log but don't fail
+	if verify {
+		// The code proved ownership of the email
+		if err := s.borrowerRepo.MarkEmailVerified(borrower.ID); err != nil {
+			s.logger.WarnContext(ctx, "VerifyAndRegister: failed to mark email verified", "borrower_id", borrower.ID, "error", err)
+		}
+		borrower.EmailVerified = sql.NullBool{Bool: true, Valid: true}
+	} else {
+		s.emailVerification.SendLink(ctx, borrower.ID, borrower.EmailAddress.String)
 	}
 
 	// Generate tokens
-	email := ""
-	if borrower.EmailAddress.Valid {
-		email = borrower.EmailAddress.String
+	return s.borrowerAuthResponse(ctx, "", borrower)
+}
+
+// SendRegisterVerificationCode emails a verification code for registering the
+// given email, which VerifyAndRegister checks
+func (s *AuthService) SendRegisterVerificationCode(ctx context.Context, req SendRegisterVerificationCodeRequest) error {
+	if s.cfg.TwoFactor.Mode == config.TwoFactorOff {
+		return ErrTwoFactorDisabled
 	}
 
-	accessToken, err := s.jwtManager.GenerateAccessToken(borrower.ID, email)
-	if err != nil {
-		return nil, errors.New("failed to generate access token")
+	borrower, err := s.borrowerRepo.GetByEmail(req.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check if borrower exists: %w", err)
+	}
+	if borrower != nil {
+		return errors.New("borrower with this email already exists")
 	}
 
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(borrower.ID, email)
-	if err != nil {
-		return nil, errors.New("failed to generate refresh token")
+	existingUser, err := s.userRepo.GetByEmail(req.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check if user exists: %w", err)
+	}
+	if existingUser != nil {
+		return errors.New("this email is already registered as an employee account")
 	}
 
-	return &AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User: UserResponse{
-			ID:        borrower.ID,
-			Email:     email,
-			FirstName: borrower.FirstName,
-			LastName:  borrower.LastName,
-			UserType:  "applicant",
-		},
-	}, nil
+	return s.issueEmailCode(ctx, verificationPurposeRegister, req.Email)
 }
 
-// SendLoginVerificationCode sends a verification code via email for login
-func (s *AuthService) SendLoginVerificationCode(req SendLoginVerificationCodeRequest) error {
+// SendLoginVerificationCode emails a login verification code to the borrower or
+// active employee with the given email. Unknown emails, and emails sent a code
+// within the resend cooldown, are ignored without error so that the response
+// does not reveal whether the email is registered.
+func (s *AuthService) SendLoginVerificationCode(ctx context.Context, req SendLoginVerificationCodeRequest) error {
+	if s.cfg.TwoFactor.Mode == config.TwoFactorOff {
+		return ErrTwoFactorDisabled
+	}
+
 	// Check if user exists (borrower or employee)
 	borrower, err := s.borrowerRepo.GetByEmail(req.Email)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return errors.New("failed to check if user exists")
+	if err == nil {
+		if !borrower.PasswordHash.Valid {
+			return nil
+		}
+		return s.issueLoginCode(ctx, req.Email)
 	}
-	
-	user, err := s.userRepo.GetByEmail(req.Email)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+	if !errors.Is(err, sql.ErrNoRows) {
 		return errors.New("failed to check if user exists")
 	}
-	
-	if borrower == nil && user == nil {
-		return errors.New("no account found with this email")
-	}
-	
-	// Generate verification code
-	code, err := utils.GenerateVerificationCode()
+
+	user, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil {
-		return errors.New("failed to generate verification code")
-	}
-	
-	// Store verification code (expires in 10 minutes)
-	expiresAt := time.Now().Add(10 * time.Minute)
-	
-	// Store code in borrower table (works for both borrowers and employees)
-	if borrower != nil {
-		err = s.borrowerRepo.SetVerificationCode(req.Email, code, "email", expiresAt)
-	} else {
-		// For employees, we'd need to add verification code support to user table
-		// For now, use borrower table if it exists, otherwise create a temporary record
-		err = s.borrowerRepo.SetVerificationCode(req.Email, code, "email", expiresAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return errors.New("failed to check if user exists")
 	}
-	
-	if err != nil {
-		return errors.New("failed to store verification code")
+	if user.Status != "active" {
+		return nil
 	}
-	
-	// Send verification code via email
-	emailService := NewEmailService(s.cfg)
-	err = emailService.SendVerificationCode(req.Email, code)
-	if err != nil {
-		return fmt.Errorf("failed to send verification email: %w", err)
+	return s.issueLoginCode(ctx, req.Email)
+}
+
+// issueLoginCode emails a login code, skipping emails still in the resend cooldown
+func (s *AuthService) issueLoginCode(ctx context.Context, email string) error {
+	err := s.issueEmailCode(ctx, verificationPurposeLogin, email)
+	if errors.Is(err, ErrVerificationCodeCooldown) {
+		return nil
 	}
-	
-	return nil
+	return err
 }
 
 // Login authenticates a user (borrower or employee)
 // First checks borrower table, then user table (employees)
-// NOTE: 2FA is currently disabled - password-only authentication
-func (s *AuthService) Login(req LoginRequest) (*AuthResponse, error) {
-	// 2FA is disabled - skip verification code check
-	
+// Accounts with TOTP MFA get an MFARequiredError carrying the token for the
+// second step instead of tokens (see mfa.go); other accounts supply an emailed
+// verification code as the 2FA mode requires (see two_factor.go)
+func (s *AuthService) Login(ctx context.Context, req LoginRequest) (*AuthResponse, error) {
 	// Try borrower login first
 	borrower, err := s.borrowerRepo.GetByEmail(req.Email)
 	if err == nil {
 		// Found in borrower table - borrower login
-		// Verify password
-		if !borrower.PasswordHash.Valid {
-			return nil, errors.New("invalid email or password")
-		}
-
-		if !utils.CheckPasswordHash(req.Password, borrower.PasswordHash.String) {
-			return nil, errors.New("invalid email or password")
-		}
-
-		// Generate tokens
 		email := ""
 		if borrower.EmailAddress.Valid {
 			email = borrower.EmailAddress.String
 		}
 
-		accessToken, err := s.jwtManager.GenerateAccessToken(borrower.ID, email)
-		if err != nil {
-			return nil, errors.New("failed to generate access token")
+		// Locked accounts are rejected without checking the password
+		if err := checkLocked(borrower.AccountLockedUntil); err != nil {
+			return nil, err
 		}
 
-		refreshToken, err := s.jwtManager.GenerateRefreshToken(borrower.ID, email)
-		if err != nil {
-			return nil, errors.New("failed to generate refresh token")
+		// Verify password
+		if !borrower.PasswordHash.Valid || !utils.CheckPasswordHash(req.Password, borrower.PasswordHash.String) {
+			if err := s.recordLoginFailure(ctx, s.borrowerRepo, borrower.ID, email); err != nil {
+				return nil, err
+			}
+			return nil, ErrInvalidCredentials
+		}
+
+		// Accounts using MFA finish logging in with their second factor
+		if err := s.mfaChallenge(borrower.ID, email, SubjectTypeApplicant, borrower.MFAEnabled.Bool); err != nil {
+			return nil, err
 		}
+		if err := s.checkLoginCode(ctx, s.borrowerRepo, borrower.ID, email, borrower.TwoFactorEnabled, req.VerificationCode); err != nil {
+			return nil, err
+		}
+		s.recordLoginSuccess(ctx, s.borrowerRepo, borrower.ID)
 
-		return &AuthResponse{
-			AccessToken:  accessToken,
-			RefreshToken: refreshToken,
-			User: UserResponse{
-				ID:        borrower.ID,
-				Email:     email,
-				FirstName: borrower.FirstName,
-				LastName:  borrower.LastName,
-				UserType:  "applicant",
-			},
-		}, nil
+		// Generate tokens
+		return s.borrowerAuthResponse(ctx, "", borrower)
 	}
 
 	// If error is not "not found", it's a database error
@@ -350,135 +377,150 @@ func (s *AuthService) Login(req LoginRequest) (*AuthResponse, error) {
 	user, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("invalid email or password")
+			return nil, ErrInvalidCredentials
 		}
 		return nil, errors.New("failed to check user account")
 	}
 
 	// Found in user table - employee login
-	if user.Status != "active" {
-		return nil, errors.New("account is not active")
+	if err := checkLocked(user.AccountLockedUntil); err != nil {
+		return nil, err
 	}
 
 	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
-		return nil, errors.New("invalid email or password")
+		if err := s.recordLoginFailure(ctx, s.userRepo, user.ID, user.Email); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwtManager.GenerateAccessToken(user.ID, user.Email)
-	if err != nil {
-		return nil, errors.New("failed to generate access token")
+	// Deactivation, SSO enforcement and an admin's demand for a new password are
+	// only revealed after a correct password, so they do not tell who has an
+	// employee account
+	if !user.Active() {
+		return nil, ErrAccountDeactivated
 	}
-
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID, user.Email)
-	if err != nil {
-		return nil, errors.New("failed to generate refresh token")
+	if s.cfg.OIDC.DisablePasswordLogin {
+		return nil, ErrPasswordLoginDisabled
+	}
+	if user.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
 	}
 
-	firstName := ""
-	if user.FirstName.Valid {
-		firstName = user.FirstName.String
+	if err := s.mfaChallenge(user.ID, user.Email, SubjectTypeEmployee, user.MFAEnabled); err != nil {
+		return nil, err
 	}
-	lastName := ""
-	if user.LastName.Valid {
-		lastName = user.LastName.String
+	if err := s.checkLoginCode(ctx, s.userRepo, user.ID, user.Email, user.TwoFactorEnabled, req.VerificationCode); err != nil {
+		return nil, err
 	}
+	s.recordLoginSuccess(ctx, s.userRepo, user.ID)
 
-	return &AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User: UserResponse{
-			ID:        user.ID,
-			Email:     user.Email,
-			FirstName: firstName,
-			LastName:  lastName,
-			Role:      user.Role,
-			UserType:  "employee",
-		},
-	}, nil
+	// Generate tokens
+	return s.employeeAuthResponse(ctx, "", user)
 }
 
-// RefreshToken refreshes an access token using a refresh token
-// Handles both employee and applicant tokens
-func (s *AuthService) RefreshToken(req RefreshRequest) (*AuthResponse, error) {
-	// Validate refresh token
-	claims, err := s.jwtManager.ValidateToken(req.RefreshToken)
+// RefreshToken exchanges a refresh token for a new token pair, rotating the
+// refresh token. Handles both employee and applicant tokens
+func (s *AuthService) RefreshToken(ctx context.Context, req RefreshRequest) (*AuthResponse, error) {
+	// Validate and consume the refresh token
+	session, err := s.sessions.Rotate(ctx, req.RefreshToken)
 	if err != nil {
-		return nil, errors.New("invalid or expired refresh token")
+		return nil, err
 	}
 
-	// Try employee first
-	user, err := s.userRepo.GetByID(claims.UserID)
-	if err == nil {
-		// Found employee - generate new tokens
-		accessToken, err := s.jwtManager.GenerateAccessToken(user.ID, user.Email)
+	if session.SubjectType == SubjectTypeEmployee {
+		user, err := s.userRepo.GetByID(session.SubjectID)
 		if err != nil {
-			return nil, errors.New("failed to generate access token")
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrInvalidRefreshToken
+			}
+			return nil, errors.New("failed to check user account")
 		}
-
-		refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID, user.Email)
-		if err != nil {
-			return nil, errors.New("failed to generate refresh token")
+		if err := checkEmployeeLogin(user); err != nil {
+			return nil, err
 		}
 
-		firstName := ""
-		if user.FirstName.Valid {
-			firstName = user.FirstName.String
-		}
-		lastName := ""
-		if user.LastName.Valid {
-			lastName = user.LastName.String
-		}
+		// Generate new tokens in the same session family
+		return s.employeeAuthResponse(ctx, session.FamilyID, user)
+	}
 
-		return &AuthResponse{
-			AccessToken:  accessToken,
-			RefreshToken: refreshToken,
-			User: UserResponse{
-				ID:        user.ID,
-				Email:     user.Email,
-				FirstName: firstName,
-				LastName:  lastName,
-				Role:      user.Role,
-				UserType:  "employee",
-			},
-		}, nil
-	}
-
-	// Try borrower (UserID is now a UUID string)
-	borrower, err := s.borrowerRepo.GetByID(claims.UserID)
+	borrower, err := s.borrowerRepo.GetByID(session.SubjectID)
 	if err != nil {
-		return nil, errors.New("borrower not found")
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, errors.New("failed to check borrower account")
 	}
 
-	// Generate new tokens
+	// Generate new tokens in the same session family
+	return s.borrowerAuthResponse(ctx, session.FamilyID, borrower)
+}
+
+// borrowerAuthResponse issues a token pair for a borrower in the given session
+// family; an empty familyID starts a new login
+func (s *AuthService) borrowerAuthResponse(ctx context.Context, familyID string, borrower *repositories.Borrower) (*AuthResponse, error) {
 	email := ""
 	if borrower.EmailAddress.Valid {
 		email = borrower.EmailAddress.String
 	}
 
-	accessToken, err := s.jwtManager.GenerateAccessToken(borrower.ID, email)
+	accessToken, refreshToken, err := s.sessions.Issue(ctx, familyID, borrower.ID, SubjectTypeApplicant, email, rbac.RoleApplicant)
 	if err != nil {
-		return nil, errors.New("failed to generate access token")
+		return nil, err
 	}
 
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(borrower.ID, email)
+	return &AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		User: UserResponse{
+			ID:            borrower.ID,
+			Email:         email,
+			FirstName:     borrower.FirstName,
+			LastName:      borrower.LastName,
+			UserType:      "applicant",
+			EmailVerified: borrower.EmailVerified.Bool,
+		},
+	}, nil
+}
+
+// employeeAuthResponse issues a token pair for an employee in the given session
+// family; an empty familyID starts a new login
+func (s *AuthService) employeeAuthResponse(ctx context.Context, familyID string, user *repositories.User) (*AuthResponse, error) {
+	accessToken, refreshToken, err := s.sessions.Issue(ctx, familyID, user.ID, SubjectTypeEmployee, user.Email, rbac.EmployeeRole(user.Role))
 	if err != nil {
-		return nil, errors.New("failed to generate refresh token")
+		return nil, err
+	}
+
+	firstName := ""
+	if user.FirstName.Valid {
+		firstName = user.FirstName.String
+	}
+	lastName := ""
+	if user.LastName.Valid {
+		lastName = user.LastName.String
 	}
 
 	return &AuthResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		User: UserResponse{
-			ID:        borrower.ID,
-			Email:     email,
-			FirstName: borrower.FirstName,
-			LastName:  borrower.LastName,
-			UserType:  "applicant",
+			ID:            user.ID,
+			Email:         user.Email,
+			FirstName:     firstName,
+			LastName:      lastName,
+			Role:          user.Role,
+			UserType:      "employee",
+			EmailVerified: user.EmailVerified.Bool,
 		},
 	}, nil
 }
 
+// Logout revokes the session the access token was issued with, together with
+// every refresh token rotated from the same login
+func (s *AuthService) Logout(ctx context.Context, sessionID string) error {
+	return s.sessions.Revoke(ctx, sessionID)
+}
+
 // CreateEmployeeRequest represents a request to create an employee account (admin only)
 type CreateEmployeeRequest struct {
 	Email     string
@@ -489,17 +531,17 @@ type CreateEmployeeRequest struct {
 }
 
 // CreateEmployee creates a new employee account (admin only)
-func (s *AuthService) CreateEmployee(req CreateEmployeeRequest) (*UserResponse, error) {
+func (s *AuthService) CreateEmployee(ctx context.Context, req CreateEmployeeRequest) (*UserResponse, error) {
 	// Check if user already exists
 	existingUser, _ := s.userRepo.GetByEmail(req.Email)
 	if existingUser != nil {
-		return nil, errors.New("employee with this email already exists")
+		return nil, ErrEmployeeExists
 	}
 
 	// Check if borrower with this email exists
 	existingBorrower, _ := s.borrowerRepo.GetByEmail(req.Email)
 	if existingBorrower != nil {
-		return nil, errors.New("email already registered as borrower")
+		return nil, ErrEmailRegisteredAsBorrower
 	}
 
 	// Hash password
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"taulen/backend/internal/rbac"
+)
+
+func TestRegisterLoginRefreshSaveApplication(t *testing.T) {
+	s := newTestServices(t, nil)
+	ctx := context.Background()
+
+	registered, err := s.auth.Register(ctx, RegisterRequest{
+		Email:     "jane@example.com",
+		Password:  "correct horse",
+		FirstName: "Jane",
+		LastName:  "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if registered.User.UserType != "applicant" || registered.AccessToken == "" || registered.RefreshToken == "" {
+		t.Fatalf("Register returned %+v", registered)
+	}
+
+	if _, err := s.auth.Login(ctx, LoginRequest{Email: "jane@example.com", Password: "wrong password"}); err == nil {
+		t.Fatal("Login with a wrong password succeeded")
+	}
+	loggedIn, err := s.auth.Login(ctx, LoginRequest{Email: "jane@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if loggedIn.User.ID != registered.User.ID {
+		t.Fatalf("Login returned user %s, want %s", loggedIn.User.ID, registered.User.ID)
+	}
+
+	refreshed, err := s.auth.RefreshToken(ctx, RefreshRequest{RefreshToken: loggedIn.RefreshToken})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if refreshed.RefreshToken == loggedIn.RefreshToken {
+		t.Fatal("RefreshToken did not rotate the refresh token")
+	}
+	if _, err := s.auth.RefreshToken(ctx, RefreshRequest{RefreshToken: loggedIn.RefreshToken}); err == nil {
+		t.Fatal("reusing a rotated refresh token succeeded")
+	}
+	if _, err := s.auth.RefreshToken(ctx, RefreshRequest{RefreshToken: refreshed.RefreshToken}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("refresh after reuse detection: got %v, want ErrInvalidRefreshToken", err)
+	}
+
+	borrowerID := registered.User.ID
+	app, err := s.urla.CreateApplicationForBorrower(ctx, borrowerID, CreateApplicationRequest{
+		LoanType:    "Conventional",
+		LoanPurpose: "Purchase",
+		LoanAmount:  350000,
+	})
+	if err != nil {
+		t.Fatalf("CreateApplicationForBorrower: %v", err)
+	}
+
+	err = s.urla.SaveApplication(ctx, app.ID, NewEditor(borrowerID, rbac.RoleApplicant, ""), SaveApplicationRequest{
+		Borrower:          map[string]interface{}{"firstName": "Janet", "middleName": "Q"},
+		CompletedSections: []string{"Section1a_PersonalInfo"},
+		NextFormStep:      "borrower-info-2",
+	})
+	if err != nil {
+		t.Fatalf("SaveApplication: %v", err)
+	}
+
+	saved, err := s.urla.GetApplication(ctx, app.ID)
+	if err != nil {
+		t.Fatalf("GetApplication: %v", err)
+	}
+	borrower, _ := saved["borrower"].(map[string]interface{})
+	if borrower["firstName"] != "Janet" || borrower["middleName"] != "Q" {
+		t.Fatalf("saved borrower = %v", borrower)
+	}
+	if saved["currentFormStep"] != "borrower-info-2" {
+		t.Fatalf("currentFormStep = %v, want borrower-info-2", saved["currentFormStep"])
+	}
+	progress, err := s.store.DealProgress().GetByDealID(app.ID)
+	if err != nil {
+		t.Fatalf("GetByDealID: %v", err)
+	}
+	if !progress.Section1aComplete {
+		t.Fatal("Section1a_PersonalInfo was not marked complete")
+	}
+}
+
+func TestSaveApplicationRollsBackOnFailure(t *testing.T) {
+	s := newTestServices(t, nil)
+	ctx := context.Background()
+
+	registered, err := s.auth.Register(ctx, RegisterRequest{
+		Email:     "jane@example.com",
+		Password:  "correct horse",
+		FirstName: "Jane",
+		LastName:  "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	app, err := s.urla.CreateApplicationForBorrower(ctx, registered.User.ID, CreateApplicationRequest{
+		LoanType:    "Conventional",
+		LoanPurpose: "Purchase",
+		LoanAmount:  350000,
+	})
+	if err != nil {
+		t.Fatalf("CreateApplicationForBorrower: %v", err)
+	}
+
+	// The borrower section is written before the unknown progress section fails
+	err = s.urla.SaveApplication(ctx, app.ID, NewEditor(registered.User.ID, rbac.RoleApplicant, ""), SaveApplicationRequest{
+		Borrower:          map[string]interface{}{"firstName": "Janet"},
+		CompletedSections: []string{"noSuchSection"},
+	})
+	if err == nil {
+		t.Fatal("SaveApplication with an unknown section succeeded")
+	}
+
+	saved, err := s.urla.GetApplication(ctx, app.ID)
+	if err != nil {
+		t.Fatalf("GetApplication: %v", err)
+	}
+	borrower, _ := saved["borrower"].(map[string]interface{})
+	if borrower["firstName"] != "Jane" {
+		t.Fatalf("firstName = %v after a failed save, want Jane", borrower["firstName"])
+	}
+}
@@ -1,39 +1,66 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
 	"taulen/backend/internal/config"
+	"taulen/backend/internal/metrics"
+	"taulen/backend/internal/notify"
+	"taulen/backend/internal/rbac"
 	"taulen/backend/internal/repositories"
 	"taulen/backend/internal/utils"
 )
 
 // BorrowerService handles borrower-related operations
 type BorrowerService struct {
-	dealRepo     *repositories.DealRepository
-	borrowerRepo *repositories.BorrowerRepository
-	jwtManager   *utils.JWTManager
-	appService   *ApplicationService
+	dealRepo          repositories.DealRepository
+	borrowerRepo      repositories.BorrowerRepository
+	sessions          *SessionService
+	appService        *ApplicationService
+	emailVerification *EmailVerificationService
+	verification      *VerificationService
+	cfg               *config.Config
+	logger            *slog.Logger
 }
 
 // NewBorrowerService creates a new borrower service
-func NewBorrowerService(cfg *config.Config) *BorrowerService {
+func NewBorrowerService(cfg *config.Config, store repositories.Store, notifier notify.Notifier, logger *slog.Logger) *BorrowerService {
 	return &BorrowerService{
-		dealRepo:     repositories.NewDealRepository(),
-		borrowerRepo: repositories.NewBorrowerRepository(),
-		jwtManager:   utils.NewJWTManager(&cfg.JWT),
-		appService:   NewApplicationService(),
+		dealRepo:          store.Deals(),
+		borrowerRepo:      store.Borrowers(),
+		sessions:          NewSessionService(cfg, store, notifier, logger),
+		appService:        NewApplicationService(store, logger),
+		emailVerification: NewEmailVerificationService(cfg, store, notifier, logger),
+		verification:      NewVerificationService(cfg, store, notifier, logger),
+		cfg:               cfg,
+		logger:            logger,
+	}
+}
+
+// withStore returns a copy of the service whose repositories use the given store
+func (s *BorrowerService) withStore(store repositories.Store) *BorrowerService {
+	return &BorrowerService{
+		dealRepo:          store.Deals(),
+		borrowerRepo:      store.Borrowers(),
+		sessions:          s.sessions.withStore(store),
+		appService:        s.appService.withStore(store),
+		emailVerification: s.emailVerification,
+		verification:      s.verification,
+		cfg:               s.cfg,
+		logger:            s.logger,
 	}
 }
 
 // VerifyAndCreateBorrower verifies the code and creates borrower account with deal
 // Returns auth tokens and application data for seamless login
-// NOTE: 2FA is currently disabled - verification code is optional
-func (s *BorrowerService) VerifyAndCreateBorrower(req VerifyAndCreateBorrowerRequest) (*VerifyAndCreateBorrowerResponse, error) {
+// The code sent by SendVerificationCode must be supplied in required mode and is
+// checked whenever it is supplied in optional mode.
+func (s *BorrowerService) VerifyAndCreateBorrower(ctx context.Context, req VerifyAndCreateBorrowerRequest) (*VerifyAndCreateBorrowerResponse, error) {
 	// Check if borrower already exists by email
 	existingBorrowerByEmail, err := s.borrowerRepo.GetByEmail(req.Email)
 	if err != nil && err != sql.ErrNoRows {
@@ -48,10 +75,22 @@ func (s *BorrowerService) VerifyAndCreateBorrower(req VerifyAndCreateBorrowerReq
 
 	// If borrower exists by email or phone, return error
 	if existingBorrowerByEmail != nil {
-		return nil, errors.New("borrower with this email already exists")
+		return nil, &ValidationError{Message: "borrower with this email already exists"}
 	}
 	if existingBorrowerByPhone != nil {
-		return nil, errors.New("borrower with this phone number already exists")
+		return nil, &ValidationError{Message: "borrower with this phone number already exists"}
+	}
+
+	verify, err := shouldVerifyCode(s.cfg, false, req.VerificationCode)
+	if err != nil {
+		return nil, err
+	}
+	emailConfirmed := false
+	if verify {
+		emailConfirmed, err = s.verification.checkPreApplicationCode(req.VerificationMethod, req.Email, req.Phone, req.VerificationCode)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Hash password
@@ -114,15 +153,18 @@ func (s *BorrowerService) VerifyAndCreateBorrower(req VerifyAndCreateBorrowerReq
 	if borrower.EmailAddress.Valid {
 		email = borrower.EmailAddress.String
 	}
-
-	accessToken, err := s.jwtManager.GenerateAccessToken(borrowerID, email)
-	if err != nil {
-		return nil, errors.New("failed to generate access token")
+	if emailConfirmed {
+		// The code proved ownership of the email
+		if err := s.borrowerRepo.MarkEmailVerified(borrowerID); err != nil {
+			s.logger.WarnContext(ctx, "VerifyAndCreateBorrower: failed to mark email verified", "borrower_id", borrowerID, "error", err)
+		}
+	} else {
+		s.emailVerification.SendLink(ctx, borrowerID, email)
 	}
 
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(borrowerID, email)
+	accessToken, refreshToken, err := s.sessions.Issue(ctx, "", borrowerID, SubjectTypeApplicant, email, rbac.RoleApplicant)
 	if err != nil {
-		return nil, errors.New("failed to generate refresh token")
+		return nil, err
 	}
 
 	// Update borrower with date of birth if provided
@@ -207,11 +249,12 @@ func (s *BorrowerService) VerifyAndCreateBorrower(req VerifyAndCreateBorrowerReq
 	if err != nil {
 		return nil, errors.New("failed to create application")
 	}
+	metrics.ApplicationCreated(metrics.SourcePreApplication)
 
 	// Set initial form step to borrower-info-2 (next form after borrower-info-1)
-	err = s.appService.UpdateCurrentFormStep(dealID, "borrower-info-2")
+	err = s.appService.UpdateCurrentFormStep(ctx, dealID, "borrower-info-2")
 	if err != nil {
-		log.Printf("VerifyAndCreateBorrower: Failed to set initial form step: %v", err)
+		s.logger.WarnContext(ctx, "VerifyAndCreateBorrower: failed to set initial form step", "deal_id", dealID, "error", err)
 		// Don't fail the entire operation if step update fails
 	}
 
@@ -256,43 +299,13 @@ func (s *BorrowerService) VerifyAndCreateBorrower(req VerifyAndCreateBorrowerReq
 
 // SaveBorrowerData saves borrower information from the form
 // nextFormStep is the form step to navigate to after saving (e.g., "borrower-info-2", "co-borrower-question")
-func (s *BorrowerService) SaveBorrowerData(dealID string, borrowerData map[string]interface{}, nextFormStep string) error {
+func (s *BorrowerService) SaveBorrowerData(ctx context.Context, dealID string, borrowerData map[string]interface{}, nextFormStep string) error {
 	// Get the deal to find the borrower ID
-	dealRow, err := s.dealRepo.GetDealByID(dealID)
-	if err != nil {
-		return errors.New("deal not found")
-	}
-
-	// Scan the deal row to get primary_borrower_id
-	var deal struct {
-		ID                      string
-		LoanNumber              sql.NullString
-		UniversalLoanIdentifier sql.NullString
-		AgencyCaseIdentifier    sql.NullString
-		ApplicationType         sql.NullString
-		TotalBorrowers          sql.NullInt64
-		ApplicationDate         sql.NullTime
-		CreatedAt               sql.NullTime
-		PrimaryBorrowerID       sql.NullString
-		CurrentFormStep         sql.NullString
-		LoanID                  sql.NullString
-		LoanPurposeType         sql.NullString
-		LoanAmountRequested     sql.NullFloat64
-		LoanTermMonths          sql.NullInt64
-		InterestRatePercentage  sql.NullFloat64
-		PropertyType            sql.NullString
-		ManufacturedHomeWidth   sql.NullString
-		TitleMannerType         sql.NullString
-	}
-
-	err = dealRow.Scan(
-		&deal.ID, &deal.LoanNumber, &deal.UniversalLoanIdentifier, &deal.AgencyCaseIdentifier,
-		&deal.ApplicationType, &deal.TotalBorrowers, &deal.ApplicationDate, &deal.CreatedAt, &deal.PrimaryBorrowerID,
-		&deal.CurrentFormStep,
-		&deal.LoanID, &deal.LoanPurposeType, &deal.LoanAmountRequested, &deal.LoanTermMonths,
-		&deal.InterestRatePercentage, &deal.PropertyType, &deal.ManufacturedHomeWidth, &deal.TitleMannerType,
-	)
+	deal, err := s.dealRepo.GetDealByID(dealID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("deal not found")
+		}
 		return errors.New("failed to read deal: " + err.Error())
 	}
 
@@ -360,7 +373,7 @@ func (s *BorrowerService) SaveBorrowerData(dealID string, borrowerData map[strin
 	if firstName != nil || lastName != nil {
 		err = s.borrowerRepo.UpdateBorrowerName(borrowerID, firstName, lastName)
 		if err != nil {
-			log.Printf("SaveBorrowerData: Warning - failed to update borrower name: %v", err)
+			return errors.New("failed to update borrower name: " + err.Error())
 		}
 	}
 	
@@ -368,7 +381,7 @@ func (s *BorrowerService) SaveBorrowerData(dealID string, borrowerData map[strin
 	if middleName != nil || suffix != nil || phone != nil || phoneType != nil {
 		err = s.borrowerRepo.UpdateBorrowerDetails(borrowerID, middleName, suffix, nil, phone, phoneType)
 		if err != nil {
-			log.Printf("SaveBorrowerData: Warning - failed to update borrower details: %v", err)
+			return errors.New("failed to update borrower details: " + err.Error())
 		}
 	}
 	
@@ -376,15 +389,24 @@ func (s *BorrowerService) SaveBorrowerData(dealID string, borrowerData map[strin
 	if homePhone != nil || mobilePhone != nil || workPhone != nil || workPhoneExt != nil {
 		err = s.borrowerRepo.UpdateBorrowerAdditionalPhones(borrowerID, homePhone, mobilePhone, workPhone, workPhoneExt)
 		if err != nil {
-			log.Printf("SaveBorrowerData: Warning - failed to update borrower additional phones: %v", err)
+			return errors.New("failed to update borrower additional phones: " + err.Error())
 		}
 	}
 	
-	// Update email if provided
+	// Update email if provided; a new address has to be verified again. Should
+	// the save be rolled back, the link names an address the borrower doesn't
+	// have and is rejected.
 	if email != nil {
+		current, err := s.borrowerRepo.GetByID(borrowerID)
+		if err != nil {
+			return errors.New("failed to read borrower: " + err.Error())
+		}
 		err = s.borrowerRepo.UpdateEmail(borrowerID, *email)
 		if err != nil {
-			log.Printf("SaveBorrowerData: Warning - failed to update borrower email: %v", err)
+			return errors.New("failed to update borrower email: " + err.Error())
+		}
+		if !strings.EqualFold(current.EmailAddress.String, *email) {
+			s.emailVerification.SendLink(ctx, borrowerID, *email)
 		}
 	}
 	
@@ -392,7 +414,7 @@ func (s *BorrowerService) SaveBorrowerData(dealID string, borrowerData map[strin
 	if ssn != nil {
 		err = s.borrowerRepo.UpdateBorrowerSSN(borrowerID, *ssn)
 		if err != nil {
-			log.Printf("SaveBorrowerData: Warning - failed to update borrower SSN: %v", err)
+			return errors.New("failed to update borrower SSN: " + err.Error())
 		}
 	}
 	
@@ -402,7 +424,7 @@ func (s *BorrowerService) SaveBorrowerData(dealID string, borrowerData map[strin
 		if err == nil {
 			err = s.borrowerRepo.UpdateBorrowerInfo(borrowerID, &birthDate)
 			if err != nil {
-				log.Printf("SaveBorrowerData: Warning - failed to update borrower date of birth: %v", err)
+				return errors.New("failed to update borrower date of birth: " + err.Error())
 			}
 		}
 	}
@@ -411,7 +433,7 @@ func (s *BorrowerService) SaveBorrowerData(dealID string, borrowerData map[strin
 	if citizenshipType != nil {
 		err = s.borrowerRepo.UpdateBorrowerCitizenship(borrowerID, *citizenshipType)
 		if err != nil {
-			log.Printf("SaveBorrowerData: Warning - failed to update borrower citizenship: %v", err)
+			return errors.New("failed to update borrower citizenship: " + err.Error())
 		}
 	}
 	
@@ -421,7 +443,7 @@ func (s *BorrowerService) SaveBorrowerData(dealID string, borrowerData map[strin
 		if err == nil {
 			err = s.borrowerRepo.UpdateBorrowerDependents(borrowerID, count)
 			if err != nil {
-				log.Printf("SaveBorrowerData: Warning - failed to update borrower dependents: %v", err)
+				return errors.New("failed to update borrower dependents: " + err.Error())
 			}
 		}
 	}
@@ -516,15 +538,13 @@ func (s *BorrowerService) SaveBorrowerData(dealID string, borrowerData map[strin
 			// Delete existing former residences for this borrower (we'll replace with new one)
 			err = s.borrowerRepo.DeleteFormerResidences(borrowerID)
 			if err != nil {
-				log.Printf("SaveBorrowerData: Warning - failed to delete existing former residences: %v", err)
-				// Continue anyway
+				return errors.New("failed to delete existing former residences: " + err.Error())
 			}
 			
 			// Create new former residence record
 			err = s.borrowerRepo.CreateFormerResidence(borrowerID, prevAddr, prevCity, prevState, prevZip, prevYears, prevMonths, prevHousingStatus)
 			if err != nil {
-				log.Printf("SaveBorrowerData: Warning - failed to save previous address: %v", err)
-				// Don't fail the entire operation if previous address save fails
+				return errors.New("failed to save previous address: " + err.Error())
 			}
 		}
 	}
@@ -542,6 +562,20 @@ func (s *BorrowerService) SaveBorrowerData(dealID string, borrowerData map[strin
 		consentToContact = &val
 	}
 
+	// Only a borrower with a verified email may consent to a credit check;
+	// saves that send back a consent already given pass
+	if consentToCreditCheck != nil && *consentToCreditCheck {
+		current, err := s.borrowerRepo.GetByID(borrowerID)
+		if err != nil {
+			return errors.New("failed to read borrower: " + err.Error())
+		}
+		if !current.ConsentToCreditCheck.Bool {
+			if err := requireVerifiedEmail(s.borrowerRepo, borrowerID); err != nil {
+				return err
+			}
+		}
+	}
+
 	if militaryServiceStatus != nil || consentToCreditCheck != nil || consentToContact != nil {
 		err = s.borrowerRepo.UpdateBorrowerConsentsAndMilitary(borrowerID, militaryServiceStatus, consentToCreditCheck, consentToContact)
 		if err != nil {
@@ -551,10 +585,9 @@ func (s *BorrowerService) SaveBorrowerData(dealID string, borrowerData map[strin
 
 	// Update current form step if provided
 	if nextFormStep != "" {
-		err = s.appService.UpdateCurrentFormStep(dealID, nextFormStep)
+		err = s.appService.UpdateCurrentFormStep(ctx, dealID, nextFormStep)
 		if err != nil {
-			log.Printf("SaveBorrowerData: Failed to update current form step: %v", err)
-			// Don't fail the entire operation if step update fails
+			return errors.New("failed to update current form step: " + err.Error())
 		}
 	}
 
@@ -1,9 +1,10 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"log/slog"
 	"strings"
 	"taulen/backend/internal/config"
 	"taulen/backend/internal/repositories"
@@ -11,59 +12,42 @@ import (
 
 // CoBorrowerService handles co-borrower-related operations
 type CoBorrowerService struct {
-	dealRepo     *repositories.DealRepository
-	borrowerRepo *repositories.BorrowerRepository
+	dealRepo     repositories.DealRepository
+	borrowerRepo repositories.BorrowerRepository
 	appService   *ApplicationService
+	logger       *slog.Logger
 }
 
 // NewCoBorrowerService creates a new co-borrower service
-func NewCoBorrowerService(cfg *config.Config) *CoBorrowerService {
+func NewCoBorrowerService(cfg *config.Config, store repositories.Store, logger *slog.Logger) *CoBorrowerService {
 	return &CoBorrowerService{
-		dealRepo:     repositories.NewDealRepository(),
-		borrowerRepo: repositories.NewBorrowerRepository(),
-		appService:   NewApplicationService(),
+		dealRepo:     store.Deals(),
+		borrowerRepo: store.Borrowers(),
+		appService:   NewApplicationService(store, logger),
+		logger:       logger,
+	}
+}
+
+// withStore returns a copy of the service whose repositories use the given store
+func (s *CoBorrowerService) withStore(store repositories.Store) *CoBorrowerService {
+	return &CoBorrowerService{
+		dealRepo:     store.Deals(),
+		borrowerRepo: store.Borrowers(),
+		appService:   s.appService.withStore(store),
+		logger:       s.logger,
 	}
 }
 
 // SaveCoBorrowerData saves co-borrower information and links them to the deal
 // nextFormStep is the form step to navigate to after saving (e.g., "getting-to-know-you-intro")
-func (s *CoBorrowerService) SaveCoBorrowerData(dealID string, coBorrowerData map[string]interface{}, nextFormStep string) error {
+func (s *CoBorrowerService) SaveCoBorrowerData(ctx context.Context, dealID string, coBorrowerData map[string]interface{}, nextFormStep string) error {
 	// First, check if a co-borrower already exists for this deal
 	// Get the deal to find the primary borrower ID
-	dealRow, err := s.dealRepo.GetDealByID(dealID)
-	if err != nil {
-		return errors.New("deal not found")
-	}
-
-	var deal struct {
-		ID                      string
-		LoanNumber              sql.NullString
-		UniversalLoanIdentifier sql.NullString
-		AgencyCaseIdentifier    sql.NullString
-		ApplicationType         sql.NullString
-		TotalBorrowers          sql.NullInt64
-		ApplicationDate         sql.NullTime
-		CreatedAt               sql.NullTime
-		PrimaryBorrowerID       sql.NullString
-		CurrentFormStep         sql.NullString
-		LoanID                  sql.NullString
-		LoanPurposeType         sql.NullString
-		LoanAmountRequested     sql.NullFloat64
-		LoanTermMonths          sql.NullInt64
-		InterestRatePercentage  sql.NullFloat64
-		PropertyType            sql.NullString
-		ManufacturedHomeWidth   sql.NullString
-		TitleMannerType         sql.NullString
-	}
-
-	err = dealRow.Scan(
-		&deal.ID, &deal.LoanNumber, &deal.UniversalLoanIdentifier, &deal.AgencyCaseIdentifier,
-		&deal.ApplicationType, &deal.TotalBorrowers, &deal.ApplicationDate, &deal.CreatedAt, &deal.PrimaryBorrowerID,
-		&deal.CurrentFormStep,
-		&deal.LoanID, &deal.LoanPurposeType, &deal.LoanAmountRequested, &deal.LoanTermMonths,
-		&deal.InterestRatePercentage, &deal.PropertyType, &deal.ManufacturedHomeWidth, &deal.TitleMannerType,
-	)
+	deal, err := s.dealRepo.GetDealByID(dealID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("deal not found")
+		}
 		return errors.New("failed to read deal: " + err.Error())
 	}
 
@@ -78,9 +62,9 @@ func (s *CoBorrowerService) SaveCoBorrowerData(dealID string, coBorrowerData map
 		if err == nil && len(coBorrowers) > 0 {
 			existingCoBorrower = coBorrowers[0]
 			coBorrowerID = existingCoBorrower.ID
-			log.Printf("SaveCoBorrowerData: Found existing co-borrower for deal: ID=%s (from borrower_progress)", coBorrowerID)
+			s.logger.DebugContext(ctx, "SaveCoBorrowerData: found existing co-borrower for deal", "co_borrower_id", coBorrowerID)
 		} else if err != nil {
-			log.Printf("SaveCoBorrowerData: Error checking for existing co-borrower for deal: %v", err)
+			return errors.New("failed to check for existing co-borrower: " + err.Error())
 		}
 	}
 
@@ -105,10 +89,9 @@ func (s *CoBorrowerService) SaveCoBorrowerData(dealID string, coBorrowerData map
 			if err == nil && existingBorrowerByEmailOrPhone != nil {
 				existingCoBorrower = existingBorrowerByEmailOrPhone
 				coBorrowerID = existingCoBorrower.ID
-				log.Printf("SaveCoBorrowerData: Found existing borrower by email/phone: ID=%s, email=%s, phone=%s", 
-					coBorrowerID, emailForLookup, phoneForLookup)
+				s.logger.DebugContext(ctx, "SaveCoBorrowerData: found existing borrower by email or phone", "co_borrower_id", coBorrowerID)
 			} else if err != nil && err != sql.ErrNoRows {
-				log.Printf("SaveCoBorrowerData: Error checking for existing borrower by email/phone: %v", err)
+				return errors.New("failed to check for existing borrower by email/phone: " + err.Error())
 			}
 		}
 	}
@@ -121,8 +104,6 @@ func (s *CoBorrowerService) SaveCoBorrowerData(dealID string, coBorrowerData map
 	var isVeteran bool
 	var address, city, state, zipCode string
 
-	log.Printf("SaveCoBorrowerData: Starting for dealID=%s, existingCoBorrower=%v", dealID, existingCoBorrower != nil)
-
 	// Only extract firstName, lastName, email, phone if we're creating a NEW co-borrower (co-borrower-info-1)
 	// In co-borrower-info-2, these fields should NOT be present in the payload
 	if existingCoBorrower == nil {
@@ -178,19 +159,15 @@ func (s *CoBorrowerService) SaveCoBorrowerData(dealID string, coBorrowerData map
 	if val, ok := coBorrowerData["maritalStatus"].(string); ok && val != "" {
 		// Normalize marital status to match database constraint (capitalized: "Married", "Separated", "Unmarried")
 		maritalStatus = normalizeMaritalStatus(val)
-		log.Printf("SaveCoBorrowerData: Marital status provided: '%s'", maritalStatus)
 	} else if existingCoBorrower == nil {
 		// For new co-borrower (co-borrower-info-1), marital status is optional
 		// It will be set in co-borrower-info-2
 		maritalStatus = "" // Leave empty - will be NULL in database, same as borrower-info-1
-		log.Printf("SaveCoBorrowerData: No marital status provided for new co-borrower, will be NULL (same as borrower-info-1)")
 	} else if existingCoBorrower.MaritalStatus.Valid {
 		maritalStatus = existingCoBorrower.MaritalStatus.String
-		log.Printf("SaveCoBorrowerData: Using existing marital status: '%s'", maritalStatus)
 	} else {
 		// Existing co-borrower but no marital status - leave empty
 		maritalStatus = ""
-		log.Printf("SaveCoBorrowerData: Existing co-borrower has no marital status, leaving empty")
 	}
 	if val, ok := coBorrowerData["isVeteran"].(bool); ok {
 		isVeteran = val
@@ -216,7 +193,7 @@ func (s *CoBorrowerService) SaveCoBorrowerData(dealID string, coBorrowerData map
 	if existingCoBorrower != nil {
 		// Updating existing co-borrower - address should be provided
 		if address == "" || city == "" || state == "" || zipCode == "" {
-			return errors.New("co-borrower address is required (street, city, state, and zip code)")
+			return &ValidationError{Message: "co-borrower address is required (street, city, state, and zip code)"}
 		}
 	}
 	// For new co-borrower (co-borrower-info-1), address is optional - no validation needed
@@ -245,7 +222,6 @@ func (s *CoBorrowerService) SaveCoBorrowerData(dealID string, coBorrowerData map
 
 		// Note: borrower_progress will be ensured in the final ensure step below
 		// This ensures it's always created even if this branch is skipped
-		log.Printf("SaveCoBorrowerData: Existing borrower ID=%s will be linked in final ensure step", coBorrowerID)
 	} else {
 		// Create new co-borrower record
 		// This should only happen in co-borrower-info-1, not co-borrower-info-2
@@ -254,29 +230,25 @@ func (s *CoBorrowerService) SaveCoBorrowerData(dealID string, coBorrowerData map
 		
 		// Validate required fields for new co-borrower
 		if firstName == "" {
-			return errors.New("co-borrower first name is required when creating a new co-borrower")
+			return &ValidationError{Message: "co-borrower first name is required when creating a new co-borrower"}
 		}
 		if lastName == "" {
-			return errors.New("co-borrower last name is required when creating a new co-borrower")
+			return &ValidationError{Message: "co-borrower last name is required when creating a new co-borrower"}
 		}
 		if phone == "" {
-			return errors.New("co-borrower phone is required when creating a new co-borrower")
+			return &ValidationError{Message: "co-borrower phone is required when creating a new co-borrower"}
 		}
 		
 		// Marital status can be empty (will be NULL in database) - same behavior as borrower-info-1
 		// It will be set in co-borrower-info-2
-		log.Printf("SaveCoBorrowerData: Creating new co-borrower - firstName='%s', lastName='%s', email='%s', phone='%s', phoneType='%s', maritalStatus='%s' (empty means NULL), isVeteran=%v",
-			firstName, lastName, email, phone, phoneType, maritalStatus, isVeteran)
-
 		coBorrowerID, err = s.borrowerRepo.CreateCoBorrower(firstName, lastName, middleName, suffix, email, phone, phoneType, maritalStatus, isVeteran)
 		if err != nil {
-			log.Printf("SaveCoBorrowerData: Error creating co-borrower: %v", err)
-			log.Printf("SaveCoBorrowerData: Error details - firstName='%s', lastName='%s', email='%s', phone='%s', phoneType='%s', maritalStatus='%s' (empty=%v), isVeteran=%v", 
-				firstName, lastName, email, phone, phoneType, maritalStatus, maritalStatus == "", isVeteran)
+			s.logger.ErrorContext(ctx, "SaveCoBorrowerData: failed to create co-borrower",
+				"phone_type", phoneType, "has_email", email != "", "has_marital_status", maritalStatus != "", "error", err)
 			return errors.New("failed to create co-borrower: " + err.Error())
 		}
 
-		log.Printf("SaveCoBorrowerData: Successfully created co-borrower with ID=%s", coBorrowerID)
+		s.logger.InfoContext(ctx, "SaveCoBorrowerData: created co-borrower", "co_borrower_id", coBorrowerID)
 	}
 
 	// CRITICAL: Always ensure borrower_progress entry exists for this co-borrower and deal
@@ -288,11 +260,10 @@ func (s *CoBorrowerService) SaveCoBorrowerData(dealID string, coBorrowerData map
 	}
 	err = s.borrowerRepo.LinkBorrowerToDeal(coBorrowerID, dealID)
 	if err != nil {
-		log.Printf("SaveCoBorrowerData: CRITICAL ERROR - Failed to ensure borrower_progress entry exists for borrower %s and deal %s: %v", coBorrowerID, dealID, err)
+		s.logger.ErrorContext(ctx, "SaveCoBorrowerData: failed to link co-borrower to deal", "co_borrower_id", coBorrowerID, "error", err)
 		// This is critical - return error to ensure borrower_progress is created
 		return errors.New("failed to link co-borrower to deal: " + err.Error())
 	}
-	log.Printf("SaveCoBorrowerData: Ensured borrower_progress entry exists for borrower %s and deal %s", coBorrowerID, dealID)
 
 	// Save address if provided (optional for co-borrower-info-1, required for co-borrower-info-2)
 	if address != "" && city != "" && state != "" && zipCode != "" {
@@ -310,10 +281,9 @@ func (s *CoBorrowerService) SaveCoBorrowerData(dealID string, coBorrowerData map
 
 	// Update current form step if provided
 	if nextFormStep != "" {
-		err = s.appService.UpdateCurrentFormStep(dealID, nextFormStep)
+		err = s.appService.UpdateCurrentFormStep(ctx, dealID, nextFormStep)
 		if err != nil {
-			log.Printf("SaveCoBorrowerData: Failed to update current form step: %v", err)
-			// Don't fail the entire operation if step update fails
+			return errors.New("failed to update current form step: " + err.Error())
 		}
 	}
 
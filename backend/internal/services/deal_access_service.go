@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/repositories"
+	"taulen/backend/internal/utils"
+)
+
+// DealRelation describes why a user may access a deal
+type DealRelation string
+
+// Deal relations
+const (
+	DealRelationPrimaryBorrower  DealRelation = "primary_borrower"
+	DealRelationCoBorrower       DealRelation = "co_borrower"
+	DealRelationAssignedEmployee DealRelation = "assigned_employee"
+	DealRelationAdmin            DealRelation = "admin"
+	DealRelationIntegration      DealRelation = "integration" // API key; its scopes limit what it may do
+)
+
+var (
+	// ErrDealNotFound is returned when the deal does not exist
+	ErrDealNotFound = errors.New("application not found")
+	// ErrDealAccessDenied is returned when the user has no relation to the deal
+	ErrDealAccessDenied = errors.New("you do not have access to this application")
+)
+
+// DealAccessService decides whether a user may access a deal: the primary
+// borrower, co-borrowers linked through borrower_progress, employees assigned
+// through deal_assignment, admins and API keys may; everyone else may not
+type DealAccessService struct {
+	dealRepo     repositories.DealRepository
+	borrowerRepo repositories.BorrowerRepository
+}
+
+// NewDealAccessService creates a new deal access service
+func NewDealAccessService(store repositories.Store) *DealAccessService {
+	return &DealAccessService{
+		dealRepo:     store.Deals(),
+		borrowerRepo: store.Borrowers(),
+	}
+}
+
+// ResolveAccess returns how the user with the given ID and role is related to
+// the deal, ErrDealNotFound if the deal does not exist, or ErrDealAccessDenied
+func (s *DealAccessService) ResolveAccess(ctx context.Context, dealID, userID string, role rbac.Role) (DealRelation, error) {
+	if !utils.IsUUID(dealID) {
+		return "", ErrDealNotFound
+	}
+	deal, err := s.dealRepo.GetDealByID(dealID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrDealNotFound
+		}
+		return "", errors.New("failed to load application: " + err.Error())
+	}
+
+	switch {
+	case role == rbac.RoleApplicant:
+		if deal.PrimaryBorrowerID.Valid && deal.PrimaryBorrowerID.String == userID {
+			return DealRelationPrimaryBorrower, nil
+		}
+		linked, err := s.borrowerRepo.IsLinkedToDeal(userID, dealID)
+		if err != nil {
+			return "", errors.New("failed to check co-borrower access: " + err.Error())
+		}
+		if linked {
+			return DealRelationCoBorrower, nil
+		}
+	case role == rbac.RoleAdmin:
+		return DealRelationAdmin, nil
+	case role == rbac.RoleIntegration:
+		return DealRelationIntegration, nil
+	case role.IsEmployee():
+		assigned, err := s.dealRepo.IsUserAssigned(dealID, userID)
+		if err != nil {
+			return "", errors.New("failed to check employee assignment: " + err.Error())
+		}
+		if assigned {
+			return DealRelationAssignedEmployee, nil
+		}
+	}
+	return "", ErrDealAccessDenied
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/utils"
+)
+
+// Employees reach an application through deal_assignment (see
+// DealAccessService). The employee who creates an application is assigned to
+// it; applications that borrowers start have nobody assigned until an admin, or
+// an employee already on the application with the assign permission, assigns
+// one.
+
+// ErrAssigneeNotEmployee is returned when assigning someone who is not an
+// active employee
+var ErrAssigneeNotEmployee = errors.New("only active employees can be assigned to an application")
+
+// AssignEmployeeRequest represents a request to assign an employee to an application
+type AssignEmployeeRequest struct {
+	UserID string `json:"userId" binding:"required"`
+}
+
+// AssigneeResponse describes an employee assigned to an application
+type AssigneeResponse struct {
+	ID        string `json:"id"`
+	Email     string `json:"email"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Role      string `json:"role"`
+}
+
+// ListAssignees returns the employees assigned to an application
+func (s *URLAService) ListAssignees(ctx context.Context, dealID string) ([]AssigneeResponse, error) {
+	userIDs, err := s.store.Deals().ListAssignees(dealID)
+	if err != nil {
+		return nil, errors.New("failed to list assignees: " + err.Error())
+	}
+
+	assignees := make([]AssigneeResponse, 0, len(userIDs))
+	for _, userID := range userIDs {
+		user, err := s.store.Users().GetByID(userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return nil, errors.New("failed to load assignee: " + err.Error())
+		}
+		assignees = append(assignees, AssigneeResponse{
+			ID:        user.ID,
+			Email:     user.Email,
+			FirstName: user.FirstName.String,
+			LastName:  user.LastName.String,
+			Role:      string(rbac.EmployeeRole(user.Role)),
+		})
+	}
+	return assignees, nil
+}
+
+// AssignEmployee assigns the active employee userID to an application on
+// behalf of assignerID and returns the application's assignees
+func (s *URLAService) AssignEmployee(ctx context.Context, dealID, assignerID, userID string) ([]AssigneeResponse, error) {
+	if !utils.IsUUID(userID) {
+		return nil, ErrAssigneeNotEmployee
+	}
+	user, err := s.store.Users().GetByID(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAssigneeNotEmployee
+		}
+		return nil, errors.New("failed to load employee: " + err.Error())
+	}
+	if !user.Active() || rbac.EmployeeRole(user.Role) == "" {
+		return nil, ErrAssigneeNotEmployee
+	}
+
+	if err := s.store.Deals().AssignUser(dealID, userID); err != nil {
+		return nil, errors.New("failed to assign employee: " + err.Error())
+	}
+	s.logger.InfoContext(ctx, "urla: employee assigned", "deal_id", dealID, "employee_id", userID, "assigned_by", assignerID)
+	return s.ListAssignees(ctx, dealID)
+}
+
+// UnassignEmployee removes the employee userID from an application on behalf
+// of assignerID; the employee loses access to it
+func (s *URLAService) UnassignEmployee(ctx context.Context, dealID, assignerID, userID string) error {
+	if !utils.IsUUID(userID) {
+		return nil
+	}
+	if err := s.store.Deals().UnassignUser(dealID, userID); err != nil {
+		return errors.New("failed to unassign employee: " + err.Error())
+	}
+	s.logger.InfoContext(ctx, "urla: employee unassigned", "deal_id", dealID, "employee_id", userID, "unassigned_by", assignerID)
+	return nil
+}
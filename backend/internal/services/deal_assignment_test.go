@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/repositories"
+)
+
+func TestAssignedProcessorReadsBorrowerApplication(t *testing.T) {
+	s := newTestServices(t, nil)
+	ctx := context.Background()
+	access := NewDealAccessService(s.store)
+
+	registered, err := s.auth.Register(ctx, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	app, err := s.urla.CreateApplicationForBorrower(ctx, registered.User.ID, CreateApplicationRequest{
+		LoanType: "Conventional", LoanPurpose: "Purchase", LoanAmount: 350000,
+	})
+	if err != nil {
+		t.Fatalf("CreateApplicationForBorrower: %v", err)
+	}
+
+	admin, err := s.store.Users().Create("ada@example.com", "hash", "Ada", "Admin", repositories.EmployeeRole("admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	processor, err := s.store.Users().Create("pat@example.com", "hash", "Pat", "Processor", repositories.EmployeeRole("processor"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A borrower's application has nobody assigned to it
+	if _, err := access.ResolveAccess(ctx, app.ID, processor.ID, rbac.RoleProcessor); !errors.Is(err, ErrDealAccessDenied) {
+		t.Fatalf("before assignment: got %v, want ErrDealAccessDenied", err)
+	}
+
+	assignees, err := s.urla.AssignEmployee(ctx, app.ID, admin.ID, processor.ID)
+	if err != nil {
+		t.Fatalf("AssignEmployee: %v", err)
+	}
+	if len(assignees) != 1 || assignees[0].ID != processor.ID || assignees[0].Role != "processor" {
+		t.Fatalf("assignees = %+v, want the processor", assignees)
+	}
+	relation, err := access.ResolveAccess(ctx, app.ID, processor.ID, rbac.RoleProcessor)
+	if err != nil || relation != DealRelationAssignedEmployee {
+		t.Fatalf("after assignment: got %q, %v, want %q", relation, err, DealRelationAssignedEmployee)
+	}
+	if _, err := s.urla.GetApplication(ctx, app.ID); err != nil {
+		t.Fatalf("GetApplication: %v", err)
+	}
+
+	// Assigning again changes nothing
+	if assignees, err := s.urla.AssignEmployee(ctx, app.ID, admin.ID, processor.ID); err != nil || len(assignees) != 1 {
+		t.Fatalf("second assignment: got %+v, %v", assignees, err)
+	}
+
+	// Unassigning takes the access away
+	if err := s.urla.UnassignEmployee(ctx, app.ID, admin.ID, processor.ID); err != nil {
+		t.Fatalf("UnassignEmployee: %v", err)
+	}
+	if _, err := access.ResolveAccess(ctx, app.ID, processor.ID, rbac.RoleProcessor); !errors.Is(err, ErrDealAccessDenied) {
+		t.Fatalf("after unassignment: got %v, want ErrDealAccessDenied", err)
+	}
+}
+
+func TestOnlyActiveEmployeesAreAssigned(t *testing.T) {
+	s := newTestServices(t, nil)
+	ctx := context.Background()
+
+	registered, err := s.auth.Register(ctx, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	app, err := s.urla.CreateApplicationForBorrower(ctx, registered.User.ID, CreateApplicationRequest{
+		LoanType: "Conventional", LoanPurpose: "Purchase", LoanAmount: 350000,
+	})
+	if err != nil {
+		t.Fatalf("CreateApplicationForBorrower: %v", err)
+	}
+	inactive, err := s.store.Users().Create("lee@example.com", "hash", "Lee", "Officer", repositories.EmployeeRole("loan_officer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.Users().SetActive(inactive.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, userID := range map[string]string{
+		"borrower":          registered.User.ID,
+		"inactive employee": inactive.ID,
+		"unknown user":      "00000000-0000-4000-8000-000000000000",
+		"malformed ID":      "lee",
+	} {
+		if _, err := s.urla.AssignEmployee(ctx, app.ID, "", userID); !errors.Is(err, ErrAssigneeNotEmployee) {
+			t.Errorf("%s: got %v, want ErrAssigneeNotEmployee", name, err)
+		}
+	}
+	if assignees, err := s.urla.ListAssignees(ctx, app.ID); err != nil || len(assignees) != 0 {
+		t.Fatalf("assignees = %+v, %v, want none", assignees, err)
+	}
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/repositories"
+	"taulen/backend/internal/utils"
+)
+
+// Delegated editing lets an employee assigned to an application act on behalf
+// of one of its borrowers, e.g. to complete the 1003 over the phone. The
+// employee gets a short-lived delegated access token that carries the
+// borrower's identity and role, names the employee in its act claim and is
+// only good for that application. Every write to an application is recorded
+// with the account it was made as and, for delegated writes, the employee who
+// made it, so the borrower can see which sections their loan officer entered.
+
+// Account types of application edits
+const (
+	AccountTypeBorrower    = "borrower"
+	AccountTypeEmployee    = "employee"
+	AccountTypeIntegration = "integration"
+)
+
+// Sections recorded for application writes other than URLA progress sections
+const (
+	EditSectionBorrower      = "borrower"
+	EditSectionCoBorrower    = "coBorrower"
+	EditSectionLoan          = "loan"
+	EditSectionProgressNotes = "progressNotes"
+)
+
+var (
+	// ErrDelegationBorrower is returned when delegating to a borrower who is not on the application
+	ErrDelegationBorrower = errors.New("the borrower is not on this application")
+	// ErrDelegatedConsent is returned when an employee acting on a borrower's
+	// behalf consents to a credit check or submits the application, which only
+	// the borrower may do
+	ErrDelegatedConsent = errors.New("only the borrower can consent to a credit check or submit the application")
+	// ErrDelegatedEmailChange is returned when an employee acting on a
+	// borrower's behalf changes the borrower's email, which only the borrower
+	// may do
+	ErrDelegatedEmailChange = errors.New("only the borrower can change their email address")
+)
+
+// Editor identifies who makes a change to an application: the account the
+// request is authenticated as and, for delegated requests, the employee acting
+// on that account's behalf
+type Editor struct {
+	AccountID   string
+	AccountType string // one of the AccountType constants
+	ActorID     string // empty unless delegated
+}
+
+// NewEditor returns the editor for a request authenticated as accountID with
+// the given role; actorID is the delegating employee, or empty
+func NewEditor(accountID string, role rbac.Role, actorID string) Editor {
+	editor := Editor{AccountID: accountID, AccountType: AccountTypeEmployee, ActorID: actorID}
+	switch role {
+	case rbac.RoleApplicant:
+		editor.AccountType = AccountTypeBorrower
+	case rbac.RoleIntegration:
+		editor.AccountType = AccountTypeIntegration
+	}
+	return editor
+}
+
+// DelegationRequest represents a request to act on behalf of a borrower;
+// BorrowerID defaults to the application's primary borrower
+type DelegationRequest struct {
+	BorrowerID string `json:"borrowerId"`
+}
+
+// DelegationResponse represents a delegated access token
+type DelegationResponse struct {
+	AccessToken string `json:"accessToken"`
+	ExpiresAt   string `json:"expiresAt"`
+	DealID      string `json:"dealId"`
+	BorrowerID  string `json:"borrowerId"`
+}
+
+// ApplicationEditResponse represents a write to a section of an application
+type ApplicationEditResponse struct {
+	Section     string `json:"section"`
+	AccountID   string `json:"accountId"`
+	AccountType string `json:"accountType"`
+	// Delegated is set when an employee made the write on the account's behalf
+	Delegated bool   `json:"delegated"`
+	ActorID   string `json:"actorId,omitempty"`
+	ActorName string `json:"actorName,omitempty"`
+	EditedAt  string `json:"editedAt"`
+}
+
+// ApplicationEditsResponse represents who wrote an application: every write,
+// oldest first, and the latest write of each section
+type ApplicationEditsResponse struct {
+	Edits    []ApplicationEditResponse          `json:"edits"`
+	Sections map[string]ApplicationEditResponse `json:"sections"`
+}
+
+// StartDelegation issues the employee actorID, who must be assigned to the
+// application or an admin, a delegated access token for one of its borrowers
+func (s *AuthService) StartDelegation(ctx context.Context, actorID string, actorRole rbac.Role, dealID string, req DelegationRequest) (*DelegationResponse, error) {
+	actor, err := s.userRepo.GetByID(actorID)
+	if err != nil {
+		return nil, errors.New("failed to load employee: " + err.Error())
+	}
+	if !actor.Active() {
+		return nil, ErrAccountDeactivated
+	}
+
+	deal, err := s.dealRepo.GetDealByID(dealID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDealNotFound
+		}
+		return nil, errors.New("failed to load application: " + err.Error())
+	}
+	borrowerID := req.BorrowerID
+	if borrowerID == "" {
+		borrowerID = deal.PrimaryBorrowerID.String
+	}
+	if !utils.IsUUID(borrowerID) {
+		return nil, ErrDelegationBorrower
+	}
+	if borrowerID != deal.PrimaryBorrowerID.String {
+		linked, err := s.borrowerRepo.IsLinkedToDeal(borrowerID, dealID)
+		if err != nil {
+			return nil, errors.New("failed to check co-borrower: " + err.Error())
+		}
+		if !linked {
+			return nil, ErrDelegationBorrower
+		}
+	}
+	borrower, err := s.borrowerRepo.GetByID(borrowerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDelegationBorrower
+		}
+		return nil, errors.New("failed to load borrower: " + err.Error())
+	}
+
+	token, expiresAt, err := s.jwtManager.GenerateDelegatedToken(borrower.ID, borrower.EmailAddress.String,
+		string(rbac.RoleApplicant), dealID, utils.Actor{UserID: actorID, Role: string(actorRole)})
+	if err != nil {
+		return nil, errors.New("failed to generate delegated token")
+	}
+	s.logger.InfoContext(ctx, "auth: delegated access granted", "employee_id", actorID, "borrower_id", borrower.ID)
+
+	return &DelegationResponse{
+		AccessToken: token,
+		ExpiresAt:   expiresAt.Format("2006-01-02T15:04:05Z07:00"),
+		DealID:      dealID,
+		BorrowerID:  borrower.ID,
+	}, nil
+}
+
+// recordEdits records that editor wrote the given sections of a deal
+func recordEdits(dealRepo repositories.DealRepository, dealID string, editor Editor, sections ...string) error {
+	for _, section := range sections {
+		edit := &repositories.DealEdit{
+			DealID:      dealID,
+			Section:     section,
+			AccountID:   editor.AccountID,
+			AccountType: editor.AccountType,
+			ActorID:     sql.NullString{String: editor.ActorID, Valid: editor.ActorID != ""},
+		}
+		if err := dealRepo.RecordEdit(edit); err != nil {
+			return errors.New("failed to record edit: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// checkDelegatedSave refuses saves by a delegated editor that consent to a
+// credit check on the borrower's behalf or change the borrower's email, which
+// is their login. Saves that send back the stored consent and email pass, as
+// the borrower edit form sends every field.
+func checkDelegatedSave(store repositories.Store, dealID string, editor Editor, req SaveApplicationRequest) error {
+	if editor.ActorID == "" || req.Borrower == nil {
+		return nil
+	}
+	consent, _ := req.Borrower["acceptTerms"].(bool)
+	email, _ := req.Borrower["email"].(string)
+	if !consent && email == "" {
+		return nil
+	}
+
+	deal, err := store.Deals().GetDealByID(dealID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("deal not found")
+		}
+		return errors.New("failed to read deal: " + err.Error())
+	}
+	if !deal.PrimaryBorrowerID.Valid {
+		return nil
+	}
+	borrower, err := store.Borrowers().GetByID(deal.PrimaryBorrowerID.String)
+	if err != nil {
+		return errors.New("failed to read borrower: " + err.Error())
+	}
+	if consent && !borrower.ConsentToCreditCheck.Bool {
+		return ErrDelegatedConsent
+	}
+	if email != "" && !strings.EqualFold(email, borrower.EmailAddress.String) {
+		return ErrDelegatedEmailChange
+	}
+	return nil
+}
+
+// savedSections returns the sections written by an auto-save; moving to
+// another form step alone writes none
+func savedSections(req SaveApplicationRequest) []string {
+	var sections []string
+	if req.Borrower != nil {
+		sections = append(sections, EditSectionBorrower)
+	}
+	if req.CoBorrower != nil {
+		sections = append(sections, EditSectionCoBorrower)
+	}
+	if req.Loan != nil {
+		sections = append(sections, EditSectionLoan)
+	}
+	return append(sections, req.CompletedSections...)
+}
+
+// GetApplicationEdits returns who wrote each section of an application
+func (s *URLAService) GetApplicationEdits(ctx context.Context, dealID string) (*ApplicationEditsResponse, error) {
+	edits, err := s.store.Deals().ListEdits(dealID)
+	if err != nil {
+		return nil, errors.New("failed to list edits: " + err.Error())
+	}
+
+	response := &ApplicationEditsResponse{
+		Edits:    make([]ApplicationEditResponse, 0, len(edits)),
+		Sections: make(map[string]ApplicationEditResponse),
+	}
+	for _, edit := range edits {
+		entry := ApplicationEditResponse{
+			Section:     edit.Section,
+			AccountID:   edit.AccountID,
+			AccountType: edit.AccountType,
+			Delegated:   edit.ActorID.Valid,
+			ActorID:     edit.ActorID.String,
+		}
+		if edit.ActorID.Valid {
+			entry.ActorName = strings.TrimSpace(edit.ActorFirstName.String + " " + edit.ActorLastName.String)
+		}
+		if edit.CreatedAt.Valid {
+			entry.EditedAt = edit.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+		}
+		response.Edits = append(response.Edits, entry)
+		response.Sections[edit.Section] = entry
+	}
+	return response, nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/repositories"
+)
+
+func TestDelegatedEditorCannotConsentOrSubmit(t *testing.T) {
+	s := newTestServices(t, nil)
+	ctx := context.Background()
+
+	registered, err := s.auth.Register(ctx, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	borrowerID := registered.User.ID
+	if err := s.store.Borrowers().MarkEmailVerified(borrowerID); err != nil {
+		t.Fatal(err)
+	}
+	app, err := s.urla.CreateApplicationForBorrower(ctx, borrowerID, CreateApplicationRequest{
+		LoanType: "Conventional", LoanPurpose: "Purchase", LoanAmount: 350000,
+	})
+	if err != nil {
+		t.Fatalf("CreateApplicationForBorrower: %v", err)
+	}
+
+	employee, err := s.store.Users().Create("lee@example.com", "hash", "Lee", "Officer", repositories.EmployeeRole("loan_officer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegated := NewEditor(borrowerID, rbac.RoleApplicant, employee.ID)
+	borrower := NewEditor(borrowerID, rbac.RoleApplicant, "")
+
+	// The employee may enter data, but not consent to a credit check
+	if err := s.urla.SaveApplication(ctx, app.ID, delegated, SaveApplicationRequest{
+		Borrower: map[string]interface{}{"firstName": "Janet", "acceptTerms": true},
+	}); !errors.Is(err, ErrDelegatedConsent) {
+		t.Fatalf("delegated consent: got %v, want ErrDelegatedConsent", err)
+	}
+	if err := s.urla.SaveApplication(ctx, app.ID, delegated, SaveApplicationRequest{
+		Borrower: map[string]interface{}{"firstName": "Janet", "acceptTerms": false},
+	}); err != nil {
+		t.Fatalf("delegated save: %v", err)
+	}
+	if err := s.urla.SaveApplication(ctx, app.ID, borrower, SaveApplicationRequest{
+		Borrower: map[string]interface{}{"acceptTerms": true},
+	}); err != nil {
+		t.Fatalf("borrower consent: %v", err)
+	}
+
+	// Once the borrower has consented, the edit form sends the consent and
+	// email back with every save
+	if err := s.urla.SaveApplication(ctx, app.ID, delegated, SaveApplicationRequest{
+		Borrower: map[string]interface{}{"firstName": "Janet", "email": "jane@example.com", "acceptTerms": true},
+	}); err != nil {
+		t.Fatalf("delegated save of the stored consent and email: %v", err)
+	}
+
+	// Nor change the borrower's login email
+	if err := s.urla.SaveApplication(ctx, app.ID, delegated, SaveApplicationRequest{
+		Borrower: map[string]interface{}{"email": "lee@example.com"},
+	}); !errors.Is(err, ErrDelegatedEmailChange) {
+		t.Fatalf("delegated email change: got %v, want ErrDelegatedEmailChange", err)
+	}
+	stored, err := s.store.Borrowers().GetByID(borrowerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.EmailAddress.String != "jane@example.com" {
+		t.Fatalf("email = %q after a refused change", stored.EmailAddress.String)
+	}
+
+	// Nor submit the application
+	if err := s.urla.UpdateApplicationStatus(ctx, app.ID, delegated, "submitted"); !errors.Is(err, ErrDelegatedConsent) {
+		t.Fatalf("delegated submission: got %v, want ErrDelegatedConsent", err)
+	}
+	if err := s.urla.UpdateApplicationStatus(ctx, app.ID, borrower, "submitted"); err != nil {
+		t.Fatalf("borrower submission: %v", err)
+	}
+
+	edits, err := s.urla.GetApplicationEdits(ctx, app.ID)
+	if err != nil {
+		t.Fatalf("GetApplicationEdits: %v", err)
+	}
+	if n := len(edits.Edits); n != 3 {
+		t.Errorf("recorded %d edits, want 3: the accepted saves", n)
+	}
+}
+
+func TestStatusChangeRecordsNoEdit(t *testing.T) {
+	s := newTestServices(t, nil)
+	ctx := context.Background()
+
+	registered, err := s.auth.Register(ctx, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	app, err := s.urla.CreateApplicationForBorrower(ctx, registered.User.ID, CreateApplicationRequest{
+		LoanType: "Conventional", LoanPurpose: "Purchase", LoanAmount: 350000,
+	})
+	if err != nil {
+		t.Fatalf("CreateApplicationForBorrower: %v", err)
+	}
+	borrower := NewEditor(registered.User.ID, rbac.RoleApplicant, "")
+
+	// Submitting without a verified email fails
+	err = s.urla.UpdateApplicationStatus(ctx, app.ID, borrower, "submitted")
+	if !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("got %v, want ErrEmailNotVerified", err)
+	}
+
+	// The status is not stored, so even an accepted change records no edit
+	if err := s.store.Borrowers().MarkEmailVerified(registered.User.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.urla.UpdateApplicationStatus(ctx, app.ID, borrower, "submitted"); err != nil {
+		t.Fatalf("UpdateApplicationStatus: %v", err)
+	}
+	edits, err := s.urla.GetApplicationEdits(ctx, app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits.Edits) != 0 {
+		t.Fatalf("recorded %+v for status changes", edits.Edits)
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"taulen/backend/internal/repositories"
+)
+
+// Sessions and devices. Every login is a refresh session family (see
+// SessionService) that records the device and IP address it was issued to, so
+// account holders can list where they are signed in and sign out a device or
+// every device but the current one. Devices are identified by browser,
+// operating system and network; a login from a device the account has not used
+// before is reported by email, except for an account's first device.
+
+// maxUserAgentLength bounds the user agent stored with a session
+const maxUserAgentLength = 512
+
+// ErrSessionNotFound is returned when revoking a session that is not an active session of the caller
+var ErrSessionNotFound = errors.New("session not found")
+
+// Client describes the device a request was made from
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type clientKey struct{}
+
+// WithClient returns a copy of ctx carrying the client a request was made
+// from; sessions issued with the context record its device
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// clientFrom returns the client attached to ctx by WithClient
+func clientFrom(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
+
+// SessionResponse represents a login of the caller on one device
+type SessionResponse struct {
+	ID        string `json:"id"`
+	Device    string `json:"device"`
+	UserAgent string `json:"userAgent,omitempty"`
+	IPAddress string `json:"ipAddress,omitempty"`
+	CreatedAt string `json:"createdAt"`
+	// LastSeenAt is when the session last refreshed its tokens
+	LastSeenAt string `json:"lastSeenAt"`
+	// Current is set for the session the request was made with
+	Current bool `json:"current"`
+}
+
+// ListSessions returns the active sessions of the account, most recently seen
+// first; currentSessionID is the session of the caller's access token
+func (s *AuthService) ListSessions(ctx context.Context, subjectID, currentSessionID string) ([]SessionResponse, error) {
+	return s.sessions.List(ctx, subjectID, currentSessionID)
+}
+
+// RevokeSession signs the account out of one of its sessions
+func (s *AuthService) RevokeSession(ctx context.Context, subjectID, sessionID string) error {
+	return s.sessions.RevokeLogin(ctx, subjectID, sessionID)
+}
+
+// RevokeOtherSessions signs the account out of every session but the one of
+// the caller's access token
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, subjectID, currentSessionID string) error {
+	return s.sessions.RevokeOthers(ctx, subjectID, currentSessionID)
+}
+
+// noticeDevice remembers the device of a new login and emails the account
+// holder in the background when the account signed in from other devices
+// before but not this one. Failures are logged; they never fail the login.
+func (s *SessionService) noticeDevice(ctx context.Context, subjectID, email string, client Client) {
+	hadDevices, err := s.sessionRepo.HasDevices(subjectID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "session: failed to check known devices", "subject_id", subjectID, "error", err)
+		return
+	}
+	added, err := s.sessionRepo.AddDevice(subjectID, hashToken(deviceKey(client)))
+	if err != nil {
+		s.logger.ErrorContext(ctx, "session: failed to remember device", "subject_id", subjectID, "error", err)
+		return
+	}
+	if !added || !hadDevices || email == "" {
+		return
+	}
+
+	go s.sendNewDeviceLogin(context.WithoutCancel(ctx), email, client, time.Now())
+}
+
+// sendNewDeviceLogin emails the account holder about a login from a new device
+func (s *SessionService) sendNewDeviceLogin(ctx context.Context, email string, client Client, at time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+	defer cancel()
+
+	if err := s.email.SendNewDeviceLogin(ctx, email, describeDevice(client.UserAgent), client.IP, at); err != nil {
+		s.logger.WarnContext(ctx, "session: failed to send new device notification", "error", err)
+	}
+}
+
+// deviceKey identifies a client's device among the known devices of an account:
+// its browser and operating system, or its whole user agent when those are not
+// recognized, and the network it connects from. The network is the /24 of an
+// IPv4 address or the /48 of an IPv6 address, so the same browser on another
+// network counts as a new device while address changes within one do not.
+func deviceKey(client Client) string {
+	key := describeDevice(client.UserAgent)
+	if key == unknownDevice {
+		key = client.UserAgent
+	}
+	return key + "\n" + clientNetwork(client.IP)
+}
+
+// clientNetwork returns the network of an IP address, or "" if ip is not one
+func clientNetwork(ip string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+	if v4 := addr.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return addr.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+// sessionResponse converts the latest token of a login to a SessionResponse
+func sessionResponse(session *repositories.RefreshSession, currentFamilyID string) SessionResponse {
+	response := SessionResponse{
+		ID:        session.FamilyID,
+		Device:    describeDevice(session.UserAgent.String),
+		UserAgent: session.UserAgent.String,
+		IPAddress: session.IPAddress.String,
+		Current:   session.FamilyID == currentFamilyID,
+	}
+	if session.SignedInAt.Valid {
+		response.CreatedAt = session.SignedInAt.Time.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if session.CreatedAt.Valid {
+		response.LastSeenAt = session.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return response
+}
+
+// nullString returns s as a sql.NullString that is null when s is empty
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// unknownDevice describes clients whose user agent is not recognized
+const unknownDevice = "Unknown device"
+
+// describeDevice names the browser and operating system of a user agent, e.g.
+// "Chrome on macOS"
+func describeDevice(userAgent string) string {
+	var browser string
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"), strings.Contains(userAgent, "FxiOS/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"), strings.Contains(userAgent, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	var system string
+	switch {
+	case strings.Contains(userAgent, "iPhone"):
+		system = "iOS"
+	case strings.Contains(userAgent, "iPad"):
+		system = "iPadOS"
+	case strings.Contains(userAgent, "Android"):
+		system = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		system = "Windows"
+	case strings.Contains(userAgent, "CrOS"):
+		system = "ChromeOS"
+	case strings.Contains(userAgent, "Macintosh"), strings.Contains(userAgent, "Mac OS X"):
+		system = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		system = "Linux"
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system + " device"
+	default:
+		return unknownDevice
+	}
+}
+
+// truncateUserAgent bounds a user agent to the length stored with a session
+func truncateUserAgent(userAgent string) string {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+	return userAgent
+}
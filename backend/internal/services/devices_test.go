@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const firefoxOnLinux = "Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0"
+
+// newDeviceNotices returns how many new device notices are in outbox
+func newDeviceNotices(outbox *outbox) int {
+	return strings.Count(outbox.String(), "signed in to from a new device")
+}
+
+// waitForNewDeviceNotices waits for the notices sent in the background until
+// there are want of them
+func waitForNewDeviceNotices(t *testing.T, outbox *outbox, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for newDeviceNotices(outbox) < want && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := newDeviceNotices(outbox); got != want {
+		t.Fatalf("sent %d new device notices, want %d", got, want)
+	}
+}
+
+func TestDeviceKey(t *testing.T) {
+	home := deviceKey(Client{IP: "203.0.113.7", UserAgent: chromeOnMac})
+
+	same := []Client{
+		{IP: "203.0.113.200", UserAgent: chromeOnMac},
+		{IP: "203.0.113.7", UserAgent: strings.Replace(chromeOnMac, "120.0.0.0", "121.0.0.0", 1)},
+	}
+	for _, client := range same {
+		if deviceKey(client) != home {
+			t.Errorf("%+v is not the same device", client)
+		}
+	}
+	other := []Client{
+		{IP: "198.51.100.7", UserAgent: chromeOnMac},
+		{IP: "203.0.113.7", UserAgent: firefoxOnLinux},
+		{IP: "", UserAgent: chromeOnMac},
+	}
+	for _, client := range other {
+		if deviceKey(client) == home {
+			t.Errorf("%+v is the same device", client)
+		}
+	}
+
+	if a, b := deviceKey(Client{IP: "2001:db8:1:2::1", UserAgent: chromeOnMac}), deviceKey(Client{IP: "2001:db8:1:3::9", UserAgent: chromeOnMac}); a != b {
+		t.Errorf("addresses of one IPv6 /48 are different devices: %q, %q", a, b)
+	}
+	if a, b := deviceKey(Client{IP: "2001:db8:1::1", UserAgent: chromeOnMac}), deviceKey(Client{IP: "2001:db8:2::1", UserAgent: chromeOnMac}); a == b {
+		t.Errorf("addresses of two IPv6 /48s are the same device: %q", a)
+	}
+	// Unrecognized browsers are told apart by their whole user agent
+	if deviceKey(Client{UserAgent: "curl/8.5.0"}) == deviceKey(Client{UserAgent: "Wget/1.21"}) {
+		t.Error("unrecognized user agents are the same device")
+	}
+}
+
+func TestNewDeviceLoginNotice(t *testing.T) {
+	ts := newTestServices(t, nil)
+	home := WithClient(context.Background(), Client{IP: "203.0.113.7", UserAgent: chromeOnMac})
+
+	// Signing up from the first device sends no notice
+	if _, err := ts.auth.Register(home, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login := func(client Client) {
+		t.Helper()
+		ctx := WithClient(context.Background(), client)
+		if _, err := ts.auth.Login(ctx, LoginRequest{Email: "jane@example.com", Password: "correct horse"}); err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+	}
+
+	// Nor does signing in again from it, even from another address of its network
+	login(Client{IP: "203.0.113.7", UserAgent: chromeOnMac})
+	login(Client{IP: "203.0.113.99", UserAgent: chromeOnMac})
+
+	// The same browser on another network is reported, once
+	login(Client{IP: "198.51.100.7", UserAgent: chromeOnMac})
+	waitForNewDeviceNotices(t, ts.outbox, 1)
+	login(Client{IP: "198.51.100.7", UserAgent: chromeOnMac})
+
+	// As is another browser on the known network
+	login(Client{IP: "203.0.113.7", UserAgent: firefoxOnLinux})
+	waitForNewDeviceNotices(t, ts.outbox, 2)
+
+	if !strings.Contains(ts.outbox.String(), "IP address: 198.51.100.7") {
+		t.Errorf("the notice does not name the IP address: %q", ts.outbox.String())
+	}
+}
@@ -1,136 +1,168 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"taulen/backend/internal/config"
+	"log/slog"
+	"taulen/backend/internal/metrics"
+	"taulen/backend/internal/notify"
+	"time"
 )
 
-// EmailService handles email sending via Twilio SendGrid API
+// EmailService composes the emails the application sends and sends them
+// through the configured email provider
 type EmailService struct {
-	apiKey    string
-	fromEmail string
-	fromName  string
+	sender notify.EmailSender
+	logger *slog.Logger
 }
 
-// NewEmailService creates a new email service with SendGrid configuration
-func NewEmailService(cfg *config.Config) *EmailService {
+// NewEmailService creates a new email service sending through sender
+func NewEmailService(sender notify.EmailSender, logger *slog.Logger) *EmailService {
 	return &EmailService{
-		apiKey:    cfg.SendGrid.APIKey,
-		fromEmail: cfg.SendGrid.FromEmail,
-		fromName:  cfg.SendGrid.FromName,
+		sender: sender,
+		logger: logger,
 	}
 }
 
-// SendVerificationCode sends a verification code via email using Twilio SendGrid API
-func (s *EmailService) SendVerificationCode(toEmail, code string) error {
-	if s.apiKey == "" {
-		log.Printf("Email Verification Code for %s: %s (SendGrid not configured - API Key missing)", toEmail, code)
-		return fmt.Errorf("SendGrid API key is not configured")
-	}
-
-	log.Printf("Attempting to send verification email to %s using SendGrid", toEmail)
-
-	// SendGrid API v3 Mail Send endpoint
-	apiURL := "https://api.sendgrid.com/v3/mail/send"
-
+// SendVerificationCode sends a verification code via email
+func (s *EmailService) SendVerificationCode(ctx context.Context, toEmail, code string, expiresIn time.Duration) error {
 	// Prepare email content
 	emailBody := fmt.Sprintf(`
 Hello,
 
 Your verification code for Taulen is: %s
 
-This code will expire in 10 minutes.
+This code will expire in %s.
 
 If you didn't request this code, please ignore this email.
 
 Best regards,
 The Taulen Team
-`, code)
-
-	// SendGrid API request payload
-	payload := map[string]interface{}{
-		"personalizations": []map[string]interface{}{
-			{
-				"to": []map[string]string{
-					{"email": toEmail},
-				},
-			},
-		},
-		"from": map[string]string{
-			"email": s.fromEmail,
-			"name":  s.fromName,
-		},
-		"subject": "Your Taulen Verification Code",
-		"content": []map[string]string{
-			{
-				"type":  "text/plain",
-				"value": emailBody,
-			},
-		},
-	}
+`, code, formatDuration(expiresIn))
 
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to create email payload: %w", err)
-	}
+	return s.send(ctx, "verification", toEmail, "Your Taulen Verification Code", emailBody)
+}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return fmt.Errorf("failed to create email request: %w", err)
-	}
+// SendAccountLocked tells the account holder that their account was locked
+// after repeated failed logins
+func (s *EmailService) SendAccountLocked(ctx context.Context, toEmail string, until time.Time) error {
+	emailBody := fmt.Sprintf(`
+Hello,
 
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-	req.Header.Set("Content-Type", "application/json")
+Your Taulen account was temporarily locked after several failed sign-in attempts.
+You can sign in again after %s.
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Failed to send email: %v", err)
-		return fmt.Errorf("failed to send verification email: %w", err)
-	}
-	defer resp.Body.Close()
+If these attempts were not made by you, we recommend changing your password once
+the lock expires and contacting support.
 
-	// Read response body for error details
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read email response body: %v", err)
-	}
+Best regards,
+The Taulen Team
+`, until.UTC().Format("January 2, 2006 15:04 MST"))
+
+	return s.send(ctx, "account locked", toEmail, "Your Taulen account was locked", emailBody)
+}
+
+// SendPasswordReset sends a link for choosing a new password
+func (s *EmailService) SendPasswordReset(ctx context.Context, toEmail, link string, expiresIn time.Duration) error {
+	emailBody := fmt.Sprintf(`
+Hello,
+
+We received a request to reset the password of your Taulen account.
+Choose a new password here:
+
+%s
+
+This link will expire in %s and can only be used once.
+
+If you didn't request a password reset, please ignore this email; your
+password will not be changed.
+
+Best regards,
+The Taulen Team
+`, link, formatDuration(expiresIn))
 
-	// Log the response for debugging
-	log.Printf("=== SendGrid API Response ===")
-	log.Printf("Status Code: %d", resp.StatusCode)
-	if len(body) > 0 {
-		log.Printf("Response Body: %s", string(body))
+	return s.send(ctx, "password reset", toEmail, "Reset your Taulen password", emailBody)
+}
+
+// SendEmailVerification sends a link confirming that the recipient owns the address
+func (s *EmailService) SendEmailVerification(ctx context.Context, toEmail, link string, expiresIn time.Duration) error {
+	emailBody := fmt.Sprintf(`
+Hello,
+
+Please confirm the email address of your Taulen account by opening this link:
+
+%s
+
+This link will expire in %s. Until your email is confirmed you won't be able
+to submit your application or consent to a credit check.
+
+If you didn't create a Taulen account or change its email address, please
+ignore this email.
+
+Best regards,
+The Taulen Team
+`, link, formatDuration(expiresIn))
+
+	return s.send(ctx, "email verification", toEmail, "Confirm your email address", emailBody)
+}
+
+// SendNewDeviceLogin tells the account holder that their account was signed in
+// to from a device it was not used on before
+func (s *EmailService) SendNewDeviceLogin(ctx context.Context, toEmail, device, ip string, at time.Time) error {
+	if ip == "" {
+		ip = "unknown"
 	}
+	emailBody := fmt.Sprintf(`
+Hello,
+
+Your Taulen account was just signed in to from a new device:
+
+Device: %s
+IP address: %s
+Time: %s
+
+If this was you, there is nothing to do. If it wasn't, sign out the device
+from your list of active sessions and change your password right away.
 
-	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
-		// Try to parse SendGrid error response
-		var sendGridError struct {
-			Errors []struct {
-				Message string `json:"message"`
-				Field   string `json:"field,omitempty"`
-				Help    string `json:"help,omitempty"`
-			} `json:"errors"`
-		}
-		if err := json.Unmarshal(body, &sendGridError); err == nil && len(sendGridError.Errors) > 0 {
-			errorMsg := sendGridError.Errors[0].Message
-			log.Printf("SendGrid Error: %s", errorMsg)
-			log.Printf("=== End SendGrid Error ===")
-			return fmt.Errorf("failed to send verification email: %s", errorMsg)
-		}
-
-		log.Printf("Failed to parse SendGrid error response. Raw response: %s", string(body))
-		log.Printf("=== End SendGrid Error ===")
-		return fmt.Errorf("failed to send verification email: SendGrid API returned status %d. Response: %s", resp.StatusCode, string(body))
+Best regards,
+The Taulen Team
+`, device, ip, at.UTC().Format("January 2, 2006 15:04 MST"))
+
+	return s.send(ctx, "new device", toEmail, "New sign-in to your Taulen account", emailBody)
+}
+
+// send sends a plain-text email. kind names the email in logs; the body is
+// never logged, as it may carry a code or a link that signs the user in.
+func (s *EmailService) send(ctx context.Context, kind, toEmail, subject, emailBody string) error {
+	outcome := metrics.OutcomeFailed
+	defer func() { metrics.Notification(metrics.ChannelEmail, outcome) }()
+
+	err := s.sender.SendEmail(ctx, notify.Email{To: toEmail, Subject: subject, Body: emailBody})
+	if errors.Is(err, notify.ErrNotConfigured) {
+		s.logger.WarnContext(ctx, "email provider not configured, "+kind+" email not sent",
+			"email", toEmail, "error", err)
+		outcome = metrics.OutcomeSkipped
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send %s email: %w", kind, err)
 	}
 
-	log.Printf("Email sent successfully to %s", toEmail)
-	log.Printf("=== End SendGrid Response ===")
+	s.logger.InfoContext(ctx, kind+" email sent", "email", toEmail)
+	outcome = metrics.OutcomeSent
 
 	return nil
 }
+
+// formatDuration formats a duration for email and SMS text, e.g. "1 hour" or "30 minutes"
+func formatDuration(d time.Duration) string {
+	unit, n := "minute", int(d.Round(time.Minute)/time.Minute)
+	if d >= time.Hour && d%time.Hour == 0 {
+		unit, n = "hour", int(d/time.Hour)
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"taulen/backend/internal/rbac"
+)
+
+// emailVerificationLink returns the token of a verification link for email,
+// standing in for the one emailed to the borrower
+func emailVerificationLink(t *testing.T, ts *testServices, borrowerID, email string) string {
+	t.Helper()
+	token, err := ts.auth.emailVerification.jwtManager.GenerateEmailVerificationToken(borrowerID, email, ts.cfg.EmailVerification.TokenExpiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestEmailVerification(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+
+	registered, err := ts.auth.Register(ctx, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if registered.User.EmailVerified {
+		t.Fatal("a new borrower's email is verified")
+	}
+	borrowerID := registered.User.ID
+	app, err := ts.urla.CreateApplicationForBorrower(ctx, borrowerID, CreateApplicationRequest{
+		LoanType: "Conventional", LoanPurpose: "Purchase", LoanAmount: 350000,
+	})
+	if err != nil {
+		t.Fatalf("CreateApplicationForBorrower: %v", err)
+	}
+	borrower := NewEditor(borrowerID, rbac.RoleApplicant, "")
+	consent := SaveApplicationRequest{Borrower: map[string]interface{}{"acceptTerms": true}}
+
+	// Consenting to a credit check and submitting need a verified email
+	if err := ts.urla.SaveApplication(ctx, app.ID, borrower, consent); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("unverified consent: got %v, want ErrEmailNotVerified", err)
+	}
+	if err := ts.urla.UpdateApplicationStatus(ctx, app.ID, borrower, "submitted"); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("unverified submit: got %v, want ErrEmailNotVerified", err)
+	}
+
+	// Only a link for the borrower's current address confirms it
+	for name, token := range map[string]string{
+		"malformed link":   "not a token",
+		"replaced address": emailVerificationLink(t, ts, borrowerID, "old@example.com"),
+		"unknown borrower": emailVerificationLink(t, ts, "no-such-borrower", "jane@example.com"),
+	} {
+		if err := ts.auth.ConfirmEmail(ctx, ConfirmEmailRequest{Token: token}); !errors.Is(err, ErrInvalidEmailVerificationToken) {
+			t.Errorf("%s: got %v, want ErrInvalidEmailVerificationToken", name, err)
+		}
+	}
+	link := emailVerificationLink(t, ts, borrowerID, "JANE@example.com")
+	if err := ts.auth.ConfirmEmail(ctx, ConfirmEmailRequest{Token: link}); err != nil {
+		t.Fatalf("ConfirmEmail: %v", err)
+	}
+	if err := ts.auth.ConfirmEmail(ctx, ConfirmEmailRequest{Token: link}); err != nil {
+		t.Fatalf("confirming again: %v", err)
+	}
+
+	if err := ts.urla.SaveApplication(ctx, app.ID, borrower, consent); err != nil {
+		t.Fatalf("verified consent: %v", err)
+	}
+	if err := ts.urla.UpdateApplicationStatus(ctx, app.ID, borrower, "submitted"); err != nil {
+		t.Fatalf("verified submit: %v", err)
+	}
+	if err := ts.auth.ResendEmailVerification(ctx, borrowerID, rbac.RoleApplicant); !errors.Is(err, ErrEmailAlreadyVerified) {
+		t.Fatalf("resend after verifying: got %v, want ErrEmailAlreadyVerified", err)
+	}
+
+	employee := createEmployee(t, ts, "lee@example.com", "correct horse")
+	if err := ts.auth.ResendEmailVerification(ctx, employee.ID, rbac.RoleLoanOfficer); !errors.Is(err, ErrNotBorrowerAccount) {
+		t.Fatalf("employee resend: got %v, want ErrNotBorrowerAccount", err)
+	}
+}
+
+func TestChangingEmailNeedsVerifyingAgain(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+
+	registered, err := ts.auth.Register(ctx, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	borrowerID := registered.User.ID
+	oldLink := emailVerificationLink(t, ts, borrowerID, "jane@example.com")
+	app, err := ts.urla.CreateApplicationForBorrower(ctx, borrowerID, CreateApplicationRequest{
+		LoanType: "Conventional", LoanPurpose: "Purchase", LoanAmount: 350000,
+	})
+	if err != nil {
+		t.Fatalf("CreateApplicationForBorrower: %v", err)
+	}
+
+	if err := ts.urla.SaveApplication(ctx, app.ID, NewEditor(borrowerID, rbac.RoleApplicant, ""), SaveApplicationRequest{
+		Borrower: map[string]interface{}{"email": "janet@example.com"},
+	}); err != nil {
+		t.Fatalf("SaveApplication: %v", err)
+	}
+	if err := ts.auth.ConfirmEmail(ctx, ConfirmEmailRequest{Token: oldLink}); !errors.Is(err, ErrInvalidEmailVerificationToken) {
+		t.Fatalf("link for the old address: got %v, want ErrInvalidEmailVerificationToken", err)
+	}
+	if err := ts.auth.ConfirmEmail(ctx, ConfirmEmailRequest{Token: emailVerificationLink(t, ts, borrowerID, "janet@example.com")}); err != nil {
+		t.Fatalf("link for the new address: %v", err)
+	}
+}
+
+func TestConsentNeedsVerifiedEmailOnlyWhenGiven(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+
+	registered, err := ts.auth.Register(ctx, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	borrowerID := registered.User.ID
+	app, err := ts.urla.CreateApplicationForBorrower(ctx, borrowerID, CreateApplicationRequest{
+		LoanType: "Conventional", LoanPurpose: "Purchase", LoanAmount: 350000,
+	})
+	if err != nil {
+		t.Fatalf("CreateApplicationForBorrower: %v", err)
+	}
+	borrower := NewEditor(borrowerID, rbac.RoleApplicant, "")
+	consent := SaveApplicationRequest{Borrower: map[string]interface{}{"firstName": "Janet", "acceptTerms": true}}
+
+	// Consenting needs a verified email
+	if err := ts.urla.SaveApplication(ctx, app.ID, borrower, consent); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("unverified consent: got %v, want ErrEmailNotVerified", err)
+	}
+	if err := ts.urla.SaveApplication(ctx, app.ID, borrower, SaveApplicationRequest{
+		Borrower: map[string]interface{}{"firstName": "Janet", "acceptTerms": false},
+	}); err != nil {
+		t.Fatalf("save without consent: %v", err)
+	}
+
+	// Sending back a consent given before the email had to be verified does not
+	consented := true
+	if err := ts.store.Borrowers().UpdateBorrowerConsentsAndMilitary(borrowerID, nil, &consented, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.urla.SaveApplication(ctx, app.ID, borrower, consent); err != nil {
+		t.Fatalf("save of the stored consent: %v", err)
+	}
+}
+
+func TestSubmittingUnknownApplication(t *testing.T) {
+	ts := newTestServices(t, nil)
+
+	editor := NewEditor("borrower-1", rbac.RoleApplicant, "")
+	err := ts.urla.UpdateApplicationStatus(context.Background(), "missing", editor, "submitted")
+	if !errors.Is(err, ErrDealNotFound) {
+		t.Fatalf("got %v, want ErrDealNotFound", err)
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"taulen/backend/internal/repositories"
+)
+
+// Employee account management for admins. Deactivating an employee revokes
+// their sessions and refuses further logins and token refreshes; access tokens
+// already issued stay valid until they expire. Forcing a password reset emails
+// the employee a reset link and refuses logins until they have used it.
+
+// Employee list page sizes
+const (
+	defaultEmployeePageSize = 25
+	maxEmployeePageSize     = 100
+)
+
+var (
+	// ErrEmployeeNotFound is returned when no employee has the given ID
+	ErrEmployeeNotFound = errors.New("employee not found")
+	// ErrAccountDeactivated is returned when a deactivated employee logs in or refreshes tokens
+	ErrAccountDeactivated = errors.New("account is not active")
+	// ErrPasswordResetRequired is returned when logging in to an account whose password must be reset
+	ErrPasswordResetRequired = errors.New("password reset required, please use the link sent to your email")
+	// ErrCannotModifyOwnAccount is returned when an admin deactivates or changes the role of their own account
+	ErrCannotModifyOwnAccount = errors.New("admins cannot deactivate or change the role of their own account")
+	// ErrEmployeeExists is returned when creating an employee whose email already has an employee account
+	ErrEmployeeExists = errors.New("employee with this email already exists")
+	// ErrEmailRegisteredAsBorrower is returned when creating an employee with a borrower's email
+	ErrEmailRegisteredAsBorrower = errors.New("email already registered as borrower")
+)
+
+// ListEmployeesRequest represents an employee search; Page starts at 1
+type ListEmployeesRequest struct {
+	Search   string
+	Role     string // e.g. "loan_officer"
+	Status   string // "active" or "inactive"
+	Page     int
+	PageSize int
+}
+
+// UpdateEmployeeRequest represents changes to an employee's profile; nil fields are left unchanged
+type UpdateEmployeeRequest struct {
+	FirstName *string
+	LastName  *string
+	Phone     *string
+	Role      *string // e.g. "loan_officer"
+}
+
+// EmployeeResponse represents an employee account as shown to admins
+type EmployeeResponse struct {
+	ID                    string `json:"id"`
+	Email                 string `json:"email"`
+	FirstName             string `json:"firstName"`
+	LastName              string `json:"lastName"`
+	Phone                 string `json:"phone,omitempty"`
+	Role                  string `json:"role"`
+	NMLSID                string `json:"nmlsId,omitempty"`
+	Status                string `json:"status"`
+	Active                bool   `json:"active"`
+	MFAEnabled            bool   `json:"mfaEnabled"`
+	PasswordResetRequired bool   `json:"passwordResetRequired"`
+	LockedUntil           string `json:"lockedUntil,omitempty"`
+	LastLoginAt           string `json:"lastLoginAt,omitempty"`
+	CreatedAt             string `json:"createdAt,omitempty"`
+}
+
+// EmployeeListResponse represents a page of employees
+type EmployeeListResponse struct {
+	Employees []EmployeeResponse `json:"employees"`
+	Page      int                `json:"page"`
+	PageSize  int                `json:"pageSize"`
+	Total     int                `json:"total"`
+}
+
+// toEmployeeResponse converts a user record into an employee response
+func toEmployeeResponse(user *repositories.User) EmployeeResponse {
+	employee := EmployeeResponse{
+		ID:                    user.ID,
+		Email:                 user.Email,
+		FirstName:             user.FirstName.String,
+		LastName:              user.LastName.String,
+		Phone:                 user.Phone.String,
+		Role:                  user.Role,
+		NMLSID:                user.NMLSRIdentifier.String,
+		Status:                user.Status,
+		Active:                user.Active(),
+		MFAEnabled:            user.MFAEnabled,
+		PasswordResetRequired: user.PasswordResetRequired,
+	}
+	if checkLocked(user.AccountLockedUntil) != nil {
+		employee.LockedUntil = user.AccountLockedUntil.Time.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if user.LastLoginAt.Valid {
+		employee.LastLoginAt = user.LastLoginAt.Time.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if user.CreatedAt.Valid {
+		employee.CreatedAt = user.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return employee
+}
+
+// checkEmployeeLogin refuses logins to deactivated employees and to employees
+// who have to reset their password first
+func checkEmployeeLogin(user *repositories.User) error {
+	if !user.Active() {
+		return ErrAccountDeactivated
+	}
+	if user.PasswordResetRequired {
+		return ErrPasswordResetRequired
+	}
+	return nil
+}
+
+// loadEmployee loads the employee with the given ID
+func (s *AuthService) loadEmployee(id string) (*repositories.User, error) {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrEmployeeNotFound
+		}
+		return nil, errors.New("failed to load employee: " + err.Error())
+	}
+	return user, nil
+}
+
+// ListEmployees returns a page of employees matching the search, ordered by name
+func (s *AuthService) ListEmployees(ctx context.Context, req ListEmployeesRequest) (*EmployeeListResponse, error) {
+	page := max(req.Page, 1)
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultEmployeePageSize
+	}
+	pageSize = min(pageSize, maxEmployeePageSize)
+
+	filter := repositories.EmployeeFilter{
+		Search: strings.TrimSpace(req.Search),
+		Status: req.Status,
+	}
+	if req.Role != "" {
+		filter.Role = repositories.EmployeeRole(req.Role)
+	}
+
+	users, total, err := s.userRepo.List(filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, errors.New("failed to list employees: " + err.Error())
+	}
+
+	employees := make([]EmployeeResponse, 0, len(users))
+	for _, user := range users {
+		employees = append(employees, toEmployeeResponse(user))
+	}
+	return &EmployeeListResponse{Employees: employees, Page: page, PageSize: pageSize, Total: total}, nil
+}
+
+// GetEmployee returns the employee with the given ID
+func (s *AuthService) GetEmployee(ctx context.Context, id string) (*EmployeeResponse, error) {
+	user, err := s.loadEmployee(id)
+	if err != nil {
+		return nil, err
+	}
+	employee := toEmployeeResponse(user)
+	return &employee, nil
+}
+
+// UpdateEmployee updates an employee's profile and role on behalf of the admin
+// adminID. A new role applies from the employee's next token refresh.
+func (s *AuthService) UpdateEmployee(ctx context.Context, adminID, id string, req UpdateEmployeeRequest) (*EmployeeResponse, error) {
+	if req.Role != nil && id == adminID {
+		return nil, ErrCannotModifyOwnAccount
+	}
+	if _, err := s.loadEmployee(id); err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.UpdateProfile(id, req.FirstName, req.LastName, req.Phone, req.Role); err != nil {
+		return nil, errors.New("failed to update employee: " + err.Error())
+	}
+	if req.Role != nil {
+		s.logger.InfoContext(ctx, "admin: employee role changed", "employee_id", id, "role", *req.Role, "admin_id", adminID)
+	}
+	return s.GetEmployee(ctx, id)
+}
+
+// SetEmployeeNMLSID sets an employee's NMLS ID; an empty ID clears it
+func (s *AuthService) SetEmployeeNMLSID(ctx context.Context, id, nmlsID string) (*EmployeeResponse, error) {
+	if _, err := s.loadEmployee(id); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetNMLSIdentifier(id, nmlsID); err != nil {
+		return nil, errors.New("failed to set NMLS ID: " + err.Error())
+	}
+	return s.GetEmployee(ctx, id)
+}
+
+// SetEmployeeActive deactivates or reactivates an employee on behalf of the
+// admin adminID. Deactivating revokes every session of the employee.
+func (s *AuthService) SetEmployeeActive(ctx context.Context, adminID, id string, active bool) (*EmployeeResponse, error) {
+	if !active && id == adminID {
+		return nil, ErrCannotModifyOwnAccount
+	}
+	if _, err := s.loadEmployee(id); err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.SetActive(id, active); err != nil {
+		return nil, errors.New("failed to update employee: " + err.Error())
+	}
+	if !active {
+		if err := s.sessions.RevokeAll(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	s.logger.InfoContext(ctx, "admin: employee activation changed", "employee_id", id, "active", active, "admin_id", adminID)
+	return s.GetEmployee(ctx, id)
+}
+
+// ForceEmployeePasswordReset requires an employee to choose a new password:
+// logins are refused until they have, every session is revoked and a reset
+// link is emailed to them. Not available while employees sign in with SSO only.
+func (s *AuthService) ForceEmployeePasswordReset(ctx context.Context, adminID, id string) error {
+	if s.cfg.OIDC.DisablePasswordLogin {
+		return ErrPasswordLoginDisabled
+	}
+	user, err := s.loadEmployee(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.RequirePasswordReset(id); err != nil {
+		return errors.New("failed to require password reset: " + err.Error())
+	}
+	if err := s.sessions.RevokeAll(ctx, id); err != nil {
+		return err
+	}
+	if err := s.issuePasswordReset(ctx, s.userRepo, user.ID, user.Email); err != nil {
+		return err
+	}
+	s.logger.InfoContext(ctx, "admin: employee password reset required", "employee_id", id, "admin_id", adminID)
+	return nil
+}
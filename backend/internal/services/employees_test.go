@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"taulen/backend/internal/repositories"
+)
+
+func TestEmployeeSearch(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	lee := createEmployee(t, ts, "lee@example.com", "correct horse")
+	createEmployee(t, ts, "sam@example.com", "correct horse")
+	if _, err := ts.auth.SetEmployeeActive(ctx, "admin-1", lee.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tt := range map[string]struct {
+		req   ListEmployeesRequest
+		count int
+		total int
+	}{
+		"everyone":       {ListEmployeesRequest{}, 2, 2},
+		"by email":       {ListEmployeesRequest{Search: " SAM@"}, 1, 1},
+		"by role":        {ListEmployeesRequest{Role: "underwriter"}, 0, 0},
+		"inactive":       {ListEmployeesRequest{Status: "inactive"}, 1, 1},
+		"second page":    {ListEmployeesRequest{Page: 2, PageSize: 1}, 1, 2},
+		"past the pages": {ListEmployeesRequest{Page: 3, PageSize: 1}, 0, 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			list, err := ts.auth.ListEmployees(ctx, tt.req)
+			if err != nil {
+				t.Fatalf("ListEmployees: %v", err)
+			}
+			if len(list.Employees) != tt.count || list.Total != tt.total {
+				t.Errorf("got %d of %d employees, want %d of %d", len(list.Employees), list.Total, tt.count, tt.total)
+			}
+		})
+	}
+}
+
+func TestUpdateEmployee(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	admin := createEmployee(t, ts, "admin@example.com", "correct horse")
+	lee := createEmployee(t, ts, "lee@example.com", "correct horse")
+
+	role, phone := "underwriter", "+15555550100"
+	employee, err := ts.auth.UpdateEmployee(ctx, admin.ID, lee.ID, UpdateEmployeeRequest{Role: &role, Phone: &phone})
+	if err != nil {
+		t.Fatalf("UpdateEmployee: %v", err)
+	}
+	if employee.Role != repositories.EmployeeRole(role) || employee.Phone != phone || employee.FirstName != "Lee" {
+		t.Fatalf("updated employee: %+v", employee)
+	}
+	if employee, err = ts.auth.SetEmployeeNMLSID(ctx, lee.ID, "123456"); err != nil || employee.NMLSID != "123456" {
+		t.Fatalf("SetEmployeeNMLSID: %+v, %v", employee, err)
+	}
+
+	// Admins cannot demote or lock out themselves
+	if _, err := ts.auth.UpdateEmployee(ctx, admin.ID, admin.ID, UpdateEmployeeRequest{Role: &role}); !errors.Is(err, ErrCannotModifyOwnAccount) {
+		t.Fatalf("own role: got %v, want ErrCannotModifyOwnAccount", err)
+	}
+	if _, err := ts.auth.SetEmployeeActive(ctx, admin.ID, admin.ID, false); !errors.Is(err, ErrCannotModifyOwnAccount) {
+		t.Fatalf("own deactivation: got %v, want ErrCannotModifyOwnAccount", err)
+	}
+	if _, err := ts.auth.GetEmployee(ctx, "no-such-employee"); !errors.Is(err, ErrEmployeeNotFound) {
+		t.Fatalf("unknown employee: got %v, want ErrEmployeeNotFound", err)
+	}
+}
+
+func TestDeactivateEmployee(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	lee := createEmployee(t, ts, "lee@example.com", "correct horse")
+	login := LoginRequest{Email: "lee@example.com", Password: "correct horse"}
+	loggedIn, err := ts.auth.Login(ctx, login)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	employee, err := ts.auth.SetEmployeeActive(ctx, "admin-1", lee.ID, false)
+	if err != nil {
+		t.Fatalf("deactivating: %v", err)
+	}
+	if employee.Active {
+		t.Fatalf("deactivated employee: %+v", employee)
+	}
+	if _, err := ts.auth.RefreshToken(ctx, RefreshRequest{RefreshToken: loggedIn.RefreshToken}); err == nil {
+		t.Fatal("refresh after deactivating succeeded")
+	}
+	if _, err := ts.auth.Login(ctx, login); !errors.Is(err, ErrAccountDeactivated) {
+		t.Fatalf("login after deactivating: got %v, want ErrAccountDeactivated", err)
+	}
+
+	if _, err := ts.auth.SetEmployeeActive(ctx, "admin-1", lee.ID, true); err != nil {
+		t.Fatalf("reactivating: %v", err)
+	}
+	if _, err := ts.auth.Login(ctx, login); err != nil {
+		t.Fatalf("login after reactivating: %v", err)
+	}
+}
+
+func TestForceEmployeePasswordReset(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	lee := createEmployee(t, ts, "lee@example.com", "correct horse")
+	loggedIn, err := ts.auth.Login(ctx, LoginRequest{Email: "lee@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if err := ts.auth.ForceEmployeePasswordReset(ctx, "admin-1", lee.ID); err != nil {
+		t.Fatalf("ForceEmployeePasswordReset: %v", err)
+	}
+	if _, err := ts.auth.RefreshToken(ctx, RefreshRequest{RefreshToken: loggedIn.RefreshToken}); err == nil {
+		t.Fatal("refresh after forcing a reset succeeded")
+	}
+
+	// Only the correct password learns that a reset is required
+	if _, err := ts.auth.Login(ctx, LoginRequest{Email: "lee@example.com", Password: "wrong password"}); err == nil || errors.Is(err, ErrPasswordResetRequired) {
+		t.Fatalf("wrong password: got %v", err)
+	}
+	if _, err := ts.auth.Login(ctx, LoginRequest{Email: "lee@example.com", Password: "correct horse"}); !errors.Is(err, ErrPasswordResetRequired) {
+		t.Fatalf("correct password: got %v, want ErrPasswordResetRequired", err)
+	}
+
+	token := waitForResetToken(t, ts.outbox)
+	if err := ts.auth.ConfirmPasswordReset(ctx, ConfirmPasswordResetRequest{Token: token, Password: "battery staple"}); err != nil {
+		t.Fatalf("ConfirmPasswordReset: %v", err)
+	}
+	if _, err := ts.auth.Login(ctx, LoginRequest{Email: "lee@example.com", Password: "battery staple"}); err != nil {
+		t.Fatalf("login with the new password: %v", err)
+	}
+}
+
+func TestDeactivatedEmployeeNeedsCorrectPasswordToLearnIt(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	user := createEmployee(t, ts, "lee@example.com", "correct horse")
+	if err := ts.store.Users().SetActive(user.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// A wrong password gets the same answer as an unknown email
+	_, unknown := ts.auth.Login(ctx, LoginRequest{Email: "nobody@example.com", Password: "wrong password"})
+	_, err := ts.auth.Login(ctx, LoginRequest{Email: "lee@example.com", Password: "wrong password"})
+	if err == nil || errors.Is(err, ErrAccountDeactivated) || err.Error() != unknown.Error() {
+		t.Fatalf("wrong password: got %v, want %v", err, unknown)
+	}
+
+	if _, err := ts.auth.Login(ctx, LoginRequest{Email: "lee@example.com", Password: "correct horse"}); !errors.Is(err, ErrAccountDeactivated) {
+		t.Fatalf("correct password: got %v, want ErrAccountDeactivated", err)
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"regexp"
+	"sync"
+	"testing"
+
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/notify"
+	"taulen/backend/internal/repositories"
+	"taulen/backend/internal/repositories/memory"
+	"taulen/backend/internal/utils"
+)
+
+// testConfig loads the default configuration; env sets TAULEN_ variables
+// for the duration of the test before it is loaded
+func testConfig(t *testing.T, env map[string]string) *config.Config {
+	t.Helper()
+	t.Setenv("TAULEN_JWT_SECRET", "test-secret")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	return cfg
+}
+
+// testLogger discards log records
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// codePattern matches a verification code in a recorded notification
+var codePattern = regexp.MustCompile(`verification code (?:for Taulen )?is: (\d{6})`)
+
+// outbox records the notifications sent by services. It may be read while a
+// notification is being sent in the background.
+type outbox struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *outbox) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+func (o *outbox) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+func (o *outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Len()
+}
+
+// testServices bundles services sharing one in-memory store, recording every
+// notification in outbox
+type testServices struct {
+	cfg    *config.Config
+	store  *memory.Store
+	outbox *outbox
+	auth   *AuthService
+	urla   *URLAService
+}
+
+// newTestServices creates auth and URLA services over an empty in-memory store
+func newTestServices(t *testing.T, env map[string]string) *testServices {
+	t.Helper()
+	cfg := testConfig(t, env)
+	store := memory.NewStore()
+	outbox := &outbox{}
+	notifier := notify.NewConsoleSink(outbox)
+	return &testServices{
+		cfg:    cfg,
+		store:  store,
+		outbox: outbox,
+		auth:   NewAuthService(cfg, store, notifier, testLogger()),
+		urla:   NewURLAService(cfg, store, notifier, testLogger()),
+	}
+}
+
+// createEmployee creates an active employee who logs in with password
+func createEmployee(t *testing.T, ts *testServices, email, password string) *repositories.User {
+	t.Helper()
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := ts.store.Users().Create(email, hash, "Lee", "Officer", repositories.EmployeeRole("loan_officer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -1,28 +1,40 @@
 package services
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"taulen/backend/internal/config"
 	"taulen/backend/internal/repositories"
 )
 
 // LoanService handles loan-related operations
 type LoanService struct {
-	dealRepo   *repositories.DealRepository
+	dealRepo   repositories.DealRepository
 	appService *ApplicationService
+	logger     *slog.Logger
 }
 
 // NewLoanService creates a new loan service
-func NewLoanService(cfg *config.Config) *LoanService {
+func NewLoanService(cfg *config.Config, store repositories.Store, logger *slog.Logger) *LoanService {
 	return &LoanService{
-		dealRepo:   repositories.NewDealRepository(),
-		appService: NewApplicationService(),
+		dealRepo:   store.Deals(),
+		appService: NewApplicationService(store, logger),
+		logger:     logger,
+	}
+}
+
+// withStore returns a copy of the service whose repositories use the given store
+func (s *LoanService) withStore(store repositories.Store) *LoanService {
+	return &LoanService{
+		dealRepo:   store.Deals(),
+		appService: s.appService.withStore(store),
+		logger:     s.logger,
 	}
 }
 
 // SaveLoanData saves loan information for an application
-func (s *LoanService) SaveLoanData(dealID string, loanData map[string]interface{}, nextFormStep string) error {
+func (s *LoanService) SaveLoanData(ctx context.Context, dealID string, loanData map[string]interface{}, nextFormStep string) error {
 	var loanAmount *float64
 	var purchasePrice, downPayment *float64
 	var propertyAddress *string
@@ -89,7 +101,7 @@ func (s *LoanService) SaveLoanData(dealID string, loanData map[string]interface{
 	// Note: loanPurpose is not updated here as it's set at application creation and cannot be changed
 	err := s.dealRepo.UpdateLoan(dealID, nil, nil, nil, nil, loanAmount, nil, nil)
 	if err != nil {
-		log.Printf("SaveLoanData: Failed to update loan: %v", err)
+		s.logger.ErrorContext(ctx, "SaveLoanData: failed to update loan", "error", err)
 		return fmt.Errorf("failed to update loan: %w", err)
 	}
 
@@ -99,10 +111,9 @@ func (s *LoanService) SaveLoanData(dealID string, loanData map[string]interface{
 
 	// Update current form step if provided
 	if nextFormStep != "" {
-		err = s.appService.UpdateCurrentFormStep(dealID, nextFormStep)
+		err = s.appService.UpdateCurrentFormStep(ctx, dealID, nextFormStep)
 		if err != nil {
-			log.Printf("SaveLoanData: Failed to update current form step: %v", err)
-			// Don't fail the entire operation if step update fails
+			return fmt.Errorf("failed to update current form step: %w", err)
 		}
 	}
 
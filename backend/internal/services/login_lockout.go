@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// notificationTimeout bounds how long sending a lock notification may take
+const notificationTimeout = 30 * time.Second
+
+var (
+	// ErrAccountNotFound is returned when no borrower or employee has the given email
+	ErrAccountNotFound = errors.New("account not found")
+	// ErrInvalidCredentials is returned by Login for an unknown email or a wrong password
+	ErrInvalidCredentials = errors.New("invalid email or password")
+)
+
+// AccountLockedError is returned when logging in to a locked account
+type AccountLockedError struct {
+	Until time.Time
+}
+
+func (e *AccountLockedError) Error() string {
+	return "account is temporarily locked due to too many failed login attempts"
+}
+
+// lockoutRepository is the login bookkeeping shared by the borrower and user repositories
+type lockoutRepository interface {
+	RecordLoginFailure(id string) (int, error)
+	LockAccount(id string, until time.Time) error
+	RecordLoginSuccess(id string) error
+}
+
+// checkLocked returns an AccountLockedError if the account is locked now
+func checkLocked(lockedUntil sql.NullTime) error {
+	if lockedUntil.Valid && lockedUntil.Time.After(time.Now()) {
+		return &AccountLockedError{Until: lockedUntil.Time}
+	}
+	return nil
+}
+
+// lockoutDuration returns how long an account is locked after the given number
+// of consecutive failed logins: nothing below the threshold, then the lockout
+// duration, doubled for every further failure up to the maximum
+func (s *AuthService) lockoutDuration(failures int) time.Duration {
+	cfg := s.cfg.Login
+	if failures < cfg.LockoutThreshold {
+		return 0
+	}
+	d := cfg.LockoutDuration
+	for i := cfg.LockoutThreshold; i < failures && d < cfg.LockoutMaxDuration; i++ {
+		d *= 2
+	}
+	if d > cfg.LockoutMaxDuration {
+		d = cfg.LockoutMaxDuration
+	}
+	return d
+}
+
+// recordLoginFailure counts a failed login for the account and locks it once
+// the threshold is reached, notifying the account holder by email. It returns
+// an AccountLockedError when this failure locked the account.
+func (s *AuthService) recordLoginFailure(ctx context.Context, repo lockoutRepository, accountID, email string) error {
+	failures, err := repo.RecordLoginFailure(accountID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "auth: failed to record failed login", "account_id", accountID, "error", err)
+		return nil
+	}
+
+	d := s.lockoutDuration(failures)
+	if d == 0 {
+		return nil
+	}
+	until := time.Now().Add(d)
+	if err := repo.LockAccount(accountID, until); err != nil {
+		s.logger.ErrorContext(ctx, "auth: failed to lock account", "account_id", accountID, "error", err)
+		return nil
+	}
+	s.logger.WarnContext(ctx, "auth: account locked after failed logins",
+		"account_id", accountID, "failures", failures, "locked_until", until)
+
+	if email != "" {
+		// Don't make the failed login wait for the email provider
+		go s.notifyAccountLocked(context.WithoutCancel(ctx), email, until)
+	}
+	return &AccountLockedError{Until: until}
+}
+
+// recordLoginSuccess resets the account's failed logins and records the login time
+func (s *AuthService) recordLoginSuccess(ctx context.Context, repo lockoutRepository, accountID string) {
+	if err := repo.RecordLoginSuccess(accountID); err != nil {
+		s.logger.ErrorContext(ctx, "auth: failed to record login", "account_id", accountID, "error", err)
+	}
+}
+
+// notifyAccountLocked emails the account holder that their account was locked
+func (s *AuthService) notifyAccountLocked(ctx context.Context, email string, until time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+	defer cancel()
+
+	if err := s.email.SendAccountLocked(ctx, email, until); err != nil {
+		s.logger.WarnContext(ctx, "auth: failed to send account locked notification", "error", err)
+	}
+}
+
+// UnlockAccount clears the lock and failed logins of the borrower or employee
+// with the given email (admin only)
+func (s *AuthService) UnlockAccount(ctx context.Context, email string) error {
+	borrower, err := s.borrowerRepo.GetByEmail(email)
+	if err == nil {
+		if err := s.borrowerRepo.UnlockAccount(borrower.ID); err != nil {
+			return errors.New("failed to unlock account: " + err.Error())
+		}
+		s.logger.InfoContext(ctx, "auth: account unlocked", "account_id", borrower.ID)
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return errors.New("failed to check borrower account")
+	}
+
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAccountNotFound
+		}
+		return errors.New("failed to check user account")
+	}
+	if err := s.userRepo.UnlockAccount(user.ID); err != nil {
+		return errors.New("failed to unlock account: " + err.Error())
+	}
+	s.logger.InfoContext(ctx, "auth: account unlocked", "account_id", user.ID)
+	return nil
+}
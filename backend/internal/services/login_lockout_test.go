@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lockoutEnv locks accounts after three failed logins, for a minute at first
+// and at most four minutes
+var lockoutEnv = map[string]string{
+	"TAULEN_LOGIN_LOCKOUT_THRESHOLD":    "3",
+	"TAULEN_LOGIN_LOCKOUT_DURATION":     "1m",
+	"TAULEN_LOGIN_LOCKOUT_MAX_DURATION": "4m",
+}
+
+func TestLockoutDuration(t *testing.T) {
+	ts := newTestServices(t, lockoutEnv)
+
+	want := []time.Duration{0, 0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute, 4 * time.Minute}
+	for failures, d := range want {
+		if got := ts.auth.lockoutDuration(failures); got != d {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", failures, got, d)
+		}
+	}
+	if got := ts.auth.lockoutDuration(1 << 20); got != 4*time.Minute {
+		t.Errorf("lockoutDuration of many failures = %v, want the maximum", got)
+	}
+}
+
+// lockedFor asserts that err is an AccountLockedError ending about d from now
+func lockedFor(t *testing.T, err error, d time.Duration) {
+	t.Helper()
+	var locked *AccountLockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("got %v, want AccountLockedError", err)
+	}
+	if until := time.Until(locked.Until); until > d || until < d-time.Minute/2 {
+		t.Fatalf("locked for %v, want %v", until, d)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	ts := newTestServices(t, lockoutEnv)
+	ctx := context.Background()
+
+	registered, err := ts.auth.Register(ctx, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	borrowerID := registered.User.ID
+	wrong := LoginRequest{Email: "jane@example.com", Password: "wrong password"}
+	correct := LoginRequest{Email: "jane@example.com", Password: "correct horse"}
+
+	// Failures below the threshold do not lock the account
+	for i := 0; i < 2; i++ {
+		_, err := ts.auth.Login(ctx, wrong)
+		var locked *AccountLockedError
+		if err == nil || errors.As(err, &locked) {
+			t.Fatalf("failure %d: got %v, want a wrong password error", i+1, err)
+		}
+	}
+	_, err = ts.auth.Login(ctx, wrong)
+	lockedFor(t, err, time.Minute)
+
+	// The correct password does not get in while the account is locked
+	_, err = ts.auth.Login(ctx, correct)
+	lockedFor(t, err, time.Minute)
+
+	// The account holder is told in the background
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(ts.outbox.String(), "Your Taulen account was locked") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := strings.Count(ts.outbox.String(), "Your Taulen account was locked"); n != 1 {
+		t.Fatalf("sent %d lock notices, want 1: %q", n, ts.outbox.String())
+	}
+
+	// Every failure after a lock expires doubles the next lock, up to the maximum
+	for _, d := range []time.Duration{2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		if err := ts.store.Borrowers().LockAccount(borrowerID, time.Now().Add(-time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		_, err = ts.auth.Login(ctx, wrong)
+		lockedFor(t, err, d)
+	}
+
+	// An admin unlock clears the lock and the failures
+	if err := ts.auth.UnlockAccount(ctx, "jane@example.com"); err != nil {
+		t.Fatalf("UnlockAccount: %v", err)
+	}
+	if _, err := ts.auth.Login(ctx, wrong); err == nil || errors.As(err, new(*AccountLockedError)) {
+		t.Fatalf("first failure after unlocking: got %v, want a wrong password error", err)
+	}
+	if _, err := ts.auth.Login(ctx, correct); err != nil {
+		t.Fatalf("Login after unlocking: %v", err)
+	}
+}
+
+func TestUnlockEmployeeAccount(t *testing.T) {
+	ts := newTestServices(t, lockoutEnv)
+	ctx := context.Background()
+	user := createEmployee(t, ts, "lee@example.com", "correct horse")
+
+	if err := ts.store.Users().LockAccount(user.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ts.auth.Login(ctx, LoginRequest{Email: "lee@example.com", Password: "correct horse"})
+	if !errors.As(err, new(*AccountLockedError)) {
+		t.Fatalf("locked employee: got %v, want AccountLockedError", err)
+	}
+
+	if err := ts.auth.UnlockAccount(ctx, "LEE@example.com"); err != nil {
+		t.Fatalf("UnlockAccount: %v", err)
+	}
+	if _, err := ts.auth.Login(ctx, LoginRequest{Email: "lee@example.com", Password: "correct horse"}); err != nil {
+		t.Fatalf("Login after unlocking: %v", err)
+	}
+	if err := ts.auth.UnlockAccount(ctx, "nobody@example.com"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("unlocking an unknown account: got %v, want ErrAccountNotFound", err)
+	}
+}
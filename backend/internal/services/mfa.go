@@ -0,0 +1,470 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/repositories"
+	"taulen/backend/internal/utils"
+)
+
+// TOTP multi-factor authentication. An account enrolls by generating a secret
+// (SetupMFA), adding it to an authenticator app and confirming a code from it
+// (EnableMFA), which also returns single-use backup codes. Once enabled, Login
+// answers a correct password with an MFARequiredError whose token is exchanged
+// together with a TOTP or backup code for a token pair (CompleteMFALogin).
+// When MFA is mandatory for employees, an employee who has not enrolled gets
+// the same challenge and enrolls through the login step instead.
+
+// backupCodeCount is the number of backup codes generated at a time
+const backupCodeCount = 10
+
+var (
+	// ErrInvalidMFAToken is returned for MFA challenge tokens that are malformed, expired or stale
+	ErrInvalidMFAToken = errors.New("invalid or expired MFA token, please log in again")
+	// ErrInvalidMFACode is returned for wrong, reused or already consumed codes
+	ErrInvalidMFACode = errors.New("invalid authentication code")
+	// ErrMFAAlreadyEnabled is returned when setting up MFA on an account that uses it
+	ErrMFAAlreadyEnabled = errors.New("multi-factor authentication is already enabled")
+	// ErrMFANotEnabled is returned when managing MFA on an account that does not use it
+	ErrMFANotEnabled = errors.New("multi-factor authentication is not enabled")
+	// ErrMFASetupNotStarted is returned when confirming MFA before a secret was generated
+	ErrMFASetupNotStarted = errors.New("multi-factor authentication setup has not been started")
+	// ErrMFARequired is returned when disabling MFA that the policy makes mandatory
+	ErrMFARequired = errors.New("multi-factor authentication is required for employee accounts")
+)
+
+// MFARequiredError is returned by Login when the password was correct but the
+// account has to present a second factor, or enroll in MFA first
+type MFARequiredError struct {
+	// Token is the MFA challenge token for the second login step
+	Token string
+	// SetupRequired is set when the account must enroll before logging in
+	SetupRequired bool
+}
+
+func (e *MFARequiredError) Error() string {
+	if e.SetupRequired {
+		return "multi-factor authentication must be set up to log in"
+	}
+	return "multi-factor authentication code required"
+}
+
+// mfaRepository stores MFA state; implemented by the borrower and user repositories
+type mfaRepository interface {
+	lockoutRepository
+	SetMFASecret(id, secret string) error
+	EnableMFA(id, backupCodes string) error
+	DisableMFA(id string) error
+	RecordMFAVerification(id string, step time.Time) (bool, error)
+	SetMFABackupCodes(id, backupCodes string) error
+	ConsumeMFABackupCode(id, codeHash string) (bool, error)
+}
+
+// MFALoginRequest represents the second login step of an account using MFA
+type MFALoginRequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	// Code is a TOTP code, or a backup code once MFA is enabled
+	Code string `json:"code" binding:"required"`
+}
+
+// MFASetupLoginRequest represents a request to enroll in MFA during login
+type MFASetupLoginRequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+}
+
+// MFACodeRequest represents a request confirmed with an authentication code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFASetupResponse carries a new TOTP secret for the authenticator app
+type MFASetupResponse struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// URI to show as a QR code
+	URI string `json:"otpauthUri"`
+}
+
+// MFAStatusResponse describes the MFA state of an account
+type MFAStatusResponse struct {
+	Enabled              bool `json:"enabled"`
+	Pending              bool `json:"pending"`  // setup started but not confirmed
+	Required             bool `json:"required"` // MFA is mandatory for the account
+	BackupCodesRemaining int  `json:"backupCodesRemaining"`
+}
+
+// BackupCodesResponse carries newly generated backup codes, shown only once
+type BackupCodesResponse struct {
+	BackupCodes []string `json:"backupCodes"`
+}
+
+// mfaAccount is the MFA state of a borrower or employee
+type mfaAccount struct {
+	id          string
+	email       string
+	userType    string // SubjectTypeEmployee or SubjectTypeApplicant
+	enabled     bool
+	secret      sql.NullString
+	optedIn     bool // opted in to login verification codes (see two_factor.go)
+	backupCodes sql.NullString
+	lockedUntil sql.NullTime
+	repo        mfaRepository
+
+	// The loaded record, for issuing tokens
+	borrower *repositories.Borrower
+	user     *repositories.User
+}
+
+// loadMFAAccount loads the MFA state of the borrower or employee with the given ID
+func (s *AuthService) loadMFAAccount(id, userType string) (*mfaAccount, error) {
+	if userType == SubjectTypeEmployee {
+		user, err := s.userRepo.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		return &mfaAccount{
+			id:          user.ID,
+			email:       user.Email,
+			userType:    SubjectTypeEmployee,
+			enabled:     user.MFAEnabled,
+			optedIn:     user.TwoFactorEnabled,
+			secret:      user.MFASecret,
+			backupCodes: user.MFABackupCodes,
+			lockedUntil: user.AccountLockedUntil,
+			repo:        s.userRepo,
+			user:        user,
+		}, nil
+	}
+
+	borrower, err := s.borrowerRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return &mfaAccount{
+		id:          borrower.ID,
+		email:       borrower.EmailAddress.String,
+		userType:    SubjectTypeApplicant,
+		enabled:     borrower.MFAEnabled.Bool,
+		optedIn:     borrower.TwoFactorEnabled,
+		secret:      borrower.MFASecret,
+		backupCodes: borrower.MFABackupCodes,
+		lockedUntil: borrower.AccountLockedUntil,
+		repo:        s.borrowerRepo,
+		borrower:    borrower,
+	}, nil
+}
+
+// loadOwnMFAAccount loads the MFA state of an authenticated caller
+func (s *AuthService) loadOwnMFAAccount(id string, role rbac.Role) (*mfaAccount, error) {
+	userType := SubjectTypeEmployee
+	if role == rbac.RoleApplicant {
+		userType = SubjectTypeApplicant
+	}
+	account, err := s.loadMFAAccount(id, userType)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, errors.New("failed to load account: " + err.Error())
+	}
+	return account, nil
+}
+
+// mfaRequired reports whether the policy makes MFA mandatory for the user type
+func (s *AuthService) mfaRequired(userType string) bool {
+	return userType == SubjectTypeEmployee && s.cfg.MFA.RequireEmployees
+}
+
+// mfaChallenge returns an MFARequiredError if the account has to complete
+// login with a second factor, and nil if the password alone suffices
+func (s *AuthService) mfaChallenge(id, email, userType string, enabled bool) error {
+	if !enabled && !s.mfaRequired(userType) {
+		return nil
+	}
+	token, err := s.jwtManager.GenerateMFAToken(id, email, userType, s.cfg.MFA.ChallengeExpiry)
+	if err != nil {
+		return errors.New("failed to generate MFA token")
+	}
+	return &MFARequiredError{Token: token, SetupRequired: !enabled}
+}
+
+// loadChallengedAccount validates an MFA challenge token and loads its account
+func (s *AuthService) loadChallengedAccount(mfaToken string) (*mfaAccount, error) {
+	claims, err := s.jwtManager.ValidateMFAToken(mfaToken)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	account, err := s.loadMFAAccount(claims.UserID, claims.UserType)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, errors.New("failed to load account: " + err.Error())
+	}
+	if account.user != nil {
+		if err := checkEmployeeLogin(account.user); err != nil {
+			return nil, err
+		}
+	}
+	return account, nil
+}
+
+// StartMFALoginSetup generates a TOTP secret for an account that must enroll
+// in MFA before it can log in
+func (s *AuthService) StartMFALoginSetup(ctx context.Context, req MFASetupLoginRequest) (*MFASetupResponse, error) {
+	account, err := s.loadChallengedAccount(req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if account.enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if !s.mfaRequired(account.userType) {
+		return nil, ErrInvalidMFAToken
+	}
+	return s.startMFASetup(ctx, account)
+}
+
+// CompleteMFALogin finishes a login with a TOTP or backup code. For an account
+// enrolling during login the code must come from the new secret; it enables
+// MFA and the response carries the backup codes.
+func (s *AuthService) CompleteMFALogin(ctx context.Context, req MFALoginRequest) (*AuthResponse, error) {
+	account, err := s.loadChallengedAccount(req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var backupCodes []string
+	switch {
+	case account.enabled:
+		if err := s.verifyMFACode(ctx, account, req.Code, true); err != nil {
+			return nil, err
+		}
+	case s.mfaRequired(account.userType):
+		if !account.secret.Valid {
+			return nil, ErrMFASetupNotStarted
+		}
+		if err := s.verifyMFACode(ctx, account, req.Code, false); err != nil {
+			return nil, err
+		}
+		if backupCodes, err = s.enableMFA(ctx, account); err != nil {
+			return nil, err
+		}
+	default:
+		// MFA was turned off since the password check
+		return nil, ErrInvalidMFAToken
+	}
+	s.recordLoginSuccess(ctx, account.repo, account.id)
+
+	var response *AuthResponse
+	if account.user != nil {
+		response, err = s.employeeAuthResponse(ctx, "", account.user)
+	} else {
+		response, err = s.borrowerAuthResponse(ctx, "", account.borrower)
+	}
+	if err != nil {
+		return nil, err
+	}
+	response.BackupCodes = backupCodes
+	return response, nil
+}
+
+// GetMFAStatus returns the MFA state of the caller's account
+func (s *AuthService) GetMFAStatus(ctx context.Context, id string, role rbac.Role) (*MFAStatusResponse, error) {
+	account, err := s.loadOwnMFAAccount(id, role)
+	if err != nil {
+		return nil, err
+	}
+	remaining := 0
+	if account.enabled && account.backupCodes.Valid {
+		remaining = len(strings.Split(account.backupCodes.String, ","))
+	}
+	return &MFAStatusResponse{
+		Enabled:              account.enabled,
+		Pending:              !account.enabled && account.secret.Valid,
+		Required:             s.mfaRequired(account.userType),
+		BackupCodesRemaining: remaining,
+	}, nil
+}
+
+// SetupMFA generates a new TOTP secret for the caller's account. MFA stays off
+// until EnableMFA confirms a code from the secret.
+func (s *AuthService) SetupMFA(ctx context.Context, id string, role rbac.Role) (*MFASetupResponse, error) {
+	account, err := s.loadOwnMFAAccount(id, role)
+	if err != nil {
+		return nil, err
+	}
+	if account.enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	return s.startMFASetup(ctx, account)
+}
+
+// EnableMFA turns on MFA for the caller's account after checking a code from
+// the secret generated by SetupMFA, and returns the backup codes
+func (s *AuthService) EnableMFA(ctx context.Context, id string, role rbac.Role, req MFACodeRequest) (*BackupCodesResponse, error) {
+	account, err := s.loadOwnMFAAccount(id, role)
+	if err != nil {
+		return nil, err
+	}
+	if account.enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if !account.secret.Valid {
+		return nil, ErrMFASetupNotStarted
+	}
+	if err := s.verifyMFACode(ctx, account, req.Code, false); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.enableMFA(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return &BackupCodesResponse{BackupCodes: codes}, nil
+}
+
+// DisableMFA turns off MFA for the caller's account after checking a TOTP or
+// backup code. Employees cannot disable MFA while the policy requires it.
+func (s *AuthService) DisableMFA(ctx context.Context, id string, role rbac.Role, req MFACodeRequest) error {
+	account, err := s.loadOwnMFAAccount(id, role)
+	if err != nil {
+		return err
+	}
+	if s.mfaRequired(account.userType) {
+		return ErrMFARequired
+	}
+	if !account.enabled {
+		return ErrMFANotEnabled
+	}
+	if err := s.verifyMFACode(ctx, account, req.Code, true); err != nil {
+		return err
+	}
+
+	if err := account.repo.DisableMFA(account.id); err != nil {
+		return errors.New("failed to disable MFA: " + err.Error())
+	}
+	s.logger.InfoContext(ctx, "auth: MFA disabled", "account_id", account.id)
+	return nil
+}
+
+// RegenerateBackupCodes replaces the backup codes of the caller's account after
+// checking a TOTP code; the previous codes stop working
+func (s *AuthService) RegenerateBackupCodes(ctx context.Context, id string, role rbac.Role, req MFACodeRequest) (*BackupCodesResponse, error) {
+	account, err := s.loadOwnMFAAccount(id, role)
+	if err != nil {
+		return nil, err
+	}
+	if !account.enabled {
+		return nil, ErrMFANotEnabled
+	}
+	if err := s.verifyMFACode(ctx, account, req.Code, false); err != nil {
+		return nil, err
+	}
+
+	codes, hashes := newBackupCodes()
+	if err := account.repo.SetMFABackupCodes(account.id, hashes); err != nil {
+		return nil, errors.New("failed to store backup codes: " + err.Error())
+	}
+	s.logger.InfoContext(ctx, "auth: MFA backup codes regenerated", "account_id", account.id)
+	return &BackupCodesResponse{BackupCodes: codes}, nil
+}
+
+// startMFASetup stores a new pending TOTP secret for the account
+func (s *AuthService) startMFASetup(ctx context.Context, account *mfaAccount) (*MFASetupResponse, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := account.repo.SetMFASecret(account.id, secret); err != nil {
+		return nil, errors.New("failed to store MFA secret: " + err.Error())
+	}
+
+	label := account.email
+	if label == "" {
+		label = account.id
+	}
+	return &MFASetupResponse{
+		Secret: secret,
+		URI:    utils.TOTPURI(s.cfg.MFA.Issuer, label, secret),
+	}, nil
+}
+
+// enableMFA turns on MFA for the account's pending secret and returns its new backup codes
+func (s *AuthService) enableMFA(ctx context.Context, account *mfaAccount) ([]string, error) {
+	codes, hashes := newBackupCodes()
+	if err := account.repo.EnableMFA(account.id, hashes); err != nil {
+		return nil, errors.New("failed to enable MFA: " + err.Error())
+	}
+	s.logger.InfoContext(ctx, "auth: MFA enabled", "account_id", account.id)
+	return codes, nil
+}
+
+// verifyMFACode checks a TOTP code, or also a backup code when allowBackup is
+// set, against the account's secret. Codes cannot be used twice, and wrong
+// codes count as failed logins towards the account lockout.
+func (s *AuthService) verifyMFACode(ctx context.Context, account *mfaAccount, code string, allowBackup bool) error {
+	if err := checkLocked(account.lockedUntil); err != nil {
+		return err
+	}
+
+	valid, err := s.checkMFACode(ctx, account, strings.TrimSpace(code), allowBackup)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		if err := s.recordLoginFailure(ctx, account.repo, account.id, account.email); err != nil {
+			return err
+		}
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// checkMFACode reports whether a code is a TOTP code of a step that has not
+// been used yet or, if allowed, an unused backup code, consuming it
+func (s *AuthService) checkMFACode(ctx context.Context, account *mfaAccount, code string, allowBackup bool) (bool, error) {
+	if step, ok := utils.ValidateTOTP(account.secret.String, code, time.Now()); ok {
+		recorded, err := account.repo.RecordMFAVerification(account.id, step)
+		if err != nil {
+			return false, errors.New("failed to record MFA verification: " + err.Error())
+		}
+		return recorded, nil
+	}
+	if !allowBackup || !account.backupCodes.Valid {
+		return false, nil
+	}
+
+	consumed, err := account.repo.ConsumeMFABackupCode(account.id, hashToken(normalizeBackupCode(code)))
+	if err != nil {
+		return false, errors.New("failed to consume backup code: " + err.Error())
+	}
+	if consumed {
+		s.logger.InfoContext(ctx, "auth: MFA backup code used", "account_id", account.id)
+	}
+	return consumed, nil
+}
+
+// newBackupCodes generates backup codes formatted as xxxxx-xxxxx and returns
+// them with the comma-separated list of their hashes for storage
+func newBackupCodes() ([]string, string) {
+	codes := make([]string, backupCodeCount)
+	hashes := make([]string, backupCodeCount)
+	for i := range codes {
+		code := strings.ToLower(rand.Text()[:10]) // 50 bits
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashToken(code)
+	}
+	return codes, strings.Join(hashes, ",")
+}
+
+// normalizeBackupCode strips the separator and case from a backup code as typed
+func normalizeBackupCode(code string) string {
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return strings.ToLower(code)
+}
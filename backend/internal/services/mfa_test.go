@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"taulen/backend/internal/rbac"
+)
+
+// totpAt returns the TOTP code of a secret at the given time, as an
+// authenticator app would show it
+func totpAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff%1000000)
+}
+
+// otherCode returns a well-formed code that differs from code
+func otherCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
+
+// mfaChallengeToken asserts that err asks for a second factor and returns its token
+func mfaChallengeToken(t *testing.T, err error, setupRequired bool) string {
+	t.Helper()
+	var challenge *MFARequiredError
+	if !errors.As(err, &challenge) {
+		t.Fatalf("got %v, want MFARequiredError", err)
+	}
+	if challenge.SetupRequired != setupRequired {
+		t.Fatalf("SetupRequired = %v, want %v", challenge.SetupRequired, setupRequired)
+	}
+	return challenge.Token
+}
+
+func TestMFA(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+
+	registered, err := ts.auth.Register(ctx, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	id := registered.User.ID
+	login := LoginRequest{Email: "jane@example.com", Password: "correct horse"}
+
+	if _, err := ts.auth.EnableMFA(ctx, id, rbac.RoleApplicant, MFACodeRequest{Code: "123456"}); !errors.Is(err, ErrMFASetupNotStarted) {
+		t.Fatalf("enabling before setup: got %v, want ErrMFASetupNotStarted", err)
+	}
+	setup, err := ts.auth.SetupMFA(ctx, id, rbac.RoleApplicant)
+	if err != nil {
+		t.Fatalf("SetupMFA: %v", err)
+	}
+	if !strings.HasPrefix(setup.URI, "otpauth://totp/Taulen:jane@example.com?") {
+		t.Errorf("otpauth URI %q", setup.URI)
+	}
+
+	// MFA stays off until a code from the new secret confirms it
+	now := time.Now()
+	code := totpAt(t, setup.Secret, now)
+	if _, err := ts.auth.EnableMFA(ctx, id, rbac.RoleApplicant, MFACodeRequest{Code: otherCode(code)}); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("wrong code: got %v, want ErrInvalidMFACode", err)
+	}
+	status, err := ts.auth.GetMFAStatus(ctx, id, rbac.RoleApplicant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Enabled || !status.Pending {
+		t.Fatalf("status before confirming: %+v", status)
+	}
+	if _, err := ts.auth.Login(ctx, login); err != nil {
+		t.Fatalf("Login before confirming: %v", err)
+	}
+
+	enabled, err := ts.auth.EnableMFA(ctx, id, rbac.RoleApplicant, MFACodeRequest{Code: code})
+	if err != nil {
+		t.Fatalf("EnableMFA: %v", err)
+	}
+	if len(enabled.BackupCodes) != backupCodeCount {
+		t.Fatalf("got %d backup codes, want %d", len(enabled.BackupCodes), backupCodeCount)
+	}
+
+	// The password alone no longer logs in
+	_, err = ts.auth.Login(ctx, login)
+	token := mfaChallengeToken(t, err, false)
+	if _, err := ts.auth.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: "not a token", Code: code}); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("invalid MFA token: got %v, want ErrInvalidMFAToken", err)
+	}
+
+	// A code that was already used cannot be replayed
+	if _, err := ts.auth.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: token, Code: code}); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("replayed code: got %v, want ErrInvalidMFACode", err)
+	}
+	resp, err := ts.auth.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: token, Code: totpAt(t, setup.Secret, now.Add(30*time.Second))})
+	if err != nil {
+		t.Fatalf("CompleteMFALogin: %v", err)
+	}
+	if resp.AccessToken == "" || resp.User.ID != id {
+		t.Fatalf("CompleteMFALogin response: %+v", resp)
+	}
+
+	// Backup codes work once each, however they are typed
+	backup := enabled.BackupCodes[0]
+	if _, err := ts.auth.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: token, Code: strings.ToUpper(backup)}); err != nil {
+		t.Fatalf("login with a backup code: %v", err)
+	}
+	if _, err := ts.auth.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: token, Code: backup}); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("reused backup code: got %v, want ErrInvalidMFACode", err)
+	}
+	status, err = ts.auth.GetMFAStatus(ctx, id, rbac.RoleApplicant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Enabled || status.BackupCodesRemaining != backupCodeCount-1 {
+		t.Fatalf("status after using a backup code: %+v", status)
+	}
+
+	// Disabling MFA takes a code, then the password logs in on its own again
+	if err := ts.auth.DisableMFA(ctx, id, rbac.RoleApplicant, MFACodeRequest{Code: backup}); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("disabling with a used backup code: got %v, want ErrInvalidMFACode", err)
+	}
+	if err := ts.auth.DisableMFA(ctx, id, rbac.RoleApplicant, MFACodeRequest{Code: enabled.BackupCodes[1]}); err != nil {
+		t.Fatalf("DisableMFA: %v", err)
+	}
+	if _, err := ts.auth.Login(ctx, login); err != nil {
+		t.Fatalf("Login after disabling MFA: %v", err)
+	}
+	if _, err := ts.auth.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: token, Code: enabled.BackupCodes[2]}); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("challenge from before disabling: got %v, want ErrInvalidMFAToken", err)
+	}
+}
+
+func TestRequiredEmployeeMFAEnrollsAtLogin(t *testing.T) {
+	ts := newTestServices(t, map[string]string{"TAULEN_MFA_REQUIRE_EMPLOYEES": "true"})
+	ctx := context.Background()
+	user := createEmployee(t, ts, "lee@example.com", "correct horse")
+	login := LoginRequest{Email: "lee@example.com", Password: "correct horse"}
+
+	_, err := ts.auth.Login(ctx, login)
+	token := mfaChallengeToken(t, err, true)
+	if _, err := ts.auth.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: token, Code: "123456"}); !errors.Is(err, ErrMFASetupNotStarted) {
+		t.Fatalf("completing before setup: got %v, want ErrMFASetupNotStarted", err)
+	}
+
+	setup, err := ts.auth.StartMFALoginSetup(ctx, MFASetupLoginRequest{MFAToken: token})
+	if err != nil {
+		t.Fatalf("StartMFALoginSetup: %v", err)
+	}
+	resp, err := ts.auth.CompleteMFALogin(ctx, MFALoginRequest{MFAToken: token, Code: totpAt(t, setup.Secret, time.Now())})
+	if err != nil {
+		t.Fatalf("CompleteMFALogin: %v", err)
+	}
+	if resp.AccessToken == "" || len(resp.BackupCodes) != backupCodeCount {
+		t.Fatalf("enrolling login returned %d backup codes, want %d", len(resp.BackupCodes), backupCodeCount)
+	}
+
+	// Enrolled employees get the ordinary challenge and cannot opt out
+	_, err = ts.auth.Login(ctx, login)
+	mfaChallengeToken(t, err, false)
+	if _, err := ts.auth.StartMFALoginSetup(ctx, MFASetupLoginRequest{MFAToken: token}); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Fatalf("setup after enrolling: got %v, want ErrMFAAlreadyEnabled", err)
+	}
+	err = ts.auth.DisableMFA(ctx, user.ID, rbac.RoleLoanOfficer, MFACodeRequest{Code: resp.BackupCodes[0]})
+	if !errors.Is(err, ErrMFARequired) {
+		t.Fatalf("DisableMFA: got %v, want ErrMFARequired", err)
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"net/url"
+	"time"
+
+	"taulen/backend/internal/utils"
+)
+
+// ErrInvalidResetToken is returned for password reset tokens that are unknown, used or expired
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// passwordResetRepository stores reset tokens; implemented by the borrower and user repositories
+type passwordResetRepository interface {
+	SetPasswordResetToken(id, tokenHash string, expiresAt time.Time) error
+}
+
+// RequestPasswordResetRequest represents a request to start a password reset
+type RequestPasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ConfirmPasswordResetRequest represents a request to set a new password with a reset token
+type ConfirmPasswordResetRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// RequestPasswordReset emails a single-use reset link to the borrower or active
+// employee with the given email; employees get none while they have to sign in
+// with SSO. Only a hash of the token is stored. Unknown
+// emails are ignored without error and the email is sent in the background, so
+// the response does not reveal whether the email is registered.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, req RequestPasswordResetRequest) error {
+	var (
+		accountID string
+		repo      passwordResetRepository
+	)
+
+	borrower, err := s.borrowerRepo.GetByEmail(req.Email)
+	switch {
+	case err == nil:
+		accountID, repo = borrower.ID, s.borrowerRepo
+	case !errors.Is(err, sql.ErrNoRows):
+		return errors.New("failed to check borrower account")
+	default:
+		user, err := s.userRepo.GetByEmail(req.Email)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return errors.New("failed to check user account")
+		}
+		if !user.Active() || s.cfg.OIDC.DisablePasswordLogin {
+			return nil
+		}
+		accountID, repo = user.ID, s.userRepo
+	}
+
+	return s.issuePasswordReset(ctx, repo, accountID, req.Email)
+}
+
+// issuePasswordReset stores a new reset token for the account, replacing any
+// previous one, and emails the reset link in the background
+func (s *AuthService) issuePasswordReset(ctx context.Context, repo passwordResetRepository, accountID, email string) error {
+	token := rand.Text()
+	expiresIn := s.cfg.PasswordReset.TokenExpiry
+	if err := repo.SetPasswordResetToken(accountID, hashToken(token), time.Now().Add(expiresIn)); err != nil {
+		return errors.New("failed to store password reset token: " + err.Error())
+	}
+
+	link := s.cfg.PasswordReset.URL + "?token=" + url.QueryEscape(token)
+	go s.sendPasswordReset(context.WithoutCancel(ctx), email, link, expiresIn)
+	return nil
+}
+
+// sendPasswordReset emails a password reset link
+func (s *AuthService) sendPasswordReset(ctx context.Context, email, link string, expiresIn time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+	defer cancel()
+
+	if err := s.email.SendPasswordReset(ctx, email, link, expiresIn); err != nil {
+		s.logger.WarnContext(ctx, "auth: failed to send password reset email", "error", err)
+	}
+}
+
+// ConfirmPasswordReset sets a new password using a reset token. The token is
+// consumed, any login lockout is cleared and every session of the account is
+// revoked.
+func (s *AuthService) ConfirmPasswordReset(ctx context.Context, req ConfirmPasswordResetRequest) error {
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+	tokenHash := hashToken(req.Token)
+
+	accountID, err := s.borrowerRepo.ResetPassword(tokenHash, passwordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		accountID, err = s.userRepo.ResetPassword(tokenHash, passwordHash)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidResetToken
+		}
+		return errors.New("failed to reset password: " + err.Error())
+	}
+
+	if err := s.sessions.RevokeAll(ctx, accountID); err != nil {
+		return err
+	}
+	s.logger.InfoContext(ctx, "auth: password reset", "account_id", accountID)
+	return nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var resetLinkPattern = regexp.MustCompile(`reset-password\?token=(\S+)`)
+
+// waitForResetToken waits for the password reset email sent in the background
+// and returns the token of its link
+func waitForResetToken(t *testing.T, outbox *outbox) string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !resetLinkPattern.MatchString(outbox.String()) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	match := resetLinkPattern.FindStringSubmatch(outbox.String())
+	if match == nil {
+		t.Fatalf("no password reset link sent: %q", outbox.String())
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestPasswordReset(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+
+	registered, err := ts.auth.Register(ctx, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	loggedIn, err := ts.auth.Login(ctx, LoginRequest{Email: "jane@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if err := ts.auth.RequestPasswordReset(ctx, RequestPasswordResetRequest{Email: "jane@example.com"}); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	token := waitForResetToken(t, ts.outbox)
+
+	// Only the hash of the token is stored
+	borrower, err := ts.store.Borrowers().GetByID(registered.User.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if borrower.PasswordResetToken.String != hashToken(token) {
+		t.Fatalf("stored reset token %q, want the hash of the token", borrower.PasswordResetToken.String)
+	}
+
+	err = ts.auth.ConfirmPasswordReset(ctx, ConfirmPasswordResetRequest{Token: "unknown", Password: "battery staple"})
+	if !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("unknown token: got %v, want ErrInvalidResetToken", err)
+	}
+	if err := ts.auth.ConfirmPasswordReset(ctx, ConfirmPasswordResetRequest{Token: token, Password: "battery staple"}); err != nil {
+		t.Fatalf("ConfirmPasswordReset: %v", err)
+	}
+
+	// The token works once
+	err = ts.auth.ConfirmPasswordReset(ctx, ConfirmPasswordResetRequest{Token: token, Password: "another password"})
+	if !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("reused token: got %v, want ErrInvalidResetToken", err)
+	}
+
+	// Every existing session ends
+	for _, refreshToken := range []string{registered.RefreshToken, loggedIn.RefreshToken} {
+		if _, err := ts.auth.RefreshToken(ctx, RefreshRequest{RefreshToken: refreshToken}); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Fatalf("refresh after the reset: got %v, want ErrInvalidRefreshToken", err)
+		}
+	}
+
+	if _, err := ts.auth.Login(ctx, LoginRequest{Email: "jane@example.com", Password: "correct horse"}); err == nil {
+		t.Fatal("Login with the old password succeeded")
+	}
+	if _, err := ts.auth.Login(ctx, LoginRequest{Email: "jane@example.com", Password: "battery staple"}); err != nil {
+		t.Fatalf("Login with the new password: %v", err)
+	}
+}
+
+func TestExpiredPasswordResetToken(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	user := createEmployee(t, ts, "lee@example.com", "correct horse")
+
+	if err := ts.store.Users().SetPasswordResetToken(user.ID, hashToken("expired token"), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	err := ts.auth.ConfirmPasswordReset(ctx, ConfirmPasswordResetRequest{Token: "expired token", Password: "battery staple"})
+	if !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("expired token: got %v, want ErrInvalidResetToken", err)
+	}
+	if _, err := ts.auth.Login(ctx, LoginRequest{Email: "lee@example.com", Password: "correct horse"}); err != nil {
+		t.Fatalf("the password changed: %v", err)
+	}
+}
+
+func TestPasswordResetForUnknownEmail(t *testing.T) {
+	ts := newTestServices(t, nil)
+
+	// Unknown emails get the same answer and no email
+	if err := ts.auth.RequestPasswordReset(context.Background(), RequestPasswordResetRequest{Email: "nobody@example.com"}); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if ts.outbox.Len() != 0 {
+		t.Fatalf("sent %q for an unknown email", ts.outbox.String())
+	}
+}
@@ -1,23 +1,29 @@
 package services
 
 import (
+	"context"
 	"taulen/backend/internal/repositories"
 )
 
 // ProgressService handles deal progress tracking
 type ProgressService struct {
-	dealProgressRepo *repositories.DealProgressRepository
+	dealProgressRepo repositories.DealProgressRepository
 }
 
 // NewProgressService creates a new progress service
-func NewProgressService() *ProgressService {
+func NewProgressService(store repositories.Store) *ProgressService {
 	return &ProgressService{
-		dealProgressRepo: repositories.NewDealProgressRepository(),
+		dealProgressRepo: store.DealProgress(),
 	}
 }
 
+// withStore returns a copy of the service whose repositories use the given store
+func (s *ProgressService) withStore(store repositories.Store) *ProgressService {
+	return NewProgressService(store)
+}
+
 // GetDealProgress retrieves progress for a deal
-func (s *ProgressService) GetDealProgress(dealID string) (map[string]interface{}, error) {
+func (s *ProgressService) GetDealProgress(ctx context.Context, dealID string) (map[string]interface{}, error) {
 	progress, err := s.dealProgressRepo.GetByDealID(dealID)
 	if err != nil {
 		return nil, err
@@ -75,11 +81,11 @@ func (s *ProgressService) GetDealProgress(dealID string) (map[string]interface{}
 }
 
 // UpdateDealProgressSection updates a specific section's completion status
-func (s *ProgressService) UpdateDealProgressSection(dealID string, section string, complete bool) error {
+func (s *ProgressService) UpdateDealProgressSection(ctx context.Context, dealID string, section string, complete bool) error {
 	return s.dealProgressRepo.UpdateSection(dealID, section, complete)
 }
 
 // UpdateDealProgressNotes updates progress notes
-func (s *ProgressService) UpdateDealProgressNotes(dealID string, notes string) error {
+func (s *ProgressService) UpdateDealProgressNotes(ctx context.Context, dealID string, notes string) error {
 	return s.dealProgressRepo.UpdateNotes(dealID, notes)
 }
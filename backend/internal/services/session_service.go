@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/notify"
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/repositories"
+	"taulen/backend/internal/utils"
+	"time"
+)
+
+// Refresh session subject types
+const (
+	SubjectTypeEmployee  = "employee"
+	SubjectTypeApplicant = "applicant"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for refresh tokens that are malformed,
+	// expired, unknown or revoked
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is
+	// presented again; the whole token family has been revoked
+	ErrRefreshTokenReused = errors.New("refresh token has already been used, please log in again")
+)
+
+// SessionService issues token pairs backed by server-side refresh sessions.
+// Every refresh rotates the refresh token; presenting a rotated token again is
+// treated as theft and revokes every token descended from the same login.
+type SessionService struct {
+	sessionRepo repositories.RefreshSessionRepository
+	jwtManager  *utils.JWTManager
+	email       *EmailService
+	logger      *slog.Logger
+}
+
+// NewSessionService creates a new session service sending new device notices
+// through notifier
+func NewSessionService(cfg *config.Config, store repositories.Store, notifier notify.Notifier, logger *slog.Logger) *SessionService {
+	return &SessionService{
+		sessionRepo: store.RefreshSessions(),
+		jwtManager:  utils.NewJWTManager(&cfg.JWT),
+		email:       NewEmailService(notifier, logger),
+		logger:      logger,
+	}
+}
+
+// withStore returns a copy of the service whose repositories use the given store
+func (s *SessionService) withStore(store repositories.Store) *SessionService {
+	return &SessionService{
+		sessionRepo: store.RefreshSessions(),
+		jwtManager:  s.jwtManager,
+		email:       s.email,
+		logger:      s.logger,
+	}
+}
+
+// Issue creates a refresh session for the subject and returns an access and a
+// refresh token bound to it. An empty familyID starts a new family (a login).
+// The session records the client attached to ctx by WithClient.
+func (s *SessionService) Issue(ctx context.Context, familyID, subjectID, subjectType, email string, role rbac.Role) (accessToken, refreshToken string, err error) {
+	sessionID, err := utils.NewUUID()
+	if err != nil {
+		return "", "", errors.New("failed to generate session id: " + err.Error())
+	}
+	newLogin := familyID == ""
+	if newLogin {
+		familyID = sessionID
+	}
+	client := clientFrom(ctx)
+
+	refreshToken, expiresAt, err := s.jwtManager.GenerateRefreshToken(subjectID, email, sessionID)
+	if err != nil {
+		return "", "", errors.New("failed to generate refresh token")
+	}
+	accessToken, err = s.jwtManager.GenerateAccessToken(subjectID, email, string(role), sessionID)
+	if err != nil {
+		return "", "", errors.New("failed to generate access token")
+	}
+
+	err = s.sessionRepo.Create(&repositories.RefreshSession{
+		ID:          sessionID,
+		FamilyID:    familyID,
+		SubjectID:   subjectID,
+		SubjectType: subjectType,
+		TokenHash:   hashToken(refreshToken),
+		ExpiresAt:   expiresAt,
+		UserAgent:   nullString(truncateUserAgent(client.UserAgent)),
+		IPAddress:   nullString(client.IP),
+	})
+	if err != nil {
+		return "", "", errors.New("failed to store refresh session: " + err.Error())
+	}
+	if newLogin {
+		s.noticeDevice(ctx, subjectID, email, client)
+	}
+	return accessToken, refreshToken, nil
+}
+
+// Rotate consumes a refresh token and returns its session, whose FamilyID and
+// subject the caller passes to Issue for the replacement tokens. Presenting a
+// token that was already rotated revokes its family and returns ErrRefreshTokenReused.
+func (s *SessionService) Rotate(ctx context.Context, refreshToken string) (*repositories.RefreshSession, error) {
+	claims, err := s.jwtManager.ValidateRefreshToken(refreshToken)
+	if err != nil || claims.ID == "" || !utils.IsUUID(claims.ID) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessionRepo.GetByID(claims.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, errors.New("failed to load refresh session: " + err.Error())
+	}
+	if subtle.ConstantTimeCompare([]byte(session.TokenHash), []byte(hashToken(refreshToken))) != 1 {
+		return nil, ErrInvalidRefreshToken
+	}
+	if session.RevokedAt.Valid || !session.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	rotated := false
+	if !session.RotatedAt.Valid {
+		rotated, err = s.sessionRepo.MarkRotated(session.ID)
+		if err != nil {
+			return nil, errors.New("failed to rotate refresh session: " + err.Error())
+		}
+	}
+	if !rotated {
+		// Either an earlier refresh or a concurrent one already used this token
+		if err := s.sessionRepo.RevokeFamily(session.FamilyID); err != nil {
+			return nil, errors.New("failed to revoke refresh sessions: " + err.Error())
+		}
+		s.logger.WarnContext(ctx, "session: refresh token reuse detected, revoked token family",
+			"session_id", session.ID, "family_id", session.FamilyID, "subject_type", session.SubjectType)
+		return nil, ErrRefreshTokenReused
+	}
+	return session, nil
+}
+
+// Revoke revokes the family of the given session, ending the login it belongs
+// to. Unknown sessions are ignored so that logging out twice succeeds.
+func (s *SessionService) Revoke(ctx context.Context, sessionID string) error {
+	if !utils.IsUUID(sessionID) {
+		return nil
+	}
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return errors.New("failed to load refresh session: " + err.Error())
+	}
+	if err := s.sessionRepo.RevokeFamily(session.FamilyID); err != nil {
+		return errors.New("failed to revoke refresh sessions: " + err.Error())
+	}
+	return nil
+}
+
+// List returns the subject's active logins, most recently seen first, marking
+// the one of currentSessionID as current
+func (s *SessionService) List(ctx context.Context, subjectID, currentSessionID string) ([]SessionResponse, error) {
+	currentFamilyID, err := s.familyOf(subjectID, currentSessionID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.sessionRepo.ListActive(subjectID)
+	if err != nil {
+		return nil, errors.New("failed to list refresh sessions: " + err.Error())
+	}
+
+	responses := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, sessionResponse(session, currentFamilyID))
+	}
+	return responses, nil
+}
+
+// RevokeLogin revokes the subject's active login with the given family ID, as
+// listed by List, and returns ErrSessionNotFound for any other ID
+func (s *SessionService) RevokeLogin(ctx context.Context, subjectID, familyID string) error {
+	sessions, err := s.sessionRepo.ListActive(subjectID)
+	if err != nil {
+		return errors.New("failed to list refresh sessions: " + err.Error())
+	}
+	for _, session := range sessions {
+		if session.FamilyID == familyID {
+			if err := s.sessionRepo.RevokeFamily(familyID); err != nil {
+				return errors.New("failed to revoke refresh sessions: " + err.Error())
+			}
+			return nil
+		}
+	}
+	return ErrSessionNotFound
+}
+
+// RevokeOthers revokes every session of the subject except the login of
+// currentSessionID; without a current session every session is revoked
+func (s *SessionService) RevokeOthers(ctx context.Context, subjectID, currentSessionID string) error {
+	currentFamilyID, err := s.familyOf(subjectID, currentSessionID)
+	if err != nil {
+		return err
+	}
+	if currentFamilyID == "" {
+		return s.RevokeAll(ctx, subjectID)
+	}
+	if err := s.sessionRepo.RevokeSubjectExcept(subjectID, currentFamilyID); err != nil {
+		return errors.New("failed to revoke refresh sessions: " + err.Error())
+	}
+	return nil
+}
+
+// familyOf returns the family of the subject's session with the given ID, or
+// an empty string if there is no such session
+func (s *SessionService) familyOf(subjectID, sessionID string) (string, error) {
+	if !utils.IsUUID(sessionID) {
+		return "", nil
+	}
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", errors.New("failed to load refresh session: " + err.Error())
+	}
+	if session.SubjectID != subjectID {
+		return "", nil
+	}
+	return session.FamilyID, nil
+}
+
+// RevokeAll revokes every session of the subject, logging it out everywhere
+func (s *SessionService) RevokeAll(ctx context.Context, subjectID string) error {
+	if err := s.sessionRepo.RevokeSubject(subjectID); err != nil {
+		return errors.New("failed to revoke refresh sessions: " + err.Error())
+	}
+	return nil
+}
+
+// DeleteExpired deletes refresh sessions that have expired and returns how many were deleted
+func (s *SessionService) DeleteExpired(ctx context.Context) (int64, error) {
+	deleted, err := s.sessionRepo.DeleteExpired(time.Now())
+	if err != nil {
+		return 0, errors.New("failed to delete expired refresh sessions: " + err.Error())
+	}
+	return deleted, nil
+}
+
+// hashToken returns the hex SHA-256 of a token, the form in which tokens are stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
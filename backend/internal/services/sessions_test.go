@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const (
+	chromeOnMac    = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	safariOnIPhone = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+)
+
+// sessionOf returns the session ID carried by an access token
+func sessionOf(t *testing.T, ts *testServices, accessToken string) string {
+	t.Helper()
+	claims, err := ts.auth.jwtManager.ValidateAccessToken(accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims.SessionID
+}
+
+func TestSessions(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	login := LoginRequest{Email: "jane@example.com", Password: "correct horse"}
+
+	registered, err := ts.auth.Register(WithClient(ctx, Client{IP: "192.0.2.1", UserAgent: chromeOnMac}), RegisterRequest{
+		Email: login.Email, Password: login.Password, FirstName: "Jane", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	id := registered.User.ID
+	onPhone := WithClient(ctx, Client{IP: "198.51.100.7", UserAgent: safariOnIPhone})
+	phone, err := ts.auth.Login(onPhone, login)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	// Refreshing keeps the login a single session
+	refreshed, err := ts.auth.RefreshToken(onPhone, RefreshRequest{RefreshToken: phone.RefreshToken})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	current := sessionOf(t, ts, refreshed.AccessToken)
+
+	sessions, err := ts.auth.ListSessions(ctx, id, current)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	devices := map[string]SessionResponse{}
+	for _, session := range sessions {
+		devices[session.Device] = session
+	}
+	mac, iPhone := devices["Chrome on macOS"], devices["Safari on iOS"]
+	if len(sessions) != 2 || mac.ID == "" || iPhone.ID == "" {
+		t.Fatalf("sessions = %+v, want one on each device", sessions)
+	}
+	if mac.Current || !iPhone.Current || iPhone.IPAddress != "198.51.100.7" {
+		t.Fatalf("sessions = %+v, want the iPhone's to be current", sessions)
+	}
+
+	// Sessions can only be revoked by their own account
+	if err := ts.auth.RevokeSession(ctx, "someone-else", mac.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoking another account's session: got %v, want ErrSessionNotFound", err)
+	}
+	if err := ts.auth.RevokeSession(ctx, id, mac.ID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, err := ts.auth.RefreshToken(ctx, RefreshRequest{RefreshToken: registered.RefreshToken}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("refresh of the revoked session: got %v, want ErrInvalidRefreshToken", err)
+	}
+	if err := ts.auth.RevokeSession(ctx, id, mac.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoking again: got %v, want ErrSessionNotFound", err)
+	}
+
+	// Signing out everywhere else keeps the current session
+	laptop, err := ts.auth.Login(ctx, login)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if err := ts.auth.RevokeOtherSessions(ctx, id, current); err != nil {
+		t.Fatalf("RevokeOtherSessions: %v", err)
+	}
+	if _, err := ts.auth.RefreshToken(ctx, RefreshRequest{RefreshToken: laptop.RefreshToken}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("refresh of another session: got %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := ts.auth.RefreshToken(ctx, RefreshRequest{RefreshToken: refreshed.RefreshToken}); err != nil {
+		t.Fatalf("refresh of the current session: %v", err)
+	}
+}
+
+func TestDescribeDevice(t *testing.T) {
+	for userAgent, want := range map[string]string{
+		chromeOnMac:    "Chrome on macOS",
+		safariOnIPhone: "Safari on iOS",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0": "Edge on Windows",
+		"curl/8.4.0": unknownDevice,
+		"":           unknownDevice,
+	} {
+		if got := describeDevice(userAgent); got != want {
+			t.Errorf("describeDevice(%q) = %q, want %q", userAgent, got, want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+
+	"taulen/backend/internal/oidc"
+	"taulen/backend/internal/repositories"
+	"taulen/backend/internal/utils"
+)
+
+// Single sign-on for employees through the company identity provider with
+// OpenID Connect. Starting a login returns the provider URL to send the browser
+// to and an SSO token holding the login's state, nonce and PKCE code verifier;
+// the client keeps the token and presents it with the code and state the
+// provider sends back. Employees are matched by their provider subject, linked
+// by verified email on their first SSO login or provisioned on the spot, and
+// get the role mapped from their provider groups on every login. SSO logins do
+// not ask for a second factor; the provider is trusted to have checked one.
+
+var (
+	// ErrSSODisabled is returned by the SSO endpoints when no identity provider is configured
+	ErrSSODisabled = errors.New("single sign-on is not enabled")
+	// ErrSSOUnavailable is returned when the identity provider cannot be reached
+	ErrSSOUnavailable = errors.New("identity provider is unavailable, please try again later")
+	// ErrInvalidSSOLogin is returned for expired or tampered logins and codes the provider rejects
+	ErrInvalidSSOLogin = errors.New("invalid or expired single sign-on login")
+	// ErrSSONotAuthorized is returned when none of the employee's provider groups maps to a role
+	ErrSSONotAuthorized = errors.New("your account is not authorized to use this application")
+	// ErrSSOAccountConflict is returned when the provider account cannot be matched to an employee safely
+	ErrSSOAccountConflict = errors.New("this email belongs to an account that cannot be linked to single sign-on, please contact an administrator")
+	// ErrPasswordLoginDisabled is returned for employee password logins and resets while SSO is enforced
+	ErrPasswordLoginDisabled = errors.New("employees must sign in with single sign-on")
+)
+
+// SSOAuthorizeResponse represents the start of an SSO login
+type SSOAuthorizeResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+	SSOToken         string `json:"ssoToken"` // kept by the client and presented with the callback
+	State            string `json:"state"`
+}
+
+// SSOCallbackRequest represents the provider's callback to the frontend
+type SSOCallbackRequest struct {
+	Code     string `json:"code" binding:"required"`
+	State    string `json:"state" binding:"required"`
+	SSOToken string `json:"ssoToken" binding:"required"`
+}
+
+// StartSSOLogin returns the provider URL that starts an SSO login, together
+// with the SSO token to present when the provider redirects back
+func (s *AuthService) StartSSOLogin(ctx context.Context) (*SSOAuthorizeResponse, error) {
+	if s.sso == nil {
+		return nil, ErrSSODisabled
+	}
+
+	state, nonce, verifier := rand.Text(), rand.Text(), oidc.NewCodeVerifier()
+	authorizationURL, err := s.sso.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "auth: failed to start SSO login", "error", err)
+		return nil, ErrSSOUnavailable
+	}
+	ssoToken, err := s.jwtManager.GenerateSSOToken(state, nonce, verifier, s.cfg.OIDC.LoginExpiry)
+	if err != nil {
+		return nil, errors.New("failed to generate SSO token")
+	}
+
+	return &SSOAuthorizeResponse{AuthorizationURL: authorizationURL, SSOToken: ssoToken, State: state}, nil
+}
+
+// CompleteSSOLogin redeems the provider's authorization code and logs the
+// employee in, provisioning their account on their first login
+func (s *AuthService) CompleteSSOLogin(ctx context.Context, req SSOCallbackRequest) (*AuthResponse, error) {
+	if s.sso == nil {
+		return nil, ErrSSODisabled
+	}
+
+	claims, err := s.jwtManager.ValidateSSOToken(req.SSOToken)
+	if err != nil || subtle.ConstantTimeCompare([]byte(claims.State), []byte(req.State)) != 1 {
+		return nil, ErrInvalidSSOLogin
+	}
+
+	idToken, err := s.sso.Exchange(ctx, req.Code, claims.CodeVerifier, claims.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrProvider) {
+			s.logger.ErrorContext(ctx, "auth: SSO provider unavailable", "error", err)
+			return nil, ErrSSOUnavailable
+		}
+		s.logger.WarnContext(ctx, "auth: SSO login rejected", "error", err)
+		return nil, ErrInvalidSSOLogin
+	}
+
+	role := s.ssoRole(idToken.Groups(s.cfg.OIDC.GroupsClaim))
+	if role == "" {
+		s.logger.WarnContext(ctx, "auth: SSO login without a mapped group", "subject", idToken.Subject)
+		return nil, ErrSSONotAuthorized
+	}
+
+	user, err := s.ssoEmployee(ctx, idToken, role)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active() {
+		return nil, ErrAccountDeactivated
+	}
+	if err := s.syncSSOProfile(ctx, user, idToken, role); err != nil {
+		return nil, err
+	}
+
+	s.recordLoginSuccess(ctx, s.userRepo, user.ID)
+	return s.employeeAuthResponse(ctx, "", user)
+}
+
+// ssoRole returns the role of the first role mapping whose group is listed,
+// or "" if none is
+func (s *AuthService) ssoRole(groups []string) string {
+	for _, mapping := range s.cfg.OIDC.RoleMappings {
+		if slices.Contains(groups, mapping.Group) {
+			return mapping.Role
+		}
+	}
+	return ""
+}
+
+// ssoEmployee returns the employee linked to the provider account. An employee
+// who has not used SSO yet is linked by email, but only if the provider has
+// verified the email; an unknown email gets a new employee account.
+func (s *AuthService) ssoEmployee(ctx context.Context, idToken *oidc.IDToken, role string) (*repositories.User, error) {
+	user, err := s.userRepo.GetBySSOSubject(idToken.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("failed to check user account")
+	}
+
+	if idToken.Email == "" {
+		s.logger.WarnContext(ctx, "auth: SSO login without an email", "subject", idToken.Subject)
+		return nil, ErrSSOAccountConflict
+	}
+
+	user, err = s.userRepo.GetByEmail(idToken.Email)
+	switch {
+	case err == nil:
+		if user.SSOSubject.Valid || !idToken.EmailVerified {
+			s.logger.WarnContext(ctx, "auth: SSO login cannot be linked", "subject", idToken.Subject, "employee_id", user.ID)
+			return nil, ErrSSOAccountConflict
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		user, err = s.provisionSSOEmployee(ctx, idToken, role)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("failed to check user account")
+	}
+
+	if err := s.userRepo.LinkSSOSubject(user.ID, idToken.Subject); err != nil {
+		return nil, errors.New("failed to link SSO account: " + err.Error())
+	}
+	user.SSOSubject = sql.NullString{String: idToken.Subject, Valid: true}
+	s.logger.InfoContext(ctx, "auth: SSO account linked", "employee_id", user.ID)
+	return user, nil
+}
+
+// provisionSSOEmployee creates the employee account for a provider account.
+// Its password is random and never revealed, so the employee signs in with SSO
+// until they set a password through a password reset.
+func (s *AuthService) provisionSSOEmployee(ctx context.Context, idToken *oidc.IDToken, role string) (*repositories.User, error) {
+	if _, err := s.borrowerRepo.GetByEmail(idToken.Email); err == nil {
+		s.logger.WarnContext(ctx, "auth: SSO email belongs to a borrower", "subject", idToken.Subject)
+		return nil, ErrSSOAccountConflict
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("failed to check borrower account")
+	}
+
+	passwordHash, err := utils.HashPassword(rand.Text())
+	if err != nil {
+		return nil, errors.New("failed to hash password")
+	}
+	user, err := s.userRepo.Create(idToken.Email, passwordHash, idToken.GivenName, idToken.FamilyName, role)
+	if err != nil {
+		return nil, errors.New("failed to create employee: " + err.Error())
+	}
+	s.logger.InfoContext(ctx, "auth: SSO employee provisioned", "employee_id", user.ID, "role", role)
+	return user, nil
+}
+
+// syncSSOProfile applies the role mapped from the employee's provider groups
+// and the name and email verification the provider reports
+func (s *AuthService) syncSSOProfile(ctx context.Context, user *repositories.User, idToken *oidc.IDToken, role string) error {
+	var firstName, lastName, newRole *string
+	if idToken.GivenName != "" && idToken.GivenName != user.FirstName.String {
+		firstName = &idToken.GivenName
+	}
+	if idToken.FamilyName != "" && idToken.FamilyName != user.LastName.String {
+		lastName = &idToken.FamilyName
+	}
+	if repositories.EmployeeRole(role) != user.Role {
+		newRole = &role
+	}
+
+	if firstName != nil || lastName != nil || newRole != nil {
+		if err := s.userRepo.UpdateProfile(user.ID, firstName, lastName, nil, newRole); err != nil {
+			return errors.New("failed to update employee: " + err.Error())
+		}
+		if newRole != nil {
+			s.logger.InfoContext(ctx, "auth: employee role changed by SSO", "employee_id", user.ID, "from", user.Role, "role", role)
+		}
+		updated, err := s.userRepo.GetByID(user.ID)
+		if err != nil {
+			return errors.New("failed to load employee: " + err.Error())
+		}
+		*user = *updated
+	}
+
+	if idToken.EmailVerified && strings.EqualFold(idToken.Email, user.Email) && !user.EmailVerified.Bool {
+		if err := s.userRepo.MarkEmailVerified(user.ID); err != nil {
+			s.logger.WarnContext(ctx, "auth: failed to mark email verified", "account_id", user.ID, "error", err)
+		}
+		user.EmailVerified = sql.NullBool{Bool: true, Valid: true}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"taulen/backend/internal/oidc/oidctest"
+	"taulen/backend/internal/repositories"
+)
+
+// newSSOTestServices starts a stand-in identity provider and creates services
+// whose SSO logins go to it
+func newSSOTestServices(t *testing.T) (*testServices, *oidctest.Provider) {
+	t.Helper()
+	idp, err := oidctest.NewProvider("taulen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(idp.Close)
+	return newTestServices(t, map[string]string{
+		"TAULEN_OIDC_ISSUER_URL":    idp.URL,
+		"TAULEN_OIDC_CLIENT_ID":     "taulen",
+		"TAULEN_OIDC_ROLE_MAPPINGS": "admins=admin,loan-officers=loan_officer",
+	}), idp
+}
+
+// ssoLogin starts an SSO login, has the provider authorize it with claims and
+// completes it
+func ssoLogin(t *testing.T, ts *testServices, idp *oidctest.Provider, claims jwt.MapClaims) (*AuthResponse, error) {
+	t.Helper()
+	ctx := context.Background()
+	start, err := ts.auth.StartSSOLogin(ctx)
+	if err != nil {
+		t.Fatalf("StartSSOLogin: %v", err)
+	}
+	code, state, err := idp.Authorize(start.AuthorizationURL, claims)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if state != start.State {
+		t.Fatalf("provider returned state %q, want %q", state, start.State)
+	}
+	return ts.auth.CompleteSSOLogin(ctx, SSOCallbackRequest{Code: code, State: state, SSOToken: start.SSOToken})
+}
+
+func TestSSOProvisionsAndMapsRoles(t *testing.T) {
+	ts, idp := newSSOTestServices(t)
+	claims := jwt.MapClaims{
+		"sub":            "subject-1",
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"given_name":     "Jane",
+		"family_name":    "Doe",
+		"groups":         []string{"everyone", "loan-officers"},
+	}
+
+	// The first login provisions the employee with the mapped role
+	resp, err := ssoLogin(t, ts, idp, claims)
+	if err != nil {
+		t.Fatalf("CompleteSSOLogin: %v", err)
+	}
+	if resp.User.Role != repositories.EmployeeRole("loan_officer") || resp.User.Email != "jane@example.com" || resp.AccessToken == "" {
+		t.Fatalf("first login = %+v", resp.User)
+	}
+	user, err := ts.store.Users().GetBySSOSubject("subject-1")
+	if err != nil {
+		t.Fatalf("provisioned employee not linked: %v", err)
+	}
+	if user.FirstName.String != "Jane" || !user.EmailVerified.Bool {
+		t.Errorf("provisioned employee = %+v", user)
+	}
+
+	// The first mapping whose group is listed wins, and roles follow the groups
+	claims["groups"] = []string{"loan-officers", "admins"}
+	resp, err = ssoLogin(t, ts, idp, claims)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if resp.User.ID != user.ID || resp.User.Role != repositories.EmployeeRole("admin") {
+		t.Fatalf("second login = %+v, want the same employee as admin", resp.User)
+	}
+
+	// Without a mapped group the login is refused
+	claims["groups"] = "everyone"
+	if _, err := ssoLogin(t, ts, idp, claims); !errors.Is(err, ErrSSONotAuthorized) {
+		t.Fatalf("login without a mapped group: got %v, want ErrSSONotAuthorized", err)
+	}
+}
+
+func TestSSORejectsStateMismatch(t *testing.T) {
+	ts, idp := newSSOTestServices(t)
+	ctx := context.Background()
+
+	start, err := ts.auth.StartSSOLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := idp.Authorize(start.AuthorizationURL, jwt.MapClaims{"sub": "subject-1", "groups": "admins"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The SSO token of another login does not match the provider's state
+	other, err := ts.auth.StartSSOLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ts.auth.CompleteSSOLogin(ctx, SSOCallbackRequest{Code: code, State: start.State, SSOToken: other.SSOToken})
+	if !errors.Is(err, ErrInvalidSSOLogin) {
+		t.Fatalf("mismatched state: got %v, want ErrInvalidSSOLogin", err)
+	}
+}
+
+func TestSSODoesNotLinkUnverifiedEmail(t *testing.T) {
+	ts, idp := newSSOTestServices(t)
+	if _, err := ts.auth.Register(context.Background(), RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The email belongs to a borrower, so no employee is provisioned for it
+	_, err := ssoLogin(t, ts, idp, jwt.MapClaims{
+		"sub": "subject-1", "email": "jane@example.com", "email_verified": true, "groups": "admins",
+	})
+	if !errors.Is(err, ErrSSOAccountConflict) {
+		t.Fatalf("borrower email: got %v, want ErrSSOAccountConflict", err)
+	}
+
+	// An employee is not linked to a provider account whose email is unverified
+	if _, err := ts.store.Users().Create("john@example.com", "hash", "John", "Roe", repositories.EmployeeRole("processor")); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ssoLogin(t, ts, idp, jwt.MapClaims{
+		"sub": "subject-2", "email": "john@example.com", "email_verified": false, "groups": "admins",
+	})
+	if !errors.Is(err, ErrSSOAccountConflict) {
+		t.Fatalf("unverified email: got %v, want ErrSSOAccountConflict", err)
+	}
+}
+
+func TestPasswordLoginDisabledNeedsCorrectPasswordToLearnIt(t *testing.T) {
+	ts, _ := newSSOTestServices(t)
+	ts.cfg.OIDC.DisablePasswordLogin = true
+	ctx := context.Background()
+	createEmployee(t, ts, "lee@example.com", "correct horse")
+
+	// A wrong password gets the same answer as an unknown email
+	_, unknown := ts.auth.Login(ctx, LoginRequest{Email: "nobody@example.com", Password: "wrong password"})
+	_, err := ts.auth.Login(ctx, LoginRequest{Email: "lee@example.com", Password: "wrong password"})
+	if err == nil || errors.Is(err, ErrPasswordLoginDisabled) || err.Error() != unknown.Error() {
+		t.Fatalf("wrong password: got %v, want %v", err, unknown)
+	}
+
+	if _, err := ts.auth.Login(ctx, LoginRequest{Email: "lee@example.com", Password: "correct horse"}); !errors.Is(err, ErrPasswordLoginDisabled) {
+		t.Fatalf("correct password: got %v, want ErrPasswordLoginDisabled", err)
+	}
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/rbac"
+	"taulen/backend/internal/repositories"
+)
+
+// Code-based two-factor authentication. When TwoFactorConfig.Mode is required,
+// every login, registration and pre-application carries a six-digit code sent
+// by the send-verification endpoints. When it is optional, accounts opt in with
+// a code emailed to them (SetTwoFactorEnabled); from then on every login of the
+// account must carry a code, and codes supplied by other requests are checked.
+// Codes are kept by the VerificationService: they expire after the configured
+// time, are cleared once used and stop working after too many wrong attempts.
+// Wrong login codes also count as failed logins towards the account lockout.
+// Accounts that use TOTP MFA log in with their authenticator instead.
+
+var (
+	// ErrTwoFactorDisabled is returned by the send-verification endpoints when the 2FA mode is off
+	ErrTwoFactorDisabled = errors.New("two-factor authentication is disabled")
+	// ErrVerificationCodeRequired is returned when the 2FA mode requires a code and none was supplied
+	ErrVerificationCodeRequired = errors.New("verification code required")
+	// ErrInvalidVerificationCode is returned for wrong or expired verification codes
+	ErrInvalidVerificationCode = errors.New("invalid or expired verification code")
+	// ErrTwoFactorNotOptional is returned when opting in to or out of codes
+	// while the 2FA mode leaves accounts no choice
+	ErrTwoFactorNotOptional = errors.New("two-factor authentication is not optional")
+)
+
+// codeLoginRepository is the account bookkeeping for code logins; implemented
+// by the borrower and user repositories
+type codeLoginRepository interface {
+	lockoutRepository
+	MarkEmailVerified(id string) error
+}
+
+// SendRegisterVerificationCodeRequest represents a request to send a verification code for registration
+type SendRegisterVerificationCodeRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// TwoFactorCodeRequest represents a request confirmed with a code emailed by
+// SendTwoFactorCode
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorStatusResponse describes the code-based 2FA state of an account
+type TwoFactorStatusResponse struct {
+	Mode string `json:"mode"` // off, optional or required
+	// Enabled reports whether every login of the account must supply a code
+	Enabled bool `json:"enabled"`
+}
+
+// twoFactorRequired reports whether the logins of an account must supply a
+// code: always in required mode, and in optional mode once it opted in
+func twoFactorRequired(cfg *config.Config, optedIn bool) bool {
+	switch cfg.TwoFactor.Mode {
+	case config.TwoFactorRequired:
+		return true
+	case config.TwoFactorOptional:
+		return optedIn
+	default:
+		return false
+	}
+}
+
+// shouldVerifyCode applies the 2FA mode to the code supplied with a request of
+// an account that has opted in to codes or not: it reports whether the code has
+// to be verified, or returns ErrVerificationCodeRequired if a code is required
+// but missing. In optional mode a supplied code is checked even when it is not
+// required.
+func shouldVerifyCode(cfg *config.Config, optedIn bool, code string) (bool, error) {
+	if twoFactorRequired(cfg, optedIn) {
+		if code == "" {
+			return false, ErrVerificationCodeRequired
+		}
+		return true, nil
+	}
+	return cfg.TwoFactor.Mode == config.TwoFactorOptional && code != "", nil
+}
+
+// checkLoginCode applies the 2FA mode to a password login of an account that
+// has opted in to codes or not; accounts using TOTP MFA have been challenged
+// for their authenticator code instead. A wrong code counts as a failed login
+// and may lock the account.
+func (s *AuthService) checkLoginCode(ctx context.Context, repo codeLoginRepository, accountID, email string, optedIn bool, code string) error {
+	verify, err := shouldVerifyCode(s.cfg, optedIn, code)
+	if err != nil || !verify {
+		return err
+	}
+	if err := s.verifyEmailCode(verificationPurposeLogin, email, code); err != nil {
+		if errors.Is(err, ErrInvalidVerificationCode) {
+			if err := s.recordLoginFailure(ctx, repo, accountID, email); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+
+	// The code proved the account holder receives mail at the address
+	if err := repo.MarkEmailVerified(accountID); err != nil {
+		s.logger.WarnContext(ctx, "auth: failed to mark email verified", "account_id", accountID, "error", err)
+	}
+	return nil
+}
+
+// verifyEmailCode checks and consumes the code emailed for the purpose
+func (s *AuthService) verifyEmailCode(purpose, email, code string) error {
+	valid, err := s.verification.checkCode(purpose, repositories.VerificationChannelEmail, email, code)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrInvalidVerificationCode
+	}
+	return nil
+}
+
+// issueEmailCode stores a new code for the purpose, replacing any previous code
+// sent to the email, and emails it in the background
+func (s *AuthService) issueEmailCode(ctx context.Context, purpose, email string) error {
+	code, err := s.verification.issueCode(purpose, repositories.VerificationChannelEmail, email)
+	if err != nil {
+		return err
+	}
+
+	go s.sendVerificationCode(context.WithoutCancel(ctx), email, code)
+	return nil
+}
+
+// sendVerificationCode emails a verification code
+func (s *AuthService) sendVerificationCode(ctx context.Context, email, code string) {
+	ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+	defer cancel()
+
+	if err := s.email.SendVerificationCode(ctx, email, code, s.cfg.TwoFactor.CodeExpiry); err != nil {
+		s.logger.WarnContext(ctx, "auth: failed to send verification code", "error", err)
+	}
+}
+
+// GetTwoFactorStatus returns the code-based 2FA state of the caller's account
+func (s *AuthService) GetTwoFactorStatus(ctx context.Context, id string, role rbac.Role) (*TwoFactorStatusResponse, error) {
+	account, err := s.loadOwnMFAAccount(id, role)
+	if err != nil {
+		return nil, err
+	}
+	return s.twoFactorStatus(account), nil
+}
+
+// SendTwoFactorCode emails the caller a code for opting in to or out of
+// login codes; only available while the 2FA mode is optional
+func (s *AuthService) SendTwoFactorCode(ctx context.Context, id string, role rbac.Role) error {
+	account, err := s.loadTwoFactorAccount(id, role)
+	if err != nil {
+		return err
+	}
+	return s.issueEmailCode(ctx, verificationPurposeTwoFactor, account.email)
+}
+
+// SetTwoFactorEnabled opts the caller's account in to or out of login codes
+// after checking a code sent by SendTwoFactorCode, so that neither a stolen
+// session nor a mistyped address can change it. A wrong code counts as a
+// failed login and may lock the account.
+func (s *AuthService) SetTwoFactorEnabled(ctx context.Context, id string, role rbac.Role, enabled bool, req TwoFactorCodeRequest) (*TwoFactorStatusResponse, error) {
+	account, err := s.loadTwoFactorAccount(id, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLocked(account.lockedUntil); err != nil {
+		return nil, err
+	}
+	if err := s.verifyEmailCode(verificationPurposeTwoFactor, account.email, req.Code); err != nil {
+		if errors.Is(err, ErrInvalidVerificationCode) {
+			if err := s.recordLoginFailure(ctx, account.repo, account.id, account.email); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	var repo interface {
+		SetTwoFactorEnabled(id string, enabled bool) error
+	} = s.borrowerRepo
+	if account.userType == SubjectTypeEmployee {
+		repo = s.userRepo
+	}
+	if err := repo.SetTwoFactorEnabled(account.id, enabled); err != nil {
+		return nil, errors.New("failed to update two-factor authentication: " + err.Error())
+	}
+	s.logger.InfoContext(ctx, "auth: two-factor codes changed", "account_id", account.id, "enabled", enabled)
+
+	account.optedIn = enabled
+	return s.twoFactorStatus(account), nil
+}
+
+// loadTwoFactorAccount loads the caller's account for opting in to or out of
+// codes, which needs the optional 2FA mode and an email to send codes to
+func (s *AuthService) loadTwoFactorAccount(id string, role rbac.Role) (*mfaAccount, error) {
+	if s.cfg.TwoFactor.Mode != config.TwoFactorOptional {
+		return nil, ErrTwoFactorNotOptional
+	}
+	account, err := s.loadOwnMFAAccount(id, role)
+	if err != nil {
+		return nil, err
+	}
+	if account.email == "" {
+		return nil, ErrAccountNotFound
+	}
+	return account, nil
+}
+
+// twoFactorStatus describes the code-based 2FA state of the account
+func (s *AuthService) twoFactorStatus(account *mfaAccount) *TwoFactorStatusResponse {
+	return &TwoFactorStatusResponse{
+		Mode:    s.cfg.TwoFactor.Mode,
+		Enabled: twoFactorRequired(s.cfg, account.optedIn),
+	}
+}
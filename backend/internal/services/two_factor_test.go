@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/rbac"
+)
+
+// waitForCode waits until outbox holds n verification codes, which may be
+// sent in the background, and returns the last one
+func waitForCode(t *testing.T, outbox *outbox, n int) string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(codePattern.FindAllString(outbox.String(), -1)) < n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := len(codePattern.FindAllString(outbox.String(), -1)); got != n {
+		t.Fatalf("sent %d verification codes, want %d", got, n)
+	}
+	return lastCode(t, outbox)
+}
+
+// lastCode returns the last verification code recorded in outbox
+func lastCode(t *testing.T, outbox *outbox) string {
+	t.Helper()
+	matches := codePattern.FindAllStringSubmatch(outbox.String(), -1)
+	if len(matches) == 0 {
+		t.Fatalf("no verification code in %q", outbox.String())
+	}
+	return matches[len(matches)-1][1]
+}
+
+func preApplicationRequest(code, method string) VerifyAndCreateBorrowerRequest {
+	return VerifyAndCreateBorrowerRequest{
+		Email:              "jane@example.com",
+		FirstName:          "Jane",
+		LastName:           "Doe",
+		Phone:              "555-123-4567",
+		Password:           "correct horse",
+		LoanPurpose:        "Purchase",
+		LoanAmount:         350000,
+		VerificationCode:   code,
+		VerificationMethod: method,
+	}
+}
+
+func TestPreApplicationRequiresCode(t *testing.T) {
+	ts := newTestServices(t, map[string]string{"TAULEN_TWO_FACTOR_MODE": config.TwoFactorRequired})
+	ctx := context.Background()
+
+	if _, err := ts.urla.VerifyAndCreateBorrower(ctx, preApplicationRequest("", "")); !errors.Is(err, ErrVerificationCodeRequired) {
+		t.Fatalf("without a code: got %v, want ErrVerificationCodeRequired", err)
+	}
+
+	err := ts.urla.SendVerificationCode(ctx, SendVerificationCodeRequest{Email: "jane@example.com", Phone: "555-123-4567"})
+	if err != nil {
+		t.Fatalf("SendVerificationCode: %v", err)
+	}
+	code := lastCode(t, ts.outbox)
+	wrong := otherCode(code)
+
+	if _, err := ts.urla.VerifyAndCreateBorrower(ctx, preApplicationRequest(wrong, "")); !errors.Is(err, ErrInvalidVerificationCode) {
+		t.Fatalf("wrong code: got %v, want ErrInvalidVerificationCode", err)
+	}
+	// The code was sent by SMS, so it does not work for the email address
+	if _, err := ts.urla.VerifyAndCreateBorrower(ctx, preApplicationRequest(code, "email")); !errors.Is(err, ErrInvalidVerificationCode) {
+		t.Fatalf("code checked against the email: got %v, want ErrInvalidVerificationCode", err)
+	}
+
+	resp, err := ts.urla.VerifyAndCreateBorrower(ctx, preApplicationRequest(code, ""))
+	if err != nil {
+		t.Fatalf("VerifyAndCreateBorrower: %v", err)
+	}
+	if resp.AccessToken == "" {
+		t.Fatal("no access token")
+	}
+
+	borrower, err := ts.store.Borrowers().GetByEmail("jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if borrower.EmailVerified.Bool {
+		t.Error("an SMS code marked the email verified")
+	}
+}
+
+func TestPreApplicationEmailCodeConfirmsEmail(t *testing.T) {
+	ts := newTestServices(t, map[string]string{"TAULEN_TWO_FACTOR_MODE": config.TwoFactorRequired})
+	ctx := context.Background()
+
+	err := ts.urla.SendVerificationCode(ctx, SendVerificationCodeRequest{
+		Email: "jane@example.com", Phone: "555-123-4567", VerificationMethod: "email",
+	})
+	if err != nil {
+		t.Fatalf("SendVerificationCode: %v", err)
+	}
+	if _, err := ts.urla.VerifyAndCreateBorrower(ctx, preApplicationRequest(lastCode(t, ts.outbox), "email")); err != nil {
+		t.Fatalf("VerifyAndCreateBorrower: %v", err)
+	}
+
+	borrower, err := ts.store.Borrowers().GetByEmail("jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !borrower.EmailVerified.Bool {
+		t.Error("the emailed code did not mark the email verified")
+	}
+}
+
+func TestPreApplicationWithTwoFactorOff(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+
+	err := ts.urla.SendVerificationCode(ctx, SendVerificationCodeRequest{Email: "jane@example.com", Phone: "555-123-4567"})
+	if !errors.Is(err, ErrTwoFactorDisabled) {
+		t.Fatalf("SendVerificationCode: got %v, want ErrTwoFactorDisabled", err)
+	}
+	if ts.outbox.Len() != 0 {
+		t.Errorf("a code was sent: %q", ts.outbox.String())
+	}
+	if _, err := ts.urla.VerifyAndCreateBorrower(ctx, preApplicationRequest("", "")); err != nil {
+		t.Fatalf("VerifyAndCreateBorrower: %v", err)
+	}
+}
+
+func TestLoginRequiresCode(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+	if _, err := ts.auth.Register(ctx, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	ts.cfg.TwoFactor.Mode = config.TwoFactorRequired
+	credentials := LoginRequest{Email: "jane@example.com", Password: "correct horse"}
+	if _, err := ts.auth.Login(ctx, credentials); !errors.Is(err, ErrVerificationCodeRequired) {
+		t.Fatalf("login without a code: got %v, want ErrVerificationCodeRequired", err)
+	}
+
+	if err := ts.auth.SendLoginVerificationCode(ctx, SendLoginVerificationCodeRequest{Email: credentials.Email}); err != nil {
+		t.Fatalf("SendLoginVerificationCode: %v", err)
+	}
+	code := waitForCode(t, ts.outbox, 1)
+	credentials.VerificationCode = otherCode(code)
+	if _, err := ts.auth.Login(ctx, credentials); !errors.Is(err, ErrInvalidVerificationCode) {
+		t.Fatalf("login with a wrong code: got %v, want ErrInvalidVerificationCode", err)
+	}
+	credentials.VerificationCode = code
+	if _, err := ts.auth.Login(ctx, credentials); err != nil {
+		t.Fatalf("login with the code: %v", err)
+	}
+
+	// A code works once
+	if _, err := ts.auth.Login(ctx, credentials); !errors.Is(err, ErrInvalidVerificationCode) {
+		t.Fatalf("login with a used code: got %v, want ErrInvalidVerificationCode", err)
+	}
+}
+
+func TestOptionalTwoFactorOptIn(t *testing.T) {
+	ts := newTestServices(t, map[string]string{
+		"TAULEN_TWO_FACTOR_MODE":            config.TwoFactorOptional,
+		"TAULEN_TWO_FACTOR_RESEND_COOLDOWN": "0s",
+	})
+	ctx := context.Background()
+	registered, err := ts.auth.Register(ctx, RegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	id := registered.User.ID
+	credentials := LoginRequest{Email: "jane@example.com", Password: "correct horse"}
+
+	// Until the account opts in it logs in without a code, but a code it
+	// supplies must be valid
+	if _, err := ts.auth.Login(ctx, credentials); err != nil {
+		t.Fatalf("login before opting in: %v", err)
+	}
+	if _, err := ts.auth.Login(ctx, LoginRequest{Email: credentials.Email, Password: credentials.Password, VerificationCode: "000000"}); !errors.Is(err, ErrInvalidVerificationCode) {
+		t.Fatalf("login with a code never sent: got %v, want ErrInvalidVerificationCode", err)
+	}
+
+	// Opting in takes a code emailed to the account
+	if err := ts.auth.SendTwoFactorCode(ctx, id, rbac.RoleApplicant); err != nil {
+		t.Fatalf("SendTwoFactorCode: %v", err)
+	}
+	code := waitForCode(t, ts.outbox, 1)
+	if _, err := ts.auth.SetTwoFactorEnabled(ctx, id, rbac.RoleApplicant, true, TwoFactorCodeRequest{Code: otherCode(code)}); !errors.Is(err, ErrInvalidVerificationCode) {
+		t.Fatalf("opting in with a wrong code: got %v, want ErrInvalidVerificationCode", err)
+	}
+	status, err := ts.auth.SetTwoFactorEnabled(ctx, id, rbac.RoleApplicant, true, TwoFactorCodeRequest{Code: code})
+	if err != nil || !status.Enabled {
+		t.Fatalf("opting in: %+v, %v", status, err)
+	}
+
+	// From then on every login needs a code; a wrong password still gets the
+	// usual answer
+	if _, err := ts.auth.Login(ctx, credentials); !errors.Is(err, ErrVerificationCodeRequired) {
+		t.Fatalf("login without a code: got %v, want ErrVerificationCodeRequired", err)
+	}
+	if _, err := ts.auth.Login(ctx, LoginRequest{Email: credentials.Email, Password: "wrong password"}); err == nil || errors.Is(err, ErrVerificationCodeRequired) {
+		t.Fatalf("wrong password: got %v", err)
+	}
+	if err := ts.auth.SendLoginVerificationCode(ctx, SendLoginVerificationCodeRequest{Email: credentials.Email}); err != nil {
+		t.Fatalf("SendLoginVerificationCode: %v", err)
+	}
+	withCode := credentials
+	withCode.VerificationCode = waitForCode(t, ts.outbox, 2)
+	if _, err := ts.auth.Login(ctx, withCode); err != nil {
+		t.Fatalf("login with a code: %v", err)
+	}
+
+	// Opting out takes a code too
+	if err := ts.auth.SendTwoFactorCode(ctx, id, rbac.RoleApplicant); err != nil {
+		t.Fatalf("SendTwoFactorCode: %v", err)
+	}
+	status, err = ts.auth.SetTwoFactorEnabled(ctx, id, rbac.RoleApplicant, false, TwoFactorCodeRequest{Code: waitForCode(t, ts.outbox, 3)})
+	if err != nil || status.Enabled {
+		t.Fatalf("opting out: %+v, %v", status, err)
+	}
+	if _, err := ts.auth.Login(ctx, credentials); err != nil {
+		t.Fatalf("login after opting out: %v", err)
+	}
+}
+
+func TestTwoFactorOptInNeedsOptionalMode(t *testing.T) {
+	for _, mode := range []string{config.TwoFactorOff, config.TwoFactorRequired} {
+		ts := newTestServices(t, nil)
+		registered, err := ts.auth.Register(context.Background(), RegisterRequest{
+			Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+		})
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		ts.cfg.TwoFactor.Mode = mode
+
+		if err := ts.auth.SendTwoFactorCode(context.Background(), registered.User.ID, rbac.RoleApplicant); !errors.Is(err, ErrTwoFactorNotOptional) {
+			t.Errorf("%s: got %v, want ErrTwoFactorNotOptional", mode, err)
+		}
+		status, err := ts.auth.GetTwoFactorStatus(context.Background(), registered.User.ID, rbac.RoleApplicant)
+		if err != nil || status.Enabled != (mode == config.TwoFactorRequired) {
+			t.Errorf("%s: status %+v, %v", mode, status, err)
+		}
+	}
+}
+
+func TestRegistrationRequiresCode(t *testing.T) {
+	ts := newTestServices(t, map[string]string{"TAULEN_TWO_FACTOR_MODE": config.TwoFactorRequired})
+	ctx := context.Background()
+	req := VerifyAndRegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	}
+
+	if _, err := ts.auth.VerifyAndRegister(ctx, req); !errors.Is(err, ErrVerificationCodeRequired) {
+		t.Fatalf("registering without a code: got %v, want ErrVerificationCodeRequired", err)
+	}
+	if err := ts.auth.SendRegisterVerificationCode(ctx, SendRegisterVerificationCodeRequest{Email: req.Email}); err != nil {
+		t.Fatalf("SendRegisterVerificationCode: %v", err)
+	}
+	code := waitForCode(t, ts.outbox, 1)
+	req.VerificationCode = otherCode(code)
+	if _, err := ts.auth.VerifyAndRegister(ctx, req); !errors.Is(err, ErrInvalidVerificationCode) {
+		t.Fatalf("registering with a wrong code: got %v, want ErrInvalidVerificationCode", err)
+	}
+	req.VerificationCode = code
+	resp, err := ts.auth.VerifyAndRegister(ctx, req)
+	if err != nil {
+		t.Fatalf("VerifyAndRegister: %v", err)
+	}
+	if resp.AccessToken == "" {
+		t.Fatal("no access token")
+	}
+
+	borrower, err := ts.store.Borrowers().GetByEmail(req.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !borrower.EmailVerified.Bool {
+		t.Error("the emailed code did not mark the email verified")
+	}
+}
+
+func TestTwoFactorOff(t *testing.T) {
+	ts := newTestServices(t, nil)
+	ctx := context.Background()
+
+	err := ts.auth.SendLoginVerificationCode(ctx, SendLoginVerificationCodeRequest{Email: "jane@example.com"})
+	if !errors.Is(err, ErrTwoFactorDisabled) {
+		t.Fatalf("SendLoginVerificationCode: got %v, want ErrTwoFactorDisabled", err)
+	}
+	err = ts.auth.SendRegisterVerificationCode(ctx, SendRegisterVerificationCodeRequest{Email: "jane@example.com"})
+	if !errors.Is(err, ErrTwoFactorDisabled) {
+		t.Fatalf("SendRegisterVerificationCode: got %v, want ErrTwoFactorDisabled", err)
+	}
+	if _, err := ts.auth.VerifyAndRegister(ctx, VerifyAndRegisterRequest{
+		Email: "jane@example.com", Password: "correct horse", FirstName: "Jane", LastName: "Doe",
+	}); err != nil {
+		t.Fatalf("VerifyAndRegister: %v", err)
+	}
+}
@@ -1,116 +1,212 @@
 package services
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
 	"taulen/backend/internal/config"
+	"taulen/backend/internal/metrics"
+	"taulen/backend/internal/notify"
+	"taulen/backend/internal/repositories"
 )
 
 // URLAService handles URLA application business logic
 // In the new schema, a mortgage application is called a "deal"
 // This service acts as a facade, delegating to specialized services
 type URLAService struct {
-	appService         *ApplicationService
-	borrowerService    *BorrowerService
-	coBorrowerService  *CoBorrowerService
-	loanService        *LoanService
-	progressService    *ProgressService
+	store               repositories.Store
+	appService          *ApplicationService
+	borrowerService     *BorrowerService
+	coBorrowerService   *CoBorrowerService
+	loanService         *LoanService
+	progressService     *ProgressService
 	verificationService *VerificationService
+	logger              *slog.Logger
 }
 
-// NewURLAService creates a new URLA service
-func NewURLAService(cfg *config.Config) *URLAService {
+// NewURLAService creates a new URLA service backed by the given store and
+// sending notifications through notifier
+func NewURLAService(cfg *config.Config, store repositories.Store, notifier notify.Notifier, logger *slog.Logger) *URLAService {
 	return &URLAService{
-		appService:         NewApplicationService(),
-		borrowerService:    NewBorrowerService(cfg),
-		coBorrowerService:  NewCoBorrowerService(cfg),
-		loanService:        NewLoanService(cfg),
-		progressService:    NewProgressService(),
-		verificationService: NewVerificationService(cfg),
+		store:               store,
+		appService:          NewApplicationService(store, logger),
+		borrowerService:     NewBorrowerService(cfg, store, notifier, logger),
+		coBorrowerService:   NewCoBorrowerService(cfg, store, logger),
+		loanService:         NewLoanService(cfg, store, logger),
+		progressService:     NewProgressService(store),
+		verificationService: NewVerificationService(cfg, store, notifier, logger),
+		logger:              logger,
 	}
 }
 
 // Application/Deal management methods - delegate to ApplicationService
 
 // CreateApplication creates a new URLA application
-func (s *URLAService) CreateApplication(userID string, req CreateApplicationRequest) (*ApplicationResponse, error) {
-	return s.appService.CreateApplication(userID, req)
+func (s *URLAService) CreateApplication(ctx context.Context, userID string, req CreateApplicationRequest) (*ApplicationResponse, error) {
+	return s.appService.CreateApplication(ctx, userID, req)
 }
 
 // GetApplication retrieves a deal (application) by ID
-func (s *URLAService) GetApplication(dealID string) (map[string]interface{}, error) {
-	return s.appService.GetApplication(dealID)
+func (s *URLAService) GetApplication(ctx context.Context, dealID string) (map[string]interface{}, error) {
+	return s.appService.GetApplication(ctx, dealID)
 }
 
-// UpdateApplicationStatus updates the status of an application
-func (s *URLAService) UpdateApplicationStatus(applicationID string, status string) error {
-	return s.appService.UpdateApplicationStatus(applicationID, status)
+// UpdateApplicationStatus updates the status of an application on behalf of
+// editor, who cannot submit the application if delegated or an integration.
+// The status is not stored yet, so no edit is recorded for it.
+func (s *URLAService) UpdateApplicationStatus(ctx context.Context, applicationID string, editor Editor, status string) error {
+	if status == "submitted" && editor.ActorID != "" {
+		return ErrDelegatedConsent
+	}
+	if status == "submitted" && editor.AccountType == AccountTypeIntegration {
+		return ErrIntegrationSubmit
+	}
+	return s.appService.UpdateApplicationStatus(ctx, applicationID, status)
 }
 
 // GetApplicationsByEmployee retrieves all applications managed by an employee
-func (s *URLAService) GetApplicationsByEmployee(userID string) ([]ApplicationResponse, error) {
-	return s.appService.GetApplicationsByEmployee(userID)
+func (s *URLAService) GetApplicationsByEmployee(ctx context.Context, userID string) ([]ApplicationResponse, error) {
+	return s.appService.GetApplicationsByEmployee(ctx, userID)
+}
+
+// ExportApplications returns a page of every application, newest first
+func (s *URLAService) ExportApplications(ctx context.Context, page, pageSize int) (*ApplicationExportResponse, error) {
+	return s.appService.ExportApplications(ctx, page, pageSize)
 }
 
 // GetApplicationsByBorrower retrieves all applications for a borrower
-func (s *URLAService) GetApplicationsByBorrower(borrowerID string) ([]ApplicationResponse, error) {
-	return s.appService.GetApplicationsByBorrower(borrowerID)
+func (s *URLAService) GetApplicationsByBorrower(ctx context.Context, borrowerID string) ([]ApplicationResponse, error) {
+	return s.appService.GetApplicationsByBorrower(ctx, borrowerID)
 }
 
 // CreateApplicationForBorrower creates a new application for a borrower
-func (s *URLAService) CreateApplicationForBorrower(borrowerID string, req CreateApplicationRequest) (*ApplicationResponse, error) {
-	return s.appService.CreateApplicationForBorrower(borrowerID, req)
+func (s *URLAService) CreateApplicationForBorrower(ctx context.Context, borrowerID string, req CreateApplicationRequest) (*ApplicationResponse, error) {
+	return s.appService.CreateApplicationForBorrower(ctx, borrowerID, req)
 }
 
 // UpdateCurrentFormStep updates the current form step for a deal
-func (s *URLAService) UpdateCurrentFormStep(dealID string, formStep string) error {
-	return s.appService.UpdateCurrentFormStep(dealID, formStep)
+func (s *URLAService) UpdateCurrentFormStep(ctx context.Context, dealID string, formStep string) error {
+	return s.appService.UpdateCurrentFormStep(ctx, dealID, formStep)
+}
+
+// SaveApplication saves every section in the request in a single transaction,
+// so a failure in any section (or in the form step update) leaves the deal unchanged.
+// The sections written are recorded as edited by editor, who cannot consent to a
+// credit check or change the borrower's email if delegated.
+func (s *URLAService) SaveApplication(ctx context.Context, dealID string, editor Editor, req SaveApplicationRequest) error {
+	err := s.store.WithinTx(func(tx repositories.Store) error {
+		if err := checkDelegatedSave(tx, dealID, editor, req); err != nil {
+			return err
+		}
+
+		if req.Borrower != nil {
+			if err := s.borrowerService.withStore(tx).SaveBorrowerData(ctx, dealID, req.Borrower, req.NextFormStep); err != nil {
+				return fmt.Errorf("failed to save borrower data: %w", err)
+			}
+		}
+
+		if req.CoBorrower != nil {
+			if err := s.coBorrowerService.withStore(tx).SaveCoBorrowerData(ctx, dealID, req.CoBorrower, req.NextFormStep); err != nil {
+				return fmt.Errorf("failed to save co-borrower data: %w", err)
+			}
+		}
+
+		if req.Loan != nil {
+			if err := s.loanService.withStore(tx).SaveLoanData(ctx, dealID, req.Loan, req.NextFormStep); err != nil {
+				return fmt.Errorf("failed to save loan data: %w", err)
+			}
+		}
+
+		progressService := s.progressService.withStore(tx)
+		for _, section := range req.CompletedSections {
+			if err := progressService.UpdateDealProgressSection(ctx, dealID, section, true); err != nil {
+				return fmt.Errorf("failed to update progress section %s: %w", section, err)
+			}
+		}
+
+		// If only nextFormStep is provided, update the form step directly
+		if req.NextFormStep != "" && req.Borrower == nil && req.CoBorrower == nil && req.Loan == nil {
+			if err := s.appService.withStore(tx).UpdateCurrentFormStep(ctx, dealID, req.NextFormStep); err != nil {
+				return fmt.Errorf("failed to update form step: %w", err)
+			}
+		}
+
+		return recordEdits(tx.Deals(), dealID, editor, savedSections(req)...)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Count completed sections only once the transaction has committed
+	for _, section := range req.CompletedSections {
+		metrics.SectionCompleted(section)
+	}
+	return nil
 }
 
 // Borrower management methods - delegate to BorrowerService
 
 // VerifyAndCreateBorrower verifies the code and creates borrower account with deal
-func (s *URLAService) VerifyAndCreateBorrower(req VerifyAndCreateBorrowerRequest) (*VerifyAndCreateBorrowerResponse, error) {
-	return s.borrowerService.VerifyAndCreateBorrower(req)
+func (s *URLAService) VerifyAndCreateBorrower(ctx context.Context, req VerifyAndCreateBorrowerRequest) (*VerifyAndCreateBorrowerResponse, error) {
+	return s.borrowerService.VerifyAndCreateBorrower(ctx, req)
 }
 
 // SaveBorrowerData saves borrower information from the form
-func (s *URLAService) SaveBorrowerData(dealID string, borrowerData map[string]interface{}, nextFormStep string) error {
-	return s.borrowerService.SaveBorrowerData(dealID, borrowerData, nextFormStep)
+func (s *URLAService) SaveBorrowerData(ctx context.Context, dealID string, borrowerData map[string]interface{}, nextFormStep string) error {
+	return s.borrowerService.SaveBorrowerData(ctx, dealID, borrowerData, nextFormStep)
 }
 
 // Co-borrower management methods - delegate to CoBorrowerService
 
 // SaveCoBorrowerData saves co-borrower information and links them to the deal
-func (s *URLAService) SaveCoBorrowerData(dealID string, coBorrowerData map[string]interface{}, nextFormStep string) error {
-	return s.coBorrowerService.SaveCoBorrowerData(dealID, coBorrowerData, nextFormStep)
+func (s *URLAService) SaveCoBorrowerData(ctx context.Context, dealID string, coBorrowerData map[string]interface{}, nextFormStep string) error {
+	return s.coBorrowerService.SaveCoBorrowerData(ctx, dealID, coBorrowerData, nextFormStep)
 }
 
 // Loan management methods - delegate to LoanService
 
 // SaveLoanData saves loan information for an application
-func (s *URLAService) SaveLoanData(dealID string, loanData map[string]interface{}, nextFormStep string) error {
-	return s.loanService.SaveLoanData(dealID, loanData, nextFormStep)
+func (s *URLAService) SaveLoanData(ctx context.Context, dealID string, loanData map[string]interface{}, nextFormStep string) error {
+	return s.loanService.SaveLoanData(ctx, dealID, loanData, nextFormStep)
 }
 
 // Progress tracking methods - delegate to ProgressService
 
 // GetDealProgress retrieves progress for a deal
-func (s *URLAService) GetDealProgress(dealID string) (map[string]interface{}, error) {
-	return s.progressService.GetDealProgress(dealID)
+func (s *URLAService) GetDealProgress(ctx context.Context, dealID string) (map[string]interface{}, error) {
+	return s.progressService.GetDealProgress(ctx, dealID)
 }
 
 // UpdateDealProgressSection updates a specific section's completion status
-func (s *URLAService) UpdateDealProgressSection(dealID string, section string, complete bool) error {
-	return s.progressService.UpdateDealProgressSection(dealID, section, complete)
+func (s *URLAService) UpdateDealProgressSection(ctx context.Context, dealID string, editor Editor, section string, complete bool) error {
+	err := s.store.WithinTx(func(tx repositories.Store) error {
+		if err := s.progressService.withStore(tx).UpdateDealProgressSection(ctx, dealID, section, complete); err != nil {
+			return err
+		}
+		return recordEdits(tx.Deals(), dealID, editor, section)
+	})
+	if err != nil {
+		return err
+	}
+	if complete {
+		metrics.SectionCompleted(section)
+	}
+	return nil
 }
 
 // UpdateDealProgressNotes updates progress notes
-func (s *URLAService) UpdateDealProgressNotes(dealID string, notes string) error {
-	return s.progressService.UpdateDealProgressNotes(dealID, notes)
+func (s *URLAService) UpdateDealProgressNotes(ctx context.Context, dealID string, editor Editor, notes string) error {
+	return s.store.WithinTx(func(tx repositories.Store) error {
+		if err := s.progressService.withStore(tx).UpdateDealProgressNotes(ctx, dealID, notes); err != nil {
+			return err
+		}
+		return recordEdits(tx.Deals(), dealID, editor, EditSectionProgressNotes)
+	})
 }
 
 // Verification methods - delegate to VerificationService
 
 // SendVerificationCode sends a verification code via email or SMS
-func (s *URLAService) SendVerificationCode(req SendVerificationCodeRequest) error {
-	return s.verificationService.SendVerificationCode(req)
+func (s *URLAService) SendVerificationCode(ctx context.Context, req SendVerificationCodeRequest) error {
+	return s.verificationService.SendVerificationCode(ctx, req)
 }
@@ -9,6 +9,16 @@ type CreateApplicationRequest struct {
 	LoanAmount  float64 `json:"loanAmount" binding:"required,gt=0"`
 }
 
+// SaveApplicationRequest represents an auto-save of one or more application sections
+// All provided sections, completed progress sections and the form step are saved atomically
+type SaveApplicationRequest struct {
+	Borrower          map[string]interface{} `json:"borrower"`
+	CoBorrower        map[string]interface{} `json:"coBorrower"`
+	Loan              map[string]interface{} `json:"loan"`
+	CompletedSections []string               `json:"completedSections"`
+	NextFormStep      string                 `json:"nextFormStep"`
+}
+
 // ApplicationResponse represents an application in API responses
 type ApplicationResponse struct {
 	ID                  string   `json:"id"`
@@ -22,6 +32,13 @@ type ApplicationResponse struct {
 	LastUpdatedSection  *string  `json:"lastUpdatedSection,omitempty"`
 }
 
+// ApplicationExportResponse represents a page of exported applications
+type ApplicationExportResponse struct {
+	Applications []ApplicationResponse `json:"applications"`
+	Page         int                   `json:"page"`
+	PageSize     int                   `json:"pageSize"`
+}
+
 // VerifyAndCreateBorrowerResponse represents the response after verification and account creation
 type VerifyAndCreateBorrowerResponse struct {
 	Application        ApplicationResponse        `json:"application"`
@@ -78,7 +95,8 @@ type VerifyAndCreateBorrowerRequest struct {
 	State          string  `json:"state"`
 	ZipCode        string  `json:"zipCode"`
 	LoanPurpose    string  `json:"loanPurpose" binding:"required"`
-	VerificationCode string `json:"verificationCode"` // Optional - 2FA is disabled
+	VerificationCode string `json:"verificationCode"` // Required when the 2FA mode is required
+	VerificationMethod string `json:"verificationMethod,omitempty"` // As passed to SendVerificationCode
 	
 	// Purchase-specific fields
 	PurchasePrice  float64 `json:"purchasePrice"`
@@ -0,0 +1,12 @@
+package services
+
+// ValidationError is returned for request data that is missing or malformed.
+// Its message tells the client what to fix, so it never carries submitted
+// values or storage details.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
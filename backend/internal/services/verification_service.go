@@ -1,111 +1,203 @@
 package services
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 	"taulen/backend/internal/config"
+	"taulen/backend/internal/notify"
 	"taulen/backend/internal/repositories"
 	"taulen/backend/internal/utils"
 )
 
-// VerificationService handles verification code operations
+// Verification code purposes; a code only works for the purpose it was sent for
+const (
+	verificationPurposeLogin          = "login"
+	verificationPurposeRegister       = "register"
+	verificationPurposePreApplication = "pre_application"
+	verificationPurposeTwoFactor      = "two_factor" // opting in to or out of login codes
+)
+
+// ErrVerificationCodeCooldown is returned when a code was sent to the same email
+// or phone too recently
+var ErrVerificationCodeCooldown = errors.New("a verification code was sent recently, please wait before requesting another")
+
+// VerificationService handles verification code operations. Codes live in the
+// verification_code table, one per email address or phone number and purpose,
+// stored as a keyed hash and invalidated after too many wrong attempts.
 type VerificationService struct {
-	borrowerRepo *repositories.BorrowerRepository
-	cfg          *config.Config
+	codeRepo repositories.VerificationCodeRepository
+	email    *EmailService
+	sms      *SMSService
+	cfg      *config.Config
+	logger   *slog.Logger
 }
 
-// NewVerificationService creates a new verification service
-func NewVerificationService(cfg *config.Config) *VerificationService {
+// NewVerificationService creates a new verification service sending codes
+// through notifier
+func NewVerificationService(cfg *config.Config, store repositories.Store, notifier notify.Notifier, logger *slog.Logger) *VerificationService {
 	return &VerificationService{
-		borrowerRepo: repositories.NewBorrowerRepository(),
-		cfg:          cfg,
+		codeRepo: store.VerificationCodes(),
+		email:    NewEmailService(notifier, logger),
+		sms:      NewSMSService(notifier, logger),
+		cfg:      cfg,
+		logger:   logger,
 	}
 }
 
-// SendVerificationCode sends a verification code via email or SMS
-// Automatically selects phone if both email and phone are available (phone is preferred)
-// Uses email if only email is available, phone if only phone is available
-func (s *VerificationService) SendVerificationCode(req SendVerificationCodeRequest) error {
-	emailService := NewEmailService(s.cfg)
-	smsService := NewSMSService(s.cfg)
-
-	// Determine verification method automatically:
-	// 1. If both email and phone are available, prefer phone (SMS)
-	// 2. If only phone is available, use phone (SMS)
-	// 3. If only email is available, use email
-	verificationMethod := req.VerificationMethod
-	if verificationMethod == "" {
-		if req.Phone != "" {
-			verificationMethod = "sms" // Prefer phone when available
-		} else if req.Email != "" {
-			verificationMethod = "email"
-		} else {
-			return errors.New("either email or phone must be provided")
-		}
+// SendVerificationCode sends a pre-application verification code via email or
+// SMS, which VerifyAndCreateBorrower checks. It returns ErrTwoFactorDisabled
+// when the 2FA mode is off.
+func (s *VerificationService) SendVerificationCode(ctx context.Context, req SendVerificationCodeRequest) error {
+	if s.cfg.TwoFactor.Mode == config.TwoFactorOff {
+		return ErrTwoFactorDisabled
 	}
 
-	// Validate phone is provided if SMS is selected
-	if verificationMethod == "sms" && req.Phone == "" {
-		return errors.New("phone number is required for SMS verification")
+	// Generate and store the code for the address it is sent to
+	channel, destination, err := preApplicationDestination(req.VerificationMethod, req.Email, req.Phone)
+	if err != nil {
+		return err
+	}
+	code, err := s.issueCode(verificationPurposePreApplication, channel, destination)
+	if err != nil {
+		return err
 	}
 
-	// Validate email is provided if email is selected
-	if verificationMethod == "email" && req.Email == "" {
-		return errors.New("email is required for email verification")
+	// Send verification code via selected method
+	if channel == repositories.VerificationChannelEmail {
+		err = s.email.SendVerificationCode(ctx, destination, code, s.cfg.TwoFactor.CodeExpiry)
+	} else {
+		err = s.sms.SendVerificationCode(ctx, destination, code, s.cfg.TwoFactor.CodeExpiry)
 	}
 
-	// Generate 6-digit verification code
-	code, err := utils.GenerateVerificationCode()
 	if err != nil {
-		return errors.New("failed to generate verification code")
-	}
-
-	// Store verification code (expires in 10 minutes)
-	expiresAt := time.Now().Add(10 * time.Minute)
-
-	// Check if borrower exists, if not create a temporary record
-	existingBorrower, err := s.borrowerRepo.GetByEmail(req.Email)
-	if err != nil && err != sql.ErrNoRows {
-		return errors.New("failed to check if borrower exists")
-	}
-
-	if existingBorrower == nil {
-		// Create temporary borrower record (without password)
-		_, err = s.borrowerRepo.CreateFromPreApplication(
-			req.Email,
-			"", // First name - will be set later
-			"", // Last name - will be set later
-			req.Phone,
-			"", // Date of birth - will be set later
-			"", "", "", "", // Address fields
-		)
-		if err != nil && !errors.Is(err, sql.ErrNoRows) {
-			// If it's not a "not found" error, it might be a duplicate - continue
-			if !errors.Is(err, sql.ErrNoRows) {
-				// Try to update existing record instead
-			}
+		// Return the actual error message from SMS/Email service for better debugging
+		return fmt.Errorf("failed to send verification code: %w", err)
+	}
+
+	return nil
+}
+
+// checkPreApplicationCode checks and consumes the pre-application code sent to
+// the email or phone selected by method, returning ErrInvalidVerificationCode
+// for wrong or expired codes. It reports whether the code was sent by email.
+func (s *VerificationService) checkPreApplicationCode(method, email, phone, code string) (bool, error) {
+	channel, destination, err := preApplicationDestination(method, email, phone)
+	if err != nil {
+		return false, err
+	}
+	valid, err := s.checkCode(verificationPurposePreApplication, channel, destination, code)
+	if err != nil {
+		return false, err
+	}
+	if !valid {
+		return false, ErrInvalidVerificationCode
+	}
+	return channel == repositories.VerificationChannelEmail, nil
+}
+
+// preApplicationDestination selects where a pre-application code is sent: by
+// SMS when a phone is available (phone is preferred), otherwise by email,
+// unless method asks for "email" or "sms"
+func preApplicationDestination(method, email, phone string) (channel, destination string, err error) {
+	if method == "" {
+		if phone != "" {
+			method = "sms" // Prefer phone when available
+		} else if email != "" {
+			method = "email"
+		} else {
+			return "", "", &ValidationError{Message: "either email or phone must be provided"}
 		}
 	}
 
-	// Store verification code
-	err = s.borrowerRepo.SetVerificationCode(req.Email, code, verificationMethod, expiresAt)
+	switch method {
+	case "email":
+		if email == "" {
+			return "", "", &ValidationError{Message: "email is required for email verification"}
+		}
+		return repositories.VerificationChannelEmail, email, nil
+	case "sms":
+		if phone == "" {
+			return "", "", &ValidationError{Message: "phone number is required for SMS verification"}
+		}
+		return repositories.VerificationChannelSMS, phone, nil
+	default:
+		return "", "", &ValidationError{Message: "verification method must be email or sms"}
+	}
+}
+
+// issueCode generates a code for the purpose and stores it for the email or
+// phone, replacing any previous code. It returns ErrVerificationCodeCooldown if
+// the last code for the destination was sent within the resend cooldown.
+func (s *VerificationService) issueCode(purpose, channel, destination string) (string, error) {
+	code, err := utils.GenerateVerificationCode()
 	if err != nil {
-		return errors.New("failed to store verification code")
+		return "", errors.New("failed to generate verification code")
+	}
+	id, err := utils.NewUUID()
+	if err != nil {
+		return "", errors.New("failed to generate verification code id: " + err.Error())
 	}
 
-	// Send verification code via selected method
-	if verificationMethod == "email" {
-		err = emailService.SendVerificationCode(req.Email, code)
-	} else {
-		err = smsService.SendVerificationCode(req.Phone, code)
+	destination = normalizeDestination(channel, destination)
+	now := time.Now()
+	issued, err := s.codeRepo.Issue(&repositories.VerificationCode{
+		ID:          id,
+		Channel:     channel,
+		Destination: destination,
+		Purpose:     purpose,
+		CodeHash:    s.hashCode(destination, code),
+		ExpiresAt:   now.Add(s.cfg.TwoFactor.CodeExpiry),
+	}, now.Add(-s.cfg.TwoFactor.ResendCooldown))
+	if err != nil {
+		return "", errors.New("failed to store verification code: " + err.Error())
+	}
+	if !issued {
+		return "", ErrVerificationCodeCooldown
 	}
+	return code, nil
+}
 
+// checkCode reports whether code is the unexpired code sent to the email or
+// phone for the purpose, consuming it. Wrong codes count towards the code's
+// attempt limit, after which it stops working.
+func (s *VerificationService) checkCode(purpose, channel, destination, code string) (bool, error) {
+	destination = normalizeDestination(channel, destination)
+	valid, err := s.codeRepo.Consume(channel, destination, purpose,
+		s.hashCode(destination, strings.TrimSpace(code)), s.cfg.TwoFactor.MaxAttempts)
 	if err != nil {
-		// Return the actual error message from SMS/Email service for better debugging
-		return fmt.Errorf("failed to send verification code: %w", err)
+		return false, errors.New("failed to check verification code: " + err.Error())
 	}
+	return valid, nil
+}
 
-	return nil
+// DeleteExpired deletes verification codes that have expired and returns how many were deleted
+func (s *VerificationService) DeleteExpired(ctx context.Context) (int64, error) {
+	deleted, err := s.codeRepo.DeleteExpired(time.Now())
+	if err != nil {
+		return 0, errors.New("failed to delete expired verification codes: " + err.Error())
+	}
+	return deleted, nil
+}
+
+// hashCode returns the keyed hash under which a code for destination is stored
+func (s *VerificationService) hashCode(destination, code string) string {
+	secret := s.cfg.TwoFactor.CodeSecret
+	if secret == "" {
+		secret = s.cfg.JWT.Secret
+	}
+	return utils.HashVerificationCode(secret, destination, code)
+}
+
+// normalizeDestination folds email case and surrounding space so that the same
+// address always maps to the same code
+func normalizeDestination(channel, destination string) string {
+	destination = strings.TrimSpace(destination)
+	if channel == repositories.VerificationChannelEmail {
+		destination = strings.ToLower(destination)
+	}
+	return destination
 }
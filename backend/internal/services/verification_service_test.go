@@ -0,0 +1,171 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"taulen/backend/internal/config"
+	"taulen/backend/internal/notify"
+	"taulen/backend/internal/repositories"
+	"taulen/backend/internal/repositories/memory"
+)
+
+func TestVerificationCodes(t *testing.T) {
+	s := NewVerificationService(testConfig(t, nil), memory.NewStore(), nil, testLogger())
+	const email = "jane@example.com"
+
+	code, err := s.issueCode(verificationPurposeLogin, repositories.VerificationChannelEmail, email)
+	if err != nil {
+		t.Fatalf("issueCode: %v", err)
+	}
+	if _, err := s.issueCode(verificationPurposeLogin, repositories.VerificationChannelEmail, email); !errors.Is(err, ErrVerificationCodeCooldown) {
+		t.Fatalf("second code: got %v, want ErrVerificationCodeCooldown", err)
+	}
+
+	// The code only works for its own purpose and destination
+	if valid, _ := s.checkCode(verificationPurposePreApplication, repositories.VerificationChannelEmail, email, code); valid {
+		t.Fatal("login code accepted for the pre-application")
+	}
+	if valid, _ := s.checkCode(verificationPurposeLogin, repositories.VerificationChannelSMS, email, code); valid {
+		t.Fatal("emailed code accepted by SMS")
+	}
+	// Email addresses are compared without case
+	if valid, err := s.checkCode(verificationPurposeLogin, repositories.VerificationChannelEmail, "Jane@Example.com ", code); err != nil || !valid {
+		t.Fatalf("code: valid %v, err %v", valid, err)
+	}
+	// Codes are consumed
+	if valid, _ := s.checkCode(verificationPurposeLogin, repositories.VerificationChannelEmail, email, code); valid {
+		t.Fatal("code accepted twice")
+	}
+}
+
+func TestVerificationCodeAttemptLimit(t *testing.T) {
+	s := NewVerificationService(testConfig(t, nil), memory.NewStore(), nil, testLogger())
+	const phone = "555-123-4567"
+
+	code, err := s.issueCode(verificationPurposePreApplication, repositories.VerificationChannelSMS, phone)
+	if err != nil {
+		t.Fatalf("issueCode: %v", err)
+	}
+	for i := 0; i < s.cfg.TwoFactor.MaxAttempts; i++ {
+		if valid, err := s.checkCode(verificationPurposePreApplication, repositories.VerificationChannelSMS, phone, otherCode(code)); err != nil || valid {
+			t.Fatalf("wrong code %d: valid %v, err %v", i+1, valid, err)
+		}
+	}
+	if valid, _ := s.checkCode(verificationPurposePreApplication, repositories.VerificationChannelSMS, phone, code); valid {
+		t.Fatal("code accepted after too many wrong attempts")
+	}
+}
+
+func TestCodesAreKeptPerPurpose(t *testing.T) {
+	s := NewVerificationService(testConfig(t, nil), memory.NewStore(), nil, testLogger())
+	const email = "jane@example.com"
+
+	register, err := s.issueCode(verificationPurposeRegister, repositories.VerificationChannelEmail, email)
+	if err != nil {
+		t.Fatalf("issue register code: %v", err)
+	}
+	// A code for another purpose is issued despite the cooldown and leaves the
+	// register code in place
+	login, err := s.issueCode(verificationPurposeLogin, repositories.VerificationChannelEmail, email)
+	if err != nil {
+		t.Fatalf("issue login code: %v", err)
+	}
+	if _, err := s.issueCode(verificationPurposeLogin, repositories.VerificationChannelEmail, email); !errors.Is(err, ErrVerificationCodeCooldown) {
+		t.Fatalf("second login code: got %v, want ErrVerificationCodeCooldown", err)
+	}
+
+	// Neither code works for another purpose
+	if valid, _ := s.checkCode(verificationPurposePreApplication, repositories.VerificationChannelEmail, email, login); valid {
+		t.Fatal("login code accepted for the pre-application")
+	}
+	if register != login {
+		if valid, _ := s.checkCode(verificationPurposeLogin, repositories.VerificationChannelEmail, email, register); valid {
+			t.Fatal("register code accepted for login")
+		}
+	}
+	if valid, err := s.checkCode(verificationPurposeLogin, repositories.VerificationChannelEmail, email, login); err != nil || !valid {
+		t.Fatalf("login code: valid %v, err %v", valid, err)
+	}
+	// Codes are consumed
+	if valid, _ := s.checkCode(verificationPurposeLogin, repositories.VerificationChannelEmail, email, login); valid {
+		t.Fatal("login code accepted twice")
+	}
+
+	// Wrong login codes do not use up the attempts of the register code
+	wrong := otherCode(register)
+	if _, err := s.issueCode(verificationPurposeLogin, repositories.VerificationChannelEmail, email); err != nil && !errors.Is(err, ErrVerificationCodeCooldown) {
+		t.Fatal(err)
+	}
+	for i := 0; i < s.cfg.TwoFactor.MaxAttempts; i++ {
+		s.checkCode(verificationPurposeLogin, repositories.VerificationChannelEmail, email, wrong)
+	}
+	if valid, err := s.checkCode(verificationPurposeRegister, repositories.VerificationChannelEmail, email, register); err != nil || !valid {
+		t.Fatalf("register code: valid %v, err %v", valid, err)
+	}
+}
+
+func TestCodesLandInFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.log")
+	cfg := testConfig(t, map[string]string{
+		"TAULEN_TWO_FACTOR_MODE":              config.TwoFactorRequired,
+		"TAULEN_TWO_FACTOR_CODE_EXPIRY":       "15m",
+		"TAULEN_NOTIFICATIONS_EMAIL_PROVIDER": config.ProviderFile,
+		"TAULEN_NOTIFICATIONS_SMS_PROVIDER":   config.ProviderFile,
+		"TAULEN_NOTIFICATIONS_FILE_PATH":      path,
+	})
+	urla := NewURLAService(cfg, memory.NewStore(), notify.New(cfg, testLogger()), testLogger())
+	ctx := context.Background()
+
+	for _, method := range []string{"sms", "email"} {
+		if err := urla.SendVerificationCode(ctx, SendVerificationCodeRequest{
+			Email: "jane@example.com", Phone: "555-123-4567", VerificationMethod: method,
+		}); err != nil {
+			t.Fatalf("SendVerificationCode by %s: %v", method, err)
+		}
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := strings.Split(string(written), "--- ")
+	if len(records) != 3 {
+		t.Fatalf("records = %q, want an SMS and an email", written)
+	}
+	if !strings.HasPrefix(records[1], "sms ") || !strings.Contains(records[1], "To: +15551234567\n") ||
+		!codePattern.MatchString(records[1]) || !strings.Contains(records[1], "expires in 15 minutes") {
+		t.Errorf("SMS record = %q", records[1])
+	}
+	if !strings.HasPrefix(records[2], "email ") || !strings.Contains(records[2], "To: jane@example.com\n") ||
+		!codePattern.MatchString(records[2]) || !strings.Contains(records[2], "expire in 15 minutes") {
+		t.Errorf("email record = %q", records[2])
+	}
+}
+
+func TestUnsentCodesAreNotLogged(t *testing.T) {
+	// The default providers have no credentials in tests
+	cfg := testConfig(t, nil)
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	notifier := notify.New(cfg, logger)
+	ctx := context.Background()
+	const code = "493817"
+
+	if err := NewEmailService(notifier, logger).SendVerificationCode(ctx, "jane@example.com", code, time.Minute); !errors.Is(err, notify.ErrNotConfigured) {
+		t.Fatalf("email: got %v, want ErrNotConfigured", err)
+	}
+	if err := NewSMSService(notifier, logger).SendVerificationCode(ctx, "555-123-4567", code, time.Minute); !errors.Is(err, notify.ErrNotConfigured) {
+		t.Fatalf("SMS: got %v, want ErrNotConfigured", err)
+	}
+	if strings.Count(logs.String(), "not sent") != 2 || strings.Contains(logs.String(), code) {
+		t.Fatalf("logs = %q, want both skipped sends without the code", logs.String())
+	}
+}
@@ -190,6 +190,26 @@ SET default_tablespace = '';
 
 SET default_table_access_method = heap;
 
+--
+-- Name: api_key; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.api_key (
+    id uuid NOT NULL,
+    name character varying(100) NOT NULL,
+    key_prefix character varying(16) NOT NULL,
+    key_hash character varying(64) NOT NULL,
+    scopes text NOT NULL,
+    created_by uuid,
+    rotated_from uuid,
+    expires_at timestamp with time zone NOT NULL,
+    last_used_at timestamp with time zone,
+    last_used_ip character varying(45),
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
 --
 -- Name: asset; Type: TABLE; Schema: public; Owner: -
 --
@@ -251,6 +271,7 @@ CREATE TABLE public.borrower (
     consent_to_contact boolean DEFAULT false,
     created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
     updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    two_factor_enabled boolean DEFAULT false NOT NULL,
     CONSTRAINT chk_citizenship CHECK (((citizenship_residency_type)::text = ANY ((ARRAY['USCitizen'::character varying, 'PermanentResidentAlien'::character varying, 'NonPermanentResidentAlien'::character varying])::text[]))),
     CONSTRAINT chk_domestic_rel_type CHECK (((domestic_relationship_type)::text = ANY ((ARRAY['CivilUnion'::character varying, 'DomesticPartnership'::character varying, 'RegisteredReciprocalBeneficiaryRelationship'::character varying, 'Other'::character varying])::text[]))),
     CONSTRAINT chk_marital_status CHECK (((marital_status IS NULL) OR ((marital_status)::text = ANY ((ARRAY['Married'::character varying, 'Separated'::character varying, 'Unmarried'::character varying])::text[])))),
@@ -308,6 +329,33 @@ CREATE TABLE public.deal (
 );
 
 
+--
+-- Name: deal_assignment; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.deal_assignment (
+    deal_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: deal_edit; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.deal_edit (
+    id uuid DEFAULT public.generate_uuid_v7() NOT NULL,
+    deal_id uuid NOT NULL,
+    section character varying(50) NOT NULL,
+    account_id uuid NOT NULL,
+    account_type character varying(20) NOT NULL,
+    actor_id uuid,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT chk_account_type CHECK (((account_type)::text = ANY ((ARRAY['borrower'::character varying, 'employee'::character varying, 'integration'::character varying])::text[])))
+);
+
+
 --
 -- Name: deal_progress; Type: TABLE; Schema: public; Owner: -
 --
@@ -423,6 +471,17 @@ CREATE TABLE public.employment_income (
 );
 
 
+--
+-- Name: known_device; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.known_device (
+    subject_id uuid NOT NULL,
+    device_hash character varying(64) NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
 --
 -- Name: liability; Type: TABLE; Schema: public; Owner: -
 --
@@ -529,6 +588,27 @@ CREATE TABLE public.party (
 );
 
 
+--
+-- Name: refresh_session; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.refresh_session (
+    id uuid NOT NULL,
+    family_id uuid NOT NULL,
+    subject_id uuid NOT NULL,
+    subject_type character varying(20) NOT NULL,
+    token_hash character varying(64) NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    rotated_at timestamp with time zone,
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    user_agent character varying(512),
+    ip_address character varying(45),
+    signed_in_at timestamp with time zone,
+    CONSTRAINT chk_refresh_session_subject_type CHECK (((subject_type)::text = ANY ((ARRAY['employee'::character varying, 'applicant'::character varying])::text[])))
+);
+
+
 --
 -- Name: residence; Type: TABLE; Schema: public; Owner: -
 --
@@ -602,10 +682,46 @@ CREATE TABLE public."user" (
     failed_login_attempts integer DEFAULT 0,
     account_locked_until timestamp with time zone,
     created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
-    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    password_reset_required boolean DEFAULT false NOT NULL,
+    sso_subject character varying(255),
+    two_factor_enabled boolean DEFAULT false NOT NULL
+);
+
+
+--
+-- Name: verification_code; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.verification_code (
+    id uuid NOT NULL,
+    channel character varying(10) NOT NULL,
+    destination character varying(255) NOT NULL,
+    purpose character varying(20) NOT NULL,
+    code_hash character varying(64) NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT chk_verification_code_channel CHECK (((channel)::text = ANY ((ARRAY['email'::character varying, 'sms'::character varying])::text[])))
 );
 
 
+--
+-- Name: api_key api_key_key_hash_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.api_key
+    ADD CONSTRAINT api_key_key_hash_key UNIQUE (key_hash);
+
+
+--
+-- Name: api_key api_key_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.api_key
+    ADD CONSTRAINT api_key_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: asset asset_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -662,6 +778,22 @@ ALTER TABLE ONLY public.deal
     ADD CONSTRAINT deal_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: deal_assignment deal_assignment_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.deal_assignment
+    ADD CONSTRAINT deal_assignment_pkey PRIMARY KEY (deal_id, user_id);
+
+
+--
+-- Name: deal_edit deal_edit_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.deal_edit
+    ADD CONSTRAINT deal_edit_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: deal_progress deal_progress_deal_id_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -726,6 +858,14 @@ ALTER TABLE ONLY public.employment
     ADD CONSTRAINT employment_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: known_device known_device_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.known_device
+    ADD CONSTRAINT known_device_pkey PRIMARY KEY (subject_id, device_hash);
+
+
 --
 -- Name: liability liability_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -782,6 +922,14 @@ ALTER TABLE ONLY public.party
     ADD CONSTRAINT party_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: refresh_session refresh_session_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.refresh_session
+    ADD CONSTRAINT refresh_session_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: residence residence_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -822,6 +970,22 @@ ALTER TABLE ONLY public."user"
     ADD CONSTRAINT user_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: verification_code verification_code_channel_destination_purpose_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.verification_code
+    ADD CONSTRAINT verification_code_channel_destination_purpose_key UNIQUE (channel, destination, purpose);
+
+
+--
+-- Name: verification_code verification_code_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.verification_code
+    ADD CONSTRAINT verification_code_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: idx_asset_borrower_id; Type: INDEX; Schema: public; Owner: -
 --
@@ -871,6 +1035,20 @@ CREATE INDEX idx_borrower_progress_borrower_id ON public.borrower_progress USING
 CREATE INDEX idx_borrower_progress_deal_id ON public.borrower_progress USING btree (deal_id);
 
 
+--
+-- Name: idx_deal_assignment_user_id; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX idx_deal_assignment_user_id ON public.deal_assignment USING btree (user_id);
+
+
+--
+-- Name: idx_deal_edit_deal_id; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX idx_deal_edit_deal_id ON public.deal_edit USING btree (deal_id, created_at);
+
+
 --
 -- Name: idx_deal_loan_number; Type: INDEX; Schema: public; Owner: -
 --
@@ -934,6 +1112,27 @@ CREATE INDEX idx_other_income_borrower_id ON public.other_income USING btree (bo
 CREATE INDEX idx_owned_property_borrower_id ON public.owned_property USING btree (borrower_id);
 
 
+--
+-- Name: idx_refresh_session_expires_at; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX idx_refresh_session_expires_at ON public.refresh_session USING btree (expires_at);
+
+
+--
+-- Name: idx_refresh_session_family_id; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX idx_refresh_session_family_id ON public.refresh_session USING btree (family_id);
+
+
+--
+-- Name: idx_refresh_session_subject_id; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX idx_refresh_session_subject_id ON public.refresh_session USING btree (subject_id);
+
+
 --
 -- Name: idx_residence_borrower_id; Type: INDEX; Schema: public; Owner: -
 --
@@ -955,6 +1154,20 @@ CREATE INDEX idx_user_email_verification_token ON public."user" USING btree (ema
 CREATE INDEX idx_user_password_reset_token ON public."user" USING btree (password_reset_token) WHERE (password_reset_token IS NOT NULL);
 
 
+--
+-- Name: idx_user_sso_subject; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX idx_user_sso_subject ON public."user" USING btree (sso_subject) WHERE (sso_subject IS NOT NULL);
+
+
+--
+-- Name: idx_verification_code_expires_at; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX idx_verification_code_expires_at ON public.verification_code USING btree (expires_at);
+
+
 --
 -- Name: deal trg_create_deal_progress; Type: TRIGGER; Schema: public; Owner: -
 --
@@ -969,6 +1182,14 @@ CREATE TRIGGER trg_create_deal_progress AFTER INSERT ON public.deal FOR EACH ROW
 CREATE TRIGGER trg_update_progress BEFORE UPDATE ON public.deal_progress FOR EACH ROW EXECUTE FUNCTION public.update_progress_percentage();
 
 
+--
+-- Name: api_key api_key_created_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.api_key
+    ADD CONSTRAINT api_key_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(id) ON DELETE SET NULL;
+
+
 --
 -- Name: asset asset_borrower_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -1017,6 +1238,38 @@ ALTER TABLE ONLY public.deal
     ADD CONSTRAINT deal_primary_borrower_id_fkey FOREIGN KEY (primary_borrower_id) REFERENCES public.borrower(id) ON DELETE SET NULL;
 
 
+--
+-- Name: deal_assignment deal_assignment_deal_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.deal_assignment
+    ADD CONSTRAINT deal_assignment_deal_id_fkey FOREIGN KEY (deal_id) REFERENCES public.deal(id) ON DELETE CASCADE;
+
+
+--
+-- Name: deal_assignment deal_assignment_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.deal_assignment
+    ADD CONSTRAINT deal_assignment_user_id_fkey FOREIGN KEY (user_id) REFERENCES public."user"(id) ON DELETE CASCADE;
+
+
+--
+-- Name: deal_edit deal_edit_actor_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.deal_edit
+    ADD CONSTRAINT deal_edit_actor_id_fkey FOREIGN KEY (actor_id) REFERENCES public."user"(id) ON DELETE SET NULL;
+
+
+--
+-- Name: deal_edit deal_edit_deal_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.deal_edit
+    ADD CONSTRAINT deal_edit_deal_id_fkey FOREIGN KEY (deal_id) REFERENCES public.deal(id) ON DELETE CASCADE;
+
+
 --
 -- Name: deal_progress deal_progress_deal_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -8,83 +8,344 @@ import (
 	"taulen/backend/internal/config"
 )
 
+// Token types, carried in the typ claim
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeMFA     = "mfa" // MFA challenge, exchanged with a second factor for a token pair
+	// Email verification link, proving the holder received mail at the address
+	TokenTypeEmailVerification = "email_verification"
+	// SSO login in progress, binding the provider callback to the browser that started it
+	TokenTypeSSO = "sso"
+)
+
+// Token audiences; an access token is only accepted by the API, a refresh
+// token only by the refresh endpoint, an MFA token only by the MFA login step,
+// an email verification token only by the email confirmation endpoint and an
+// SSO token only by the SSO callback
+const (
+	AudienceAPI               = "taulen-api"
+	AudienceRefresh           = "taulen-refresh"
+	AudienceMFA               = "taulen-mfa"
+	AudienceEmailVerification = "taulen-email-verification"
+	AudienceSSO               = "taulen-sso"
+)
+
+const issuer = "taulen"
+
+// ErrWrongTokenType is returned when a token of one kind is presented where the other is required
+var ErrWrongTokenType = errors.New("wrong token type")
+
 // Claims represents JWT claims structure
 type Claims struct {
-	UserID string `json:"userId"`
-	Email  string `json:"email"`
+	UserID    string `json:"userId"`
+	Email     string `json:"email"`
+	TokenType string `json:"typ"`                // one of the TokenType constants
+	Role      string `json:"role,omitempty"`     // rbac.Role; only set on access tokens
+	UserType  string `json:"userType,omitempty"` // employee or applicant; only set on MFA tokens
+	// SessionID is the refresh session an access token was issued with; refresh
+	// tokens carry their session ID as the jti (RegisteredClaims.ID) instead
+	SessionID string `json:"sid,omitempty"`
+	// Actor is the employee acting on the user's behalf and DealID the only
+	// application they may act on; only set on delegated access tokens
+	Actor  *Actor `json:"act,omitempty"`
+	DealID string `json:"deal,omitempty"`
+	// State, Nonce and CodeVerifier are the parameters of an SSO login; only set on SSO tokens
+	State        string `json:"state,omitempty"`
+	Nonce        string `json:"nonce,omitempty"`
+	CodeVerifier string `json:"codeVerifier,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// JWTManager handles JWT token operations
+// Actor identifies who is acting for the subject of a delegated token, in the
+// form of the act claim of RFC 8693 token exchange
+type Actor struct {
+	UserID string `json:"sub"`
+	Role   string `json:"role"` // rbac.Role
+}
+
+// JWTManager handles JWT token operations. Tokens are signed with HS256 and
+// the shared secret unless asymmetric signing keys are configured (see jwt_keys.go).
 type JWTManager struct {
-	secret            []byte
-	accessTokenExpiry time.Duration
+	secret             []byte
+	keys               []config.SigningKey
+	accessTokenExpiry  time.Duration
 	refreshTokenExpiry time.Duration
+	delegationExpiry   time.Duration
+	// keyRetention is the longest lifetime of any token the manager signs;
+	// superseded keys verify for this long
+	keyRetention time.Duration
 }
 
 // NewJWTManager creates a new JWT manager
 func NewJWTManager(cfg *config.JWTConfig) *JWTManager {
+	keyRetention := max(cfg.AccessTokenExpiry, cfg.RefreshTokenExpiry, cfg.DelegationTokenExpiry)
+	for _, expiry := range cfg.OtherTokenExpiries {
+		keyRetention = max(keyRetention, expiry)
+	}
 	return &JWTManager{
 		secret:             []byte(cfg.Secret),
-		accessTokenExpiry: cfg.AccessTokenExpiry,
+		keys:               cfg.SigningKeys,
+		accessTokenExpiry:  cfg.AccessTokenExpiry,
 		refreshTokenExpiry: cfg.RefreshTokenExpiry,
+		delegationExpiry:   cfg.DelegationTokenExpiry,
+		keyRetention:       keyRetention,
 	}
 }
 
-// GenerateAccessToken generates a new access token
-func (m *JWTManager) GenerateAccessToken(userID, email string) (string, error) {
+// GenerateAccessToken generates a new access token carrying the user's role
+// and the refresh session it belongs to
+func (m *JWTManager) GenerateAccessToken(userID, email, role, sessionID string) (string, error) {
+	tokenID, err := NewUUID()
+	if err != nil {
+		return "", err
+	}
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		TokenType: TokenTypeAccess,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.accessTokenExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "taulen",
+			Issuer:    issuer,
 			Subject:   userID,
+			Audience:  jwt.ClaimStrings{AudienceAPI},
+			ID:        tokenID,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(m.secret)
+	return m.sign(claims)
 }
 
-// GenerateRefreshToken generates a new refresh token
-func (m *JWTManager) GenerateRefreshToken(userID, email string) (string, error) {
+// GenerateDelegatedToken generates an access token for an employee acting on
+// behalf of a borrower. The token carries the borrower's identity and role, names
+// the employee in the act claim and is only good for the given deal. It has no
+// refresh session and expires after the delegation expiry.
+func (m *JWTManager) GenerateDelegatedToken(userID, email, role, dealID string, actor Actor) (string, time.Time, error) {
+	tokenID, err := NewUUID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(m.delegationExpiry)
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		TokenType: TokenTypeAccess,
+		Role:      role,
+		Actor:     &actor,
+		DealID:    dealID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.refreshTokenExpiry)),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "taulen",
+			Issuer:    issuer,
 			Subject:   userID,
+			Audience:  jwt.ClaimStrings{AudienceAPI},
+			ID:        tokenID,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(m.secret)
+	signed, err := m.sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
 }
 
-// ValidateToken validates a JWT token and returns the claims
-func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return m.secret, nil
-	})
+// GenerateRefreshToken generates a new refresh token for the given refresh
+// session and returns it with its expiry. The session ID is the token's jti.
+func (m *JWTManager) GenerateRefreshToken(userID, email, sessionID string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(m.refreshTokenExpiry)
+	claims := &Claims{
+		UserID:    userID,
+		Email:     email,
+		TokenType: TokenTypeRefresh,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    issuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{AudienceRefresh},
+			ID:        sessionID,
+		},
+	}
+
+	signed, err := m.sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// GenerateMFAToken generates a short-lived MFA challenge token for a user who
+// has passed the password check and still has to present a second factor
+func (m *JWTManager) GenerateMFAToken(userID, email, userType string, expiry time.Duration) (string, error) {
+	tokenID, err := NewUUID()
+	if err != nil {
+		return "", err
+	}
+	claims := &Claims{
+		UserID:    userID,
+		Email:     email,
+		TokenType: TokenTypeMFA,
+		UserType:  userType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    issuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{AudienceMFA},
+			ID:        tokenID,
+		},
+	}
+
+	return m.sign(claims)
+}
+
+// GenerateEmailVerificationToken generates a token for an email verification
+// link. It names the address being verified, so it stops working if the
+// account's email changes.
+func (m *JWTManager) GenerateEmailVerificationToken(userID, email string, expiry time.Duration) (string, error) {
+	tokenID, err := NewUUID()
+	if err != nil {
+		return "", err
+	}
+	claims := &Claims{
+		UserID:    userID,
+		Email:     email,
+		TokenType: TokenTypeEmailVerification,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    issuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{AudienceEmailVerification},
+			ID:        tokenID,
+		},
+	}
+
+	return m.sign(claims)
+}
+
+// GenerateSSOToken generates a token holding the state, nonce and PKCE code
+// verifier of an SSO login; the client presents it with the provider's callback
+func (m *JWTManager) GenerateSSOToken(state, nonce, codeVerifier string, expiry time.Duration) (string, error) {
+	tokenID, err := NewUUID()
+	if err != nil {
+		return "", err
+	}
+	claims := &Claims{
+		TokenType:    TokenTypeSSO,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{AudienceSSO},
+			ID:        tokenID,
+		},
+	}
+
+	return m.sign(claims)
+}
+
+// ValidateAccessToken validates an access token and returns its claims.
+// Refresh tokens are rejected.
+func (m *JWTManager) ValidateAccessToken(tokenString string) (*Claims, error) {
+	return m.validate(tokenString, TokenTypeAccess, AudienceAPI)
+}
+
+// ValidateRefreshToken validates a refresh token and returns its claims.
+// Access tokens are rejected.
+func (m *JWTManager) ValidateRefreshToken(tokenString string) (*Claims, error) {
+	return m.validate(tokenString, TokenTypeRefresh, AudienceRefresh)
+}
+
+// ValidateMFAToken validates an MFA challenge token and returns its claims.
+// Access and refresh tokens are rejected.
+func (m *JWTManager) ValidateMFAToken(tokenString string) (*Claims, error) {
+	return m.validate(tokenString, TokenTypeMFA, AudienceMFA)
+}
+
+// ValidateEmailVerificationToken validates an email verification token and
+// returns its claims. Other token types are rejected.
+func (m *JWTManager) ValidateEmailVerificationToken(tokenString string) (*Claims, error) {
+	return m.validate(tokenString, TokenTypeEmailVerification, AudienceEmailVerification)
+}
+
+// ValidateSSOToken validates an SSO login token and returns its claims. Other
+// token types are rejected.
+func (m *JWTManager) ValidateSSOToken(tokenString string) (*Claims, error) {
+	return m.validate(tokenString, TokenTypeSSO, AudienceSSO)
+}
+
+// validate checks the signature, issuer, expiry, audience and type of a token
+func (m *JWTManager) validate(tokenString, tokenType, audience string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, m.verificationKey,
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if claims.TokenType != tokenType || claims.ID == "" {
+		return nil, ErrWrongTokenType
+	}
+	return claims, nil
+}
+
+// sign signs claims with the HS256 secret or, when signing keys are configured,
+// with the active signing key, naming it in the kid header
+func (m *JWTManager) sign(claims *Claims) (string, error) {
+	if len(m.keys) == 0 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
 	}
 
-	return nil, errors.New("invalid token")
+	key := m.activeKey(time.Now())
+	if key == nil {
+		return "", errors.New("no JWT signing key is active")
+	}
+	token := jwt.NewWithClaims(signingMethod(key), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Key)
+}
+
+// verificationKey is the jwt.Keyfunc used to validate tokens
+func (m *JWTManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	if len(m.keys) == 0 {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid signing method")
+		}
+		return m.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	for _, key := range m.validKeys(time.Now()) {
+		if key.ID != kid {
+			continue
+		}
+		if token.Method != signingMethod(&key) {
+			return nil, errors.New("invalid signing method")
+		}
+		return key.Key.Public(), nil
+	}
+	return nil, errors.New("unknown signing key")
 }
 
 // ExtractTokenFromHeader extracts token from Authorization header
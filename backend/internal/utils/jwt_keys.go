@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"taulen/backend/internal/config"
+)
+
+// Key rotation: every configured key has an activation time. The key with the
+// latest activation time that has passed signs new tokens. A key that has been
+// superseded keeps verifying until every token it can have signed has expired,
+// after which it is dropped from validation and from the JWKS. Keys scheduled
+// for the future are published in the JWKS ahead of their activation so that
+// other services have them cached before the first token signed with them.
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA public exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKSet is a JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that currently verify tokens. It is empty when
+// tokens are signed with the HS256 secret, which must never be published.
+func (m *JWTManager) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range m.validKeys(time.Now()) {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: signingMethod(&key).Alg()}
+		switch pub := key.Key.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// activeKey returns the key that signs tokens at the given time, or nil if none is active
+func (m *JWTManager) activeKey(now time.Time) *config.SigningKey {
+	var active *config.SigningKey
+	for i := range m.keys {
+		key := &m.keys[i]
+		if key.ActivatesAt.After(now) {
+			continue
+		}
+		if active == nil || !key.ActivatesAt.Before(active.ActivatesAt) {
+			active = key
+		}
+	}
+	return active
+}
+
+// validKeys returns the keys that verify tokens at the given time: every key
+// except those superseded longer ago than the longest token lifetime
+func (m *JWTManager) validKeys(now time.Time) []config.SigningKey {
+	valid := make([]config.SigningKey, 0, len(m.keys))
+	for i, key := range m.keys {
+		// The key stops signing when the first key activated after it becomes active
+		var supersededAt time.Time
+		for j, next := range m.keys {
+			later := next.ActivatesAt.After(key.ActivatesAt) || (next.ActivatesAt.Equal(key.ActivatesAt) && j > i)
+			if later && (supersededAt.IsZero() || next.ActivatesAt.Before(supersededAt)) {
+				supersededAt = next.ActivatesAt
+			}
+		}
+		if !supersededAt.IsZero() && now.After(supersededAt.Add(m.keyRetention)) {
+			continue
+		}
+		valid = append(valid, key)
+	}
+	return valid
+}
+
+// signingMethod returns the JWT signing method for a key: RS256 or EdDSA
+func signingMethod(key *config.SigningKey) jwt.SigningMethod {
+	if _, ok := key.Key.(ed25519.PrivateKey); ok {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"taulen/backend/internal/config"
+)
+
+func newTestJWTManager(keys ...config.SigningKey) *JWTManager {
+	return NewJWTManager(&config.JWTConfig{
+		Secret:                "test-secret",
+		AccessTokenExpiry:     15 * time.Minute,
+		RefreshTokenExpiry:    time.Hour,
+		DelegationTokenExpiry: 30 * time.Minute,
+		SigningKeys:           keys,
+	})
+}
+
+func newSigningKey(t *testing.T, id string) config.SigningKey {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config.SigningKey{ID: id, Key: private}
+}
+
+// testClaims returns valid claims of the given type and audience
+func testClaims(tokenType, audience string) *Claims {
+	now := time.Now()
+	return &Claims{
+		UserID:    "user-1",
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    issuer,
+			Subject:   "user-1",
+			Audience:  jwt.ClaimStrings{audience},
+			ID:        "token-1",
+		},
+	}
+}
+
+func sign(t *testing.T, m *JWTManager, claims *Claims) string {
+	t.Helper()
+	token, err := m.sign(claims)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return token
+}
+
+// TestTokenTypesAreNotInterchangeable presents every kind of token to every
+// validator; only the validator of its own kind may accept it
+func TestTokenTypesAreNotInterchangeable(t *testing.T) {
+	m := newTestJWTManager()
+
+	access, err := m.GenerateAccessToken("user-1", "jane@example.com", "applicant", "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegated, _, err := m.GenerateDelegatedToken("user-1", "jane@example.com", "applicant", "deal-1", Actor{UserID: "employee-1", Role: "loan_officer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh, _, err := m.GenerateRefreshToken("user-1", "jane@example.com", "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfa, err := m.GenerateMFAToken("user-1", "jane@example.com", "applicant", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emailVerification, err := m.GenerateEmailVerificationToken("user-1", "jane@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sso, err := m.GenerateSSOToken("state", "nonce", "verifier", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := map[string]string{
+		"access":             access,
+		"delegated":          delegated,
+		"refresh":            refresh,
+		"mfa":                mfa,
+		"email verification": emailVerification,
+		"sso":                sso,
+	}
+	validators := map[string]struct {
+		validate func(string) (*Claims, error)
+		accepts  map[string]bool
+	}{
+		"ValidateAccessToken":            {m.ValidateAccessToken, map[string]bool{"access": true, "delegated": true}},
+		"ValidateRefreshToken":           {m.ValidateRefreshToken, map[string]bool{"refresh": true}},
+		"ValidateMFAToken":               {m.ValidateMFAToken, map[string]bool{"mfa": true}},
+		"ValidateEmailVerificationToken": {m.ValidateEmailVerificationToken, map[string]bool{"email verification": true}},
+		"ValidateSSOToken":               {m.ValidateSSOToken, map[string]bool{"sso": true}},
+	}
+
+	for name, v := range validators {
+		for kind, token := range tokens {
+			claims, err := v.validate(token)
+			if v.accepts[kind] {
+				if err != nil {
+					t.Errorf("%s rejected a valid %s token: %v", name, kind, err)
+				}
+				continue
+			}
+			if err == nil || claims != nil {
+				t.Errorf("%s accepted a %s token", name, kind)
+			}
+		}
+	}
+}
+
+func TestValidateRejectsInvalidClaims(t *testing.T) {
+	m := newTestJWTManager()
+
+	tests := []struct {
+		name   string
+		claims func() *Claims
+	}{
+		{"missing jti", func() *Claims {
+			c := testClaims(TokenTypeAccess, AudienceAPI)
+			c.ID = ""
+			return c
+		}},
+		{"wrong aud", func() *Claims { return testClaims(TokenTypeAccess, AudienceRefresh) }},
+		{"missing aud", func() *Claims {
+			c := testClaims(TokenTypeAccess, AudienceAPI)
+			c.Audience = nil
+			return c
+		}},
+		{"wrong typ", func() *Claims { return testClaims(TokenTypeRefresh, AudienceAPI) }},
+		{"missing typ", func() *Claims { return testClaims("", AudienceAPI) }},
+		{"wrong issuer", func() *Claims {
+			c := testClaims(TokenTypeAccess, AudienceAPI)
+			c.Issuer = "someone-else"
+			return c
+		}},
+		{"expired", func() *Claims {
+			c := testClaims(TokenTypeAccess, AudienceAPI)
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			return c
+		}},
+		{"missing exp", func() *Claims {
+			c := testClaims(TokenTypeAccess, AudienceAPI)
+			c.ExpiresAt = nil
+			return c
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.ValidateAccessToken(sign(t, m, tt.claims())); err == nil {
+				t.Error("ValidateAccessToken accepted the token")
+			}
+		})
+	}
+
+	// The claims used above are valid as they are
+	if _, err := m.ValidateAccessToken(sign(t, m, testClaims(TokenTypeAccess, AudienceAPI))); err != nil {
+		t.Fatalf("ValidateAccessToken rejected valid claims: %v", err)
+	}
+
+	// A wrong typ with the right audience is reported as such
+	if _, err := m.ValidateRefreshToken(sign(t, m, testClaims(TokenTypeAccess, AudienceRefresh))); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("ValidateRefreshToken: got %v, want ErrWrongTokenType", err)
+	}
+}
+
+func TestValidateRejectsForeignSignatures(t *testing.T) {
+	m := newTestJWTManager()
+	claims := testClaims(TokenTypeAccess, AudienceAPI)
+
+	other := NewJWTManager(&config.JWTConfig{Secret: "other-secret"})
+	if _, err := m.ValidateAccessToken(sign(t, other, claims)); err == nil {
+		t.Error("accepted a token signed with another secret")
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateAccessToken(unsigned); err == nil {
+		t.Error("accepted an unsigned token")
+	}
+}
+
+func TestValidateSigningKeys(t *testing.T) {
+	key := newSigningKey(t, "2026-10")
+	m := newTestJWTManager(key)
+	claims := testClaims(TokenTypeAccess, AudienceAPI)
+
+	if _, err := m.ValidateAccessToken(sign(t, m, claims)); err != nil {
+		t.Fatalf("rejected a token signed with the active key: %v", err)
+	}
+
+	// A token naming a kid that is not configured
+	unknown := newTestJWTManager(newSigningKey(t, "2027-01"))
+	if _, err := m.ValidateAccessToken(sign(t, unknown, claims)); err == nil {
+		t.Error("accepted a token with an unknown kid")
+	}
+
+	// A token naming the configured kid but signed by another key
+	impostor := newTestJWTManager(newSigningKey(t, key.ID))
+	if _, err := m.ValidateAccessToken(sign(t, impostor, claims)); err == nil {
+		t.Error("accepted a token signed by another key under a known kid")
+	}
+
+	// HS256 tokens are no longer accepted once signing keys are configured
+	if _, err := m.ValidateAccessToken(sign(t, newTestJWTManager(), claims)); err == nil {
+		t.Error("accepted an HS256 token with signing keys configured")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old := newSigningKey(t, "2026-10")
+	next := newSigningKey(t, "2027-01")
+	next.ActivatesAt = time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := newTestJWTManager(old, next)
+
+	kids := func(keys []config.SigningKey) string {
+		var ids []string
+		for _, key := range keys {
+			ids = append(ids, key.ID)
+		}
+		return strings.Join(ids, " ")
+	}
+
+	// The scheduled key verifies (and is published) before it starts signing
+	before := next.ActivatesAt.Add(-time.Minute)
+	if got := m.activeKey(before); got == nil || got.ID != old.ID {
+		t.Errorf("signing key before the rotation = %v, want %s", got, old.ID)
+	}
+	if got := kids(m.validKeys(before)); got != "2026-10 2027-01" {
+		t.Errorf("keys before the rotation = %s", got)
+	}
+
+	// The superseded key verifies until the longest lived token it signed has expired
+	if got := m.activeKey(next.ActivatesAt); got == nil || got.ID != next.ID {
+		t.Errorf("signing key after the rotation = %v, want %s", got, next.ID)
+	}
+	if got := kids(m.validKeys(next.ActivatesAt.Add(59 * time.Minute))); got != "2026-10 2027-01" {
+		t.Errorf("keys within the refresh token lifetime = %s", got)
+	}
+	if got := kids(m.validKeys(next.ActivatesAt.Add(61 * time.Minute))); got != "2027-01" {
+		t.Errorf("keys after the refresh token lifetime = %s", got)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	if set := newTestJWTManager().JWKS(); len(set.Keys) != 0 {
+		t.Fatalf("the HS256 secret is published: %+v", set)
+	}
+
+	key := newSigningKey(t, "2026-10")
+	set := newTestJWTManager(key).JWKS()
+	if len(set.Keys) != 1 {
+		t.Fatalf("JWKS = %+v, want one key", set)
+	}
+	jwk := set.Keys[0]
+	public := key.Key.Public().(ed25519.PublicKey)
+	if jwk.KeyID != key.ID || jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" || jwk.Algorithm != "EdDSA" ||
+		jwk.X != base64.RawURLEncoding.EncodeToString(public) {
+		t.Errorf("JWK = %+v", jwk)
+	}
+}
+
+func TestSupersededKeysVerifyForLongestLifetime(t *testing.T) {
+	old := newSigningKey(t, "2026-10")
+	next := newSigningKey(t, "2027-01")
+	next.ActivatesAt = time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	keys := []config.SigningKey{old, next}
+
+	verifies := func(m *JWTManager, at time.Time) bool {
+		for _, key := range m.validKeys(at) {
+			if key.ID == old.ID {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Refresh tokens live longest among the session tokens
+	m := newTestJWTManager(keys...)
+	if !verifies(m, next.ActivatesAt.Add(59*time.Minute)) || verifies(m, next.ActivatesAt.Add(61*time.Minute)) {
+		t.Error("the superseded key does not verify for the refresh token lifetime")
+	}
+
+	// Email verification links signed just before the rotation outlive them
+	m = NewJWTManager(&config.JWTConfig{
+		AccessTokenExpiry:  15 * time.Minute,
+		RefreshTokenExpiry: time.Hour,
+		SigningKeys:        keys,
+		OtherTokenExpiries: []time.Duration{5 * time.Minute, 24 * time.Hour},
+	})
+	if !verifies(m, next.ActivatesAt.Add(23*time.Hour)) || verifies(m, next.ActivatesAt.Add(25*time.Hour)) {
+		t.Error("the superseded key does not verify for the email verification lifetime")
+	}
+}
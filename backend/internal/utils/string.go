@@ -1,6 +1,10 @@
 package utils
 
-import "strconv"
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+)
 
 // Int64ToString converts int64 to string
 func Int64ToString(i int64) string {
@@ -11,3 +15,34 @@ func Int64ToString(i int64) string {
 func StringToInt64(s string) (int64, error) {
 	return strconv.ParseInt(s, 10, 64)
 }
+
+// IsUUID reports whether s is a UUID in canonical 8-4-4-4-12 hex form
+func IsUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, r := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NewUUID returns a random (version 4) UUID string
+func NewUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -289,7 +289,8 @@ export const urlaApi = {
     state?: string
     zipCode?: string
     loanPurpose: string
-    verificationCode?: string // Optional when 2FA is disabled
+    verificationCode?: string // Required when the 2FA mode is required
+    verificationMethod?: 'email' | 'sms' // The method the code was sent with
     // Purchase-specific fields
     purchasePrice?: number
     downPayment?: number